@@ -89,6 +89,7 @@ func (d *Daemon) Start() error {
 		d.loginedManager = nil
 		return err
 	}
+	go d.manager.scheduleLoop(d.loginedManager)
 
 	err = service.RequestName(dbusServiceName)
 	if err != nil {
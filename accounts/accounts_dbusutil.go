@@ -457,3 +457,42 @@ func (v *User) setPropHistoryLayout(value []string) {
 func (v *User) emitPropChangedHistoryLayout(value []string) error {
 	return v.service.EmitPropertyChanged(v, "HistoryLayout", value)
 }
+
+func (v *User) setPropExpirationTime(value int64) (changed bool) {
+	if v.ExpirationTime != value {
+		v.ExpirationTime = value
+		v.emitPropChangedExpirationTime(value)
+		return true
+	}
+	return false
+}
+
+func (v *User) emitPropChangedExpirationTime(value int64) error {
+	return v.service.EmitPropertyChanged(v, "ExpirationTime", value)
+}
+
+func (v *User) setPropLoginTimeWindows(value []string) (changed bool) {
+	if !isStrvEqual(v.LoginTimeWindows, value) {
+		v.LoginTimeWindows = value
+		v.emitPropChangedLoginTimeWindows(value)
+		return true
+	}
+	return false
+}
+
+func (v *User) emitPropChangedLoginTimeWindows(value []string) error {
+	return v.service.EmitPropertyChanged(v, "LoginTimeWindows", value)
+}
+
+func (v *User) setPropRemainingTime(value int64) (changed bool) {
+	if v.RemainingTime != value {
+		v.RemainingTime = value
+		v.emitPropChangedRemainingTime(value)
+		return true
+	}
+	return false
+}
+
+func (v *User) emitPropChangedRemainingTime(value int64) error {
+	return v.service.EmitPropertyChanged(v, "RemainingTime", value)
+}
@@ -39,6 +39,12 @@ const (
 	passwordOK passwordErrorCode = iota
 	passwordErrCodeShort
 	passwordErrCodeSimple
+	// 以下由 PasswordPolicy 检查
+	passwordErrCodeRepeatChars
+	passwordErrCodeSequentialChars
+	passwordErrCodeDictionary
+	passwordErrCodeUsername
+	passwordErrCodeReused
 )
 
 func (code passwordErrorCode) IsOk() bool {
@@ -0,0 +1,275 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package checkers
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/linuxdeepin/go-lib/keyfile"
+)
+
+// 管理员可以通过配置文件为所有版本配置密码策略，配置文件不存在时沿用 CheckPasswordValid 的规则。
+// 配置文件的格式如下，没有配置的项使用默认值：
+//
+//	[Password]
+//	# 最小长度
+//	MinLength=8
+//	# 小写字母、大写字母、数字、特殊字符中至少包含几类
+//	RequiredClasses=3
+//	# 相同字符最多连续出现几次，0 表示不限制
+//	MaxRepeatChars=3
+//	# 最长的连续字符（如 abc、321）的长度，0 表示不限制
+//	MaxSequentialChars=3
+//	# 去掉首尾的数字和符号后不能是字典中的单词
+//	DictionaryCheck=true
+//	DictionaryFile=/usr/share/dict/words
+//	# 不能包含用户名或倒序的用户名
+//	UsernameCheck=true
+//	# 不能与最近几次使用过的密码相同（包括当前密码），0 表示不检查
+//	History=5
+
+const (
+	passwordPolicySection = "Password"
+
+	defaultPasswordDictionaryFile = "/usr/share/dict/words"
+	// 太短的单词不做字典检查
+	passwordDictionaryMinWordLength = 4
+	// 用户名太短时不做相似度检查
+	passwordUsernameMinLength = 3
+)
+
+type PasswordPolicy struct {
+	MinLength          int
+	RequiredClasses    int
+	MaxRepeatChars     int
+	MaxSequentialChars int
+	DictionaryCheck    bool
+	DictionaryFile     string
+	UsernameCheck      bool
+	History            int
+}
+
+func defaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:       passwordMinLength,
+		RequiredClasses: 3,
+		DictionaryFile:  defaultPasswordDictionaryFile,
+	}
+}
+
+// LoadPasswordPolicy 从配置文件加载密码策略，没有配置的项使用默认值
+func LoadPasswordPolicy(file string) (*PasswordPolicy, error) {
+	kf := keyfile.NewKeyFile()
+	err := kf.LoadFromFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p := defaultPasswordPolicy()
+	getInt := func(key string, value *int, max int) {
+		v, err := kf.GetInteger(passwordPolicySection, key)
+		if err == nil && v >= 0 && int(v) <= max {
+			*value = int(v)
+		}
+	}
+	getBool := func(key string, value *bool) {
+		v, err := kf.GetBool(passwordPolicySection, key)
+		if err == nil {
+			*value = v
+		}
+	}
+	getInt("MinLength", &p.MinLength, 512)
+	getInt("RequiredClasses", &p.RequiredClasses, 4)
+	getInt("MaxRepeatChars", &p.MaxRepeatChars, 512)
+	getInt("MaxSequentialChars", &p.MaxSequentialChars, 512)
+	getInt("History", &p.History, 400)
+	getBool("DictionaryCheck", &p.DictionaryCheck)
+	getBool("UsernameCheck", &p.UsernameCheck)
+	dictFile, err := kf.GetString(passwordPolicySection, "DictionaryFile")
+	if err == nil && dictFile != "" {
+		p.DictionaryFile = dictFile
+	}
+	return p, nil
+}
+
+// CheckPassword 按策略检查密码，username 为空时不检查与用户名的相似度，
+// isUsedBefore 用于判断密码是否在最近 History 次中使用过，为 nil 时不检查
+func (p *PasswordPolicy) CheckPassword(passwd, username string, isUsedBefore func(passwd string) bool) passwordErrorCode {
+	if len([]rune(passwd)) < p.MinLength {
+		return passwordErrCodeShort
+	}
+	if countPasswordCharClasses(passwd) < p.RequiredClasses {
+		return passwordErrCodeSimple
+	}
+	if p.MaxRepeatChars > 0 && maxRepeatChars(passwd) > p.MaxRepeatChars {
+		return passwordErrCodeRepeatChars
+	}
+	if p.MaxSequentialChars > 0 && maxSequentialChars(passwd) > p.MaxSequentialChars {
+		return passwordErrCodeSequentialChars
+	}
+	if p.UsernameCheck && isPasswordSimilarToUsername(passwd, username) {
+		return passwordErrCodeUsername
+	}
+	if p.DictionaryCheck && isPasswordInDictionary(passwd, p.DictionaryFile) {
+		return passwordErrCodeDictionary
+	}
+	if p.History > 0 && isUsedBefore != nil && isUsedBefore(passwd) {
+		return passwordErrCodeReused
+	}
+	return passwordOK
+}
+
+func (p *PasswordPolicy) Prompt(code passwordErrorCode) string {
+	switch code {
+	case passwordErrCodeShort:
+		return fmt.Sprintf(Tr("Please enter a password not less than %d characters"), p.MinLength)
+	case passwordErrCodeSimple:
+		return fmt.Sprintf(Tr("The password must contain at least %d of the following: lowercase letters, uppercase letters, numbers and special symbols"), p.RequiredClasses)
+	case passwordErrCodeRepeatChars:
+		return fmt.Sprintf(Tr("The same character cannot be repeated more than %d times"), p.MaxRepeatChars)
+	case passwordErrCodeSequentialChars:
+		return fmt.Sprintf(Tr("The password cannot contain more than %d sequential characters"), p.MaxSequentialChars)
+	case passwordErrCodeDictionary:
+		return Tr("The password cannot be a dictionary word")
+	case passwordErrCodeUsername:
+		return Tr("The password cannot contain the username")
+	case passwordErrCodeReused:
+		return fmt.Sprintf(Tr("The password cannot be the same as the last %d passwords"), p.History)
+	default:
+		return code.Prompt()
+	}
+}
+
+// countPasswordCharClasses 统计密码包含小写字母、大写字母、数字、特殊字符中的几类
+func countPasswordCharClasses(passwd string) int {
+	var lower, upper, digit, special bool
+	for _, r := range passwd {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			special = true
+		}
+	}
+	count := 0
+	for _, has := range []bool{lower, upper, digit, special} {
+		if has {
+			count++
+		}
+	}
+	return count
+}
+
+// maxRepeatChars 返回相同字符连续出现的最多次数
+func maxRepeatChars(passwd string) int {
+	var max, count int
+	var last rune
+	for i, r := range []rune(passwd) {
+		if i > 0 && r == last {
+			count++
+		} else {
+			count = 1
+		}
+		if count > max {
+			max = count
+		}
+		last = r
+	}
+	return max
+}
+
+func isSequentialClass(a, b rune) bool {
+	return (unicode.IsDigit(a) && unicode.IsDigit(b)) ||
+		(unicode.IsLetter(a) && unicode.IsLetter(b))
+}
+
+// maxSequentialChars 返回最长的递增或递减的连续字母或数字的长度，不区分大小写
+func maxSequentialChars(passwd string) int {
+	runes := []rune(strings.ToLower(passwd))
+	if len(runes) == 0 {
+		return 0
+	}
+	max, count := 1, 1
+	var step rune
+	for i := 1; i < len(runes); i++ {
+		diff := runes[i] - runes[i-1]
+		if (diff == 1 || diff == -1) && isSequentialClass(runes[i], runes[i-1]) {
+			if count > 1 && diff == step {
+				count++
+			} else {
+				count = 2
+			}
+			step = diff
+		} else {
+			count = 1
+		}
+		if count > max {
+			max = count
+		}
+	}
+	return max
+}
+
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func isPasswordSimilarToUsername(passwd, username string) bool {
+	if len(username) < passwordUsernameMinLength {
+		return false
+	}
+	passwd = strings.ToLower(passwd)
+	username = strings.ToLower(username)
+	return strings.Contains(passwd, username) || strings.Contains(passwd, reverseString(username))
+}
+
+// isPasswordInDictionary 判断密码去掉首尾的数字和符号后是否为字典中的单词，字典文件不存在时不检查
+func isPasswordInDictionary(passwd, dictFile string) bool {
+	candidate := strings.TrimFunc(strings.ToLower(passwd), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len([]rune(candidate)) < passwordDictionaryMinWordLength {
+		return false
+	}
+
+	f, err := os.Open(dictFile)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.EqualFold(strings.TrimSpace(scanner.Text()), candidate) {
+			return true
+		}
+	}
+	return false
+}
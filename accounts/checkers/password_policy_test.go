@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package checkers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadPasswordPolicy(t *testing.T) {
+	p, err := LoadPasswordPolicy("testdata/password-policy.conf")
+	require.NoError(t, err)
+	assert.Equal(t, &PasswordPolicy{
+		MinLength:          10,
+		RequiredClasses:    4,
+		MaxRepeatChars:     2,
+		MaxSequentialChars: 3,
+		DictionaryCheck:    true,
+		DictionaryFile:     "testdata/words",
+		UsernameCheck:      true,
+		History:            0, // 非法值使用默认值
+	}, p)
+
+	_, err = LoadPasswordPolicy("testdata/not-exist.conf")
+	assert.Error(t, err)
+}
+
+func Test_PasswordPolicy_CheckPassword(t *testing.T) {
+	p, err := LoadPasswordPolicy("testdata/password-policy.conf")
+	require.NoError(t, err)
+	p.History = 3
+	usedBefore := func(passwd string) bool {
+		return passwd == "Old-Pass-w0rd"
+	}
+
+	var tests = []struct {
+		passwd string
+		code   passwordErrorCode
+	}{
+		{"aA1?", passwordErrCodeShort},
+		{"aaaaaaaaaaaa", passwordErrCodeSimple},
+		{"abcdAB12xyz", passwordErrCodeSimple},
+		{"abbbA1?-Qwer", passwordErrCodeRepeatChars},
+		{"zxcvA1?-efgh", passwordErrCodeSequentialChars},
+		{"xA1?-9876q", passwordErrCodeSequentialChars},
+		{"Alice#2022x", passwordErrCodeUsername},
+		{"ecila#2022X", passwordErrCodeUsername},
+		{"Sunshine?97", passwordErrCodeDictionary},
+		{"Old-Pass-w0rd", passwordErrCodeReused},
+		{"Tr0ub4dor&3x", passwordOK},
+	}
+	for _, test := range tests {
+		code := p.CheckPassword(test.passwd, "alice", usedBefore)
+		assert.Equal(t, test.code, code, test.passwd)
+		assert.Equal(t, code.IsOk(), p.Prompt(code) == "", test.passwd)
+	}
+
+	// 不检查用户名和历史密码
+	assert.Equal(t, passwordOK, p.CheckPassword("Alice#2022x", "", nil))
+	assert.Equal(t, passwordOK, p.CheckPassword("Old-Pass-w0rd", "alice", nil))
+	assert.Equal(t, "Please enter a password not less than 10 characters", p.Prompt(passwordErrCodeShort))
+}
+
+func Test_maxRepeatChars(t *testing.T) {
+	assert.Equal(t, 0, maxRepeatChars(""))
+	assert.Equal(t, 1, maxRepeatChars("abc"))
+	assert.Equal(t, 3, maxRepeatChars("aabbbc"))
+}
+
+func Test_maxSequentialChars(t *testing.T) {
+	assert.Equal(t, 0, maxSequentialChars(""))
+	assert.Equal(t, 1, maxSequentialChars("a"))
+	assert.Equal(t, 1, maxSequentialChars("ace"))
+	assert.Equal(t, 4, maxSequentialChars("xABcd"))
+	assert.Equal(t, 3, maxSequentialChars("97321"))
+	assert.Equal(t, 2, maxSequentialChars("aba"))
+	assert.Equal(t, 1, maxSequentialChars("9:"))
+}
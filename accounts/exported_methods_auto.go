@@ -28,6 +28,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.AllowGuestAccount,
 			InArgs: []string{"allow"},
 		},
+		{
+			Name:    "ApplyUserManifest",
+			Fn:      v.ApplyUserManifest,
+			InArgs:  []string{"manifest", "dryRun"},
+			OutArgs: []string{"report"},
+		},
 		{
 			Name:    "CreateGuestAccount",
 			Fn:      v.CreateGuestAccount,
@@ -113,6 +119,12 @@ func (v *User) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.EnableNoPasswdLogin,
 			InArgs: []string{"enabled"},
 		},
+		{
+			Name:    "GetLoginHistory",
+			Fn:      v.GetLoginHistory,
+			InArgs:  []string{"since", "limit"},
+			OutArgs: []string{"history"},
+		},
 		{
 			Name:    "GetReminderInfo",
 			Fn:      v.GetReminderInfo,
@@ -128,6 +140,12 @@ func (v *User) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.IsPasswordExpired,
 			OutArgs: []string{"expired"},
 		},
+		{
+			Name:    "IsPasswordValid",
+			Fn:      v.IsPasswordValid,
+			InArgs:  []string{"password"},
+			OutArgs: []string{"valid", "msg", "code"},
+		},
 		{
 			Name:    "PasswordExpiredInfo",
 			Fn:      v.PasswordExpiredInfo,
@@ -148,6 +166,11 @@ func (v *User) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetDesktopBackgrounds,
 			InArgs: []string{"val"},
 		},
+		{
+			Name:   "SetExpirationTime",
+			Fn:     v.SetExpirationTime,
+			InArgs: []string{"expirationTime"},
+		},
 		{
 			Name:   "SetFullName",
 			Fn:     v.SetFullName,
@@ -193,6 +216,11 @@ func (v *User) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetLocked,
 			InArgs: []string{"locked"},
 		},
+		{
+			Name:   "SetLoginTimeWindows",
+			Fn:     v.SetLoginTimeWindows,
+			InArgs: []string{"windows"},
+		},
 		{
 			Name:   "SetLongDateFormat",
 			Fn:     v.SetLongDateFormat,
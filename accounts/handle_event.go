@@ -48,10 +48,11 @@ const (
 	taskNameGroup  = "group"
 	taskNameShadow = "shadow"
 	taskNameDM     = "dm"
+	taskNameBtmp   = "btmp"
 )
 
 func (m *Manager) getWatchFiles() []string {
-	list := []string{"/etc"}
+	list := []string{"/etc", filepath.Dir(userFileBtmp)}
 	dmConfig, err := users.GetDMConfig()
 	if err == nil {
 		list = append(list, filepath.Dir(dmConfig))
@@ -83,6 +84,11 @@ func (m *Manager) handleFileChanged(ev fsnotify.Event) {
 		if task, _ := m.delayTaskManager.GetTask(taskNameDM); task != nil {
 			err = task.Start()
 		}
+	case userFileBtmp:
+		logger.Debug("File changed:", ev)
+		if task, _ := m.delayTaskManager.GetTask(taskNameBtmp); task != nil {
+			err = task.Start()
+		}
 	default:
 		return
 	}
@@ -185,6 +191,10 @@ func (m *Manager) handleFileShadowChanged() {
 	}
 }
 
+func (m *Manager) handleFileBtmpChanged() {
+	m.updateFailedLoginCounts(true)
+}
+
 func (m *Manager) handleDMConfigChanged() {
 	for _, u := range m.usersMap {
 		u.updatePropAutomaticLogin()
@@ -0,0 +1,265 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	userFileWtmp = "/var/log/wtmp"
+	userFileBtmp = "/var/log/btmp"
+)
+
+// 自上次成功登录以来失败的次数达到此值时发送 FailedLoginThresholdExceeded 信号
+const failedLoginThreshold = 3
+
+// utmpx 记录的类型，与 utmpx.h 中的一致
+const (
+	utmpxRunLevel    = 1
+	utmpxBootTime    = 2
+	utmpxUserProcess = 7
+	utmpxDeadProcess = 8
+)
+
+// 登录会话的类型
+const (
+	SessionTypeGraphical = "graphical" // 图形界面登录
+	SessionTypeConsole   = "console"   // 虚拟控制台登录
+	SessionTypeTerminal  = "terminal"  // 本地的伪终端，比如 su、终端模拟器
+	SessionTypeRemote    = "remote"    // 远程登录，比如 ssh
+)
+
+// 登录会话的状态
+const (
+	SessionStatusActive      = "active"      // 还没有注销
+	SessionStatusClosed      = "closed"      // 已经注销
+	SessionStatusInterrupted = "interrupted" // 没有注销记录，被关机或重启中断
+)
+
+// utmpxRecord wtmp 或 btmp 中的一条记录
+type utmpxRecord struct {
+	Type    int
+	Pid     int
+	User    string
+	Line    string
+	Host    string
+	Address string
+	Time    time.Time
+}
+
+// LoginSession 一次成功的登录，时间为 unix 时间戳，LogoutTime 为 0 表示还没有注销
+type LoginSession struct {
+	Line        string
+	Host        string
+	Address     string
+	SessionType string
+	Status      string
+	LoginTime   int64
+	LogoutTime  int64
+}
+
+// FailedLoginAttempt 一次失败的登录
+type FailedLoginAttempt struct {
+	Line    string
+	Host    string
+	Address string
+	Time    int64
+}
+
+// LoginHistory 用户的登录历史，都按时间从新到旧排列
+type LoginHistory struct {
+	Sessions       []LoginSession
+	FailedAttempts []FailedLoginAttempt
+	// 自上次成功登录以来失败的次数
+	FailedCountSinceLastLogin int32
+}
+
+// getSessionType 根据终端和主机判断会话的类型
+func getSessionType(line, host string) string {
+	switch {
+	case strings.HasPrefix(line, ":") || strings.HasPrefix(host, ":"):
+		return SessionTypeGraphical
+	case host != "":
+		return SessionTypeRemote
+	case strings.HasPrefix(line, "tty"):
+		return SessionTypeConsole
+	default:
+		return SessionTypeTerminal
+	}
+}
+
+// buildLoginSessions 把 wtmp 中用户的登录记录和对应终端的注销记录配对，
+// 没有注销记录就遇到开机或关机记录的会话被认为是被中断的。
+func buildLoginSessions(records []utmpxRecord, username string) []LoginSession {
+	var sessions []LoginSession
+	// 终端 => 在 sessions 中的位置
+	opened := make(map[string]int)
+	closeAll := func(t time.Time) {
+		for line, idx := range opened {
+			sessions[idx].Status = SessionStatusInterrupted
+			sessions[idx].LogoutTime = t.Unix()
+			delete(opened, line)
+		}
+	}
+
+	for _, record := range records {
+		switch record.Type {
+		case utmpxUserProcess:
+			if idx, ok := opened[record.Line]; ok {
+				// 同一个终端上又有新的登录，之前的会话一定已经结束
+				sessions[idx].Status = SessionStatusInterrupted
+				sessions[idx].LogoutTime = record.Time.Unix()
+				delete(opened, record.Line)
+			}
+			if record.User != username {
+				continue
+			}
+			opened[record.Line] = len(sessions)
+			sessions = append(sessions, LoginSession{
+				Line:        record.Line,
+				Host:        record.Host,
+				Address:     record.Address,
+				SessionType: getSessionType(record.Line, record.Host),
+				Status:      SessionStatusActive,
+				LoginTime:   record.Time.Unix(),
+			})
+		case utmpxDeadProcess:
+			if idx, ok := opened[record.Line]; ok {
+				sessions[idx].Status = SessionStatusClosed
+				sessions[idx].LogoutTime = record.Time.Unix()
+				delete(opened, record.Line)
+			}
+		case utmpxBootTime:
+			closeAll(record.Time)
+		case utmpxRunLevel:
+			if record.User == "shutdown" {
+				closeAll(record.Time)
+			}
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LoginTime > sessions[j].LoginTime
+	})
+	return sessions
+}
+
+// getFailedLoginAttempts 返回 btmp 中用户在 since 之后失败的登录
+func getFailedLoginAttempts(records []utmpxRecord, username string, since time.Time) []FailedLoginAttempt {
+	var attempts []FailedLoginAttempt
+	for _, record := range records {
+		if record.User != username || record.Time.Before(since) {
+			continue
+		}
+		attempts = append(attempts, FailedLoginAttempt{
+			Line:    record.Line,
+			Host:    record.Host,
+			Address: record.Address,
+			Time:    record.Time.Unix(),
+		})
+	}
+	sort.SliceStable(attempts, func(i, j int) bool {
+		return attempts[i].Time > attempts[j].Time
+	})
+	return attempts
+}
+
+// getLastLoginTime 返回 wtmp 中用户最后一次成功登录的时间，没有登录过时返回零值
+func getLastLoginTime(records []utmpxRecord, username string) time.Time {
+	var last time.Time
+	for _, record := range records {
+		if record.Type == utmpxUserProcess && record.User == username && record.Time.After(last) {
+			last = record.Time
+		}
+	}
+	return last
+}
+
+// newLoginHistory 根据 wtmp 和 btmp 的记录生成 since 之后的登录历史，limit 大于 0 时限制每种记录的数量
+func newLoginHistory(wtmp, btmp []utmpxRecord, username string, since time.Time, limit int) *LoginHistory {
+	var history LoginHistory
+	for _, session := range buildLoginSessions(wtmp, username) {
+		if session.LoginTime < since.Unix() {
+			continue
+		}
+		history.Sessions = append(history.Sessions, session)
+	}
+	history.FailedAttempts = getFailedLoginAttempts(btmp, username, since)
+	lastLogin := getLastLoginTime(wtmp, username)
+	history.FailedCountSinceLastLogin = int32(len(getFailedLoginAttempts(btmp, username, lastLogin)))
+
+	if limit > 0 {
+		if len(history.Sessions) > limit {
+			history.Sessions = history.Sessions[:limit]
+		}
+		if len(history.FailedAttempts) > limit {
+			history.FailedAttempts = history.FailedAttempts[:limit]
+		}
+	}
+	return &history
+}
+
+// isFailedLoginThresholdCrossed 判断失败次数是否刚刚达到阈值
+func isFailedLoginThresholdCrossed(oldCount, newCount, threshold int) bool {
+	return threshold > 0 && oldCount < threshold && newCount >= threshold
+}
+
+// updateFailedLoginCounts 重新统计各用户自上次成功登录以来失败的次数，
+// notify 为 true 时对刚达到阈值的用户发送 FailedLoginThresholdExceeded 信号。
+func (m *Manager) updateFailedLoginCounts(notify bool) {
+	wtmp, err := readUtmpxFile(userFileWtmp)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	btmp, err := readUtmpxFile(userFileBtmp)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+
+	m.usersMapMu.Lock()
+	userList := make([]*User, 0, len(m.usersMap))
+	for _, u := range m.usersMap {
+		userList = append(userList, u)
+	}
+	m.usersMapMu.Unlock()
+
+	m.failedLoginMu.Lock()
+	defer m.failedLoginMu.Unlock()
+
+	counts := make(map[string]int, len(userList))
+	for _, u := range userList {
+		attempts := getFailedLoginAttempts(btmp, u.UserName, getLastLoginTime(wtmp, u.UserName))
+		count := len(attempts)
+		counts[u.UserName] = count
+		if !notify || !isFailedLoginThresholdCrossed(m.failedLoginCounts[u.UserName], count, failedLoginThreshold) {
+			continue
+		}
+
+		logger.Infof("user %s failed to login %d times", u.UserName, count)
+		err = m.service.Emit(u, "FailedLoginThresholdExceeded", int32(count), attempts[0].Time)
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+	m.failedLoginCounts = counts
+}
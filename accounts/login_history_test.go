@@ -0,0 +1,88 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_getSessionType(t *testing.T) {
+	assert.Equal(t, SessionTypeGraphical, getSessionType(":0", ":0"))
+	assert.Equal(t, SessionTypeRemote, getSessionType("pts/1", "192.168.1.2"))
+	assert.Equal(t, SessionTypeConsole, getSessionType("tty2", ""))
+	assert.Equal(t, SessionTypeTerminal, getSessionType("pts/0", ""))
+}
+
+func Test_newLoginHistory(t *testing.T) {
+	wtmp := []utmpxRecord{
+		{Type: utmpxBootTime, User: "reboot", Line: "~", Time: time.Unix(1000, 0)},
+		{Type: utmpxUserProcess, User: "test", Line: "tty2", Time: time.Unix(1100, 0)},
+		{Type: utmpxUserProcess, User: "other", Line: "pts/0", Time: time.Unix(1150, 0)},
+		{Type: utmpxDeadProcess, Line: "pts/0", Time: time.Unix(1160, 0)},
+		{Type: utmpxRunLevel, User: "shutdown", Line: "~", Time: time.Unix(1200, 0)},
+		{Type: utmpxBootTime, User: "reboot", Line: "~", Time: time.Unix(1300, 0)},
+		{Type: utmpxUserProcess, User: "test", Line: "pts/1", Host: "10.0.0.1", Address: "10.0.0.1",
+			Time: time.Unix(1400, 0)},
+		{Type: utmpxDeadProcess, Line: "pts/1", Time: time.Unix(1500, 0)},
+		{Type: utmpxUserProcess, User: "test", Line: ":0", Host: ":0", Time: time.Unix(1600, 0)},
+	}
+	btmp := []utmpxRecord{
+		{Type: 6, User: "test", Line: "ssh:notty", Host: "10.0.0.2", Time: time.Unix(1050, 0)},
+		{Type: 6, User: "other", Line: "ssh:notty", Host: "10.0.0.2", Time: time.Unix(1700, 0)},
+		{Type: 6, User: "test", Line: "ssh:notty", Host: "10.0.0.2", Time: time.Unix(1700, 0)},
+		{Type: 6, User: "test", Line: ":0", Time: time.Unix(1800, 0)},
+	}
+
+	history := newLoginHistory(wtmp, btmp, "test", time.Unix(0, 0), 0)
+	assert.Equal(t, []LoginSession{
+		{Line: ":0", Host: ":0", SessionType: SessionTypeGraphical, Status: SessionStatusActive,
+			LoginTime: 1600},
+		{Line: "pts/1", Host: "10.0.0.1", Address: "10.0.0.1", SessionType: SessionTypeRemote,
+			Status: SessionStatusClosed, LoginTime: 1400, LogoutTime: 1500},
+		{Line: "tty2", SessionType: SessionTypeConsole, Status: SessionStatusInterrupted,
+			LoginTime: 1100, LogoutTime: 1200},
+	}, history.Sessions)
+	assert.Equal(t, []FailedLoginAttempt{
+		{Line: ":0", Time: 1800},
+		{Line: "ssh:notty", Host: "10.0.0.2", Time: 1700},
+		{Line: "ssh:notty", Host: "10.0.0.2", Time: 1050},
+	}, history.FailedAttempts)
+	assert.Equal(t, int32(2), history.FailedCountSinceLastLogin)
+
+	history = newLoginHistory(wtmp, btmp, "test", time.Unix(1300, 0), 1)
+	assert.Len(t, history.Sessions, 1)
+	assert.Equal(t, int64(1600), history.Sessions[0].LoginTime)
+	assert.Len(t, history.FailedAttempts, 1)
+	assert.Equal(t, int64(1800), history.FailedAttempts[0].Time)
+
+	history = newLoginHistory(wtmp, btmp, "nobody", time.Unix(0, 0), 0)
+	assert.Empty(t, history.Sessions)
+	assert.Empty(t, history.FailedAttempts)
+	assert.Equal(t, int32(0), history.FailedCountSinceLastLogin)
+}
+
+func Test_isFailedLoginThresholdCrossed(t *testing.T) {
+	assert.True(t, isFailedLoginThresholdCrossed(2, 3, 3))
+	assert.True(t, isFailedLoginThresholdCrossed(0, 5, 3))
+	assert.False(t, isFailedLoginThresholdCrossed(3, 4, 3))
+	assert.False(t, isFailedLoginThresholdCrossed(1, 2, 3))
+	assert.False(t, isFailedLoginThresholdCrossed(0, 5, 0))
+}
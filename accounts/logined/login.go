@@ -172,6 +172,33 @@ func (m *Manager) deleteSession(sessionPath dbus.ObjectPath) bool {
 	return deleted
 }
 
+// TerminateUserSessions terminate all sessions of the user
+func (m *Manager) TerminateUserSessions(uid uint32) error {
+	m.locker.Lock()
+	infos := append(SessionInfos(nil), m.userSessions[uid]...)
+	m.locker.Unlock()
+	if len(infos) == 0 {
+		return nil
+	}
+
+	systemBus, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		m.logger.Debug("Terminate session:", info.sessionPath)
+		session, err := login1.NewSession(systemBus, info.sessionPath)
+		if err != nil {
+			return err
+		}
+		err = session.Terminate(0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *Manager) setPropUserList() {
 	m.locker.Lock()
 	defer m.locker.Unlock()
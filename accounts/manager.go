@@ -87,6 +87,12 @@ type Manager struct {
 	userAddedChanMap map[string]chan string
 	udcpCache        udcp.UdcpCache
 
+	failedLoginMu sync.Mutex
+	// 用户名 => 自上次成功登录以来失败的次数
+	failedLoginCounts map[string]int
+
+	scheduleQuit chan struct{}
+
 	//nolint
 	signals *struct {
 		UserAdded struct {
@@ -113,6 +119,7 @@ func NewManager(service *dbusutil.Service) *Manager {
 		login1Manager:              login1Manager,
 		sysSigLoop:                 sysSigLoop,
 		enablePasswdChangedHandler: true,
+		scheduleQuit:               make(chan struct{}),
 	}
 
 	m.usersMap = make(map[string]*User)
@@ -130,11 +137,13 @@ func NewManager(service *dbusutil.Service) *Manager {
 		_ = m.delayTaskManager.AddTask(taskNameGroup, fileEventDelay, m.handleFileGroupChanged)
 		_ = m.delayTaskManager.AddTask(taskNameShadow, fileEventDelay, m.handleFileShadowChanged)
 		_ = m.delayTaskManager.AddTask(taskNameDM, fileEventDelay, m.handleDMConfigChanged)
+		_ = m.delayTaskManager.AddTask(taskNameBtmp, fileEventDelay, m.handleFileBtmpChanged)
 
 		m.watcher.SetFileList(m.getWatchFiles())
 		m.watcher.SetEventHandler(m.handleFileChanged)
 		go m.watcher.StartWatch()
 	}
+	go m.updateFailedLoginCounts(false)
 
 	m.login1Manager.InitSignalExt(m.sysSigLoop, true)
 	_, _ = m.login1Manager.ConnectSessionNew(func(id string, sessionPath dbus.ObjectPath) {
@@ -169,6 +178,7 @@ func (m *Manager) destroy() {
 		m.watcher = nil
 	}
 
+	close(m.scheduleQuit)
 	m.sysSigLoop.Stop()
 	m.stopExportUsers(m.UserList)
 	_ = m.service.StopExport(m)
@@ -74,6 +74,11 @@ func (m *Manager) CreateUser(sender dbus.Sender,
 		return nilObjPath, dbusutil.ToError(err)
 	}
 
+	userPath, err = m.createUser(name, fullName, accountType)
+	return userPath, dbusutil.ToError(err)
+}
+
+func (m *Manager) createUser(name, fullName string, accountType int32) (dbus.ObjectPath, error) {
 	ch := make(chan string)
 	m.usersMapMu.Lock()
 	m.userAddedChanMap[name] = ch
@@ -86,13 +91,13 @@ func (m *Manager) CreateUser(sender dbus.Sender,
 	}()
 
 	homeDir := "/home/" + name
-	_, err = os.Stat(homeDir)
+	_, err := os.Stat(homeDir)
 	homeDirExist := err == nil
 
 	if err := users.CreateUser(name, fullName, ""); err != nil {
 		logger.Warningf("DoAction: create user '%s' failed: %v\n",
 			name, err)
-		return nilObjPath, dbusutil.ToError(err)
+		return nilObjPath, err
 	}
 
 	groups := users.GetPresetGroups(int(accountType))
@@ -106,12 +111,12 @@ func (m *Manager) CreateUser(sender dbus.Sender,
 	select {
 	case userPath, ok := <-ch:
 		if !ok {
-			return nilObjPath, dbusutil.ToError(errors.New("invalid user path event"))
+			return nilObjPath, errors.New("invalid user path event")
 		}
 
 		logger.Debug("receive user path", userPath)
 		if userPath == "" {
-			return nilObjPath, dbusutil.ToError(errors.New("failed to install user on session bus"))
+			return nilObjPath, errors.New("failed to install user on session bus")
 		}
 		if homeDirExist {
 			go chownHomeDir(homeDir, name)
@@ -120,7 +125,7 @@ func (m *Manager) CreateUser(sender dbus.Sender,
 	case <-time.After(time.Second * 60):
 		err := errors.New("wait timeout exceeded")
 		logger.Warning(err)
-		return nilObjPath, dbusutil.ToError(err)
+		return nilObjPath, err
 	}
 }
 
@@ -299,7 +304,14 @@ func (m *Manager) IsUsernameValid(sender dbus.Sender, name string) (valid bool,
 // ret1: 提示信息
 //
 // ret2: 不合法代码
+//
+// 配置了密码策略时按策略检查，不检查与用户名的相似度和历史密码，需要时使用 User 的 IsPasswordValid
 func (m *Manager) IsPasswordValid(password string) (valid bool, msg string, code int32, busErr *dbus.Error) {
+	if policy := loadPasswordPolicy(); policy != nil {
+		errCode := policy.CheckPassword(password, "", nil)
+		return errCode.IsOk(), policy.Prompt(errCode), int32(errCode), nil
+	}
+
 	releaseType := getDeepinReleaseType()
 	logger.Infof("release type %q", releaseType)
 	errCode := checkers.CheckPasswordValid(releaseType, password)
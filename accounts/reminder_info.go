@@ -7,15 +7,20 @@ package accounts
 // #include <sys/time.h>
 // #include <shadow.h>
 // #include <stdio.h>
+// #include <stdlib.h>
 // #include <netinet/in.h>
 // #include <arpa/inet.h>
 import "C"
 import (
 	"fmt"
+	"sync"
 	"time"
 	"unsafe"
 )
 
+// utmpxname、getutxent 等函数使用全局状态，需要串行调用
+var utmpxMu sync.Mutex
+
 type LoginUtmpx struct {
 	InittabID string
 	Line      string
@@ -56,6 +61,8 @@ func getLoginReminderInfo(user string) (res LoginReminderInfo) {
 	var current C.struct_utmpx
 	var last C.struct_utmpx
 
+	utmpxMu.Lock()
+	defer utmpxMu.Unlock()
 	C.count_utmpx(C.CString(C.WTMPX_FILE), C.CString(user), nil, &current, &last)
 
 	var last_tv C.struct_timeval
@@ -89,3 +96,37 @@ func genLoginUtmpx(last C.struct_utmpx) (res LoginUtmpx) {
 
 	return
 }
+
+// readUtmpxFile 读取 wtmp 或 btmp 文件中的全部记录
+func readUtmpxFile(file string) ([]utmpxRecord, error) {
+	cFile := C.CString(file)
+	defer C.free(unsafe.Pointer(cFile))
+
+	utmpxMu.Lock()
+	defer utmpxMu.Unlock()
+
+	if C.utmpxname(cFile) != 0 {
+		return nil, fmt.Errorf("failed to set utmpx file %s", file)
+	}
+	C.setutxent()
+	defer C.endutxent()
+
+	var records []utmpxRecord
+	for {
+		u := C.getutxent()
+		if u == nil {
+			break
+		}
+		info := genLoginUtmpx(*u)
+		records = append(records, utmpxRecord{
+			Type:    int(u.ut_type),
+			Pid:     int(u.ut_pid),
+			User:    C.GoString(&(u.ut_user[0])),
+			Line:    info.Line,
+			Host:    info.Host,
+			Address: info.Address,
+			Time:    time.Unix(int64(u.ut_tv.tv_sec), int64(u.ut_tv.tv_usec)*1000),
+		})
+	}
+	return records, nil
+}
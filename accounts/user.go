@@ -73,6 +73,9 @@ const (
 	confKeyLongTimeFormat     = "LongTimeFormat"
 	confKeyWeekBegins         = "WeekBegins"
 	confKeyPasswordHint       = "PasswordHint"
+	confKeyExpirationTime     = "ExpirationTime"
+	confKeyLoginTimeWindows   = "LoginTimeWindows"
+	confKeyScheduleLocked     = "ScheduleLocked"
 
 	defaultUse24HourFormat = true
 	defaultWeekdayFormat   = 0
@@ -148,7 +151,26 @@ type User struct {
 	// dbusutil-gen: equal=nil
 	HistoryLayout []string
 
+	// 账户过期的 unix 时间，0 表示不过期
+	ExpirationTime int64
+	// 允许登录的时间段，为空表示不限制
+	// dbusutil-gen: equal=isStrvEqual
+	LoginTimeWindows []string
+	// 距离账户过期或者允许登录的时间段结束的秒数，-1 表示没有限制
+	RemainingTime int64
+	// 账户是否是因为不在允许登录的时间被锁定的
+	scheduleLocked bool
+
 	configLocker sync.Mutex
+
+	//nolint
+	signals *struct {
+		// 自上次成功登录以来失败的次数达到阈值
+		FailedLoginThresholdExceeded struct {
+			count        int32
+			lastFailTime int64
+		}
+	}
 }
 
 func NewUser(userPath string, service *dbusutil.Service, ignoreErr bool) (*User, error) {
@@ -291,7 +313,7 @@ func (u *User) setIconFile(iconURI string) (string, bool, error) {
 
 type configChange struct {
 	key   string
-	value interface{} // allowed type are bool, string, []string , int32
+	value interface{} // allowed type are bool, string, []string , int32, int64
 }
 
 func (u *User) writeUserConfigWithChanges(changes []configChange) error {
@@ -340,6 +362,8 @@ func (u *User) writeUserConfigWithChanges(changes []configChange) error {
 			kf.SetStringList(confGroupUser, change.key, val)
 		case int32:
 			kf.SetInteger(confGroupUser, change.key, val)
+		case int64:
+			kf.SetInt64(confGroupUser, change.key, val)
 		default:
 			return errors.New("unsupported value type")
 		}
@@ -700,6 +724,7 @@ func loadUserConfigInfo(u *User) {
 	var err error
 
 	u.IconList = u.getAllIcons()
+	u.RemainingTime = -1
 
 	// NOTICE(jouyouyun): Got created time,  not accurate, can only be used as a reference
 	u.CreatedTime, err = u.getCreatedTime()
@@ -872,6 +897,10 @@ func loadUserConfigInfo(u *User) {
 		}
 	}
 
+	u.ExpirationTime, _ = kf.GetInt64(confGroupUser, confKeyExpirationTime)
+	_, u.LoginTimeWindows, _ = kf.GetStringList(confGroupUser, confKeyLoginTimeWindows)
+	u.scheduleLocked, _ = kf.GetBoolean(confGroupUser, confKeyScheduleLocked)
+
 	if isSave {
 		err := u.writeUserConfig()
 		if err != nil {
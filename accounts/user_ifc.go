@@ -48,6 +48,7 @@ import (
 
 	dbus "github.com/godbus/dbus"
 	"github.com/linuxdeepin/dde-api/lang_info"
+	"github.com/linuxdeepin/dde-daemon/accounts/checkers"
 	"github.com/linuxdeepin/dde-daemon/accounts/users"
 	"github.com/linuxdeepin/go-lib/dbusutil"
 	"github.com/linuxdeepin/go-lib/gdkpixbuf"
@@ -80,13 +81,18 @@ func (u *User) SetFullName(sender dbus.Sender, name string) *dbus.Error {
 		return dbusutil.ToError(fmt.Errorf("only dde-control-center allowed to call this method"))
 	}
 
+	err = u.modifyFullName(name)
+	return dbusutil.ToError(err)
+}
+
+func (u *User) modifyFullName(name string) error {
 	u.PropsMu.Lock()
 	defer u.PropsMu.Unlock()
 
 	if u.FullName != name {
 		if err := users.ModifyFullName(name, u.UserName); err != nil {
 			logger.Warning("DoAction: modify full name failed:", err)
-			return dbusutil.ToError(err)
+			return err
 		}
 
 		u.FullName = name
@@ -134,17 +140,22 @@ func (u *User) SetShell(sender dbus.Sender, shell string) *dbus.Error {
 		return dbusutil.ToError(err)
 	}
 
+	err = u.modifyShell(shell)
+	return dbusutil.ToError(err)
+}
+
+func (u *User) modifyShell(shell string) error {
 	shells := getAvailableShells("/etc/shells")
 	if len(shells) == 0 {
 		err := fmt.Errorf("no available shell found")
 		logger.Error("[SetShell] failed:", err)
-		return dbusutil.ToError(err)
+		return err
 	}
 
 	if !strv.Strv(shells).Contains(shell) {
 		err := fmt.Errorf("not found the shell: %s", shell)
 		logger.Warning("[SetShell] failed:", err)
-		return dbusutil.ToError(err)
+		return err
 	}
 
 	u.PropsMu.Lock()
@@ -153,7 +164,7 @@ func (u *User) SetShell(sender dbus.Sender, shell string) *dbus.Error {
 	if u.Shell != shell {
 		if err := users.ModifyShell(shell, u.UserName); err != nil {
 			logger.Warning("DoAction: modify shell failed:", err)
-			return dbusutil.ToError(err)
+			return err
 		}
 		u.Shell = shell
 		_ = u.emitPropChangedShell(shell)
@@ -195,6 +206,13 @@ func (u *User) SetPassword(sender dbus.Sender, password string) *dbus.Error {
 		time.Sleep(time.Second)
 	}
 
+	if policy := loadPasswordPolicy(); policy != nil && policy.History > 0 {
+		err = users.SavePasswdHistory(u.UserName, u.Uid, policy.History)
+		if err != nil {
+			logger.Warning("failed to save password history:", err)
+		}
+	}
+
 	if err := users.ModifyPasswd(password, u.UserName); err != nil {
 		logger.Warning("DoAction: modify password failed:", err)
 		return dbusutil.ToError(err)
@@ -252,6 +270,9 @@ func (u *User) SetLocked(sender dbus.Sender, locked bool) *dbus.Error {
 	u.PropsMu.Lock()
 	defer u.PropsMu.Unlock()
 
+	// 管理员手动修改后不再自动解锁
+	u.setScheduleLocked(false)
+
 	if u.Locked != locked {
 		if err := users.LockedUser(locked, u.UserName); err != nil {
 			logger.Warning("DoAction: locked user failed:", err)
@@ -273,6 +294,66 @@ func (u *User) SetLocked(sender dbus.Sender, locked bool) *dbus.Error {
 	return nil
 }
 
+// SetExpirationTime 设置账户过期的 unix 时间，过期后账户被锁定，0 表示不过期
+func (u *User) SetExpirationTime(sender dbus.Sender, expirationTime int64) *dbus.Error {
+	logger.Debug("[SetExpirationTime] expiration time:", expirationTime)
+
+	err := u.checkAuth(sender, false, polkitActionUserAdministration)
+	if err != nil {
+		logger.Debug("[SetExpirationTime] access denied:", err)
+		return dbusutil.ToError(err)
+	}
+
+	if expirationTime < 0 {
+		return dbusutil.ToError(fmt.Errorf("invalid expiration time %d", expirationTime))
+	}
+
+	u.PropsMu.Lock()
+	if u.ExpirationTime != expirationTime {
+		err = u.writeUserConfigWithChange(confKeyExpirationTime, expirationTime)
+		if err != nil {
+			u.PropsMu.Unlock()
+			return dbusutil.ToError(err)
+		}
+		u.setPropExpirationTime(expirationTime)
+	}
+	u.PropsMu.Unlock()
+
+	u.checkSchedule(time.Now())
+	return nil
+}
+
+// SetLoginTimeWindows 设置允许登录的时间段，格式如 "Mon-Fri 08:00-18:00"，
+// 时间段结束时账户被锁定并结束会话，为空表示不限制。
+func (u *User) SetLoginTimeWindows(sender dbus.Sender, windows []string) *dbus.Error {
+	logger.Debug("[SetLoginTimeWindows] windows:", windows)
+
+	err := u.checkAuth(sender, false, polkitActionUserAdministration)
+	if err != nil {
+		logger.Debug("[SetLoginTimeWindows] access denied:", err)
+		return dbusutil.ToError(err)
+	}
+
+	_, err = parseLoginTimeWindows(windows)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	u.PropsMu.Lock()
+	if !isStrvEqual(u.LoginTimeWindows, windows) {
+		err = u.writeUserConfigWithChange(confKeyLoginTimeWindows, windows)
+		if err != nil {
+			u.PropsMu.Unlock()
+			return dbusutil.ToError(err)
+		}
+		u.setPropLoginTimeWindows(windows)
+	}
+	u.PropsMu.Unlock()
+
+	u.checkSchedule(time.Now())
+	return nil
+}
+
 func (u *User) AddGroup(sender dbus.Sender, group string) *dbus.Error {
 	logger.Debugf("add group %s for %s", group, u.UserName)
 	err := u.checkAuth(sender, false, polkitActionUserAdministration)
@@ -327,11 +408,16 @@ func (u *User) SetAutomaticLogin(sender dbus.Sender, enabled bool) *dbus.Error {
 		return dbusutil.ToError(err)
 	}
 
+	err = u.modifyAutomaticLogin(enabled)
+	return dbusutil.ToError(err)
+}
+
+func (u *User) modifyAutomaticLogin(enabled bool) error {
 	u.PropsMu.Lock()
 	defer u.PropsMu.Unlock()
 
 	if u.Locked {
-		return dbusutil.ToError(fmt.Errorf("user %s has been locked", u.UserName))
+		return fmt.Errorf("user %s has been locked", u.UserName)
 	}
 
 	if u.AutomaticLogin == enabled {
@@ -349,7 +435,7 @@ func (u *User) SetAutomaticLogin(sender dbus.Sender, enabled bool) *dbus.Error {
 	}
 	if err := users.SetAutoLoginUser(name, session); err != nil {
 		logger.Warning("DoAction: set auto login failed:", err)
-		return dbusutil.ToError(err)
+		return err
 	}
 
 	u.AutomaticLogin = enabled
@@ -408,10 +494,15 @@ func (u *User) SetLocale(sender dbus.Sender, locale string) *dbus.Error {
 		return dbusutil.ToError(fmt.Errorf("only deepin daemons allowed to call this method"))
 	}
 
+	err = u.modifyLocale(locale)
+	return dbusutil.ToError(err)
+}
+
+func (u *User) modifyLocale(locale string) error {
 	if !lang_info.IsSupportedLocale(locale) {
 		err := fmt.Errorf("invalid locale %q", locale)
 		logger.Debug("[SetLocale]", err)
-		return dbusutil.ToError(err)
+		return err
 	}
 
 	u.PropsMu.Lock()
@@ -421,9 +512,9 @@ func (u *User) SetLocale(sender dbus.Sender, locale string) *dbus.Error {
 		return nil
 	}
 
-	err = u.writeUserConfigWithChange(confKeyLocale, locale)
+	err := u.writeUserConfigWithChange(confKeyLocale, locale)
 	if err != nil {
-		return dbusutil.ToError(err)
+		return err
 	}
 	u.setLocale(locale)
 	_ = u.emitPropChangedLocale(locale)
@@ -439,6 +530,11 @@ func (u *User) SetLayout(sender dbus.Sender, layout string) *dbus.Error {
 		return dbusutil.ToError(err)
 	}
 
+	err = u.modifyLayout(layout)
+	return dbusutil.ToError(err)
+}
+
+func (u *User) modifyLayout(layout string) error {
 	// TODO: check layout validity
 
 	u.PropsMu.Lock()
@@ -448,9 +544,9 @@ func (u *User) SetLayout(sender dbus.Sender, layout string) *dbus.Error {
 		return nil
 	}
 
-	err = u.writeUserConfigWithChange(confKeyLayout, layout)
+	err := u.writeUserConfigWithChange(confKeyLayout, layout)
 	if err != nil {
-		return dbusutil.ToError(err)
+		return err
 	}
 	u.Layout = layout
 	_ = u.emitPropChangedLayout(layout)
@@ -466,14 +562,19 @@ func (u *User) SetIconFile(sender dbus.Sender, iconURI string) *dbus.Error {
 		return dbusutil.ToError(err)
 	}
 
+	err = u.modifyIconFile(iconURI)
+	return dbusutil.ToError(err)
+}
+
+func (u *User) modifyIconFile(iconURI string) error {
 	iconURI = dutils.EncodeURI(iconURI, dutils.SCHEME_FILE)
 	iconFile := dutils.DecodeURI(iconURI)
 
 	// check if file exist
-	_, err = os.Stat(iconFile)
+	_, err := os.Stat(iconFile)
 	if err != nil {
 		logger.Warning(err)
-		return dbusutil.ToError(err)
+		return err
 	}
 
 	// if iconURI not in iconList, need to create temp icon file
@@ -482,7 +583,7 @@ func (u *User) SetIconFile(sender dbus.Sender, iconURI string) *dbus.Error {
 		iconFile, err = copyTempIconFile(iconFile, u.UserName)
 		if err != nil {
 			logger.Warningf("copy temp file failed, err: %v", err)
-			return dbusutil.ToError(err)
+			return err
 		}
 		// remove file
 		defer func() {
@@ -499,7 +600,7 @@ func (u *User) SetIconFile(sender dbus.Sender, iconURI string) *dbus.Error {
 	if !gdkpixbuf.IsSupportedImage(iconFile) {
 		err := fmt.Errorf("%q is not a image file", iconFile)
 		logger.Debug(err)
-		return dbusutil.ToError(err)
+		return err
 	}
 
 	u.PropsMu.Lock()
@@ -512,7 +613,7 @@ func (u *User) SetIconFile(sender dbus.Sender, iconURI string) *dbus.Error {
 	newIconURI, added, err := u.setIconFile(iconURI)
 	if err != nil {
 		logger.Warning("Set icon failed:", err)
-		return dbusutil.ToError(err)
+		return err
 	}
 
 	if added {
@@ -522,7 +623,7 @@ func (u *User) SetIconFile(sender dbus.Sender, iconURI string) *dbus.Error {
 			{confKeyIcon, newIconURI},
 		})
 		if err != nil {
-			return dbusutil.ToError(err)
+			return err
 		}
 
 		// remove old custom icon
@@ -538,7 +639,7 @@ func (u *User) SetIconFile(sender dbus.Sender, iconURI string) *dbus.Error {
 	} else {
 		err = u.writeUserConfigWithChange(confKeyIcon, newIconURI)
 		if err != nil {
-			return dbusutil.ToError(err)
+			return err
 		}
 	}
 
@@ -1031,6 +1132,30 @@ func (u *User) PasswordExpiredInfo() (expiredStatus ExpiredStatus, dayLeft int64
 	return expiredStatusNormal, daysLeft, nil
 }
 
+// IsPasswordValid 按密码策略检查用户的新密码，包括与用户名的相似度和历史密码，
+// 返回值与 Manager 的 IsPasswordValid 相同
+func (u *User) IsPasswordValid(sender dbus.Sender, password string) (valid bool, msg string, code int32, busErr *dbus.Error) {
+	policy := loadPasswordPolicy()
+	if policy == nil {
+		errCode := checkers.CheckPasswordValid(getDeepinReleaseType(), password)
+		return errCode.IsOk(), errCode.Prompt(), int32(errCode), nil
+	}
+
+	var isUsedBefore func(passwd string) bool
+	if policy.History > 0 {
+		// 历史密码的检查可以用来猜测当前密码，需要与修改密码相同的认证
+		err := u.checkAuth(sender, false, "")
+		if err != nil {
+			return false, "", 0, dbusutil.ToError(err)
+		}
+		isUsedBefore = func(passwd string) bool {
+			return users.IsPasswdUsedBefore(u.UserName, passwd, policy.History)
+		}
+	}
+	errCode := policy.CheckPassword(password, u.UserName, isUsedBefore)
+	return errCode.IsOk(), policy.Prompt(errCode), int32(errCode), nil
+}
+
 func (u *User) SetPasswordHint(hint string) (busErr *dbus.Error) {
 	encodeHint := base64.StdEncoding.EncodeToString([]byte(hint))
 	err := u.writeUserConfigWithChange(confKeyPasswordHint, encodeHint)
@@ -1044,6 +1169,29 @@ func (u *User) GetReminderInfo() (info LoginReminderInfo, dbusErr *dbus.Error) {
 	return getLoginReminderInfo(u.UserName), nil
 }
 
+// GetLoginHistory 获取用户成功的登录和失败的登录记录
+//
+// since: 只返回此 unix 时间戳之后的记录
+//
+// limit: 大于 0 时限制每种记录的数量
+func (u *User) GetLoginHistory(sender dbus.Sender, since int64, limit int32) (history LoginHistory, busErr *dbus.Error) {
+	err := u.checkAuth(sender, true, "")
+	if err != nil {
+		logger.Debug("[GetLoginHistory] access denied:", err)
+		return LoginHistory{}, dbusutil.ToError(err)
+	}
+
+	wtmp, err := readUtmpxFile(userFileWtmp)
+	if err != nil {
+		return LoginHistory{}, dbusutil.ToError(err)
+	}
+	btmp, err := readUtmpxFile(userFileBtmp)
+	if err != nil {
+		return LoginHistory{}, dbusutil.ToError(err)
+	}
+	return *newLoginHistory(wtmp, btmp, u.UserName, time.Unix(since, 0), int(limit)), nil
+}
+
 /* secret question */
 // #nosec G101
 const secretQuestionDirectory = "/var/lib/dde-daemon/secret-question/"
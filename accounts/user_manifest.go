@@ -0,0 +1,383 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/user"
+	"sort"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/linuxdeepin/dde-daemon/accounts/checkers"
+	"github.com/linuxdeepin/dde-daemon/accounts/users"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	dutils "github.com/linuxdeepin/go-lib/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// UserManifest 批量配置用户的清单，支持 YAML 和 JSON 格式
+type UserManifest struct {
+	Users []*UserManifestEntry `json:"users" yaml:"users"`
+}
+
+// UserManifestEntry 清单中的一个用户，除 Name 外的字段不填表示不修改
+type UserManifestEntry struct {
+	Name        string  `json:"name" yaml:"name"`
+	FullName    *string `json:"fullName,omitempty" yaml:"fullName"`
+	AccountType int32   `json:"accountType,omitempty" yaml:"accountType"` // 只在创建用户时使用
+	// 附加组，不包含用户的主组，空列表表示移出所有附加组
+	Groups         []string `json:"groups,omitempty" yaml:"groups"`
+	Shell          *string  `json:"shell,omitempty" yaml:"shell"`
+	Locale         *string  `json:"locale,omitempty" yaml:"locale"`
+	Layout         *string  `json:"layout,omitempty" yaml:"layout"`
+	Icon           *string  `json:"icon,omitempty" yaml:"icon"`
+	MaxPasswordAge *int32   `json:"maxPasswordAge,omitempty" yaml:"maxPasswordAge"`
+	AutomaticLogin *bool    `json:"automaticLogin,omitempty" yaml:"automaticLogin"`
+}
+
+// 计划中的修改项
+const (
+	manifestChangeCreate         = "create"
+	manifestChangeFullName       = "fullName"
+	manifestChangeGroups         = "groups"
+	manifestChangeShell          = "shell"
+	manifestChangeLocale         = "locale"
+	manifestChangeLayout         = "layout"
+	manifestChangeIcon           = "icon"
+	manifestChangeMaxPasswordAge = "maxPasswordAge"
+	manifestChangeAutomaticLogin = "automaticLogin"
+)
+
+// 每个用户的处理结果
+const (
+	ManifestStatusUnchanged = "unchanged" // 已经符合清单
+	ManifestStatusPlanned   = "planned"   // dry-run 时需要修改
+	ManifestStatusCreated   = "created"
+	ManifestStatusChanged   = "changed"
+	ManifestStatusFailed    = "failed"
+)
+
+// UserManifestResult 清单中一个用户的处理结果
+type UserManifestResult struct {
+	Name    string
+	Status  string
+	Changes []string `json:",omitempty"`
+	Error   string   `json:",omitempty"`
+}
+
+// userManifestState 用户的当前状态，用来和清单比较
+type userManifestState struct {
+	FullName       string
+	Groups         []string // 不包含主组
+	Shell          string
+	Locale         string
+	Layout         string
+	IconFile       string
+	MaxPasswordAge int32
+	AutomaticLogin bool
+}
+
+// parseUserManifest 解析清单，以 { 开头的按 JSON 解析，其他按 YAML 解析
+func parseUserManifest(data []byte) (*UserManifest, error) {
+	data = bytes.TrimSpace(data)
+	var manifest UserManifest
+	if bytes.HasPrefix(data, []byte("{")) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&manifest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json manifest: %v", err)
+		}
+	} else {
+		err := yaml.UnmarshalStrict(data, &manifest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid yaml manifest: %v", err)
+		}
+	}
+
+	err := manifest.check()
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func (m *UserManifest) check() error {
+	if len(m.Users) == 0 {
+		return errors.New("no user in manifest")
+	}
+
+	names := make(map[string]bool, len(m.Users))
+	var autoLoginUser string
+	for _, entry := range m.Users {
+		if entry == nil || entry.Name == "" {
+			return errors.New("user name is empty")
+		}
+		if names[entry.Name] {
+			return fmt.Errorf("user %q is duplicated", entry.Name)
+		}
+		names[entry.Name] = true
+
+		err := checkAccountType(int(entry.AccountType))
+		if err != nil {
+			return fmt.Errorf("user %q: %v", entry.Name, err)
+		}
+		if entry.MaxPasswordAge != nil && *entry.MaxPasswordAge < -1 {
+			return fmt.Errorf("user %q: invalid max password age %d", entry.Name, *entry.MaxPasswordAge)
+		}
+		if entry.AutomaticLogin != nil && *entry.AutomaticLogin {
+			// 只能有一个自动登录的用户
+			if autoLoginUser != "" {
+				return fmt.Errorf("both %q and %q are set to automatic login", autoLoginUser, entry.Name)
+			}
+			autoLoginUser = entry.Name
+		}
+	}
+	return nil
+}
+
+// normalizeGroups 去掉主组和重复的组并排序
+func normalizeGroups(groups []string, primaryGroup string) []string {
+	result := make([]string, 0, len(groups))
+	seen := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		if group == "" || group == primaryGroup || seen[group] {
+			continue
+		}
+		seen[group] = true
+		result = append(result, group)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// isSameIconFile 判断清单中的图标和用户当前的图标是否相同，
+// 非预置的图标会被复制为用户的自定义图标，所以还需要比较文件内容。
+func isSameIconFile(icon, current string) bool {
+	iconURI := dutils.EncodeURI(icon, dutils.SCHEME_FILE)
+	if iconURI == current {
+		return true
+	}
+	if current == "" {
+		return false
+	}
+	data0, err := ioutil.ReadFile(dutils.DecodeURI(iconURI))
+	if err != nil {
+		return false
+	}
+	data1, err := ioutil.ReadFile(dutils.DecodeURI(current))
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(data0, data1)
+}
+
+// planUserManifestEntry 返回把用户修改成清单中的样子需要的修改项，state 为 nil 表示用户不存在
+func planUserManifestEntry(entry *UserManifestEntry, state *userManifestState) []string {
+	var changes []string
+	if state == nil {
+		// 全名在创建用户时设置
+		changes = append(changes, manifestChangeCreate)
+		state = &userManifestState{}
+		if entry.FullName != nil {
+			state.FullName = *entry.FullName
+		}
+	}
+
+	if entry.FullName != nil && *entry.FullName != state.FullName {
+		changes = append(changes, manifestChangeFullName)
+	}
+	if entry.Groups != nil && !isStrvEqual(normalizeGroups(entry.Groups, entry.Name), state.Groups) {
+		changes = append(changes, manifestChangeGroups)
+	}
+	if entry.Shell != nil && *entry.Shell != state.Shell {
+		changes = append(changes, manifestChangeShell)
+	}
+	if entry.Locale != nil && *entry.Locale != state.Locale {
+		changes = append(changes, manifestChangeLocale)
+	}
+	if entry.Layout != nil && *entry.Layout != state.Layout {
+		changes = append(changes, manifestChangeLayout)
+	}
+	if entry.Icon != nil && !isSameIconFile(*entry.Icon, state.IconFile) {
+		changes = append(changes, manifestChangeIcon)
+	}
+	if entry.MaxPasswordAge != nil && *entry.MaxPasswordAge != state.MaxPasswordAge {
+		changes = append(changes, manifestChangeMaxPasswordAge)
+	}
+	if entry.AutomaticLogin != nil && *entry.AutomaticLogin != state.AutomaticLogin {
+		changes = append(changes, manifestChangeAutomaticLogin)
+	}
+	return changes
+}
+
+func (u *User) getManifestState() *userManifestState {
+	primaryGroup := u.UserName
+	group, err := user.LookupGroupId(u.Gid)
+	if err == nil {
+		primaryGroup = group.Name
+	}
+
+	u.PropsMu.RLock()
+	defer u.PropsMu.RUnlock()
+	return &userManifestState{
+		FullName:       u.FullName,
+		Groups:         normalizeGroups(u.Groups, primaryGroup),
+		Shell:          u.Shell,
+		Locale:         u.Locale,
+		Layout:         u.Layout,
+		IconFile:       u.IconFile,
+		MaxPasswordAge: u.MaxPasswordAge,
+		AutomaticLogin: u.AutomaticLogin,
+	}
+}
+
+// applyManifestChange 修改用户的一项设置
+func (u *User) applyManifestChange(entry *UserManifestEntry, change string) error {
+	switch change {
+	case manifestChangeFullName:
+		return u.modifyFullName(*entry.FullName)
+	case manifestChangeGroups:
+		err := users.SetGroupsForUser(normalizeGroups(entry.Groups, entry.Name), u.UserName)
+		if err != nil {
+			return err
+		}
+		u.updatePropGroups()
+		return nil
+	case manifestChangeShell:
+		return u.modifyShell(*entry.Shell)
+	case manifestChangeLocale:
+		return u.modifyLocale(*entry.Locale)
+	case manifestChangeLayout:
+		return u.modifyLayout(*entry.Layout)
+	case manifestChangeIcon:
+		return u.modifyIconFile(*entry.Icon)
+	case manifestChangeMaxPasswordAge:
+		err := users.ModifyMaxPasswordAge(u.UserName, int(*entry.MaxPasswordAge))
+		if err != nil {
+			return err
+		}
+		u.PropsMu.Lock()
+		u.setPropMaxPasswordAge(*entry.MaxPasswordAge)
+		u.PropsMu.Unlock()
+		return nil
+	case manifestChangeAutomaticLogin:
+		return u.modifyAutomaticLogin(*entry.AutomaticLogin)
+	default:
+		return fmt.Errorf("unknown change %q", change)
+	}
+}
+
+// applyUserManifestEntry 按清单创建或修改一个用户，已经符合清单的设置不会被修改
+func (m *Manager) applyUserManifestEntry(entry *UserManifestEntry, dryRun bool) *UserManifestResult {
+	result := &UserManifestResult{
+		Name: entry.Name,
+	}
+	fail := func(err error) *UserManifestResult {
+		logger.Warningf("failed to apply manifest for user %s: %v", entry.Name, err)
+		result.Status = ManifestStatusFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	u := m.getUserByName(entry.Name)
+	var state *userManifestState
+	if u != nil {
+		state = u.getManifestState()
+	}
+	result.Changes = planUserManifestEntry(entry, state)
+	if len(result.Changes) == 0 {
+		result.Status = ManifestStatusUnchanged
+		return result
+	}
+	if dryRun {
+		result.Status = ManifestStatusPlanned
+		return result
+	}
+
+	changes := result.Changes
+	result.Status = ManifestStatusChanged
+	if u == nil {
+		if info := checkers.CheckUsernameValid(entry.Name); info != nil {
+			return fail(info.Error)
+		}
+		var fullName string
+		if entry.FullName != nil {
+			fullName = *entry.FullName
+		}
+		_, err := m.createUser(entry.Name, fullName, entry.AccountType)
+		if err != nil {
+			return fail(err)
+		}
+		result.Status = ManifestStatusCreated
+
+		u = m.getUserByName(entry.Name)
+		if u == nil {
+			return fail(errors.New("user not found after creation"))
+		}
+		// 新用户有预置的组等默认设置，重新计算需要的修改
+		changes = planUserManifestEntry(entry, u.getManifestState())
+	}
+
+	for _, change := range changes {
+		err := u.applyManifestChange(entry, change)
+		if err != nil {
+			return fail(fmt.Errorf("%s: %v", change, err))
+		}
+	}
+	return result
+}
+
+func (m *Manager) applyUserManifest(manifest *UserManifest, dryRun bool) []*UserManifestResult {
+	results := make([]*UserManifestResult, 0, len(manifest.Users))
+	for _, entry := range manifest.Users {
+		results = append(results, m.applyUserManifestEntry(entry, dryRun))
+	}
+	return results
+}
+
+// ApplyUserManifest 按 YAML 或 JSON 格式的清单批量创建和配置用户，
+// 只修改与清单不一致的设置，可以重复执行。
+//
+// dryRun: 为 true 时只计算需要的修改，不实际执行
+//
+// report: JSON 格式的每个用户的处理结果
+func (m *Manager) ApplyUserManifest(sender dbus.Sender, manifest string, dryRun bool) (report string, busErr *dbus.Error) {
+	logger.Debug("[ApplyUserManifest] dry run:", dryRun)
+
+	err := m.checkAuth(sender)
+	if err != nil {
+		logger.Debug("[ApplyUserManifest] access denied:", err)
+		return "", dbusutil.ToError(err)
+	}
+
+	userManifest, err := parseUserManifest([]byte(manifest))
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+
+	data, err := json.Marshal(m.applyUserManifest(userManifest, dryRun))
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
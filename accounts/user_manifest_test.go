@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseUserManifest(t *testing.T) {
+	for _, file := range []string{"testdata/manifest/users.yaml", "testdata/manifest/users.json"} {
+		data, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+
+		manifest, err := parseUserManifest(data)
+		require.NoError(t, err, file)
+		require.Len(t, manifest.Users, 2)
+
+		teacher := manifest.Users[0]
+		assert.Equal(t, "teacher", teacher.Name)
+		assert.Equal(t, "Teacher", *teacher.FullName)
+		assert.Equal(t, int32(1), teacher.AccountType)
+		assert.Equal(t, []string{"lpadmin", "sudo"}, teacher.Groups)
+		assert.Equal(t, "/bin/bash", *teacher.Shell)
+		assert.Equal(t, "zh_CN.UTF-8", *teacher.Locale)
+		assert.Equal(t, "us;", *teacher.Layout)
+		assert.Equal(t, int32(90), *teacher.MaxPasswordAge)
+		assert.Nil(t, teacher.Icon)
+		assert.Nil(t, teacher.AutomaticLogin)
+
+		student := manifest.Users[1]
+		assert.Equal(t, "student", student.Name)
+		assert.NotNil(t, student.Groups)
+		assert.Empty(t, student.Groups)
+		assert.Nil(t, student.Shell)
+		assert.True(t, *student.AutomaticLogin)
+	}
+
+	for _, data := range []string{
+		"",
+		"users: []",
+		"users:\n  - fullName: Test",
+		"users:\n  - name: test\n  - name: test",
+		"users:\n  - name: test\n    accountType: 3",
+		"users:\n  - name: test\n    maxPasswordAge: -2",
+		"users:\n  - name: a\n    automaticLogin: true\n  - name: b\n    automaticLogin: true",
+		"users:\n  - name: test\n    unknown: 1",
+		`{"users": [{"name": "test", "unknown": 1}]}`,
+	} {
+		_, err := parseUserManifest([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func Test_normalizeGroups(t *testing.T) {
+	assert.Equal(t, []string{"lp", "sudo"}, normalizeGroups([]string{"sudo", "test", "lp", "", "sudo"}, "test"))
+	assert.Equal(t, []string{}, normalizeGroups(nil, "test"))
+}
+
+func Test_planUserManifestEntry(t *testing.T) {
+	fullName := "Test"
+	shell := "/bin/zsh"
+	maxAge := int32(90)
+	autoLogin := false
+	entry := &UserManifestEntry{
+		Name:           "test",
+		FullName:       &fullName,
+		Groups:         []string{"sudo", "lp"},
+		Shell:          &shell,
+		MaxPasswordAge: &maxAge,
+		AutomaticLogin: &autoLogin,
+	}
+
+	assert.Equal(t, []string{manifestChangeCreate, manifestChangeGroups, manifestChangeShell,
+		manifestChangeMaxPasswordAge}, planUserManifestEntry(entry, nil))
+
+	state := &userManifestState{
+		FullName:       "Test",
+		Groups:         []string{"lp", "sudo"},
+		Shell:          "/bin/zsh",
+		Locale:         "en_US.UTF-8",
+		MaxPasswordAge: 90,
+	}
+	assert.Empty(t, planUserManifestEntry(entry, state))
+
+	state.FullName = "Old"
+	state.Groups = []string{"sudo"}
+	state.AutomaticLogin = true
+	assert.Equal(t, []string{manifestChangeFullName, manifestChangeGroups, manifestChangeAutomaticLogin},
+		planUserManifestEntry(entry, state))
+
+	// 不填的字段不修改
+	assert.Empty(t, planUserManifestEntry(&UserManifestEntry{Name: "test"}, state))
+}
+
+func Test_isSameIconFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "user-manifest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	icon := filepath.Join(dir, "icon.png")
+	custom := filepath.Join(dir, "custom.png")
+	other := filepath.Join(dir, "other.png")
+	require.NoError(t, ioutil.WriteFile(icon, []byte("icon"), 0644))
+	require.NoError(t, ioutil.WriteFile(custom, []byte("icon"), 0644))
+	require.NoError(t, ioutil.WriteFile(other, []byte("other"), 0644))
+
+	assert.True(t, isSameIconFile(icon, "file://"+icon))
+	assert.True(t, isSameIconFile(icon, "file://"+custom))
+	assert.False(t, isSameIconFile(icon, "file://"+other))
+	assert.False(t, isSameIconFile(icon, ""))
+	assert.False(t, isSameIconFile(filepath.Join(dir, "none.png"), "file://"+other))
+}
@@ -0,0 +1,323 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linuxdeepin/dde-daemon/accounts/logined"
+	"github.com/linuxdeepin/dde-daemon/accounts/users"
+)
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// loginTimeWindow 允许登录的时间段，格式如 "Mon-Fri 08:00-18:00"、"Sat,Sun 09:00-12:00"、"* 20:00-06:00"，
+// 结束时间不晚于开始时间时表示结束于第二天。
+type loginTimeWindow struct {
+	days  [7]bool // 下标为 time.Weekday
+	start int     // 从 0 点开始的分钟数
+	end   int
+}
+
+func parseWeekday(str string) (int, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	for i, name := range weekdayNames {
+		if str == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", str)
+}
+
+func parseWindowDays(str string) ([7]bool, error) {
+	var days [7]bool
+	if str == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return days, nil
+	}
+
+	for _, item := range strings.Split(str, ",") {
+		fields := strings.SplitN(item, "-", 2)
+		first, err := parseWeekday(fields[0])
+		if err != nil {
+			return days, err
+		}
+		last := first
+		if len(fields) == 2 {
+			last, err = parseWeekday(fields[1])
+			if err != nil {
+				return days, err
+			}
+		}
+		// 支持 Fri-Mon 这样跨周末的范围
+		for i := first; ; i = (i + 1) % 7 {
+			days[i] = true
+			if i == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseClock 解析 HH:MM 格式的时间，允许 24:00
+func parseClock(str string) (int, error) {
+	fields := strings.Split(str, ":")
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("invalid time %q", str)
+	}
+	hour, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", str)
+	}
+	minute, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", str)
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q", str)
+	}
+	return hour*60 + minute, nil
+}
+
+func parseLoginTimeWindow(str string) (*loginTimeWindow, error) {
+	fields := strings.Fields(str)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid login time window %q", str)
+	}
+	days, err := parseWindowDays(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	clocks := strings.Split(fields[1], "-")
+	if len(clocks) != 2 {
+		return nil, fmt.Errorf("invalid login time window %q", str)
+	}
+	start, err := parseClock(clocks[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(clocks[1])
+	if err != nil {
+		return nil, err
+	}
+	if start == 24*60 {
+		return nil, fmt.Errorf("invalid login time window %q", str)
+	}
+	return &loginTimeWindow{
+		days:  days,
+		start: start,
+		end:   end,
+	}, nil
+}
+
+func parseLoginTimeWindows(list []string) ([]*loginTimeWindow, error) {
+	windows := make([]*loginTimeWindow, 0, len(list))
+	for _, str := range list {
+		window, err := parseLoginTimeWindow(str)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+type timeRange struct {
+	start, end time.Time
+}
+
+// getLoginTimeRanges 把时间段展开为 now 前一天到后七天内具体的时间范围，并合并相连的范围
+func getLoginTimeRanges(windows []*loginTimeWindow, now time.Time) []timeRange {
+	var ranges []timeRange
+	year, month, day := now.Date()
+	for offset := -1; offset <= 7; offset++ {
+		date := time.Date(year, month, day+offset, 0, 0, 0, 0, now.Location())
+		for _, window := range windows {
+			if !window.days[date.Weekday()] {
+				continue
+			}
+			end := window.end
+			if end <= window.start {
+				end += 24 * 60
+			}
+			ranges = append(ranges, timeRange{
+				start: time.Date(year, month, day+offset, 0, window.start, 0, 0, now.Location()),
+				end:   time.Date(year, month, day+offset, 0, end, 0, 0, now.Location()),
+			})
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start.Before(ranges[j].start)
+	})
+	var merged []timeRange
+	for _, r := range ranges {
+		last := len(merged) - 1
+		if last >= 0 && !r.start.After(merged[last].end) {
+			if r.end.After(merged[last].end) {
+				merged[last].end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// getAccountScheduleState 返回账户在 now 时是否允许登录，以及距离不允许登录还剩多少秒，
+// 没有任何限制时剩余时间为 -1。expirationTime 为 0 表示不过期。
+func getAccountScheduleState(expirationTime int64, windows []*loginTimeWindow, now time.Time) (allowed bool, remaining int64) {
+	if expirationTime <= 0 && len(windows) == 0 {
+		return true, -1
+	}
+
+	var deadline time.Time
+	if expirationTime > 0 {
+		deadline = time.Unix(expirationTime, 0)
+		if !now.Before(deadline) {
+			return false, 0
+		}
+	}
+
+	if len(windows) > 0 {
+		var windowEnd time.Time
+		for _, r := range getLoginTimeRanges(windows, now) {
+			if !now.Before(r.start) && now.Before(r.end) {
+				windowEnd = r.end
+				break
+			}
+		}
+		if windowEnd.IsZero() {
+			return false, 0
+		}
+		if deadline.IsZero() || windowEnd.Before(deadline) {
+			deadline = windowEnd
+		}
+	}
+
+	return true, int64(deadline.Sub(now) / time.Second)
+}
+
+// checkSchedule 按过期时间和允许登录的时间段锁定或解锁账户，账户因此被锁定时结束用户的会话
+func (u *User) checkSchedule(now time.Time, loginedManager *logined.Manager) {
+	u.PropsMu.Lock()
+	windows, err := parseLoginTimeWindows(u.LoginTimeWindows)
+	if err != nil {
+		logger.Warning(err)
+	}
+	allowed, remaining := getAccountScheduleState(u.ExpirationTime, windows, now)
+	u.setPropRemainingTime(remaining)
+
+	var terminate bool
+	switch {
+	case !allowed && !u.Locked:
+		logger.Infof("user %s is not allowed to login now, lock it", u.UserName)
+		err = users.LockedUser(true, u.UserName)
+		if err != nil {
+			logger.Warning("failed to lock user:", err)
+			break
+		}
+		err = users.ExpireUser(true, u.UserName)
+		if err != nil {
+			logger.Warning("failed to expire user:", err)
+		}
+		u.setPropLocked(true)
+		u.setScheduleLocked(true)
+		// 只在进入锁定状态时结束会话一次
+		terminate = true
+	case allowed && u.scheduleLocked:
+		logger.Infof("user %s is allowed to login now, unlock it", u.UserName)
+		err = users.LockedUser(false, u.UserName)
+		if err != nil {
+			logger.Warning("failed to unlock user:", err)
+			break
+		}
+		err = users.ExpireUser(false, u.UserName)
+		if err != nil {
+			logger.Warning("failed to unexpire user:", err)
+		}
+		u.setPropLocked(false)
+		u.setScheduleLocked(false)
+	}
+	u.PropsMu.Unlock()
+
+	if terminate && loginedManager != nil {
+		terminateUserSessions(loginedManager, u.Uid)
+	}
+}
+
+// setScheduleLocked 记录账户是否是因为不在允许登录的时间被锁定的，只解锁这样的账户，需要持有 u.PropsMu
+func (u *User) setScheduleLocked(locked bool) {
+	if u.scheduleLocked == locked {
+		return
+	}
+	u.scheduleLocked = locked
+	err := u.writeUserConfigWithChange(confKeyScheduleLocked, locked)
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+func terminateUserSessions(loginedManager *logined.Manager, uid string) {
+	id, err := strconv.ParseUint(uid, 10, 32)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	err = loginedManager.TerminateUserSessions(uint32(id))
+	if err != nil {
+		logger.Warningf("failed to terminate sessions of user %s: %v", uid, err)
+	}
+}
+
+// scheduleLoop 每分钟检查一次所有用户的过期时间和允许登录的时间段，
+// 需要在 logined manager 注册之后启动，以便结束被锁定用户的会话
+func (m *Manager) scheduleLoop(loginedManager *logined.Manager) {
+	for {
+		now := time.Now()
+		m.checkSchedules(now, loginedManager)
+
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-timer.C:
+		case <-m.scheduleQuit:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (m *Manager) checkSchedules(now time.Time, loginedManager *logined.Manager) {
+	m.usersMapMu.Lock()
+	userList := make([]*User, 0, len(m.usersMap))
+	for _, u := range m.usersMap {
+		userList = append(userList, u)
+	}
+	m.usersMapMu.Unlock()
+
+	for _, u := range userList {
+		u.checkSchedule(now, loginedManager)
+	}
+}
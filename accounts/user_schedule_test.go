@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseLoginTimeWindow(t *testing.T) {
+	window, err := parseLoginTimeWindow("Mon-Fri 08:00-18:30")
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{false, true, true, true, true, true, false}, window.days)
+	assert.Equal(t, 8*60, window.start)
+	assert.Equal(t, 18*60+30, window.end)
+
+	window, err = parseLoginTimeWindow("Fri-Mon,wed 20:00-06:00")
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{true, true, false, true, false, true, true}, window.days)
+
+	window, err = parseLoginTimeWindow("* 00:00-24:00")
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{true, true, true, true, true, true, true}, window.days)
+	assert.Equal(t, 24*60, window.end)
+
+	for _, str := range []string{
+		"",
+		"Mon-Fri",
+		"Mon-Fri 08:00",
+		"Monday 08:00-18:00",
+		"Mon 8-18",
+		"Mon 08:60-18:00",
+		"Mon 24:00-08:00",
+		"Mon 08:00-24:30",
+	} {
+		_, err = parseLoginTimeWindow(str)
+		assert.Error(t, err, str)
+	}
+}
+
+func Test_getAccountScheduleState(t *testing.T) {
+	// 2022-06-15 是星期三
+	now := time.Date(2022, 6, 15, 10, 0, 0, 0, time.Local)
+
+	allowed, remaining := getAccountScheduleState(0, nil, now)
+	assert.True(t, allowed)
+	assert.Equal(t, int64(-1), remaining)
+
+	allowed, remaining = getAccountScheduleState(now.Add(time.Hour).Unix(), nil, now)
+	assert.True(t, allowed)
+	assert.Equal(t, int64(3600), remaining)
+
+	allowed, remaining = getAccountScheduleState(now.Unix(), nil, now)
+	assert.False(t, allowed)
+	assert.Equal(t, int64(0), remaining)
+
+	windows, err := parseLoginTimeWindows([]string{"Mon-Fri 08:00-12:00"})
+	require.NoError(t, err)
+	allowed, remaining = getAccountScheduleState(0, windows, now)
+	assert.True(t, allowed)
+	assert.Equal(t, int64(2*3600), remaining)
+
+	// 过期时间早于时间段结束
+	allowed, remaining = getAccountScheduleState(now.Add(time.Minute).Unix(), windows, now)
+	assert.True(t, allowed)
+	assert.Equal(t, int64(60), remaining)
+
+	allowed, _ = getAccountScheduleState(0, windows, now.Add(3*time.Hour))
+	assert.False(t, allowed)
+	allowed, _ = getAccountScheduleState(0, windows, now.Add(-48*time.Hour+time.Hour)) // 星期一 11:00
+	assert.True(t, allowed)
+	allowed, _ = getAccountScheduleState(0, windows, now.Add(72*time.Hour)) // 星期六
+	assert.False(t, allowed)
+
+	// 跨午夜的时间段，相连的时间段会被合并
+	windows, err = parseLoginTimeWindows([]string{"Tue 20:00-06:00", "Wed 06:00-11:00"})
+	require.NoError(t, err)
+	allowed, remaining = getAccountScheduleState(0, windows, now.Add(-8*time.Hour)) // 星期三 02:00
+	assert.True(t, allowed)
+	assert.Equal(t, int64(9*3600), remaining)
+	allowed, _ = getAccountScheduleState(0, windows, now.Add(-24*time.Hour)) // 星期二 10:00
+	assert.False(t, allowed)
+}
@@ -54,6 +54,14 @@ char *mkpasswd(const char *words) {
     return password;
 }
 
+int verify_passwd(const char *words, const char *hash) {
+    char *password = crypt(words, hash);
+    if (!password) {
+        return 0;
+    }
+    return strcmp(password, hash) == 0;
+}
+
 int lock_shadow_file() {
     return lckpwdf();
 }
@@ -53,6 +53,19 @@ func EncodePasswd(words string) string {
 	return C.GoString(C.mkpasswd(cwords))
 }
 
+// VerifyPasswd 判断明文密码 words 加密后是否与 hash 一致
+func VerifyPasswd(words, hash string) bool {
+	if words == "" || hash == "" {
+		return false
+	}
+	cwords := C.CString(words)
+	defer C.free(unsafe.Pointer(cwords))
+	chash := C.CString(hash)
+	defer C.free(unsafe.Pointer(chash))
+
+	return C.verify_passwd(cwords, chash) == 1
+}
+
 func ExistPwUid(uid uint32) int {
 	return int(C.exist_pw_uid(C.uint(uid)))
 }
@@ -21,6 +21,7 @@
 #define __PASSWORD_H__
 
 char *mkpasswd(const char *words);
+int verify_passwd(const char *words, const char *hash);
 
 int lock_shadow_file();
 int unlock_shadow_file();
@@ -0,0 +1,134 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package users
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 与 pam_pwhistory 共用的历史密码文件，每行的格式为：用户名:uid:数量:旧密码1,旧密码2，越靠后越新
+var userFilePasswdHistory = "/etc/security/opasswd"
+
+func parsePasswdHistory(content []byte, username string) []string {
+	for _, line := range strings.Split(string(content), "\n") {
+		items := strings.SplitN(line, ":", 4)
+		if len(items) != 4 || items[0] != username {
+			continue
+		}
+		if items[3] == "" {
+			return nil
+		}
+		return strings.Split(items[3], ",")
+	}
+	return nil
+}
+
+// addPasswdHistory 把 hash 追加到用户的历史密码中，最多保留 max 个
+func addPasswdHistory(content []byte, username, uid, hash string, max int) []byte {
+	var lines []string
+	var found bool
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		items := strings.SplitN(line, ":", 4)
+		if len(items) != 4 || items[0] != username {
+			lines = append(lines, line)
+			continue
+		}
+		found = true
+		var hashes []string
+		if items[3] != "" {
+			hashes = strings.Split(items[3], ",")
+		}
+		hashes = append(hashes, hash)
+		if len(hashes) > max {
+			hashes = hashes[len(hashes)-max:]
+		}
+		lines = append(lines, fmt.Sprintf("%s:%s:%d:%s", username, items[1], len(hashes), strings.Join(hashes, ",")))
+	}
+	if !found {
+		lines = append(lines, fmt.Sprintf("%s:%s:1:%s", username, uid, hash))
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// getUsablePasswd 返回用户当前加密后的密码，没有密码或已被锁定时返回空
+func getUsablePasswd(username string) string {
+	info, err := getSpwd(username)
+	if err != nil {
+		return ""
+	}
+	hash := info.ShadowPwdp
+	if hash == "" || hash[0] == '!' || hash[0] == '*' {
+		return ""
+	}
+	return hash
+}
+
+// SavePasswdHistory 修改密码前把用户当前的密码保存到历史密码中，最多保留 max 个
+func SavePasswdHistory(username, uid string, max int) error {
+	hash := getUsablePasswd(username)
+	if hash == "" || max <= 0 {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(userFilePasswdHistory)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content = addPasswdHistory(content, username, uid, hash, max)
+
+	err = os.MkdirAll(filepath.Dir(userFilePasswdHistory), 0755)
+	if err != nil {
+		return err
+	}
+	tmpFile := userFilePasswdHistory + ".tmp"
+	err = ioutil.WriteFile(tmpFile, content, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, userFilePasswdHistory)
+}
+
+// IsPasswdUsedBefore 判断明文密码 words 是否为用户当前的密码或最近使用过的密码，
+// 包括当前密码在内共检查 count 个
+func IsPasswdUsedBefore(username, words string, count int) bool {
+	if count <= 0 {
+		return false
+	}
+	hashes := []string{getUsablePasswd(username)}
+	content, err := ioutil.ReadFile(userFilePasswdHistory)
+	if err == nil {
+		history := parsePasswdHistory(content, username)
+		if len(history) > count-1 {
+			history = history[len(history)-(count-1):]
+		}
+		hashes = append(hashes, history...)
+	}
+	for _, hash := range hashes {
+		if VerifyPasswd(words, hash) {
+			return true
+		}
+	}
+	return false
+}
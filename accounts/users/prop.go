@@ -202,6 +202,19 @@ func ModifyMaxPasswordAge(username string, nDays int) error {
 	return doAction(cmdChAge, []string{"-M", strconv.Itoa(nDays), username})
 }
 
+// ExpireUser 设置账户在 shadow 中过期或取消过期，usermod -L 只锁定密码，
+// 过期后 ssh 密钥等不使用密码的登录方式也会被拒绝。
+// shadow(5) 中 0 可能被当作不过期，所以使用 1。
+func ExpireUser(expired bool, username string) error {
+	var days string
+	if expired {
+		days = "1"
+	} else {
+		days = "-1"
+	}
+	return doAction(cmdChAge, []string{"-E", days, username})
+}
+
 const (
 	// Same as the abbreviation in `passwd --status`
 	PasswordStatusUsable     = "P"
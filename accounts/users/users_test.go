@@ -340,3 +340,19 @@ func Test_IsPasswordExpired(t *testing.T) {
 		assert.Equal(t, isPasswordExpired(testCase.shadowInfo, testCase.today), testCase.result)
 	}
 }
+
+func Test_PasswdHistory(t *testing.T) {
+	content := []byte("test1:1000:2:$6$a$old1,$6$b$old2\ntest2:1001:1:$6$c$old3\n")
+	assert.Equal(t, []string{"$6$a$old1", "$6$b$old2"}, parsePasswdHistory(content, "test1"))
+	assert.Nil(t, parsePasswdHistory(content, "test3"))
+
+	content = addPasswdHistory(content, "test1", "1000", "$6$d$new", 2)
+	assert.Equal(t, []string{"$6$b$old2", "$6$d$new"}, parsePasswdHistory(content, "test1"))
+	assert.Equal(t, []string{"$6$c$old3"}, parsePasswdHistory(content, "test2"))
+
+	content = addPasswdHistory(content, "test3", "1002", "$6$e$new", 2)
+	assert.Equal(t, "test1:1000:2:$6$b$old2,$6$d$new\ntest2:1001:1:$6$c$old3\ntest3:1002:1:$6$e$new\n", string(content))
+
+	content = addPasswdHistory(nil, "test1", "1000", "$6$f$new", 5)
+	assert.Equal(t, "test1:1000:1:$6$f$new\n", string(content))
+}
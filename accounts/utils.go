@@ -34,11 +34,12 @@ import (
 	"time"
 
 	"github.com/godbus/dbus"
+	"github.com/linuxdeepin/dde-daemon/accounts/checkers"
+	"github.com/linuxdeepin/dde-daemon/accounts/users"
 	polkit "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.policykit1"
 	"github.com/linuxdeepin/go-lib/encoding/kv"
 	"github.com/linuxdeepin/go-lib/graphic"
 	"github.com/linuxdeepin/go-lib/utils"
-	"github.com/linuxdeepin/dde-daemon/accounts/users"
 )
 
 // #nosec G101
@@ -58,6 +59,8 @@ const (
 	layoutDelimiter   = ";"
 	defaultLayout     = "us" + layoutDelimiter
 	defaultLayoutFile = "/etc/default/keyboard"
+
+	passwordPolicyFile = "/etc/deepin/dde-daemon/password-policy.conf"
 )
 
 type ErrCodeType int32
@@ -139,6 +142,18 @@ func isStrvEqual(l1, l2 []string) bool {
 	return true
 }
 
+// loadPasswordPolicy 加载管理员配置的密码策略，没有配置时返回 nil
+func loadPasswordPolicy() *checkers.PasswordPolicy {
+	policy, err := checkers.LoadPasswordPolicy(passwordPolicyFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning("failed to load password policy:", err)
+		}
+		return nil
+	}
+	return policy
+}
+
 func checkAccountType(accountType int) error {
 	switch accountType {
 	case users.UserTypeStandard, users.UserTypeAdmin:
@@ -0,0 +1,191 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+)
+
+var errInvalidAppId = errors.New("invalid app id")
+
+func (a *Audio) initAppRules() {
+	a.appRules = NewAppRuleKeeper(globalAppRuleKeeperFile)
+	err := a.appRules.Load()
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning("failed to load app rules:", err)
+	}
+}
+
+func (a *Audio) findSinkIndexByName(name string) (uint32, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for idx, sink := range a.sinks {
+		sink.PropsMu.RLock()
+		sinkName := sink.Name
+		sink.PropsMu.RUnlock()
+		if sinkName == name {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+func (a *Audio) findSourceIndexByName(name string) (uint32, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for idx, source := range a.sources {
+		source.PropsMu.RLock()
+		sourceName := source.Name
+		source.PropsMu.RUnlock()
+		if sourceName == name {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+// getPreferredSinkIndex 返回应用首选的输出设备，设备不存在时返回 false
+func (a *Audio) getPreferredSinkIndex(appIds []string) (uint32, bool) {
+	rule := a.appRules.Match(appIds)
+	if rule == nil || rule.Sink == "" {
+		return 0, false
+	}
+	return a.findSinkIndexByName(rule.Sink)
+}
+
+// filterRoutedSinkInputs 过滤掉首选输出设备存在的 sink-input，它们不跟随默认输出设备
+func (a *Audio) filterRoutedSinkInputs(list []uint32) []uint32 {
+	var result []uint32
+	for _, idx := range list {
+		a.mu.Lock()
+		sinkInput := a.sinkInputs[idx]
+		a.mu.Unlock()
+		if sinkInput != nil {
+			if _, ok := a.getPreferredSinkIndex(sinkInput.appIds); ok {
+				continue
+			}
+		}
+		result = append(result, idx)
+	}
+	return result
+}
+
+// applyAppRuleToSinkInput 把 sink-input 移动到应用首选的输出设备，withVolume 为 true 时同时恢复音量和静音
+func (a *Audio) applyAppRuleToSinkInput(idx uint32, withVolume bool) {
+	a.mu.Lock()
+	sinkInput := a.sinkInputs[idx]
+	a.mu.Unlock()
+	if sinkInput == nil || !sinkInput.visible {
+		return
+	}
+
+	rule := a.appRules.Match(sinkInput.appIds)
+	if rule == nil {
+		return
+	}
+
+	if rule.Sink != "" {
+		sinkIdx, ok := a.findSinkIndexByName(rule.Sink)
+		if ok && sinkIdx != sinkInput.getPropSinkIndex() {
+			logger.Debugf("move sink-input #%d of app %s to sink #%d", idx, rule.App, sinkIdx)
+			a.context().MoveSinkInputsByIndex([]uint32{idx}, sinkIdx)
+		}
+	}
+
+	if !withVolume {
+		return
+	}
+	if rule.Volume != nil && isVolumeValid(*rule.Volume) {
+		sinkInput.PropsMu.RLock()
+		cv := sinkInput.cVolume.SetAvg(*rule.Volume)
+		sinkInput.PropsMu.RUnlock()
+		logger.Debugf("restore volume of sink-input #%d to %v", idx, *rule.Volume)
+		a.context().SetSinkInputVolume(idx, cv)
+	}
+	if rule.Mute != nil {
+		a.context().SetSinkInputMute(idx, *rule.Mute)
+	}
+}
+
+func (a *Audio) applyAppRulesToSinkInputs(withVolume bool) {
+	a.mu.Lock()
+	list := make([]uint32, 0, len(a.sinkInputs))
+	for idx := range a.sinkInputs {
+		list = append(list, idx)
+	}
+	a.mu.Unlock()
+
+	for _, idx := range list {
+		a.applyAppRuleToSinkInput(idx, withVolume)
+	}
+}
+
+// applyAppRulesToSourceOutputs 把录音的流移动到应用首选的输入设备
+func (a *Audio) applyAppRulesToSourceOutputs() {
+	for _, sourceOutput := range a.context().GetSourceOutputList() {
+		rule := a.appRules.Match(getStreamAppIds(sourceOutput.PropList))
+		if rule == nil || rule.Source == "" {
+			continue
+		}
+		sourceIdx, ok := a.findSourceIndexByName(rule.Source)
+		if !ok || sourceIdx == sourceOutput.Source {
+			continue
+		}
+		logger.Debugf("move source-output #%d of app %s to source #%d",
+			sourceOutput.Index, rule.App, sourceIdx)
+		a.context().MoveSourceOutputsByIndex([]uint32{sourceOutput.Index}, sourceIdx)
+	}
+}
+
+// SetAppDevices 设置应用首选的输出和输入设备，参数为 sink 和 source 的名称，为空表示跟随默认设备
+func (a *Audio) SetAppDevices(app, sinkName, sourceName string) *dbus.Error {
+	logger.Debugf("SetAppDevices app: %q, sink: %q, source: %q", app, sinkName, sourceName)
+	err := a.appRules.SetDevices(app, sinkName, sourceName)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	a.applyAppRulesToSinkInputs(false)
+	a.applyAppRulesToSourceOutputs()
+	return nil
+}
+
+// SetAppVolume 设置应用的音量和静音，应用的声音再次出现时恢复。
+// 只有这个接口会记住应用的音量，SinkInput 的 SetVolume 和 SetMute 只修改当前的流。
+func (a *Audio) SetAppVolume(app string, volume float64, mute bool) *dbus.Error {
+	if !isVolumeValid(volume) {
+		return dbusutil.ToError(fmt.Errorf("invalid volume value: %v", volume))
+	}
+	err := a.appRules.SetVolume(app, volume, mute)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	a.applyAppRulesToSinkInputs(true)
+	return nil
+}
+
+// ClearAppRule 删除应用的所有规则
+func (a *Audio) ClearAppRule(app string) *dbus.Error {
+	err := a.appRules.Remove(app)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	// 之前被固定到首选设备的流回到默认设备
+	a.moveSinkInputsToDefaultSink()
+	return nil
+}
+
+// GetAppRules 返回 JSON 格式的应用规则列表
+func (a *Audio) GetAppRules() (rules string, busErr *dbus.Error) {
+	data, err := json.Marshal(a.appRules.List())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,189 @@
+package audio
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/linuxdeepin/go-lib/xdg/basedir"
+)
+
+const (
+	PropAppId = "application.id"
+)
+
+// AppRule 应用的声音规则，应用由 application.process.binary 或 desktop id 标识
+type AppRule struct {
+	App    string
+	Sink   string   `json:",omitempty"` // 首选输出设备的 sink 名称，为空时跟随默认输出
+	Source string   `json:",omitempty"` // 首选输入设备的 source 名称，为空时跟随默认输入
+	Volume *float64 `json:",omitempty"` // 记住的音量
+	Mute   *bool    `json:",omitempty"` // 记住的静音状态
+}
+
+func (r *AppRule) isEmpty() bool {
+	return r.Sink == "" && r.Source == "" && r.Volume == nil && r.Mute == nil
+}
+
+type AppRuleKeeper struct {
+	mu    sync.Mutex
+	Rules map[string]*AppRule // App => AppRule
+	file  string
+}
+
+var globalAppRuleKeeperFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/audio-app-rules.json")
+
+func NewAppRuleKeeper(path string) *AppRuleKeeper {
+	return &AppRuleKeeper{
+		Rules: make(map[string]*AppRule),
+		file:  path,
+	}
+}
+
+// normalizeAppId 统一应用标识的写法，
+// 比如 /usr/share/applications/firefox.desktop、firefox.desktop 和 Firefox 都得到 firefox。
+func normalizeAppId(app string) string {
+	app = strings.TrimSpace(app)
+	if app == "" {
+		return ""
+	}
+	app = filepath.Base(app)
+	app = strings.TrimSuffix(app, ".desktop")
+	return strings.ToLower(app)
+}
+
+// getStreamAppIds 从流的属性中获取应用标识
+func getStreamAppIds(propList map[string]string) []string {
+	var ids []string
+	for _, key := range []string{PropAppProcessBinary, PropAppId} {
+		id := normalizeAppId(propList[key])
+		if id == "" {
+			continue
+		}
+		if len(ids) > 0 && ids[0] == id {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (k *AppRuleKeeper) Load() error {
+	data, err := ioutil.ReadFile(k.file)
+	if err != nil {
+		return err
+	}
+
+	var rules map[string]*AppRule
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Rules = make(map[string]*AppRule, len(rules))
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		rule.App = normalizeAppId(rule.App)
+		if rule.App == "" {
+			continue
+		}
+		k.Rules[rule.App] = rule
+	}
+	return nil
+}
+
+func (k *AppRuleKeeper) save() error {
+	data, err := json.MarshalIndent(k.Rules, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(k.file), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(k.file, data, 0644)
+}
+
+// update 修改应用的规则并保存，规则为空时删除
+func (k *AppRuleKeeper) update(app string, fn func(rule *AppRule)) error {
+	app = normalizeAppId(app)
+	if app == "" {
+		return errInvalidAppId
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	rule, ok := k.Rules[app]
+	if !ok {
+		rule = &AppRule{App: app}
+	}
+	fn(rule)
+	if rule.isEmpty() {
+		delete(k.Rules, app)
+	} else {
+		k.Rules[app] = rule
+	}
+	return k.save()
+}
+
+func (k *AppRuleKeeper) SetDevices(app, sink, source string) error {
+	return k.update(app, func(rule *AppRule) {
+		rule.Sink = sink
+		rule.Source = source
+	})
+}
+
+func (k *AppRuleKeeper) SetVolume(app string, volume float64, mute bool) error {
+	return k.update(app, func(rule *AppRule) {
+		rule.Volume = &volume
+		rule.Mute = &mute
+	})
+}
+
+func (k *AppRuleKeeper) Remove(app string) error {
+	app = normalizeAppId(app)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.Rules[app]; !ok {
+		return nil
+	}
+	delete(k.Rules, app)
+	return k.save()
+}
+
+// Match 返回与应用标识匹配的规则的副本，靠前的标识优先
+func (k *AppRuleKeeper) Match(ids []string) *AppRule {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, id := range ids {
+		rule, ok := k.Rules[id]
+		if ok {
+			ruleCopy := *rule
+			return &ruleCopy
+		}
+	}
+	return nil
+}
+
+func (k *AppRuleKeeper) List() []*AppRule {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	result := make([]*AppRule, 0, len(k.Rules))
+	for _, rule := range k.Rules {
+		ruleCopy := *rule
+		result = append(result, &ruleCopy)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].App < result[j].App
+	})
+	return result
+}
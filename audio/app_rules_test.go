@@ -0,0 +1,64 @@
+package audio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_normalizeAppId(t *testing.T) {
+	assert.Equal(t, "", normalizeAppId(" "))
+	assert.Equal(t, "firefox", normalizeAppId("Firefox"))
+	assert.Equal(t, "firefox", normalizeAppId("firefox.desktop"))
+	assert.Equal(t, "firefox", normalizeAppId("/usr/share/applications/firefox.desktop"))
+}
+
+func Test_getStreamAppIds(t *testing.T) {
+	assert.Equal(t, []string{"firefox"}, getStreamAppIds(map[string]string{
+		PropAppProcessBinary: "firefox",
+		PropAppId:            "Firefox",
+	}))
+	assert.Equal(t, []string{"deepin-music", "org.deepin.music"}, getStreamAppIds(map[string]string{
+		PropAppProcessBinary: "deepin-music",
+		PropAppId:            "org.deepin.music.desktop",
+	}))
+	assert.Nil(t, getStreamAppIds(map[string]string{}))
+}
+
+func TestAppRuleKeeper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audio-app-rules")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "sub/audio-app-rules.json")
+	k := NewAppRuleKeeper(file)
+	assert.Equal(t, errInvalidAppId, k.SetDevices("", "sink", ""))
+
+	require.NoError(t, k.SetDevices("Firefox.desktop", "hdmi-sink", ""))
+	require.NoError(t, k.SetVolume("deepin-music", 0.5, true))
+
+	rule := k.Match([]string{"firefox"})
+	require.NotNil(t, rule)
+	assert.Equal(t, "hdmi-sink", rule.Sink)
+	assert.Nil(t, rule.Volume)
+	assert.Nil(t, k.Match([]string{"vlc"}))
+
+	k1 := NewAppRuleKeeper(file)
+	require.NoError(t, k1.Load())
+	rules := k1.List()
+	require.Len(t, rules, 2)
+	assert.Equal(t, "deepin-music", rules[0].App)
+	assert.Equal(t, 0.5, *rules[0].Volume)
+	assert.True(t, *rules[0].Mute)
+	assert.Equal(t, "firefox", rules[1].App)
+
+	// 规则为空时删除
+	require.NoError(t, k1.SetDevices("firefox", "", ""))
+	assert.Nil(t, k1.Match([]string{"firefox"}))
+	require.NoError(t, k1.Remove("deepin-music"))
+	assert.Len(t, k1.List(), 0)
+}
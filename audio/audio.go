@@ -162,6 +162,15 @@ type Audio struct {
 	syncConfig     *dsync.Config
 	sessionSigLoop *dbusutil.SignalLoop
 
+	// 应用的首选设备和记住的音量
+	appRules *AppRuleKeeper
+
+	// 保存的音频场景
+	scenes *SceneKeeper
+
+	// 通话时降低或暂停其他声音
+	ducker *ducker
+
 	noRestartPulseAudio bool
 
 	// 当前输入端口
@@ -212,6 +221,9 @@ func newAudio(service *dbusutil.Service) *Audio {
 	}
 	gMaxUIVolume = a.MaxUIVolume
 	a.listenGSettingVolumeIncreaseChanged()
+	a.initAppRules()
+	a.initScenes()
+	a.initDucking()
 	a.sessionSigLoop = dbusutil.NewSignalLoop(service.Conn(), 10)
 	a.syncConfig = dsync.NewConfig("audio", &syncConfig{a: a},
 		a.sessionSigLoop, dbusPath, logger)
@@ -576,6 +588,9 @@ func (a *Audio) init() error {
 	GetPriorityManager().Init(a.cards)
 	GetPriorityManager().Print()
 
+	// 上次退出时可能有 sink-input 的音量还没有恢复
+	a.restoreDuckedVolumes()
+
 	go a.handleEvent()
 	go a.handleStateChanged()
 	logger.Debug("init done")
@@ -1011,6 +1026,8 @@ func (a *Audio) moveSinkInputsToSink(sinkId uint32) {
 		list = append(list, sinkInput.index)
 	}
 	a.mu.Unlock()
+	// 有首选输出设备的应用不跟随默认输出设备
+	list = a.filterRoutedSinkInputs(list)
 	if len(list) == 0 {
 		return
 	}
@@ -72,6 +72,8 @@ func (a *Audio) dispatchEvents(events []*pulse.Event) {
 			a.saveConfig()
 		case pulse.FacilitySinkInput:
 			a.handleSinkInputEvent(event.Type, event.Index)
+		case pulse.FacilitySourceOutput:
+			a.handleSourceOutputEvent(event.Type, event.Index)
 		}
 	}
 	logger.Debug("dispatch events done")
@@ -296,6 +298,11 @@ func (a *Audio) handleCardEvent(eventType int, idx uint32) {
 
 	// 触发自动切换
 	a.autoSwitchPort()
+
+	// 应用与新声卡关联的场景
+	if eventType == pulse.EventTypeNew {
+		a.autoApplyScene(idx)
+	}
 }
 
 func (a *Audio) handleCardAdded(idx uint32) {
@@ -360,6 +367,8 @@ func (a *Audio) handleSinkEvent(eventType int, idx uint32) {
 func (a *Audio) handleSinkAdded(idx uint32) {
 	// 数据更新在refreshSinks中统一处理，这里只做业务逻辑上的响应
 	logger.Debugf("sink %d added", idx)
+	// 应用首选的输出设备重新出现
+	a.applyAppRulesToSinkInputs(false)
 }
 
 func (a *Audio) handleSinkRemoved(idx uint32) {
@@ -404,6 +413,8 @@ func (a *Audio) handleSourceEvent(eventType int, idx uint32) {
 func (a *Audio) handleSourceAdded(idx uint32) {
 	// 数据更新在refreshSources中统一处理，这里只做业务逻辑上的响应
 	logger.Debugf("source %d added", idx)
+	// 应用首选的输入设备重新出现
+	a.applyAppRulesToSourceOutputs()
 }
 
 func (a *Audio) handleSourceRemoved(idx uint32) {
@@ -435,12 +446,16 @@ func (a *Audio) handleSinkInputEvent(eventType int, idx uint32) {
 func (a *Audio) handleSinkInputAdded(idx uint32) {
 	// 数据更新在refreshSinkInputs中统一处理，这里只做业务逻辑上的响应
 	logger.Debugf("sink-input %d added", idx)
+	a.applyAppRuleToSinkInput(idx, true)
+	a.restoreDuckedVolume(idx)
+	a.updateDucking()
 }
 
 func (a *Audio) handleSinkInputRemoved(idx uint32) {
 	// 数据更新在refreshSinkInputs中统一处理，这里只做业务逻辑上的响应
 	// 注意，此时idx已经失效了，无法获取已经失去的数据，如果业务需要，应当在refresh前进行数据备份
 	logger.Debugf("sink-input %d removed", idx)
+	a.updateDucking()
 }
 
 func (a *Audio) handleSinkInputChanged(idx uint32) {
@@ -448,6 +463,13 @@ func (a *Audio) handleSinkInputChanged(idx uint32) {
 	logger.Debugf("sink-input %d changed", idx)
 }
 
+func (a *Audio) handleSourceOutputEvent(eventType int, idx uint32) {
+	if eventType == pulse.EventTypeNew {
+		logger.Debugf("source-output %d added", idx)
+		a.applyAppRulesToSourceOutputs()
+	}
+}
+
 /* 创建开启端口的命令，提供给notification调用 */
 func makeNotifyCmdEnablePort(cardId uint32, portName string) string {
 	dest := "com.deepin.daemon.Audio"
@@ -0,0 +1,467 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/go-lib/pulse"
+	"github.com/linuxdeepin/go-lib/xdg/basedir"
+)
+
+// 通话时其他声音的处理方式
+const (
+	DuckingModeNone  = "none"  // 不处理
+	DuckingModeLower = "lower" // 降低其他声音的音量
+	DuckingModePause = "pause" // 通过 MPRIS 暂停正在播放的播放器
+)
+
+const (
+	mediaRolePhone      = "phone"
+	defaultDuckingLevel = 0.3
+)
+
+// 常见的通话、会议应用，它们的声音不一定带有 media.role=phone 属性
+var defaultCommunicationApps = []string{
+	"linphone",
+	"skypeforlinux",
+	"zoom",
+	"teams",
+	"wemeetapp",
+	"dingtalk",
+	"com.alibabainc.dingtalk",
+}
+
+var errInvalidDuckingMode = errors.New("invalid ducking mode")
+
+// DuckingPolicy 通话时其他声音的处理策略
+type DuckingPolicy struct {
+	Mode  string
+	Level float64  // Mode 为 lower 时其他声音的音量变为原来的 Level 倍
+	Apps  []string `json:",omitempty"` // 额外被当作通话的应用
+}
+
+func (p *DuckingPolicy) isCommunicationApp(appIds []string) bool {
+	for _, id := range appIds {
+		for _, app := range defaultCommunicationApps {
+			if id == app {
+				return true
+			}
+		}
+		for _, app := range p.Apps {
+			if id == normalizeAppId(app) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCommunicationStream 判断流是否是通话的声音
+func (p *DuckingPolicy) isCommunicationStream(mediaRole string, appIds []string) bool {
+	return mediaRole == mediaRolePhone || p.isCommunicationApp(appIds)
+}
+
+func (p *DuckingPolicy) check() error {
+	switch p.Mode {
+	case DuckingModeNone, DuckingModeLower, DuckingModePause:
+	default:
+		return errInvalidDuckingMode
+	}
+	if p.Level < 0 || p.Level > 1 {
+		return fmt.Errorf("invalid ducking level: %v", p.Level)
+	}
+	return nil
+}
+
+var globalDuckingPolicyFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/audio-ducking.json")
+
+// 被降低音量的应用原来的音量，降低音量前保存。module-stream-restore 会记住降低后的音量，
+// 流在通话中结束或者本程序中途退出时，根据它在应用的流再次出现时恢复音量。
+var globalDuckingVolumesFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/audio-ducking-volumes.json")
+
+func loadDuckingPolicy(file string) (*DuckingPolicy, error) {
+	policy := &DuckingPolicy{
+		Mode:  DuckingModeLower,
+		Level: defaultDuckingLevel,
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return policy, err
+	}
+	var p DuckingPolicy
+	err = json.Unmarshal(data, &p)
+	if err != nil {
+		return policy, err
+	}
+	err = p.check()
+	if err != nil {
+		return policy, err
+	}
+	return &p, nil
+}
+
+func loadDuckingVolumes(file string) (map[string]float64, error) {
+	volumes := make(map[string]float64)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return volumes, err
+	}
+	err = json.Unmarshal(data, &volumes)
+	if err != nil {
+		return make(map[string]float64), err
+	}
+	for app, volume := range volumes {
+		if volume < 0 {
+			delete(volumes, app)
+		}
+	}
+	return volumes, nil
+}
+
+// saveDuckingVolumes 保存应用原来的音量，没有需要恢复的音量时删除文件
+func saveDuckingVolumes(file string, volumes map[string]float64) error {
+	if len(volumes) == 0 {
+		err := os.Remove(file)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(volumes, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+func saveDuckingPolicy(file string, policy *DuckingPolicy) error {
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// duckedVolume 返回降低后的音量
+func duckedVolume(volume, level float64) float64 {
+	v := floatPrecision(volume * level)
+	if v == 0 {
+		// 音量为 0 会被当作静音
+		v = 0.001
+	}
+	return v
+}
+
+// isVolumeChangedByUser 判断降低音量后用户是否又调整过音量，调整过就不再恢复
+func isVolumeChangedByUser(current, ducked float64) bool {
+	return math.Abs(current-ducked) > 0.01
+}
+
+// duckedSinkInput 被降低音量的 sink-input
+type duckedSinkInput struct {
+	app    string  // 应用标识，为空时无法在流结束后恢复音量
+	volume float64 // 原来的音量
+	ducked float64 // 降低后的音量
+}
+
+type ducker struct {
+	mu          sync.Mutex
+	policy      *DuckingPolicy
+	file        string
+	active      bool
+	mode        string                      // 开始时的处理方式，策略中途修改时按它恢复
+	sinkInputs  map[uint32]*duckedSinkInput // 被降低音量的 sink-input
+	players     []string                    // 被暂停的播放器
+	volumes     map[string]float64          // 应用 => 降低前的音量，需要在应用的流再次出现时恢复
+	volumesFile string
+}
+
+func (a *Audio) initDucking() {
+	policy, err := loadDuckingPolicy(globalDuckingPolicyFile)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning("failed to load ducking policy:", err)
+	}
+	volumes, err := loadDuckingVolumes(globalDuckingVolumesFile)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning("failed to load ducking volumes:", err)
+	}
+	a.ducker = &ducker{
+		policy:      policy,
+		file:        globalDuckingPolicyFile,
+		volumes:     volumes,
+		volumesFile: globalDuckingVolumesFile,
+	}
+}
+
+// saveVolumes 需要持有 d.mu
+func (d *ducker) saveVolumes() {
+	err := saveDuckingVolumes(d.volumesFile, d.volumes)
+	if err != nil {
+		logger.Warning("failed to save ducking volumes:", err)
+	}
+}
+
+func getDuckingApp(appIds []string) string {
+	if len(appIds) == 0 {
+		return ""
+	}
+	return appIds[0]
+}
+
+// restoreDuckedVolume 恢复新出现的 sink-input 被 module-stream-restore 记住的降低后的音量
+func (a *Audio) restoreDuckedVolume(idx uint32) {
+	a.mu.Lock()
+	sinkInput := a.sinkInputs[idx]
+	a.mu.Unlock()
+	if sinkInput == nil {
+		return
+	}
+
+	d := a.ducker
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.active && d.mode == DuckingModeLower {
+		// 由 lowerSinkInputs 按原来的音量降低，结束时恢复
+		return
+	}
+	app := getDuckingApp(sinkInput.appIds)
+	volume, ok := d.volumes[app]
+	if !ok {
+		return
+	}
+	delete(d.volumes, app)
+	d.saveVolumes()
+
+	// 应用记住的音量已经由 applyAppRuleToSinkInput 恢复
+	rule := a.appRules.Match(sinkInput.appIds)
+	if rule != nil && rule.Volume != nil {
+		return
+	}
+	sinkInput.PropsMu.RLock()
+	cv := sinkInput.cVolume.SetAvg(volume)
+	sinkInput.PropsMu.RUnlock()
+	logger.Debugf("restore ducked volume of sink-input #%d of app %s to %v", idx, app, volume)
+	a.context().SetSinkInputVolume(idx, cv)
+}
+
+// restoreDuckedVolumes 处理上次退出时仍然被降低音量的 sink-input，
+// 仍在通话时按原来的音量重新降低，否则恢复原来的音量
+func (a *Audio) restoreDuckedVolumes() {
+	a.updateDucking()
+
+	a.mu.Lock()
+	list := make([]uint32, 0, len(a.sinkInputs))
+	for idx := range a.sinkInputs {
+		list = append(list, idx)
+	}
+	a.mu.Unlock()
+
+	for _, idx := range list {
+		a.restoreDuckedVolume(idx)
+	}
+}
+
+// hasCommunicationStream 当前是否有通话的声音
+func (a *Audio) hasCommunicationStream(policy *DuckingPolicy) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, sinkInput := range a.sinkInputs {
+		if policy.isCommunicationStream(sinkInput.mediaRole, sinkInput.appIds) {
+			return true
+		}
+	}
+	return false
+}
+
+// getDuckableSinkInputs 返回需要被降低音量的 sink-input
+func (a *Audio) getDuckableSinkInputs(policy *DuckingPolicy) []*SinkInput {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var result []*SinkInput
+	for _, sinkInput := range a.sinkInputs {
+		if !sinkInput.visible || policy.isCommunicationStream(sinkInput.mediaRole, sinkInput.appIds) {
+			continue
+		}
+		result = append(result, sinkInput)
+	}
+	return result
+}
+
+// updateDucking 在 sink-input 增删后根据是否有通话降低或恢复其他声音
+func (a *Audio) updateDucking() {
+	d := a.ducker
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	calling := d.policy.Mode != DuckingModeNone && a.hasCommunicationStream(d.policy)
+	switch {
+	case calling && !d.active:
+		a.startDucking()
+	case calling && d.active && d.mode == DuckingModeLower:
+		// 通话过程中新出现的声音也要降低
+		a.lowerSinkInputs()
+	case !calling && d.active:
+		a.stopDucking()
+	}
+}
+
+// startDucking 需要持有 d.mu
+func (a *Audio) startDucking() {
+	d := a.ducker
+	logger.Debugf("communication stream appeared, ducking mode: %s", d.policy.Mode)
+	d.active = true
+	d.mode = d.policy.Mode
+	switch d.mode {
+	case DuckingModeLower:
+		d.sinkInputs = make(map[uint32]*duckedSinkInput)
+		a.lowerSinkInputs()
+	case DuckingModePause:
+		d.players = pausePlayingPlayers()
+	}
+}
+
+// lowerSinkInputs 需要持有 d.mu
+func (a *Audio) lowerSinkInputs() {
+	d := a.ducker
+	var list []*SinkInput
+	for _, sinkInput := range a.getDuckableSinkInputs(d.policy) {
+		if _, ok := d.sinkInputs[sinkInput.index]; ok {
+			continue
+		}
+
+		app := getDuckingApp(sinkInput.appIds)
+		sinkInput.PropsMu.RLock()
+		volume := sinkInput.Volume
+		sinkInput.PropsMu.RUnlock()
+		// 新出现的 sink-input 可能正在恢复记住的音量
+		if v, ok := d.volumes[app]; ok {
+			volume = v
+		}
+		rule := a.appRules.Match(sinkInput.appIds)
+		if rule != nil && rule.Volume != nil {
+			volume = *rule.Volume
+		}
+
+		d.sinkInputs[sinkInput.index] = &duckedSinkInput{
+			app:    app,
+			volume: volume,
+			ducked: duckedVolume(volume, d.policy.Level),
+		}
+		if app != "" {
+			d.volumes[app] = volume
+		}
+		list = append(list, sinkInput)
+	}
+	if len(list) == 0 {
+		return
+	}
+	// 先保存原来的音量，避免降低音量后本程序退出导致无法恢复
+	d.saveVolumes()
+
+	for _, sinkInput := range list {
+		info := d.sinkInputs[sinkInput.index]
+		sinkInput.PropsMu.RLock()
+		cv := sinkInput.cVolume.SetAvg(info.ducked)
+		sinkInput.PropsMu.RUnlock()
+		logger.Debugf("lower volume of sink-input #%d from %v to %v", sinkInput.index, info.volume, info.ducked)
+		a.context().SetSinkInputVolume(sinkInput.index, cv)
+	}
+}
+
+// stopDucking 需要持有 d.mu
+func (a *Audio) stopDucking() {
+	d := a.ducker
+	logger.Debug("communication stream disappeared, stop ducking")
+	switch d.mode {
+	case DuckingModeLower:
+		for idx, info := range d.sinkInputs {
+			a.mu.Lock()
+			sinkInput := a.sinkInputs[idx]
+			a.mu.Unlock()
+			if sinkInput == nil {
+				// 流已经结束，保留 d.volumes 中的音量，在应用的流再次出现时恢复
+				continue
+			}
+			delete(d.volumes, info.app)
+
+			sinkInput.PropsMu.RLock()
+			current := sinkInput.Volume
+			cv := sinkInput.cVolume.SetAvg(info.volume)
+			sinkInput.PropsMu.RUnlock()
+			if isVolumeChangedByUser(current, info.ducked) {
+				continue
+			}
+			logger.Debugf("restore volume of sink-input #%d to %v", idx, info.volume)
+			a.context().SetSinkInputVolume(idx, cv)
+		}
+		d.saveVolumes()
+	case DuckingModePause:
+		resumePlayers(d.players)
+	}
+	d.active = false
+	d.mode = ""
+	d.sinkInputs = nil
+	d.players = nil
+}
+
+// SetDuckingPolicy 设置通话时其他声音的处理策略，mode 为 none、lower 或 pause，
+// level 为 lower 模式下其他声音音量的比例，apps 为额外被当作通话的应用。
+func (a *Audio) SetDuckingPolicy(mode string, level float64, apps []string) *dbus.Error {
+	policy := &DuckingPolicy{
+		Mode:  mode,
+		Level: level,
+		Apps:  apps,
+	}
+	err := policy.check()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	d := a.ducker
+	d.mu.Lock()
+	err = saveDuckingPolicy(d.file, policy)
+	if err != nil {
+		d.mu.Unlock()
+		return dbusutil.ToError(err)
+	}
+	d.policy = policy
+	if d.active && d.mode != mode {
+		// 先按旧的方式恢复，再按新的方式处理
+		a.stopDucking()
+	}
+	d.mu.Unlock()
+
+	a.updateDucking()
+	return nil
+}
+
+// GetDuckingPolicy 返回 JSON 格式的通话时其他声音的处理策略
+func (a *Audio) GetDuckingPolicy() (policy string, busErr *dbus.Error) {
+	a.ducker.mu.Lock()
+	data, err := json.Marshal(a.ducker.policy)
+	a.ducker.mu.Unlock()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func getSinkInputMediaRole(sinkInputInfo *pulse.SinkInput) string {
+	return sinkInputInfo.PropList[pulse.PA_PROP_MEDIA_ROLE]
+}
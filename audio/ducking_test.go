@@ -0,0 +1,85 @@
+package audio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuckingPolicy_isCommunicationStream(t *testing.T) {
+	p := &DuckingPolicy{Mode: DuckingModeLower, Level: 0.3, Apps: []string{"Telegram.desktop"}}
+	assert.True(t, p.isCommunicationStream("phone", []string{"firefox"}))
+	assert.True(t, p.isCommunicationStream("", []string{"zoom"}))
+	assert.True(t, p.isCommunicationStream("", []string{"telegram-desktop", "telegram"}))
+	assert.False(t, p.isCommunicationStream("music", []string{"deepin-music"}))
+	assert.False(t, p.isCommunicationStream("", nil))
+}
+
+func TestDuckingPolicy_check(t *testing.T) {
+	assert.NoError(t, (&DuckingPolicy{Mode: DuckingModeNone}).check())
+	assert.NoError(t, (&DuckingPolicy{Mode: DuckingModePause, Level: 1}).check())
+	assert.Equal(t, errInvalidDuckingMode, (&DuckingPolicy{Mode: "mute"}).check())
+	assert.Error(t, (&DuckingPolicy{Mode: DuckingModeLower, Level: 1.5}).check())
+}
+
+func Test_duckedVolume(t *testing.T) {
+	assert.Equal(t, 0.3, duckedVolume(1, 0.3))
+	assert.Equal(t, 0.15, duckedVolume(0.5, 0.3))
+	assert.Equal(t, 0.001, duckedVolume(0.5, 0))
+	assert.False(t, isVolumeChangedByUser(0.301, 0.3))
+	assert.True(t, isVolumeChangedByUser(0.5, 0.3))
+}
+
+func TestDuckingPolicyLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audio-ducking")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "sub/audio-ducking.json")
+	policy, err := loadDuckingPolicy(file)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, &DuckingPolicy{Mode: DuckingModeLower, Level: defaultDuckingLevel}, policy)
+
+	p := &DuckingPolicy{Mode: DuckingModePause, Level: 0.5, Apps: []string{"telegram"}}
+	require.NoError(t, saveDuckingPolicy(file, p))
+	policy, err = loadDuckingPolicy(file)
+	require.NoError(t, err)
+	assert.Equal(t, p, policy)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"Mode":"mute"}`), 0644))
+	policy, err = loadDuckingPolicy(file)
+	assert.Equal(t, errInvalidDuckingMode, err)
+	assert.Equal(t, DuckingModeLower, policy.Mode)
+}
+
+func TestDuckingVolumesLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audio-ducking")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "sub/audio-ducking-volumes.json")
+	volumes, err := loadDuckingVolumes(file)
+	assert.True(t, os.IsNotExist(err))
+	assert.Empty(t, volumes)
+
+	volumes = map[string]float64{"deepin-music": 0.8, "firefox": 0.5}
+	require.NoError(t, saveDuckingVolumes(file, volumes))
+	loaded, err := loadDuckingVolumes(file)
+	require.NoError(t, err)
+	assert.Equal(t, volumes, loaded)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"firefox":-1,"vlc":0.3}`), 0644))
+	loaded, err = loadDuckingVolumes(file)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"vlc": 0.3}, loaded)
+
+	// 没有需要恢复的音量时删除文件
+	require.NoError(t, saveDuckingVolumes(file, nil))
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, saveDuckingVolumes(file, nil))
+}
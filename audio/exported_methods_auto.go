@@ -8,12 +8,42 @@ import (
 
 func (v *Audio) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:   "ApplyScene",
+			Fn:     v.ApplyScene,
+			InArgs: []string{"name"},
+		},
+		{
+			Name:   "ClearAppRule",
+			Fn:     v.ClearAppRule,
+			InArgs: []string{"app"},
+		},
+		{
+			Name:   "DeleteScene",
+			Fn:     v.DeleteScene,
+			InArgs: []string{"name"},
+		},
+		{
+			Name:    "GetAppRules",
+			Fn:      v.GetAppRules,
+			OutArgs: []string{"rules"},
+		},
+		{
+			Name:    "GetDuckingPolicy",
+			Fn:      v.GetDuckingPolicy,
+			OutArgs: []string{"policy"},
+		},
 		{
 			Name:    "IsPortEnabled",
 			Fn:      v.IsPortEnabled,
 			InArgs:  []string{"cardId", "portName"},
 			OutArgs: []string{"enabled"},
 		},
+		{
+			Name:    "ListScenes",
+			Fn:      v.ListScenes,
+			OutArgs: []string{"scenes"},
+		},
 		{
 			Name: "NoRestartPulseAudio",
 			Fn:   v.NoRestartPulseAudio,
@@ -22,11 +52,31 @@ func (v *Audio) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "Reset",
 			Fn:   v.Reset,
 		},
+		{
+			Name:   "SaveScene",
+			Fn:     v.SaveScene,
+			InArgs: []string{"name", "autoApplyCard"},
+		},
+		{
+			Name:   "SetAppDevices",
+			Fn:     v.SetAppDevices,
+			InArgs: []string{"app", "sinkName", "sourceName"},
+		},
+		{
+			Name:   "SetAppVolume",
+			Fn:     v.SetAppVolume,
+			InArgs: []string{"app", "volume", "mute"},
+		},
 		{
 			Name:   "SetBluetoothAudioMode",
 			Fn:     v.SetBluetoothAudioMode,
 			InArgs: []string{"mode"},
 		},
+		{
+			Name:   "SetDuckingPolicy",
+			Fn:     v.SetDuckingPolicy,
+			InArgs: []string{"mode", "level", "apps"},
+		},
 		{
 			Name:   "SetPort",
 			Fn:     v.SetPort,
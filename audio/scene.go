@@ -0,0 +1,334 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/go-lib/pulse"
+	"github.com/linuxdeepin/go-lib/xdg/basedir"
+)
+
+// SceneDevice 场景中的输出或输入设备
+type SceneDevice struct {
+	Card    string // 声卡名称，与 ConfigKeeper 中的一致
+	Port    string
+	Volume  float64
+	Balance float64
+	Mute    bool
+}
+
+// Scene 音频场景，比如“会议”、“音乐”、“演示”，保存了一整套输出输入设置
+type Scene struct {
+	Name          string
+	Output        *SceneDevice `json:",omitempty"`
+	Input         *SceneDevice `json:",omitempty"`
+	ReduceNoise   bool
+	AutoApplyCard string `json:",omitempty"` // 该声卡出现时自动应用此场景
+}
+
+type SceneKeeper struct {
+	mu     sync.Mutex
+	Scenes map[string]*Scene // Name => Scene
+	file   string
+}
+
+var globalSceneKeeperFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/audio-scenes.json")
+
+var errInvalidSceneName = errors.New("invalid scene name")
+
+func NewSceneKeeper(path string) *SceneKeeper {
+	return &SceneKeeper{
+		Scenes: make(map[string]*Scene),
+		file:   path,
+	}
+}
+
+func (k *SceneKeeper) Load() error {
+	data, err := ioutil.ReadFile(k.file)
+	if err != nil {
+		return err
+	}
+
+	var scenes map[string]*Scene
+	err = json.Unmarshal(data, &scenes)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Scenes = make(map[string]*Scene, len(scenes))
+	for _, scene := range scenes {
+		if scene == nil || scene.Name == "" {
+			continue
+		}
+		k.Scenes[scene.Name] = scene
+	}
+	return nil
+}
+
+func (k *SceneKeeper) save() error {
+	data, err := json.MarshalIndent(k.Scenes, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(k.file), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(k.file, data, 0644)
+}
+
+func (k *SceneKeeper) Set(scene *Scene) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Scenes[scene.Name] = scene
+	return k.save()
+}
+
+func (k *SceneKeeper) Get(name string) (*Scene, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	scene, ok := k.Scenes[name]
+	if !ok {
+		return nil, false
+	}
+	sceneCopy := *scene
+	return &sceneCopy, true
+}
+
+func (k *SceneKeeper) Delete(name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.Scenes[name]; !ok {
+		return fmt.Errorf("scene %q not found", name)
+	}
+	delete(k.Scenes, name)
+	return k.save()
+}
+
+func (k *SceneKeeper) List() []*Scene {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	result := make([]*Scene, 0, len(k.Scenes))
+	for _, scene := range k.Scenes {
+		sceneCopy := *scene
+		result = append(result, &sceneCopy)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// FindByCard 返回声卡出现时需要自动应用的场景，有多个时按名称取第一个
+func (k *SceneKeeper) FindByCard(cardName string) *Scene {
+	if cardName == "" {
+		return nil
+	}
+	for _, scene := range k.List() {
+		if scene.AutoApplyCard == cardName {
+			return scene
+		}
+	}
+	return nil
+}
+
+func (a *Audio) initScenes() {
+	a.scenes = NewSceneKeeper(globalSceneKeeperFile)
+	err := a.scenes.Load()
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning("failed to load audio scenes:", err)
+	}
+}
+
+// snapshotScene 把当前的默认输出输入设置保存为场景
+func (a *Audio) snapshotScene(name string) *Scene {
+	a.PropsMu.RLock()
+	reduceNoise := a.ReduceNoise
+	a.PropsMu.RUnlock()
+	scene := &Scene{
+		Name:        name,
+		ReduceNoise: reduceNoise,
+	}
+
+	if sink := a.getDefaultSink(); sink != nil {
+		sink.PropsMu.RLock()
+		scene.Output = &SceneDevice{
+			Card:    a.getCardNameById(sink.Card),
+			Port:    sink.ActivePort.Name,
+			Volume:  sink.Volume,
+			Balance: sink.Balance,
+			Mute:    sink.Mute,
+		}
+		sink.PropsMu.RUnlock()
+	}
+
+	if source := a.getDefaultSource(); source != nil {
+		source.PropsMu.RLock()
+		scene.Input = &SceneDevice{
+			Card:    a.getCardNameById(source.Card),
+			Port:    source.ActivePort.Name,
+			Volume:  source.Volume,
+			Balance: source.Balance,
+			Mute:    source.Mute,
+		}
+		source.PropsMu.RUnlock()
+	}
+	return scene
+}
+
+func (a *Audio) isSceneDeviceActive(dev *SceneDevice, direction int) bool {
+	var cardId uint32
+	var portName string
+	if direction == pulse.DirectionSink {
+		sink := a.getDefaultSink()
+		if sink == nil {
+			return false
+		}
+		sink.PropsMu.RLock()
+		cardId, portName = sink.Card, sink.ActivePort.Name
+		sink.PropsMu.RUnlock()
+	} else {
+		source := a.getDefaultSource()
+		if source == nil {
+			return false
+		}
+		source.PropsMu.RLock()
+		cardId, portName = source.Card, source.ActivePort.Name
+		source.PropsMu.RUnlock()
+	}
+	return portName == dev.Port && a.getCardNameById(cardId) == dev.Card
+}
+
+// applySceneDevice 切换到场景中的端口，音量等设置写入 ConfigKeeper 后由配置恢复的流程生效
+func (a *Audio) applySceneDevice(dev *SceneDevice, direction int) error {
+	a.mu.Lock()
+	card, err := a.cards.getByName(dev.Card)
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	ck := GetConfigKeeper()
+	ck.SetVolume(dev.Card, dev.Port, dev.Volume)
+	ck.SetBalance(dev.Card, dev.Port, dev.Balance)
+	if direction == pulse.DirectionSink {
+		ck.SetMuteOutput(dev.Mute)
+	} else {
+		ck.SetMuteInput(dev.Mute)
+	}
+
+	if a.isSceneDeviceActive(dev, direction) {
+		// 端口没有变化，不会触发配置恢复
+		if direction == pulse.DirectionSink {
+			a.resumeSinkConfig(a.getDefaultSink())
+		} else {
+			source := a.getDefaultSource()
+			a.resumeSourceConfig(source, isPhysicalDevice(a.getDefaultSourceName()))
+		}
+		return nil
+	}
+
+	busErr := a.SetPort(card.Id, dev.Port, int32(direction))
+	if busErr != nil {
+		return busErr
+	}
+	return nil
+}
+
+func (a *Audio) applyScene(scene *Scene) error {
+	logger.Debugf("apply audio scene %q", scene.Name)
+	var errs []string
+	if scene.Output != nil {
+		err := a.applySceneDevice(scene.Output, pulse.DirectionSink)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("output: %v", err))
+		}
+	}
+	if scene.Input != nil {
+		GetConfigKeeper().SetReduceNoise(scene.Input.Card, scene.Input.Port, scene.ReduceNoise)
+		err := a.applySceneDevice(scene.Input, pulse.DirectionSource)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("input: %v", err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to apply scene %q: %s", scene.Name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// autoApplyScene 声卡出现时自动应用关联的场景
+func (a *Audio) autoApplyScene(cardId uint32) {
+	a.mu.Lock()
+	card, err := a.cards.get(cardId)
+	a.mu.Unlock()
+	if err != nil {
+		return
+	}
+	scene := a.scenes.FindByCard(card.core.Name)
+	if scene == nil {
+		return
+	}
+	logger.Infof("card %s appeared, auto apply audio scene %q", card.core.Name, scene.Name)
+	err = a.applyScene(scene)
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+// SaveScene 把当前的输出输入设置保存为名为 name 的场景，同名场景会被覆盖。
+// autoApplyCard 为声卡名称，该声卡出现时自动应用此场景，为空表示不自动应用。
+func (a *Audio) SaveScene(name string, autoApplyCard string) *dbus.Error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return dbusutil.ToError(errInvalidSceneName)
+	}
+	scene := a.snapshotScene(name)
+	scene.AutoApplyCard = autoApplyCard
+	err := a.scenes.Set(scene)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	return nil
+}
+
+func (a *Audio) ApplyScene(name string) *dbus.Error {
+	scene, ok := a.scenes.Get(name)
+	if !ok {
+		return dbusutil.ToError(fmt.Errorf("scene %q not found", name))
+	}
+	err := a.applyScene(scene)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	return nil
+}
+
+// ListScenes 返回 JSON 格式的场景列表
+func (a *Audio) ListScenes() (scenes string, busErr *dbus.Error) {
+	data, err := json.Marshal(a.scenes.List())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (a *Audio) DeleteScene(name string) *dbus.Error {
+	err := a.scenes.Delete(name)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	return nil
+}
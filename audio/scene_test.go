@@ -0,0 +1,64 @@
+package audio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSceneKeeper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audio-scenes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "sub/audio-scenes.json")
+	k := NewSceneKeeper(file)
+	assert.Error(t, k.Delete("Music"))
+
+	meeting := &Scene{
+		Name: "Meeting",
+		Output: &SceneDevice{
+			Card:    "alsa_card.usb-headset",
+			Port:    "analog-output-headphones",
+			Volume:  0.6,
+			Balance: 0,
+		},
+		Input: &SceneDevice{
+			Card:   "alsa_card.usb-headset",
+			Port:   "analog-input-mic",
+			Volume: 0.8,
+		},
+		ReduceNoise:   true,
+		AutoApplyCard: "alsa_card.usb-headset",
+	}
+	require.NoError(t, k.Set(meeting))
+	require.NoError(t, k.Set(&Scene{Name: "Music", Output: &SceneDevice{Card: "alsa_card.pci", Volume: 1}}))
+
+	k1 := NewSceneKeeper(file)
+	require.NoError(t, k1.Load())
+	scenes := k1.List()
+	require.Len(t, scenes, 2)
+	assert.Equal(t, "Meeting", scenes[0].Name)
+	assert.Equal(t, "Music", scenes[1].Name)
+	assert.Nil(t, scenes[1].Input)
+
+	scene, ok := k1.Get("Meeting")
+	require.True(t, ok)
+	assert.Equal(t, meeting, scene)
+
+	assert.Equal(t, "Meeting", k1.FindByCard("alsa_card.usb-headset").Name)
+	assert.Nil(t, k1.FindByCard("alsa_card.pci"))
+	assert.Nil(t, k1.FindByCard(""))
+
+	require.NoError(t, k1.Delete("Meeting"))
+	_, ok = k1.Get("Meeting")
+	assert.False(t, ok)
+
+	k2 := NewSceneKeeper(file)
+	require.NoError(t, k2.Load())
+	assert.Len(t, k2.List(), 1)
+}
@@ -46,6 +46,8 @@ type SinkInput struct {
 	correctIconCalled bool
 	correctedIcon     string
 	visible           bool
+	appIds            []string
+	mediaRole         string
 	cVolume           pulse.CVolume
 	channelMap        pulse.ChannelMap
 	// Name process name
@@ -65,10 +67,12 @@ func newSinkInput(sinkInputInfo *pulse.SinkInput, audio *Audio) *SinkInput {
 		return nil
 	}
 	sinkInput := &SinkInput{
-		audio:   audio,
-		service: audio.service,
-		index:   sinkInputInfo.Index,
-		visible: getSinkInputVisible(sinkInputInfo),
+		audio:     audio,
+		service:   audio.service,
+		index:     sinkInputInfo.Index,
+		visible:   getSinkInputVisible(sinkInputInfo),
+		appIds:    getStreamAppIds(sinkInputInfo.PropList),
+		mediaRole: getSinkInputMediaRole(sinkInputInfo),
 	}
 	sinkInput.update(sinkInputInfo)
 	return sinkInput
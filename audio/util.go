@@ -104,6 +104,58 @@ func pauseAllPlayers() {
 	}
 }
 
+// pausePlayingPlayers 暂停正在播放的播放器，返回被暂停的播放器
+func pausePlayingPlayers() []string {
+	sessionConn, err := dbus.SessionBus()
+	if err != nil {
+		return nil
+	}
+	playerNames, err := getMprisPlayers(sessionConn)
+	if err != nil {
+		logger.Warning("getMprisPlayers failed:", err)
+		return nil
+	}
+
+	var paused []string
+	for _, playerName := range playerNames {
+		player := mpris2.NewMediaPlayer(sessionConn, playerName)
+		status, err := player.Player().PlaybackStatus().Get(0)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		if status != "Playing" {
+			continue
+		}
+		err = player.Player().Pause(0)
+		if err != nil {
+			logger.Warningf("failed to pause player %s: %v", playerName, err)
+			continue
+		}
+		logger.Debug("pause player", playerName)
+		paused = append(paused, playerName)
+	}
+	return paused
+}
+
+// resumePlayers 继续播放之前被暂停的播放器
+func resumePlayers(playerNames []string) {
+	if len(playerNames) == 0 {
+		return
+	}
+	sessionConn, err := dbus.SessionBus()
+	if err != nil {
+		return
+	}
+	for _, playerName := range playerNames {
+		player := mpris2.NewMediaPlayer(sessionConn, playerName)
+		err := player.Player().Play(0)
+		if err != nil {
+			logger.Warningf("failed to resume player %s: %v", playerName, err)
+		}
+	}
+}
+
 // 四舍五入
 func floatPrecision(f float64) float64 {
 	// 精确到小数点后2位
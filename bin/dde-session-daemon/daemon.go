@@ -20,6 +20,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -28,6 +29,7 @@ import (
 	"github.com/godbus/dbus"
 	"github.com/linuxdeepin/dde-api/session"
 	"github.com/linuxdeepin/dde-daemon/calltrace"
+	"github.com/linuxdeepin/dde-daemon/common/dsync"
 	"github.com/linuxdeepin/dde-daemon/loader"
 	"github.com/linuxdeepin/go-gir/glib-2.0"
 	"github.com/linuxdeepin/go-lib/dbusutil"
@@ -323,6 +325,15 @@ func (s *SessionDaemon) CallTrace(times, seconds uint32) *dbus.Error {
 	return nil
 }
 
+// GetStartupReport 以 JSON 格式返回各批次模块启动的耗时报告
+func (s *SessionDaemon) GetStartupReport() (report string, busErr *dbus.Error) {
+	data, err := json.Marshal(loader.GetStartupReports())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
 func (s *SessionDaemon) StartPart2() *dbus.Error {
 	if !hasDDECookie {
 		return dbusutil.ToError(errors.New("env DDE_SESSION_PROCESS_COOKIE_ID is empty"))
@@ -332,6 +343,72 @@ func (s *SessionDaemon) StartPart2() *dbus.Error {
 	return dbusutil.ToError(err)
 }
 
+// RestartModule 重新启动单个模块，用于在不重启整个进程的情况下恢复异常的模块
+func (s *SessionDaemon) RestartModule(name string) *dbus.Error {
+	moduleLocker.Lock()
+	defer moduleLocker.Unlock()
+
+	module := loader.GetModule(name)
+	if module == nil {
+		return dbusutil.ToError(fmt.Errorf("no such a module named %s", name))
+	}
+	if !s.getConfigValue(name) {
+		return dbusutil.ToError(fmt.Errorf("module %s is disabled", name))
+	}
+
+	err := loader.RestartModule(name)
+	return dbusutil.ToError(err)
+}
+
+func (s *SessionDaemon) ListModules() (modules []string, busErr *dbus.Error) {
+	for _, state := range loader.ListStates() {
+		modules = append(modules, state.Name)
+	}
+	return modules, nil
+}
+
+// GetModuleState 以 JSON 格式返回模块的状态、最近一次的错误和启动耗时
+func (s *SessionDaemon) GetModuleState(name string) (state string, busErr *dbus.Error) {
+	module := loader.GetModule(name)
+	if module == nil {
+		return "", dbusutil.ToError(fmt.Errorf("no such a module named %s", name))
+	}
+
+	data, err := json.Marshal(module.GetState())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// ListSyncModules 返回可以备份和恢复设置的模块
+func (s *SessionDaemon) ListSyncModules() (modules []string, busErr *dbus.Error) {
+	return dsync.ListModules(), nil
+}
+
+// BackupSettings 把模块的设置导出为本地备份，modules 为空时导出所有模块
+func (s *SessionDaemon) BackupSettings(modules []string) (data []byte, busErr *dbus.Error) {
+	data, err := dsync.CreateBackup(modules)
+	if err != nil {
+		return nil, dbusutil.ToError(err)
+	}
+	return data, nil
+}
+
+// RestoreSettings 从本地备份恢复模块的设置，modules 为空时恢复备份中的所有模块，
+// dryRun 为 true 时只比较差异不做修改，以 JSON 格式返回每个模块的结果
+func (s *SessionDaemon) RestoreSettings(data []byte, modules []string, dryRun bool) (results string, busErr *dbus.Error) {
+	restoreResults, err := dsync.RestoreBackup(data, modules, dryRun)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	content, err := json.Marshal(restoreResults)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(content), nil
+}
+
 func filterList(origin, condition []string) []string {
 	if len(condition) == 0 {
 		return origin
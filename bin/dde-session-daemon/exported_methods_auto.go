@@ -8,11 +8,49 @@ import (
 
 func (v *SessionDaemon) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:    "BackupSettings",
+			Fn:      v.BackupSettings,
+			InArgs:  []string{"modules"},
+			OutArgs: []string{"data"},
+		},
 		{
 			Name:   "CallTrace",
 			Fn:     v.CallTrace,
 			InArgs: []string{"times", "seconds"},
 		},
+		{
+			Name:    "GetModuleState",
+			Fn:      v.GetModuleState,
+			InArgs:  []string{"name"},
+			OutArgs: []string{"state"},
+		},
+		{
+			Name:    "GetStartupReport",
+			Fn:      v.GetStartupReport,
+			OutArgs: []string{"report"},
+		},
+		{
+			Name:    "ListModules",
+			Fn:      v.ListModules,
+			OutArgs: []string{"modules"},
+		},
+		{
+			Name:    "ListSyncModules",
+			Fn:      v.ListSyncModules,
+			OutArgs: []string{"modules"},
+		},
+		{
+			Name:   "RestartModule",
+			Fn:     v.RestartModule,
+			InArgs: []string{"name"},
+		},
+		{
+			Name:    "RestoreSettings",
+			Fn:      v.RestoreSettings,
+			InArgs:  []string{"data", "modules", "dryRun"},
+			OutArgs: []string{"results"},
+		},
 		{
 			Name: "StartPart2",
 			Fn:   v.StartPart2,
@@ -0,0 +1,109 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	dbus "github.com/godbus/dbus"
+)
+
+const (
+	accountsServiceName = "com.deepin.daemon.Accounts"
+	accountsPath        = "/com/deepin/daemon/Accounts"
+	accountsInterface   = "com.deepin.daemon.Accounts"
+)
+
+type userManifestResult struct {
+	Name    string
+	Status  string
+	Changes []string
+	Error   string
+}
+
+var optDryRun bool
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Create and configure users according to a YAML or JSON manifest.")
+	fmt.Fprintf(os.Stderr, "\nUsage: %s [-dry-run] <manifest file>\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func applyUserManifest(manifest []byte, dryRun bool) ([]userManifestResult, error) {
+	sysBus, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+
+	var report string
+	obj := sysBus.Object(accountsServiceName, accountsPath)
+	// 服务逐个创建用户，每个用户可能需要较长时间，不能使用默认的超时时间
+	err = obj.CallWithContext(context.Background(), accountsInterface+".ApplyUserManifest", 0,
+		string(manifest), dryRun).Store(&report)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []userManifestResult
+	err = json.Unmarshal([]byte(report), &results)
+	return results, err
+}
+
+func main() {
+	flag.BoolVar(&optDryRun, "dry-run", false, "only show what would be changed")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	manifest, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Read manifest failed:", err)
+		os.Exit(1)
+	}
+
+	results, err := applyUserManifest(manifest, optDryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Apply manifest failed:", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, result := range results {
+		line := fmt.Sprintf("%-16s %-10s", result.Name, result.Status)
+		if len(result.Changes) > 0 {
+			line += " " + strings.Join(result.Changes, ",")
+		}
+		if result.Error != "" {
+			line += ": " + result.Error
+			failed = true
+		}
+		fmt.Println(line)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -8,10 +8,40 @@ import (
 
 func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:   "ActivateHistory",
+			Fn:     v.ActivateHistory,
+			InArgs: []string{"id"},
+		},
 		{
 			Name: "BecomeClipboardOwner",
 			Fn:   v.BecomeClipboardOwner,
 		},
+		{
+			Name: "ClearHistory",
+			Fn:   v.ClearHistory,
+		},
+		{
+			Name:    "GetHistoryTargets",
+			Fn:      v.GetHistoryTargets,
+			InArgs:  []string{"id"},
+			OutArgs: []string{"targets"},
+		},
+		{
+			Name:    "ListHistory",
+			Fn:      v.ListHistory,
+			OutArgs: []string{"entries"},
+		},
+		{
+			Name:   "PinHistory",
+			Fn:     v.PinHistory,
+			InArgs: []string{"id", "pinned"},
+		},
+		{
+			Name:   "RemoveHistory",
+			Fn:     v.RemoveHistory,
+			InArgs: []string{"id"},
+		},
 		{
 			Name:   "RemoveTarget",
 			Fn:     v.RemoveTarget,
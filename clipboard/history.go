@@ -0,0 +1,324 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	historyIndexFile       = "index.json"
+	historyMaxEntries      = 50               // 未固定的条目的最大数量
+	historyMaxEntrySize    = 32 * 1024 * 1024 // 超过此大小的剪贴板数据不记录
+	historyPreviewMaxRunes = 200
+)
+
+const (
+	historyKindText    = "text"
+	historyKindImage   = "image"
+	historyKindUriList = "uri-list"
+	historyKindOther   = "other"
+)
+
+var errHistoryEntryNotFound = errors.New("history entry not found")
+
+// historyTarget 是历史条目中的一个 target，数据保存在单独的文件中
+type historyTarget struct {
+	Name   string
+	Type   string
+	Format uint8
+}
+
+type historyEntry struct {
+	Id      uint64
+	Time    int64
+	Pinned  bool
+	Kind    string
+	Preview string
+	Size    int
+	Md5     string
+	Targets []*historyTarget
+}
+
+// history 保存剪贴板历史，最新的条目在最前面
+type history struct {
+	mu         sync.Mutex
+	dir        string
+	entries    []*historyEntry
+	nextId     uint64
+	maxEntries int
+}
+
+func newHistory(dir string, maxEntries int) *history {
+	return &history{
+		dir:        dir,
+		nextId:     1,
+		maxEntries: maxEntries,
+	}
+}
+
+func (h *history) entryDir(id uint64) string {
+	return filepath.Join(h.dir, strconv.FormatUint(id, 10))
+}
+
+func (h *history) load() error {
+	content, err := ioutil.ReadFile(filepath.Join(h.dir, historyIndexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var entries []*historyEntry
+	err = json.Unmarshal(content, &entries)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = entries
+	for _, entry := range entries {
+		if entry.Id >= h.nextId {
+			h.nextId = entry.Id + 1
+		}
+	}
+	return nil
+}
+
+// save 保存索引文件，调用者需要持有 h.mu
+func (h *history) save() error {
+	err := os.MkdirAll(h.dir, 0700)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(h.entries)
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(h.dir, historyIndexFile)
+	tmpFile := filename + ".tmp"
+	err = ioutil.WriteFile(tmpFile, content, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filename)
+}
+
+func getHistoryMd5(targets []*historyTarget, data [][]byte) string {
+	var buf []byte
+	for i, t := range targets {
+		buf = append(buf, t.Name...)
+		buf = append(buf, 0)
+		buf = append(buf, data[i]...)
+	}
+	return getBytesMd5sum(buf)
+}
+
+// add 添加新的条目，如果已经有相同内容的条目，则将其移动到最前面
+func (h *history) add(targets []*historyTarget, data [][]byte) (*historyEntry, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no targets")
+	}
+	size := 0
+	for _, d := range data {
+		size += len(d)
+	}
+	if size > historyMaxEntrySize {
+		return nil, fmt.Errorf("content size %d exceeds limit", size)
+	}
+
+	sum := getHistoryMd5(targets, data)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now().Unix()
+	for idx, entry := range h.entries {
+		if entry.Md5 == sum {
+			entry.Time = now
+			h.moveToFront(idx)
+			return entry, h.save()
+		}
+	}
+
+	entry := &historyEntry{
+		Id:      h.nextId,
+		Time:    now,
+		Size:    size,
+		Md5:     sum,
+		Targets: targets,
+	}
+	entry.Kind, entry.Preview = getHistoryKindAndPreview(targets, data)
+	dir := h.entryDir(entry.Id)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	for i, d := range data {
+		err = ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(i)), d, 0600)
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	h.nextId++
+	h.entries = append([]*historyEntry{entry}, h.entries...)
+	h.trim()
+	return entry, h.save()
+}
+
+func (h *history) moveToFront(idx int) {
+	entry := h.entries[idx]
+	copy(h.entries[1:idx+1], h.entries[:idx])
+	h.entries[0] = entry
+}
+
+// trim 删除超出数量限制的最旧的未固定条目
+func (h *history) trim() {
+	count := 0
+	entries := h.entries[:0]
+	for _, entry := range h.entries {
+		if !entry.Pinned {
+			count++
+			if count > h.maxEntries {
+				h.removeFiles(entry.Id)
+				continue
+			}
+		}
+		entries = append(entries, entry)
+	}
+	h.entries = entries
+}
+
+func (h *history) removeFiles(id uint64) {
+	err := os.RemoveAll(h.entryDir(id))
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+func (h *history) list() []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	result := make([]historyEntry, len(h.entries))
+	for i, entry := range h.entries {
+		result[i] = *entry
+	}
+	return result
+}
+
+func (h *history) findIndex(id uint64) int {
+	for idx, entry := range h.entries {
+		if entry.Id == id {
+			return idx
+		}
+	}
+	return -1
+}
+
+// get 返回条目和各个 target 的数据
+func (h *history) get(id uint64) (*historyEntry, [][]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	idx := h.findIndex(id)
+	if idx < 0 {
+		return nil, nil, errHistoryEntryNotFound
+	}
+	entry := *h.entries[idx]
+	dir := h.entryDir(id)
+	data := make([][]byte, len(entry.Targets))
+	for i := range entry.Targets {
+		d, err := ioutil.ReadFile(filepath.Join(dir, strconv.Itoa(i)))
+		if err != nil {
+			return nil, nil, err
+		}
+		data[i] = d
+	}
+	return &entry, data, nil
+}
+
+func (h *history) setPinned(id uint64, pinned bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	idx := h.findIndex(id)
+	if idx < 0 {
+		return errHistoryEntryNotFound
+	}
+	h.entries[idx].Pinned = pinned
+	h.trim()
+	return h.save()
+}
+
+func (h *history) remove(id uint64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	idx := h.findIndex(id)
+	if idx < 0 {
+		return errHistoryEntryNotFound
+	}
+	h.entries = append(h.entries[:idx], h.entries[idx+1:]...)
+	h.removeFiles(id)
+	return h.save()
+}
+
+// clear 删除所有未固定的条目
+func (h *history) clear() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := h.entries[:0]
+	for _, entry := range h.entries {
+		if entry.Pinned {
+			entries = append(entries, entry)
+			continue
+		}
+		h.removeFiles(entry.Id)
+	}
+	h.entries = entries
+	return h.save()
+}
+
+func isTextTarget(name string) bool {
+	switch name {
+	case "UTF8_STRING", "STRING", "TEXT", "text/plain", "text/plain;charset=utf-8":
+		return true
+	}
+	return false
+}
+
+// getHistoryKindAndPreview 根据 target 判断内容的类型，并生成用于显示的预览文本
+func getHistoryKindAndPreview(targets []*historyTarget, data [][]byte) (kind, preview string) {
+	kind = historyKindOther
+	for i, t := range targets {
+		switch {
+		case t.Name == "text/uri-list":
+			return historyKindUriList, truncateText(string(data[i]))
+		case strings.HasPrefix(t.Name, "image/"):
+			kind = historyKindImage
+		case isTextTarget(t.Name) && kind == historyKindOther:
+			kind = historyKindText
+			preview = truncateText(string(data[i]))
+		}
+	}
+	if kind == historyKindImage {
+		preview = ""
+	}
+	return
+}
+
+func truncateText(str string) string {
+	if !utf8.ValidString(str) {
+		str = strings.ToValidUTF8(str, "")
+	}
+	if utf8.RuneCountInString(str) <= historyPreviewMaxRunes {
+		return str
+	}
+	return string([]rune(str)[:historyPreviewMaxRunes])
+}
@@ -0,0 +1,86 @@
+package clipboard
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clipboard-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	textTargets := func() []*historyTarget {
+		return []*historyTarget{{Name: "UTF8_STRING", Type: "UTF8_STRING", Format: 8}}
+	}
+	h := newHistory(dir, 2)
+	e1, err := h.add(textTargets(), [][]byte{[]byte("one")})
+	require.NoError(t, err)
+	assert.Equal(t, historyKindText, e1.Kind)
+	assert.Equal(t, "one", e1.Preview)
+	e2, err := h.add(textTargets(), [][]byte{[]byte("two")})
+	require.NoError(t, err)
+
+	// 相同的内容移动到最前面
+	e, err := h.add(textTargets(), [][]byte{[]byte("one")})
+	require.NoError(t, err)
+	assert.Equal(t, e1.Id, e.Id)
+	entries := h.list()
+	require.Len(t, entries, 2)
+	assert.Equal(t, e1.Id, entries[0].Id)
+
+	// 固定的条目不计入数量限制
+	require.NoError(t, h.setPinned(e2.Id, true))
+	_, err = h.add(textTargets(), [][]byte{[]byte("three")})
+	require.NoError(t, err)
+	e4, err := h.add(textTargets(), [][]byte{[]byte("four")})
+	require.NoError(t, err)
+	entries = h.list()
+	require.Len(t, entries, 3)
+	_, _, err = h.get(e1.Id)
+	assert.Equal(t, errHistoryEntryNotFound, err)
+
+	entry, data, err := h.get(e4.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "UTF8_STRING", entry.Targets[0].Name)
+	assert.Equal(t, []byte("four"), data[0])
+
+	// 重新加载
+	h2 := newHistory(dir, 2)
+	require.NoError(t, h2.load())
+	assert.Equal(t, entries, h2.list())
+	assert.Equal(t, h.nextId, h2.nextId)
+
+	require.NoError(t, h2.clear())
+	entries = h2.list()
+	require.Len(t, entries, 1)
+	assert.Equal(t, e2.Id, entries[0].Id)
+	require.NoError(t, h2.remove(e2.Id))
+	assert.Empty(t, h2.list())
+	assert.Equal(t, errHistoryEntryNotFound, h2.remove(e2.Id))
+}
+
+func Test_getHistoryKindAndPreview(t *testing.T) {
+	kind, preview := getHistoryKindAndPreview([]*historyTarget{
+		{Name: "text/plain"}, {Name: "text/uri-list"},
+	}, [][]byte{[]byte("/a"), []byte("file:///a")})
+	assert.Equal(t, historyKindUriList, kind)
+	assert.Equal(t, "file:///a", preview)
+
+	kind, preview = getHistoryKindAndPreview([]*historyTarget{
+		{Name: "TEXT"}, {Name: "image/png"},
+	}, [][]byte{[]byte("a"), {0x89}})
+	assert.Equal(t, historyKindImage, kind)
+	assert.Equal(t, "", preview)
+
+	kind, _ = getHistoryKindAndPreview([]*historyTarget{{Name: "application/x-foo"}}, [][]byte{nil})
+	assert.Equal(t, historyKindOther, kind)
+
+	long := strings.Repeat("中", historyPreviewMaxRunes+10)
+	assert.Equal(t, historyPreviewMaxRunes, len([]rune(truncateText(long))))
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/linuxdeepin/go-lib/dbusutil"
 	"github.com/linuxdeepin/go-lib/log"
 	x "github.com/linuxdeepin/go-x11-client"
 	"github.com/linuxdeepin/go-x11-client/ext/xfixes"
@@ -79,6 +80,16 @@ type Manager struct {
 	saveTargetsMu          sync.Mutex
 	saveTargetsSuccessTime time.Time
 	saveTargetsRequestor   x.Window
+
+	service      *dbusutil.Service
+	history      *history
+	policyLoader *policyLoader
+	expireTimer  *time.Timer
+
+	//nolint
+	signals *struct {
+		HistoryChanged struct{}
+	}
 }
 
 func (m *Manager) getTargetData(target x.Atom) *TargetData {
@@ -111,6 +122,103 @@ func (m *Manager) setContent(targetDataMap map[x.Atom]*TargetData) {
 	m.contentMu.Unlock()
 }
 
+// storeContent 保存剪贴板内容并记录到历史中，
+// 会过期的内容不记录到历史中，否则过期后内容仍然保存在磁盘上。
+func (m *Manager) storeContent(targetDataMap map[x.Atom]*TargetData, expireSec int) {
+	m.setContent(targetDataMap)
+	if expireSec > 0 {
+		logger.Debugf("content expires in %ds, skip history", expireSec)
+	} else {
+		m.addHistory(targetDataMap)
+	}
+	m.resetExpireTimer(expireSec)
+}
+
+func (m *Manager) hasContent() bool {
+	m.contentMu.Lock()
+	defer m.contentMu.Unlock()
+	return len(m.content) > 0
+}
+
+func (m *Manager) clearContent() {
+	m.contentMu.Lock()
+	m.content = nil
+	m.contentMu.Unlock()
+}
+
+func (m *Manager) getContentPolicy() *contentPolicy {
+	if m.policyLoader == nil {
+		return getDefaultContentPolicy()
+	}
+	return m.policyLoader.getPolicy()
+}
+
+// saveContent 根据策略保存剪贴板内容，每次的决定都会记录到日志中
+func (m *Manager) saveContent(targets []x.Atom, ts x.Timestamp, owner x.Window) {
+	names := make([]string, 0, len(targets))
+	nameAtoms := make(map[string]x.Atom, len(targets))
+	for _, target := range targets {
+		name, err := m.xc.GetAtomName(target)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		names = append(names, name)
+		nameAtoms[name] = target
+	}
+
+	apps := getOwnerApps(m.xc.Conn(), owner)
+	policy := m.getContentPolicy()
+	save, reason := policy.decide(apps, names, func(name string) []byte {
+		td, err := m.saveTarget(nameAtoms[name], ts)
+		if err != nil {
+			logger.Warning(err)
+			return nil
+		}
+		return td.Data
+	})
+	if !save {
+		logger.Infof("ignore clipboard content from %q (owner: %d), reason: %s", apps, owner, reason)
+		m.clearContent()
+		return
+	}
+	logger.Infof("save clipboard content from %q (owner: %d), reason: %s", apps, owner, reason)
+
+	targetDataMap := m.saveTargets(targets, ts)
+	m.storeContent(targetDataMap, policy.ExpireSec)
+}
+
+// resetExpireTimer 在 expireSec 秒后清除保存的内容，如果此时本程序是剪贴板的所有者，则放弃所有权
+func (m *Manager) resetExpireTimer(expireSec int) {
+	m.contentMu.Lock()
+	defer m.contentMu.Unlock()
+	if m.expireTimer != nil {
+		m.expireTimer.Stop()
+		m.expireTimer = nil
+	}
+	if expireSec <= 0 {
+		return
+	}
+	m.expireTimer = time.AfterFunc(time.Duration(expireSec)*time.Second, m.expireContent)
+}
+
+func (m *Manager) expireContent() {
+	logger.Info("clipboard content expired")
+	m.clearContent()
+	owner, err := m.xc.GetSelectionOwner(atomClipboard)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	if owner == m.window {
+		m.xc.SetSelectionOwner(x.None, atomClipboard, x.TimeCurrentTime)
+		err = m.xc.Flush()
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+}
+
 func mapToSliceTargetData(dataMap map[x.Atom]*TargetData) []*TargetData {
 	result := make([]*TargetData, 0, len(dataMap))
 	for _, data := range dataMap {
@@ -249,7 +357,7 @@ func (m *Manager) handleEvent(ev x.GenericEvent) {
 							logger.Debug("do not call handleClipboardUpdated")
 							return
 						}
-						err := m.handleClipboardUpdated(event.SelectionTimestamp)
+						err := m.handleClipboardUpdated(event.SelectionTimestamp, event.Owner)
 						if err != nil {
 							logger.Warning("handle clipboard updated err:", err)
 						}
@@ -269,6 +377,11 @@ func (m *Manager) handleEvent(ev x.GenericEvent) {
 
 		case xfixes.SelectionEventSelectionWindowDestroy, xfixes.SelectionEventSelectionClientClose:
 			if event.Selection == atomClipboard {
+				if !m.hasContent() {
+					// 内容没有被保存或者已经过期
+					logger.Debug("no content, do not become clipboard owner")
+					break
+				}
 				err := m.becomeClipboardOwner(event.Timestamp)
 				if err != nil {
 					logger.Warning(err)
@@ -279,7 +392,7 @@ func (m *Manager) handleEvent(ev x.GenericEvent) {
 }
 
 // 处理剪贴板数据更新
-func (m *Manager) handleClipboardUpdated(ts x.Timestamp) error {
+func (m *Manager) handleClipboardUpdated(ts x.Timestamp, owner x.Window) error {
 	logger.Debug("handleClipboardUpdated", ts)
 
 	targets, err := m.getClipboardTargets(ts)
@@ -287,8 +400,7 @@ func (m *Manager) handleClipboardUpdated(ts x.Timestamp) error {
 		return err
 	}
 	logger.Debug("targets:", targets)
-	targetDataMap := m.saveTargets(targets, ts)
-	m.setContent(targetDataMap)
+	m.saveContent(targets, ts, owner)
 
 	logger.Debug("handleClipboardUpdated finish", ts)
 	return nil
@@ -455,8 +567,8 @@ func (m *Manager) covertClipboardManagerSaveTargets(ev *x.SelectionRequestEvent)
 		}
 	}
 
-	targetDataMap := m.saveTargets(targets, ev.Time)
-	m.setContent(targetDataMap)
+	// 请求 SAVE_TARGETS 的是剪贴板的所有者
+	m.saveContent(targets, ev.Time, ev.Requestor)
 
 	m.saveTargetsRequestor = ev.Requestor
 	m.saveTargetsSuccessTime = time.Now()
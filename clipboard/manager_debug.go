@@ -32,8 +32,7 @@ func (m *Manager) saveClipboard() error {
 	}
 	logger.Debug("targets:", targets)
 
-	targetDataMap := m.saveTargets(targets, ts)
-	m.setContent(targetDataMap)
+	m.saveContent(targets, ts, owner)
 	m.contentMu.Lock()
 	for _, targetData := range m.content {
 		logger.Debugf("target %d type: %v", targetData.Target, targetData.Type)
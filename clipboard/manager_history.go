@@ -0,0 +1,162 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/godbus/dbus"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/go-lib/xdg/basedir"
+	x "github.com/linuxdeepin/go-x11-client"
+)
+
+func getHistoryDir() string {
+	return filepath.Join(basedir.GetUserDataDir(), "deepin/dde-daemon/clipboard-history")
+}
+
+func (m *Manager) emitHistoryChanged() {
+	if m.service == nil {
+		return
+	}
+	err := m.service.Emit(m, "HistoryChanged")
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+// addHistory 将剪贴板内容记录到历史中
+func (m *Manager) addHistory(targetDataMap map[x.Atom]*TargetData) {
+	if m.history == nil {
+		return
+	}
+
+	var targets []*historyTarget
+	var data [][]byte
+	for _, td := range targetDataMap {
+		if td.Target == atomFromClipboardManager {
+			continue
+		}
+		name, err := m.xc.GetAtomName(td.Target)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		var typeName string
+		if td.Type != x.None {
+			typeName, err = m.xc.GetAtomName(td.Type)
+			if err != nil {
+				logger.Warning(err)
+				continue
+			}
+		}
+		targets = append(targets, &historyTarget{
+			Name:   name,
+			Type:   typeName,
+			Format: td.Format,
+		})
+		data = append(data, td.Data)
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	entry, err := m.history.add(targets, data)
+	if err != nil {
+		logger.Warning("add clipboard history failed:", err)
+		return
+	}
+	logger.Debugf("add clipboard history %d, kind: %s", entry.Id, entry.Kind)
+	m.emitHistoryChanged()
+}
+
+// activateHistory 将历史条目设为当前的剪贴板内容
+func (m *Manager) activateHistory(id uint64) error {
+	entry, data, err := m.history.get(id)
+	if err != nil {
+		return err
+	}
+
+	targetDataMap := make(map[x.Atom]*TargetData, len(entry.Targets))
+	for i, t := range entry.Targets {
+		target, err := m.xc.GetAtom(t.Name)
+		if err != nil {
+			return err
+		}
+		typ := x.Atom(x.None)
+		if t.Type != "" {
+			typ, err = m.xc.GetAtom(t.Type)
+			if err != nil {
+				return err
+			}
+		}
+		targetDataMap[target] = &TargetData{
+			Target: target,
+			Type:   typ,
+			Format: t.Format,
+			Data:   data[i],
+		}
+	}
+	// 内容不会过期时，会将条目移动到历史的最前面
+	m.storeContent(targetDataMap, m.getContentPolicy().ExpireSec)
+
+	ts, err := m.getTimestamp()
+	if err != nil {
+		return err
+	}
+	return m.becomeClipboardOwner(ts)
+}
+
+// ListHistory 以 JSON 格式返回剪贴板历史，最新的在前
+func (m *Manager) ListHistory() (entries string, busErr *dbus.Error) {
+	data, err := json.Marshal(m.history.list())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// GetHistoryTargets 返回历史条目的所有 target 的数据，键为 target 名
+func (m *Manager) GetHistoryTargets(id uint64) (targets map[string][]byte, busErr *dbus.Error) {
+	entry, data, err := m.history.get(id)
+	if err != nil {
+		return nil, dbusutil.ToError(err)
+	}
+	targets = make(map[string][]byte, len(entry.Targets))
+	for i, t := range entry.Targets {
+		targets[t.Name] = data[i]
+	}
+	return targets, nil
+}
+
+func (m *Manager) ActivateHistory(id uint64) *dbus.Error {
+	err := m.activateHistory(id)
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) PinHistory(id uint64, pinned bool) *dbus.Error {
+	err := m.history.setPinned(id, pinned)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	m.emitHistoryChanged()
+	return nil
+}
+
+func (m *Manager) RemoveHistory(id uint64) *dbus.Error {
+	err := m.history.remove(id)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	m.emitHistoryChanged()
+	return nil
+}
+
+// ClearHistory 删除所有未固定的历史条目
+func (m *Manager) ClearHistory() *dbus.Error {
+	err := m.history.clear()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	m.emitHistoryChanged()
+	return nil
+}
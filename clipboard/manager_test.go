@@ -2,11 +2,14 @@ package clipboard
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/linuxdeepin/dde-daemon/clipboard/mocks"
 	x "github.com/linuxdeepin/go-x11-client"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func initAtomsForTest() {
@@ -194,3 +197,36 @@ func Test_shouldIgnoreSaveTarget(t *testing.T) {
 	assert.False(t, fn(200, "text/plain"))
 	assert.False(t, fn(200, "application/x-qt-image"))
 }
+
+func TestManager_storeContent(t *testing.T) {
+	initAtomsForTest()
+	dir, err := ioutil.TempDir("", "clipboard-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	m := &Manager{
+		history: newHistory(dir, historyMaxEntries),
+	}
+	xc := &mocks.XClient{}
+	m.xc = xc
+	xc.On("Conn").Return(nil)
+	xc.On("GetAtomName", x.Atom(200)).Return("UTF8_STRING", nil)
+	newContent := func(text string) map[x.Atom]*TargetData {
+		return map[x.Atom]*TargetData{
+			200: {Target: 200, Format: 8, Data: []byte(text)},
+		}
+	}
+
+	// 会过期的内容不记录到历史中
+	m.storeContent(newContent("secret"), 60)
+	require.NotNil(t, m.expireTimer)
+	m.expireTimer.Stop()
+	assert.True(t, m.hasContent())
+	assert.Len(t, m.history.list(), 0)
+
+	m.storeContent(newContent("text"), 0)
+	assert.Nil(t, m.expireTimer)
+	entries := m.history.list()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "text", entries[0].Preview)
+}
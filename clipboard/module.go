@@ -45,17 +45,29 @@ func (mo *Module) Start() error {
 		logger.Warning(err)
 	}
 
-	m := &Manager{}
+	service := loader.GetService()
+	m := &Manager{
+		service:      service,
+		history:      newHistory(getHistoryDir(), historyMaxEntries),
+		policyLoader: newPolicyLoader(),
+	}
 	m.xc = &xClient{
 		conn: xConn,
 	}
+	err = m.history.load()
+	if err != nil {
+		logger.Warning("load clipboard history failed:", err)
+	}
+	err = m.policyLoader.init()
+	if err != nil {
+		logger.Warning("init clipboard content policy failed:", err)
+	}
 
 	err = m.start()
 	if err != nil {
 		return err
 	}
 
-	service := loader.GetService()
 	err = service.Export("/com/deepin/daemon/ClipboardManager", m)
 	if err != nil {
 		return err
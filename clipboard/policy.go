@@ -0,0 +1,237 @@
+package clipboard
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	x "github.com/linuxdeepin/go-x11-client"
+	"github.com/linuxdeepin/go-x11-client/util/wm/ewmh"
+	"github.com/linuxdeepin/go-x11-client/util/wm/icccm"
+)
+
+const (
+	configManagerId    = "org.desktopspec.ConfigManager"
+	dconfigAppId       = "org.deepin.dde.daemon"
+	dconfigClipboardId = "org.deepin.dde.daemon.clipboard"
+
+	dconfigKeyHonorPasswordManagerHint = "honorPasswordManagerHint"
+	dconfigKeyDenyApps                 = "denyApps"
+	dconfigKeyDenyTargets              = "denyTargets"
+	dconfigKeyExpireSec                = "expireSec"
+)
+
+// 密码管理器等程序用于标记敏感内容的 target，值为空表示只要存在该 target 就是敏感内容
+var passwordManagerHints = map[string]string{
+	"x-kde-passwordManagerHint":                    "secret", // KeePassXC, KDE
+	"application/x-nspasteboard-concealed-type":    "",       // 1Password 等跨平台程序
+	"ExcludeClipboardContentFromMonitorProcessing": "",
+}
+
+// contentPolicy 决定剪贴板内容是否可以被保存
+type contentPolicy struct {
+	HonorPasswordManagerHint bool
+	DenyApps                 []string // 程序名，可执行文件名、WM_CLASS 或者 Qt 程序名，不区分大小写
+	DenyTargets              []string
+	ExpireSec                int // 大于 0 时，保存的内容在该时间之后清除，并且不记录到历史中
+}
+
+func getDefaultContentPolicy() *contentPolicy {
+	return &contentPolicy{
+		HonorPasswordManagerHint: true,
+	}
+}
+
+// decide 返回是否保存内容以及原因，apps 是所有者程序的各种标识，任意一个被禁止都不保存，
+// getData 用于获取 target 的数据
+func (p *contentPolicy) decide(apps []string, targetNames []string, getData func(name string) []byte) (bool, string) {
+	for _, denyApp := range p.DenyApps {
+		for _, app := range apps {
+			if app != "" && strings.EqualFold(app, denyApp) {
+				return false, fmt.Sprintf("app %q is denied", app)
+			}
+		}
+	}
+
+	for _, name := range targetNames {
+		if strSliceContains(p.DenyTargets, name) {
+			return false, fmt.Sprintf("target %q is denied", name)
+		}
+	}
+
+	if p.HonorPasswordManagerHint {
+		for _, name := range targetNames {
+			value, ok := passwordManagerHints[name]
+			if !ok {
+				continue
+			}
+			if value == "" || strings.TrimSpace(string(getData(name))) == value {
+				return false, fmt.Sprintf("password manager hint %q", name)
+			}
+		}
+	}
+	return true, "allowed"
+}
+
+func strSliceContains(slice []string, str string) bool {
+	for _, s := range slice {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// policyLoader 从 DConfig 中读取策略，配置变化时重新读取
+type policyLoader struct {
+	mu     sync.Mutex
+	policy *contentPolicy
+
+	sigLoop           *dbusutil.SignalLoop
+	configManagerPath dbus.ObjectPath
+}
+
+func newPolicyLoader() *policyLoader {
+	return &policyLoader{
+		policy: getDefaultContentPolicy(),
+	}
+}
+
+func (pl *policyLoader) getPolicy() *contentPolicy {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.policy
+}
+
+func (pl *policyLoader) init() error {
+	systemConn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	systemConnObj := systemConn.Object(configManagerId, "/")
+	err = systemConnObj.Call(configManagerId+".acquireManager", 0, dconfigAppId, dconfigClipboardId, "").Store(&pl.configManagerPath)
+	if err != nil {
+		return err
+	}
+	pl.reload(systemConn)
+
+	err = dbusutil.NewMatchRuleBuilder().Type("signal").
+		PathNamespace(string(pl.configManagerPath)).
+		Interface("org.desktopspec.ConfigManager.Manager").
+		Member("valueChanged").Build().AddTo(systemConn)
+	if err != nil {
+		logger.Warning(err)
+	}
+	pl.sigLoop = dbusutil.NewSignalLoop(systemConn, 10)
+	pl.sigLoop.Start()
+	pl.sigLoop.AddHandler(&dbusutil.SignalRule{
+		Name: "org.desktopspec.ConfigManager.Manager.valueChanged",
+	}, func(sig *dbus.Signal) {
+		if sig.Path != pl.configManagerPath {
+			return
+		}
+		pl.reload(systemConn)
+	})
+	return nil
+}
+
+func (pl *policyLoader) reload(systemConn *dbus.Conn) {
+	obj := systemConn.Object(configManagerId, pl.configManagerPath)
+	getValue := func(key string) interface{} {
+		var val dbus.Variant
+		err := obj.Call("org.desktopspec.ConfigManager.Manager.value", 0, key).Store(&val)
+		if err != nil {
+			logger.Warningf("get config value %s failed: %v", key, err)
+			return nil
+		}
+		return val.Value()
+	}
+
+	policy := getDefaultContentPolicy()
+	if v, ok := getValue(dconfigKeyHonorPasswordManagerHint).(bool); ok {
+		policy.HonorPasswordManagerHint = v
+	}
+	policy.DenyApps = toStringSlice(getValue(dconfigKeyDenyApps))
+	policy.DenyTargets = toStringSlice(getValue(dconfigKeyDenyTargets))
+	switch v := getValue(dconfigKeyExpireSec).(type) {
+	case int64:
+		policy.ExpireSec = int(v)
+	case int32:
+		policy.ExpireSec = int(v)
+	case float64:
+		policy.ExpireSec = int(v)
+	}
+	logger.Infof("clipboard content policy: %+v", *policy)
+
+	pl.mu.Lock()
+	pl.policy = policy
+	pl.mu.Unlock()
+}
+
+// toStringSlice 转换 DConfig 中的字符串数组，数组的元素可能是 dbus.Variant
+func toStringSlice(value interface{}) []string {
+	var result []string
+	switch v := value.(type) {
+	case []string:
+		result = v
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+	case []dbus.Variant:
+		for _, item := range v {
+			if s, ok := item.Value().(string); ok {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// appendAppId 添加不为空且不重复的程序标识
+func appendAppId(apps []string, app string) []string {
+	if app == "" || strSliceContains(apps, app) {
+		return apps
+	}
+	return append(apps, app)
+}
+
+// getOwnerApps 获取 selection 所有者窗口所属程序的所有标识：通过 _NET_WM_PID 获取的可执行文件名、
+// WM_CLASS 的 instance 和 class，以及 Qt 设置的 WM_NAME "Qt Selection Owner for <app>" 中的程序名。
+// Flatpak 和 Electron 等程序的可执行文件可能是包装程序，需要同时用其他标识匹配。
+func getOwnerApps(conn *x.Conn, owner x.Window) []string {
+	if conn == nil || owner == 0 {
+		return nil
+	}
+	var apps []string
+	pid, err := ewmh.GetWMPid(conn, owner).Reply(conn)
+	if err == nil && pid != 0 {
+		exe, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(int(pid)), "exe"))
+		if err == nil {
+			apps = appendAppId(apps, filepath.Base(exe))
+		}
+	}
+
+	wmClass, err := icccm.GetWMClass(conn, owner).Reply(conn)
+	if err == nil {
+		apps = appendAppId(apps, wmClass.Instance)
+		apps = appendAppId(apps, wmClass.Class)
+	}
+
+	nameTp, err := icccm.GetWMName(conn, owner).Reply(conn)
+	if err == nil {
+		name, _ := nameTp.GetStr()
+		const qtPrefix = "Qt Selection Owner for "
+		if strings.HasPrefix(name, qtPrefix) {
+			apps = appendAppId(apps, strings.TrimPrefix(name, qtPrefix))
+		}
+	}
+	return apps
+}
@@ -0,0 +1,69 @@
+package clipboard
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentPolicy_decide(t *testing.T) {
+	p := getDefaultContentPolicy()
+	getData := func(name string) []byte {
+		if name == "x-kde-passwordManagerHint" {
+			return []byte("secret")
+		}
+		return nil
+	}
+
+	save, _ := p.decide([]string{"firefox"}, []string{"UTF8_STRING", "text/plain"}, getData)
+	assert.True(t, save)
+
+	save, reason := p.decide([]string{"keepassxc"}, []string{"UTF8_STRING", "x-kde-passwordManagerHint"}, getData)
+	assert.False(t, save)
+	assert.Contains(t, reason, "x-kde-passwordManagerHint")
+
+	// 标记的值不是 secret 时不认为是敏感内容
+	save, _ = p.decide([]string{"keepassxc"}, []string{"UTF8_STRING", "x-kde-passwordManagerHint"},
+		func(string) []byte { return nil })
+	assert.True(t, save)
+
+	save, _ = p.decide([]string{"app"}, []string{"application/x-nspasteboard-concealed-type"}, getData)
+	assert.False(t, save)
+
+	p.HonorPasswordManagerHint = false
+	save, _ = p.decide([]string{"keepassxc"}, []string{"UTF8_STRING", "x-kde-passwordManagerHint"}, getData)
+	assert.True(t, save)
+
+	p.DenyApps = []string{"KeePassXC"}
+	p.DenyTargets = []string{"application/x-secret"}
+	save, _ = p.decide([]string{"keepassxc"}, []string{"UTF8_STRING"}, getData)
+	assert.False(t, save)
+	save, _ = p.decide(nil, []string{"UTF8_STRING"}, getData)
+	assert.True(t, save)
+	save, _ = p.decide([]string{"gedit"}, []string{"UTF8_STRING", "application/x-secret"}, getData)
+	assert.False(t, save)
+
+	// 可执行文件名和 WM_CLASS 不一致时，任意一个标识匹配都不保存
+	p.DenyApps = []string{"Signal"}
+	save, reason = p.decide([]string{"electron", "signal", "Signal"}, []string{"UTF8_STRING"}, getData)
+	assert.False(t, save)
+	assert.Contains(t, reason, "signal")
+	save, _ = p.decide([]string{"electron", "code", "Code"}, []string{"UTF8_STRING"}, getData)
+	assert.True(t, save)
+}
+
+func Test_appendAppId(t *testing.T) {
+	var apps []string
+	apps = appendAppId(apps, "keepassxc")
+	apps = appendAppId(apps, "")
+	apps = appendAppId(apps, "keepassxc")
+	apps = appendAppId(apps, "KeePassXC")
+	assert.Equal(t, []string{"keepassxc", "KeePassXC"}, apps)
+}
+
+func Test_toStringSlice(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, toStringSlice([]interface{}{"a", 1, "b"}))
+	assert.Equal(t, []string{"a"}, toStringSlice([]dbus.Variant{dbus.MakeVariant("a"), dbus.MakeVariant(1)}))
+	assert.Nil(t, toStringSlice(nil))
+}
@@ -0,0 +1,253 @@
+package dsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 本地备份：把所有已注册模块的 Get 数据导出到一个带版本号的归档中，
+// 恢复时通过 Set 写回，不依赖 com.deepin.sync.Daemon。
+
+const BackupVersion = 1
+
+// 恢复时每个模块的结果
+const (
+	RestoreStatusUnchanged   = "unchanged"   // 数据与当前一致，无需恢复
+	RestoreStatusChanged     = "changed"     // dryRun 时表示将被修改
+	RestoreStatusRestored    = "restored"    // 已恢复
+	RestoreStatusUnavailable = "unavailable" // 本机没有此模块或模块未启用
+	RestoreStatusFailed      = "failed"
+)
+
+var errBackupVersion = errors.New("unsupported backup version")
+
+type Backup struct {
+	Version int
+	Time    int64                      // 创建时间，Unix 时间戳
+	Modules map[string]json.RawMessage // 模块名 => Get 返回的数据
+}
+
+type RestoreResult struct {
+	Module  string
+	Status  string
+	Changes []string `json:",omitempty"` // 有变化的键的路径
+	Error   string   `json:",omitempty"`
+}
+
+var registry = struct {
+	mu      sync.Mutex
+	modules map[string]Interface
+}{
+	modules: make(map[string]Interface),
+}
+
+func registerModule(name string, core Interface) {
+	registry.mu.Lock()
+	registry.modules[name] = core
+	registry.mu.Unlock()
+}
+
+func unregisterModule(name string, core Interface) {
+	registry.mu.Lock()
+	// 模块重启时新的 Config 可能已经注册了
+	if registry.modules[name] == core {
+		delete(registry.modules, name)
+	}
+	registry.mu.Unlock()
+}
+
+func getModule(name string) Interface {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	return registry.modules[name]
+}
+
+// ListModules 返回已注册的模块名称
+func ListModules() []string {
+	registry.mu.Lock()
+	names := make([]string, 0, len(registry.modules))
+	for name := range registry.modules {
+		names = append(names, name)
+	}
+	registry.mu.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+func getModuleData(name string, core Interface) (json.RawMessage, error) {
+	v, err := core.Get()
+	if err != nil {
+		return nil, fmt.Errorf("get data of module %s: %v", name, err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal data of module %s: %v", name, err)
+	}
+	return data, nil
+}
+
+// CreateBackup 导出 names 中模块的数据，names 为空时导出所有已注册的模块
+func CreateBackup(names []string) ([]byte, error) {
+	if len(names) == 0 {
+		names = ListModules()
+	}
+	backup := Backup{
+		Version: BackupVersion,
+		Time:    time.Now().Unix(),
+		Modules: make(map[string]json.RawMessage, len(names)),
+	}
+	for _, name := range names {
+		core := getModule(name)
+		if core == nil {
+			return nil, fmt.Errorf("no such a module named %s", name)
+		}
+		data, err := getModuleData(name, core)
+		if err != nil {
+			return nil, err
+		}
+		backup.Modules[name] = data
+	}
+	return json.MarshalIndent(backup, "", "  ")
+}
+
+func parseBackup(data []byte) (*Backup, error) {
+	var backup Backup
+	err := json.Unmarshal(data, &backup)
+	if err != nil {
+		return nil, err
+	}
+	if backup.Version < 1 || backup.Version > BackupVersion {
+		return nil, errBackupVersion
+	}
+	return &backup, nil
+}
+
+// RestoreBackup 把归档中 names 模块的数据写回，names 为空时恢复归档中的所有模块。
+// dryRun 为 true 时只比较差异，不做修改。
+func RestoreBackup(data []byte, names []string, dryRun bool) ([]*RestoreResult, error) {
+	backup, err := parseBackup(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		for name := range backup.Modules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	results := make([]*RestoreResult, 0, len(names))
+	for _, name := range names {
+		moduleData, ok := backup.Modules[name]
+		if !ok {
+			return nil, fmt.Errorf("module %s is not in the backup", name)
+		}
+		results = append(results, restoreModule(name, moduleData, dryRun))
+	}
+	return results, nil
+}
+
+func restoreModule(name string, data json.RawMessage, dryRun bool) *RestoreResult {
+	result := &RestoreResult{Module: name}
+	core := getModule(name)
+	if core == nil {
+		result.Status = RestoreStatusUnavailable
+		return result
+	}
+
+	current, err := getModuleData(name, core)
+	if err != nil {
+		result.Status = RestoreStatusFailed
+		result.Error = err.Error()
+		return result
+	}
+	result.Changes, err = diffJSON(current, data)
+	if err != nil {
+		result.Status = RestoreStatusFailed
+		result.Error = err.Error()
+		return result
+	}
+	if len(result.Changes) == 0 {
+		result.Status = RestoreStatusUnchanged
+		return result
+	}
+	if dryRun {
+		result.Status = RestoreStatusChanged
+		return result
+	}
+
+	err = core.Set(data)
+	if err != nil {
+		result.Status = RestoreStatusFailed
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = RestoreStatusRestored
+	return result
+}
+
+// diffJSON 比较两份 JSON 数据，返回有差异的键的路径，比如 "DockedApps"、"Wireless.Enabled"、"Apps[2]"
+func diffJSON(a, b []byte) ([]string, error) {
+	var va, vb interface{}
+	err := json.Unmarshal(a, &va)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &vb)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	diffValue("", va, vb, &changes)
+	sort.Strings(changes)
+	return changes, nil
+}
+
+func diffValue(path string, a, b interface{}, changes *[]string) {
+	switch va := a.(type) {
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]struct{}, len(va)+len(vb))
+		for k := range va {
+			keys[k] = struct{}{}
+		}
+		for k := range vb {
+			keys[k] = struct{}{}
+		}
+		for k := range keys {
+			subPath := k
+			if path != "" {
+				subPath = path + "." + k
+			}
+			diffValue(subPath, va[k], vb[k], changes)
+		}
+		return
+
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok || len(va) != len(vb) {
+			// 长度不同时整个列表视为一处变化
+			break
+		}
+		for i := range va {
+			diffValue(path+"["+strconv.Itoa(i)+"]", va[i], vb[i], changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		if path == "" {
+			path = "."
+		}
+		*changes = append(*changes, path)
+	}
+}
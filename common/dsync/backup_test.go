@@ -0,0 +1,112 @@
+package dsync
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testModule struct {
+	data   map[string]interface{}
+	setErr error
+	setCnt int
+}
+
+func (m *testModule) Get() (interface{}, error) {
+	return m.data, nil
+}
+
+func (m *testModule) Set(data []byte) error {
+	m.setCnt++
+	if m.setErr != nil {
+		return m.setErr
+	}
+	return json.Unmarshal(data, &m.data)
+}
+
+func TestBackupRestore(t *testing.T) {
+	dock := &testModule{data: map[string]interface{}{
+		"DockedApps": []interface{}{"dde-file-manager", "deepin-terminal"},
+		"Position":   "bottom",
+	}}
+	audio := &testModule{data: map[string]interface{}{
+		"Output": map[string]interface{}{"Volume": 0.5, "Mute": false},
+	}}
+	registerModule("test-dock", dock)
+	registerModule("test-audio", audio)
+	defer unregisterModule("test-dock", dock)
+	defer unregisterModule("test-audio", audio)
+
+	data, err := CreateBackup([]string{"test-dock", "test-audio"})
+	require.NoError(t, err)
+	_, err = CreateBackup([]string{"test-unknown"})
+	assert.Error(t, err)
+
+	dock.data["Position"] = "left"
+	audio.data["Output"].(map[string]interface{})["Volume"] = 0.8
+
+	results, err := RestoreBackup(data, nil, true)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "test-audio", results[0].Module)
+	assert.Equal(t, RestoreStatusChanged, results[0].Status)
+	assert.Equal(t, []string{"Output.Volume"}, results[0].Changes)
+	assert.Equal(t, []string{"Position"}, results[1].Changes)
+	assert.Equal(t, 0, dock.setCnt, "dry run")
+
+	results, err = RestoreBackup(data, []string{"test-dock"}, false)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, RestoreStatusRestored, results[0].Status)
+	assert.Equal(t, "bottom", dock.data["Position"])
+	assert.Equal(t, 0.8, audio.data["Output"].(map[string]interface{})["Volume"])
+
+	results, err = RestoreBackup(data, []string{"test-dock"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, RestoreStatusUnchanged, results[0].Status)
+	assert.Equal(t, 1, dock.setCnt)
+
+	audio.setErr = errors.New("set failed")
+	results, err = RestoreBackup(data, []string{"test-audio"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, RestoreStatusFailed, results[0].Status)
+	assert.Equal(t, "set failed", results[0].Error)
+
+	unregisterModule("test-audio", audio)
+	results, err = RestoreBackup(data, []string{"test-audio"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, RestoreStatusUnavailable, results[0].Status)
+
+	_, err = RestoreBackup(data, []string{"test-power"}, false)
+	assert.Error(t, err)
+	_, err = RestoreBackup([]byte(`{"Version":2,"Modules":{}}`), nil, false)
+	assert.Error(t, err)
+}
+
+func TestUnregisterModule(t *testing.T) {
+	oldCore := &testModule{}
+	newCore := &testModule{}
+	registerModule("test-restart", oldCore)
+	registerModule("test-restart", newCore)
+	unregisterModule("test-restart", oldCore)
+	assert.Equal(t, newCore, getModule("test-restart"))
+	unregisterModule("test-restart", newCore)
+	assert.Nil(t, getModule("test-restart"))
+}
+
+func TestDiffJSON(t *testing.T) {
+	changes, err := diffJSON([]byte(`{"a":1,"b":{"c":[1,2],"d":"x"},"e":[1]}`),
+		[]byte(`{"a":1,"b":{"c":[1,3],"f":true},"e":[1,2]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.c[1]", "b.d", "b.f", "e"}, changes)
+
+	changes, err = diffJSON([]byte(`"on"`), []byte(`"off"`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"."}, changes)
+
+	_, err = diffJSON([]byte(`{`), []byte(`{}`))
+	assert.Error(t, err)
+}
@@ -41,6 +41,7 @@ func NewConfig(name string, core Interface, sessionSigLoop *dbusutil.SignalLoop,
 		path:    path,
 		logger:  logger,
 	}
+	registerModule(name, core)
 
 	sessionBus := sessionSigLoop.Conn()
 	c.dbusDaemon = ofdbus.NewDBus(sessionBus)
@@ -81,6 +82,7 @@ func (c *Config) Register() error {
 
 func (c *Config) Destroy() {
 	c.dbusDaemon.RemoveHandler(proxy.RemoveAllHandlers)
+	unregisterModule(c.name, c.core)
 }
 
 func (*Config) GetInterfaceName() string {
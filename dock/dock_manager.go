@@ -76,6 +76,7 @@ type Manager struct {
 	activeWindow    WindowInfoImp
 	activeWindowOld WindowInfoImp
 	activeWindowMu  sync.Mutex
+	focusedPid      uint32 // 最近一次报告给 scheduler 的活动进程
 
 	waylandManager *WaylandManager
 
@@ -97,6 +98,7 @@ type Manager struct {
 	ddeLauncher      libDDELauncher.Launcher
 	wm               wm.Wm
 	appsObj          libApps.Apps
+	schedulerObj     dbus.BusObject
 	startManager     sessionmanager.StartManager
 	wmSwitcher       wmswitcher.WMSwitcher
 	waylandWM        kwayland.WindowManager
@@ -141,6 +143,10 @@ const (
 	dbusServiceName = "com.deepin.dde.daemon.Dock"
 	dbusPath        = "/com/deepin/dde/daemon/Dock"
 	dbusInterface   = dbusServiceName
+
+	schedulerServiceName = "com.deepin.system.Scheduler"
+	schedulerPath        = "/com/deepin/system/Scheduler"
+	schedulerInterface   = schedulerServiceName
 )
 
 func newManager(service *dbusutil.Service) (*Manager, error) {
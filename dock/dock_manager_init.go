@@ -255,6 +255,7 @@ func (m *Manager) init() error {
 		return err
 	}
 	m.appsObj = libApps.NewApps(systemBus)
+	m.schedulerObj = systemBus.Object(schedulerServiceName, schedulerPath)
 	m.launcher = launcher.NewLauncher(sessionBus)
 	m.ddeLauncher = libDDELauncher.NewLauncher(sessionBus)
 	m.startManager = sessionmanager.NewStartManager(sessionBus)
@@ -25,6 +25,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/godbus/dbus"
 	x "github.com/linuxdeepin/go-x11-client"
 	"github.com/linuxdeepin/go-x11-client/util/wm/ewmh"
 )
@@ -152,11 +153,13 @@ func (m *Manager) handleActiveWindowChanged(activeWindow WindowInfoImp) {
 		m.activeWindowOld = m.activeWindow
 		m.activeWindow = nil
 		m.activeWindowMu.Unlock()
+		m.reportFocusedProcess(0)
 		return
 	}
 
 	m.activeWindow = activeWindow
 	m.activeWindowMu.Unlock()
+	m.reportFocusedProcess(uint32(activeWindow.getPid()))
 
 	activeWinXid := activeWindow.getXid()
 
@@ -181,6 +184,23 @@ func (m *Manager) handleActiveWindowChanged(activeWindow WindowInfoImp) {
 	m.updateHideState(true)
 }
 
+// reportFocusedProcess 将活动窗口所属的进程报告给 scheduler，以便提升其优先级
+func (m *Manager) reportFocusedProcess(pid uint32) {
+	m.activeWindowMu.Lock()
+	if m.focusedPid == pid {
+		m.activeWindowMu.Unlock()
+		return
+	}
+	m.focusedPid = pid
+	m.activeWindowMu.Unlock()
+
+	if m.schedulerObj == nil {
+		return
+	}
+	// 不等待回复，scheduler 未启用时调用失败也不影响 dock
+	m.schedulerObj.Go(schedulerInterface+".SetFocusedProcess", dbus.FlagNoReplyExpected, nil, pid)
+}
+
 func (m *Manager) listenRootWindowXEvent() {
 	const eventMask = x.EventMaskPropertyChange | x.EventMaskSubstructureNotify
 	err := x.ChangeWindowAttributesChecked(globalXConn, m.rootWindow, x.CWEventMask,
@@ -33,6 +33,7 @@ type Entry struct {
 	title         string
 	num           int
 	parentSubMenu *Entry
+	id            string
 }
 
 func (entry *Entry) getFullTitle() string {
@@ -41,3 +42,12 @@ func (entry *Entry) getFullTitle() string {
 	}
 	return entry.title
 }
+
+// getLevel 返回启动项所在的层级，第一级为 0
+func (entry *Entry) getLevel() int {
+	level := 0
+	for parent := entry.parentSubMenu; parent != nil; parent = parent.parentSubMenu {
+		level++
+	}
+	return level
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package grub2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	grubEnvFile    = "/boot/grub/grubenv"
+	grubRebootCmd  = "grub-reboot"
+	grubEditenvCmd = "grub-editenv"
+
+	entryNodeTypeMenuEntry = "menuentry"
+	entryNodeTypeSubMenu   = "submenu"
+
+	// grub 中表示启动项路径的分隔符
+	entryPathSeparator = ">"
+)
+
+var errNextEntryNotSupported = errors.New("next_entry is not supported by " + grubScriptFile)
+
+// EntryNode 是 GetEntryTree 返回的启动项树中的节点
+type EntryNode struct {
+	Id    string // grub.cfg 中 --id 或 $menuentry_id_option 指定的 id，可能为空
+	Title string
+	Type  string // menuentry 或 submenu
+	// 以 > 分隔的路径，每一级优先使用 id，内核升级后也保持不变，
+	// 可以传给 SetDefaultEntry 和 SetNextBootEntry。
+	Path     string
+	Children []*EntryNode `json:",omitempty"`
+
+	fullTitle string
+}
+
+// buildEntryTree 根据 parseEntries 的结果构建启动项树，
+// parseEntries 按 grub.cfg 中的顺序返回启动项，子项紧跟在所属的 submenu 之后。
+func buildEntryTree(entries []Entry) []*EntryNode {
+	roots := make([]*EntryNode, 0)
+	var subMenus []*EntryNode
+	for i := range entries {
+		entry := &entries[i]
+		level := entry.getLevel()
+		if level > len(subMenus) {
+			logger.Warningf("failed to find submenu of entry %q", entry.title)
+			continue
+		}
+		subMenus = subMenus[:level]
+
+		node := &EntryNode{
+			Id:        entry.id,
+			Title:     entry.title,
+			Type:      entryNodeTypeMenuEntry,
+			fullTitle: entry.getFullTitle(),
+		}
+		pathItem := entry.id
+		if pathItem == "" {
+			pathItem = entry.title
+		}
+		if level == 0 {
+			node.Path = pathItem
+			roots = append(roots, node)
+		} else {
+			parent := subMenus[level-1]
+			node.Path = parent.Path + entryPathSeparator + pathItem
+			parent.Children = append(parent.Children, node)
+		}
+
+		if entry.entryType == SUBMENU {
+			node.Type = entryNodeTypeSubMenu
+			subMenus = append(subMenus, node)
+		}
+	}
+	return roots
+}
+
+// findEntryNode 按 grub 的规则查找启动项，路径的每一级可以是 id、标题或序号，
+// 例如 "gnulinux-advanced-xxx>gnulinux-5.10-advanced-xxx"、"1>2"。
+func findEntryNode(nodes []*EntryNode, path string) *EntryNode {
+	if path == "" {
+		return nil
+	}
+	var node *EntryNode
+	for _, item := range strings.Split(path, entryPathSeparator) {
+		node = findEntryNodeInLevel(nodes, item)
+		if node == nil {
+			return nil
+		}
+		nodes = node.Children
+	}
+	return node
+}
+
+func findEntryNodeInLevel(nodes []*EntryNode, item string) *EntryNode {
+	for _, node := range nodes {
+		if node.Id != "" && node.Id == item {
+			return node
+		}
+	}
+	for _, node := range nodes {
+		if node.Title == item {
+			return node
+		}
+	}
+	idx, err := strconv.Atoi(item)
+	if err == nil && 0 <= idx && idx < len(nodes) {
+		return nodes[idx]
+	}
+	return nil
+}
+
+// findMenuEntry 查找可以启动的启动项，submenu 不能作为启动项
+func (g *Grub2) findMenuEntry(path string) *EntryNode {
+	node := findEntryNode(buildEntryTree(g.entries), path)
+	if node == nil || node.Type != entryNodeTypeMenuEntry {
+		return nil
+	}
+	return node
+}
+
+// isNextEntrySupported 判断 grub.cfg 是否会读取 grubenv 中的 next_entry
+func isNextEntrySupported(grubScript []byte) bool {
+	return bytes.Contains(grubScript, []byte("next_entry"))
+}
+
+func runGrubEnvCmd(cmd *exec.Cmd) error {
+	logger.Debugf("$ %s", strings.Join(cmd.Args, " "))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", cmd.Args[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// setNextBootEntry 设置下次启动时使用的启动项，只生效一次，不改变默认启动项
+func setNextBootEntry(path string) error {
+	grubScript, err := ioutil.ReadFile(grubScriptFile)
+	if err != nil {
+		return err
+	}
+	if !isNextEntrySupported(grubScript) {
+		return errNextEntryNotSupported
+	}
+
+	var cmd *exec.Cmd
+	grubRebootPath, err := exec.LookPath(grubRebootCmd)
+	if err == nil {
+		cmd = exec.Command(grubRebootPath, path)
+	} else {
+		// fallback to grub-editenv
+		cmd = exec.Command(grubEditenvCmd, grubEnvFile, "set", "next_entry="+path)
+	}
+	return runGrubEnvCmd(cmd)
+}
+
+// unsetNextBootEntry 取消 setNextBootEntry 的设置
+func unsetNextBootEntry() error {
+	return runGrubEnvCmd(exec.Command(grubEditenvCmd, grubEnvFile, "unset", "next_entry"))
+}
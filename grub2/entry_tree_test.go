@@ -0,0 +1,121 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package grub2
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/linuxdeepin/go-lib/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestEntries(t *testing.T) []Entry {
+	if logger == nil {
+		logger = log.NewLogger("grub2_test")
+	}
+	content, err := ioutil.ReadFile("testdata/entry/grub.cfg")
+	require.NoError(t, err)
+	entries, err := parseEntries(string(content))
+	require.NoError(t, err)
+	return entries
+}
+
+func Test_parseEntryId(t *testing.T) {
+	assert.Equal(t, "gnulinux-simple-1a2b",
+		parseEntryId(`menuentry 'UOS 20' --class uos $menuentry_id_option 'gnulinux-simple-1a2b' {`))
+	assert.Equal(t, "memtest",
+		parseEntryId(`menuentry "Memory test" --id "memtest" {`))
+	assert.Equal(t, "uefi-firmware",
+		parseEntryId(`menuentry 'UEFI Firmware Settings' --id uefi-firmware {`))
+	assert.Equal(t, "",
+		parseEntryId(`menuentry "Windows Boot Manager (on /dev/sda1)" --class windows --class os {`))
+}
+
+func Test_buildEntryTree(t *testing.T) {
+	entries := loadTestEntries(t)
+	tree := buildEntryTree(entries)
+	require.Len(t, tree, 3)
+
+	assert.Equal(t, "gnulinux-simple-1a2b", tree[0].Id)
+	assert.Equal(t, "UOS 20", tree[0].Title)
+	assert.Equal(t, entryNodeTypeMenuEntry, tree[0].Type)
+	assert.Equal(t, "gnulinux-simple-1a2b", tree[0].Path)
+	assert.Len(t, tree[0].Children, 0)
+
+	subMenu := tree[1]
+	assert.Equal(t, entryNodeTypeSubMenu, subMenu.Type)
+	assert.Equal(t, "gnulinux-advanced-1a2b", subMenu.Path)
+	require.Len(t, subMenu.Children, 3)
+	older := subMenu.Children[1]
+	assert.Equal(t, "UOS 20, with Linux 5.10.0-amd64-desktop", older.Title)
+	assert.Equal(t, "gnulinux-advanced-1a2b>gnulinux-5.10.0-amd64-desktop-advanced-1a2b", older.Path)
+	assert.Equal(t, "Advanced options for UOS 20>UOS 20, with Linux 5.10.0-amd64-desktop", older.fullTitle)
+
+	// 没有 id 的启动项使用标题作为路径
+	assert.Equal(t, "", tree[2].Id)
+	assert.Equal(t, "Windows Boot Manager (on /dev/sda1)", tree[2].Path)
+
+	data, err := json.Marshal(tree)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "fullTitle")
+
+	data, err = json.Marshal(buildEntryTree(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(data))
+}
+
+func Test_findEntryNode(t *testing.T) {
+	tree := buildEntryTree(loadTestEntries(t))
+	const olderPath = "gnulinux-advanced-1a2b>gnulinux-5.10.0-amd64-desktop-advanced-1a2b"
+
+	for _, path := range []string{
+		olderPath,
+		"Advanced options for UOS 20>UOS 20, with Linux 5.10.0-amd64-desktop",
+		"1>1",
+		"gnulinux-advanced-1a2b>UOS 20, with Linux 5.10.0-amd64-desktop",
+	} {
+		node := findEntryNode(tree, path)
+		if assert.NotNil(t, node, path) {
+			assert.Equal(t, olderPath, node.Path)
+		}
+	}
+
+	node := findEntryNode(tree, "Advanced options for UOS 20")
+	require.NotNil(t, node)
+	assert.Equal(t, entryNodeTypeSubMenu, node.Type)
+
+	for _, path := range []string{
+		"",
+		"1>3",
+		"gnulinux-advanced-1a2b>gnulinux-4.19-advanced-1a2b",
+		"UOS 20>UOS 20",
+		"Advanced options for UOS 20>",
+	} {
+		assert.Nil(t, findEntryNode(tree, path), path)
+	}
+}
+
+func Test_isNextEntrySupported(t *testing.T) {
+	content, err := ioutil.ReadFile("testdata/entry/grub.cfg")
+	require.NoError(t, err)
+	assert.True(t, isNextEntrySupported(content))
+	assert.False(t, isNextEntrySupported([]byte("set default=\"0\"\n")))
+}
@@ -22,11 +22,26 @@ func (v *EditAuth) GetExportedMethods() dbusutil.ExportedMethods {
 }
 func (v *Grub2) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:   "AddKernelParam",
+			Fn:     v.AddKernelParam,
+			InArgs: []string{"param"},
+		},
 		{
 			Name:    "GetAvailableGfxmodes",
 			Fn:      v.GetAvailableGfxmodes,
 			OutArgs: []string{"gfxModes"},
 		},
+		{
+			Name:    "GetEntryTree",
+			Fn:      v.GetEntryTree,
+			OutArgs: []string{"tree"},
+		},
+		{
+			Name:    "GetKernelParams",
+			Fn:      v.GetKernelParams,
+			OutArgs: []string{"params"},
+		},
 		{
 			Name:    "GetSimpleEntryTitles",
 			Fn:      v.GetSimpleEntryTitles,
@@ -36,6 +51,16 @@ func (v *Grub2) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "PrepareGfxmodeDetect",
 			Fn:   v.PrepareGfxmodeDetect,
 		},
+		{
+			Name:   "RemoveKernelParam",
+			Fn:     v.RemoveKernelParam,
+			InArgs: []string{"param"},
+		},
+		{
+			Name:   "ReplaceKernelParam",
+			Fn:     v.ReplaceKernelParam,
+			InArgs: []string{"oldParam", "newParam"},
+		},
 		{
 			Name: "Reset",
 			Fn:   v.Reset,
@@ -55,6 +80,11 @@ func (v *Grub2) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetGfxmode,
 			InArgs: []string{"gfxmode"},
 		},
+		{
+			Name:   "SetNextBootEntry",
+			Fn:     v.SetNextBootEntry,
+			InArgs: []string{"entry"},
+		},
 		{
 			Name:   "SetTimeout",
 			Fn:     v.SetTimeout,
@@ -74,6 +74,15 @@ type Grub2 struct {
 	Gfxmode      string
 	Timeout      uint32
 	Updating     bool
+
+	//nolint
+	signals *struct {
+		// 生成 grub 配置失败，kernelParamsRolledBack 表示内核参数是否已经恢复为修改前的值
+		UpdateFailed struct {
+			err                    string
+			kernelParamsRolledBack bool
+		}
+	}
 }
 
 // return -1 for failed
@@ -131,6 +140,9 @@ func (g *Grub2) applyParams(params map[string]string) {
 		if defaultEntry == "saved" {
 			// TODO saved
 			g.DefaultEntry, _ = g.defaultEntryIdx2Str(0)
+		} else if entry := g.findMenuEntry(defaultEntry); entry != nil {
+			// submenu 中的启动项
+			g.DefaultEntry = entry.fullTitle
 		} else {
 			g.DefaultEntry = defaultEntry
 		}
@@ -141,6 +153,8 @@ type modifyTask struct {
 	paramsModifyFunc func(map[string]string)
 	adjustTheme      bool
 	adjustThemeLang  string
+	// update-grub 失败时恢复 GRUB_CMDLINE_LINUX_DEFAULT
+	rollbackKernelParams bool
 }
 
 func getModifyTaskEnableTheme(enable bool, lang string, gfxmodeDetectState gfxmodeDetectState) modifyTask {
@@ -201,6 +215,16 @@ func getModifyTaskDefaultEntry(idx int) modifyTask {
 	}
 }
 
+// getModifyTaskDefaultEntryPath 用以 > 分隔的路径设置 submenu 中的默认启动项
+func getModifyTaskDefaultEntryPath(path string) modifyTask {
+	f := func(params map[string]string) {
+		params[grubDefault] = quoteString(path)
+	}
+	return modifyTask{
+		paramsModifyFunc: f,
+	}
+}
+
 func joinGfxmodesForDetect(gfxmodes grub_common.Gfxmodes) string {
 	const gfxmodeDelimiter = ","
 	var buf bytes.Buffer
@@ -420,7 +444,7 @@ func parseEntries(fileContent string) ([]Entry, error) {
 			}
 			title, ok := parseTitle(line)
 			if ok {
-				entry := Entry{MENUENTRY, title, numCount[level], parentMenus[len(parentMenus)-1]}
+				entry := Entry{MENUENTRY, title, numCount[level], parentMenus[len(parentMenus)-1], parseEntryId(line)}
 				entries = append(entries, entry)
 				logger.Debugf("found entry: [%d] %s %s", level, strings.Repeat(" ", level*2), title)
 
@@ -438,7 +462,7 @@ func parseEntries(fileContent string) ([]Entry, error) {
 			}
 			title, ok := parseTitle(line)
 			if ok {
-				entry := Entry{SUBMENU, title, numCount[level], parentMenus[len(parentMenus)-1]}
+				entry := Entry{SUBMENU, title, numCount[level], parentMenus[len(parentMenus)-1], parseEntryId(line)}
 				entries = append(entries, entry)
 				parentMenus = append(parentMenus, &entry)
 				logger.Debugf("found entry: [%d] %s %s", level, strings.Repeat(" ", level*2), title)
@@ -474,6 +498,7 @@ func parseEntries(fileContent string) ([]Entry, error) {
 var (
 	entryRegexpSingleQuote = regexp.MustCompile(`^ *(menuentry|submenu) +'(.*?)'.*$`)
 	entryRegexpDoubleQuote = regexp.MustCompile(`^ *(menuentry|submenu) +"(.*?)".*$`)
+	entryIdRegexp          = regexp.MustCompile(`(?:--id|\$menuentry_id_option) +(?:'([^']*)'|"([^"]*)"|([^ '"{]+))`)
 )
 
 func parseTitle(line string) (string, bool) {
@@ -487,6 +512,20 @@ func parseTitle(line string) (string, bool) {
 	}
 }
 
+// parseEntryId 解析 --id 或 $menuentry_id_option 指定的启动项 id，没有时返回空字符串
+func parseEntryId(line string) string {
+	match := entryIdRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return ""
+	}
+	for _, id := range match[1:] {
+		if id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
 func (g *Grub2) canSafelyExit() bool {
 	logger.Debug("call canSafelyExit")
 	g.PropsMu.RLock()
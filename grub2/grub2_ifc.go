@@ -20,8 +20,10 @@
 package grub2
 
 import (
+	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"os"
 	"strings"
 
 	dbus "github.com/godbus/dbus"
@@ -107,20 +109,67 @@ func (g *Grub2) SetDefaultEntry(sender dbus.Sender, entry string) *dbus.Error {
 		return dbusutil.ToError(err)
 	}
 
+	var task modifyTask
 	idx := g.defaultEntryStr2Idx(entry)
-	if idx == -1 {
-		return dbusutil.ToError(errors.New("invalid entry"))
+	if idx != -1 {
+		task = getModifyTaskDefaultEntry(idx)
+	} else {
+		// submenu 中的启动项，entry 为 GetEntryTree 返回的 Path 或以 > 分隔的标题
+		node := g.findMenuEntry(entry)
+		if node == nil {
+			return dbusutil.ToError(errInvalidEntry)
+		}
+		entry = node.fullTitle
+		task = getModifyTaskDefaultEntryPath(node.Path)
 	}
 
 	g.PropsMu.Lock()
 	if g.setPropDefaultEntry(entry) {
-		g.addModifyTask(getModifyTaskDefaultEntry(idx))
+		g.addModifyTask(task)
 	}
 	g.PropsMu.Unlock()
 	return nil
 }
 
-var errInGfxmodeDetect = errors.New("in gfxmode detection mode")
+// GetEntryTree 返回 JSON 格式的完整启动项树，包括 submenu 中的启动项
+func (g *Grub2) GetEntryTree() (tree string, busErr *dbus.Error) {
+	g.service.DelayAutoQuit()
+
+	data, err := json.Marshal(buildEntryTree(g.entries))
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetNextBootEntry 设置仅下次启动时使用的启动项，不改变默认启动项，entry 为空时取消设置。
+func (g *Grub2) SetNextBootEntry(sender dbus.Sender, entry string) *dbus.Error {
+	g.service.DelayAutoQuit()
+
+	err := g.checkAuth(sender, polikitActionIdCommon)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	if entry == "" {
+		return dbusutil.ToError(unsetNextBootEntry())
+	}
+
+	node := g.findMenuEntry(entry)
+	if node == nil {
+		return dbusutil.ToError(errInvalidEntry)
+	}
+	err = setNextBootEntry(node.Path)
+	if err != nil {
+		logger.Warning("failed to set next boot entry:", err)
+	}
+	return dbusutil.ToError(err)
+}
+
+var (
+	errInGfxmodeDetect = errors.New("in gfxmode detection mode")
+	errInvalidEntry    = errors.New("invalid entry")
+)
 
 func (g *Grub2) SetEnableTheme(sender dbus.Sender, enabled bool) *dbus.Error {
 	g.service.DelayAutoQuit()
@@ -209,6 +258,78 @@ func (g *Grub2) SetTimeout(sender dbus.Sender, timeout uint32) *dbus.Error {
 	return nil
 }
 
+// GetKernelParams 返回 GRUB_CMDLINE_LINUX_DEFAULT 中的内核参数
+func (g *Grub2) GetKernelParams() (params []string, busErr *dbus.Error) {
+	g.service.DelayAutoQuit()
+
+	grubParams, err := grub_common.LoadGrubParams()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, dbusutil.ToError(err)
+	}
+	params, err = getKernelParams(grubParams)
+	if err != nil {
+		return nil, dbusutil.ToError(err)
+	}
+	return params, nil
+}
+
+// AddKernelParam 追加内核参数，例如 nomodeset 或 mem_sleep_default=deep
+func (g *Grub2) AddKernelParam(sender dbus.Sender, param string) *dbus.Error {
+	err := g.modifyKernelParams(sender, func(kernelParams []string) ([]string, error) {
+		return addKernelParam(kernelParams, param)
+	})
+	return dbusutil.ToError(err)
+}
+
+// RemoveKernelParam 删除内核参数，param 中没有 = 时删除所有同名参数
+func (g *Grub2) RemoveKernelParam(sender dbus.Sender, param string) *dbus.Error {
+	err := g.modifyKernelParams(sender, func(kernelParams []string) ([]string, error) {
+		return removeKernelParam(kernelParams, param)
+	})
+	return dbusutil.ToError(err)
+}
+
+// ReplaceKernelParam 用 newParam 替换匹配 oldParam 的内核参数，例如把 iommu 替换为 iommu=pt
+func (g *Grub2) ReplaceKernelParam(sender dbus.Sender, oldParam, newParam string) *dbus.Error {
+	err := g.modifyKernelParams(sender, func(kernelParams []string) ([]string, error) {
+		return replaceKernelParam(kernelParams, oldParam, newParam)
+	})
+	return dbusutil.ToError(err)
+}
+
+func (g *Grub2) emitSignalUpdateFailed(err error, kernelParamsRolledBack bool) {
+	emitErr := g.service.Emit(g, "UpdateFailed", err.Error(), kernelParamsRolledBack)
+	if emitErr != nil {
+		logger.Warning(emitErr)
+	}
+}
+
+func (g *Grub2) modifyKernelParams(sender dbus.Sender, modify kernelParamsModifier) error {
+	g.service.DelayAutoQuit()
+
+	err := g.checkAuth(sender, polikitActionIdCommon)
+	if err != nil {
+		return err
+	}
+
+	params, err := grub_common.LoadGrubParams()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	kernelParams, err := getKernelParams(params)
+	if err != nil {
+		return err
+	}
+	// 先按当前的配置检查，以便直接返回错误，真正的修改在任务执行时进行
+	_, err = modify(kernelParams)
+	if err != nil {
+		return err
+	}
+
+	g.addModifyTask(getModifyTaskKernelParams(modify))
+	return nil
+}
+
 // Reset reset all configuration.
 func (g *Grub2) Reset(sender dbus.Sender) *dbus.Error {
 	g.service.DelayAutoQuit()
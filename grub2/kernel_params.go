@@ -0,0 +1,314 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package grub2
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	errKernelParamExists          = errors.New("kernel parameter already exists")
+	errKernelParamNotFound        = errors.New("kernel parameter not found")
+	errKernelParamsShellExpansion = errors.New("kernel command line contains shell expansion, can not be edited")
+)
+
+// 参数名由字母、数字和 _ - . 组成，例如 nomodeset、iommu、nvidia-drm.modeset
+var kernelParamNameReg = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// 会被 shell 或 grub 脚本特殊处理的字符，不允许出现在参数中
+const kernelParamForbiddenChars = "$`\\'\";&|<>#{}"
+
+// kernelParamToken 命令行中的一个参数，raw 是它在配置文件中的原始内容，value 是去掉 shell 转义后的内容
+type kernelParamToken struct {
+	raw   string
+	value string
+}
+
+// parseKernelParamsValue 不经过 shell 解析 GRUB_CMDLINE_LINUX_DEFAULT 在配置文件中的原始值，
+// 返回其中的参数和值两边的引号。按内核的规则拆分，双引号中的空白不作为分隔符，
+// 例如 `"quiet acpi_osi=\"Windows 2015\""` 拆分为 quiet 和 acpi_osi="Windows 2015"。
+// 含有 $ 或 ` 的值需要 shell 展开，修改后无法保持原样，直接返回错误。
+func parseKernelParamsValue(value string) (tokens []kernelParamToken, quote byte, err error) {
+	if strings.ContainsAny(value, "$`") {
+		return nil, 0, errKernelParamsShellExpansion
+	}
+	inner := value
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		quote = value[0]
+		inner = value[1 : len(value)-1]
+	}
+	switch quote {
+	case '"':
+	case '\'':
+		if strings.ContainsRune(inner, '\'') {
+			return nil, 0, fmt.Errorf("unsupported quoting in kernel command line %s", value)
+		}
+	default:
+		if strings.ContainsAny(inner, "\"'\\") {
+			return nil, 0, fmt.Errorf("unsupported quoting in kernel command line %s", value)
+		}
+	}
+
+	var raw, decoded strings.Builder
+	var inQuote bool
+	flush := func() {
+		if raw.Len() > 0 {
+			tokens = append(tokens, kernelParamToken{raw: raw.String(), value: decoded.String()})
+			raw.Reset()
+			decoded.Reset()
+		}
+	}
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		if quote == '"' {
+			if c == '"' {
+				return nil, 0, fmt.Errorf("unsupported quoting in kernel command line %s", value)
+			}
+			if c == '\\' && i+1 < len(inner) {
+				// 双引号中只有 \" \\ 和续行是转义
+				switch next := inner[i+1]; next {
+				case '"', '\\':
+					i++
+					raw.WriteByte(c)
+					raw.WriteByte(next)
+					decoded.WriteByte(next)
+					if next == '"' {
+						inQuote = !inQuote
+					}
+					continue
+				case '\n':
+					i++
+					continue
+				}
+			}
+		} else if c == '"' {
+			inQuote = !inQuote
+		}
+		if !inQuote && (c == ' ' || c == '\t' || c == '\n') {
+			flush()
+			continue
+		}
+		raw.WriteByte(c)
+		decoded.WriteByte(c)
+	}
+	if inQuote {
+		return nil, 0, fmt.Errorf("unterminated quote in kernel command line %s", value)
+	}
+	flush()
+	return tokens, quote, nil
+}
+
+// encodeKernelParamsValue 生成 GRUB_CMDLINE_LINUX_DEFAULT 的值，
+// 没有修改的参数保持原来的内容，新的参数按原来的引号转义，没有引号时使用双引号。
+func encodeKernelParamsValue(tokens []kernelParamToken, quote byte, params []string) string {
+	rawMap := make(map[string]string, len(tokens))
+	for _, token := range tokens {
+		if _, ok := rawMap[token.value]; !ok {
+			rawMap[token.value] = token.raw
+		}
+	}
+	raws := make([]string, 0, len(params))
+	for _, param := range params {
+		raw, ok := rawMap[param]
+		if !ok {
+			raw = param
+			if quote != '\'' {
+				raw = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(param)
+			}
+		}
+		raws = append(raws, raw)
+	}
+	if quote == '\'' {
+		return "'" + joinKernelParams(raws) + "'"
+	}
+	return `"` + joinKernelParams(raws) + `"`
+}
+
+func joinKernelParams(params []string) string {
+	return strings.Join(params, " ")
+}
+
+// splitKernelParam 把参数拆分为参数名和值，hasValue 表示参数中是否有 =
+func splitKernelParam(param string) (name, value string, hasValue bool) {
+	idx := strings.IndexByte(param, '=')
+	if idx == -1 {
+		return param, "", false
+	}
+	return param[:idx], param[idx+1:], true
+}
+
+// normalizeKernelParamName 内核不区分参数名中的 - 和 _
+func normalizeKernelParamName(name string) string {
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// checkKernelParam 检查单个参数，值中含有空格时需要整体用双引号括起来
+func checkKernelParam(param string) error {
+	name, value, hasValue := splitKernelParam(param)
+	if !kernelParamNameReg.MatchString(name) || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid kernel parameter name %q", name)
+	}
+	if !hasValue {
+		return nil
+	}
+
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+	} else if strings.ContainsAny(value, " \t") {
+		return fmt.Errorf("invalid kernel parameter %q: value with spaces must be quoted", param)
+	}
+	for _, r := range value {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(kernelParamForbiddenChars, r) {
+			return fmt.Errorf("invalid kernel parameter %q: contains forbidden character %q", param, r)
+		}
+	}
+	return nil
+}
+
+// matchKernelParam 判断 param 是否匹配 pattern，pattern 中没有 = 时匹配所有同名参数，
+// 否则要求参数名和值都相同。
+func matchKernelParam(param, pattern string) bool {
+	name, value, hasValue := splitKernelParam(param)
+	patternName, patternValue, patternHasValue := splitKernelParam(pattern)
+	if normalizeKernelParamName(name) != normalizeKernelParamName(patternName) {
+		return false
+	}
+	if !patternHasValue {
+		return true
+	}
+	return hasValue && value == patternValue
+}
+
+// isSameKernelParam 判断两个参数是否相同，参数名中的 - 和 _ 视为相同
+func isSameKernelParam(a, b string) bool {
+	nameA, valueA, hasValueA := splitKernelParam(a)
+	nameB, valueB, hasValueB := splitKernelParam(b)
+	return normalizeKernelParamName(nameA) == normalizeKernelParamName(nameB) &&
+		hasValueA == hasValueB && valueA == valueB
+}
+
+// addKernelParam 在末尾追加参数，同名不同值的参数允许重复，例如 console=tty0 console=ttyS0
+func addKernelParam(params []string, param string) ([]string, error) {
+	err := checkKernelParam(param)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range params {
+		if isSameKernelParam(p, param) {
+			return nil, errKernelParamExists
+		}
+	}
+	result := make([]string, 0, len(params)+1)
+	result = append(result, params...)
+	return append(result, param), nil
+}
+
+// removeKernelParam 删除所有匹配 pattern 的参数
+func removeKernelParam(params []string, pattern string) ([]string, error) {
+	err := checkKernelParam(pattern)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(params))
+	for _, p := range params {
+		if !matchKernelParam(p, pattern) {
+			result = append(result, p)
+		}
+	}
+	if len(result) == len(params) {
+		return nil, errKernelParamNotFound
+	}
+	return result, nil
+}
+
+// replaceKernelParam 用 param 替换第一个匹配 pattern 的参数，并删除其余匹配的参数，
+// 参数的位置保持不变。
+func replaceKernelParam(params []string, pattern, param string) ([]string, error) {
+	err := checkKernelParam(pattern)
+	if err != nil {
+		return nil, err
+	}
+	err = checkKernelParam(param)
+	if err != nil {
+		return nil, err
+	}
+
+	var found bool
+	result := make([]string, 0, len(params))
+	for _, p := range params {
+		if matchKernelParam(p, pattern) {
+			if !found {
+				found = true
+				result = append(result, param)
+			}
+			continue
+		}
+		if isSameKernelParam(p, param) {
+			// 避免替换后出现重复的参数
+			continue
+		}
+		result = append(result, p)
+	}
+	if !found {
+		return nil, errKernelParamNotFound
+	}
+	return result, nil
+}
+
+func getKernelParamsValues(tokens []kernelParamToken) []string {
+	values := make([]string, len(tokens))
+	for i, token := range tokens {
+		values[i] = token.value
+	}
+	return values
+}
+
+func getKernelParams(params map[string]string) ([]string, error) {
+	tokens, _, err := parseKernelParamsValue(params[grubCmdlineLinuxDefault])
+	if err != nil {
+		return nil, err
+	}
+	return getKernelParamsValues(tokens), nil
+}
+
+// kernelParamsModifier 修改内核参数列表，返回修改后的列表
+type kernelParamsModifier func(kernelParams []string) ([]string, error)
+
+// getModifyTaskKernelParams 在任务执行时基于当时的配置修改内核参数，
+// 这样排队中的多个修改不会互相覆盖。
+func getModifyTaskKernelParams(modify kernelParamsModifier) modifyTask {
+	f := func(params map[string]string) {
+		tokens, quote, err := parseKernelParamsValue(params[grubCmdlineLinuxDefault])
+		var kernelParams []string
+		if err == nil {
+			kernelParams, err = modify(getKernelParamsValues(tokens))
+		}
+		if err != nil {
+			logger.Warning("failed to modify kernel params:", err)
+			return
+		}
+		params[grubCmdlineLinuxDefault] = encodeKernelParamsValue(tokens, quote, kernelParams)
+	}
+	return modifyTask{
+		paramsModifyFunc:     f,
+		rollbackKernelParams: true,
+	}
+}
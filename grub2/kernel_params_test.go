@@ -0,0 +1,224 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package grub2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseKernelParamsValue(t *testing.T) {
+	tokens, quote, err := parseKernelParamsValue("\"  splash quiet\tacpi_osi=\\\"Windows 2015\\\"  iommu=pt \"")
+	require.NoError(t, err)
+	assert.Equal(t, byte('"'), quote)
+	assert.Equal(t, []string{"splash", "quiet", `acpi_osi="Windows 2015"`, "iommu=pt"}, getKernelParamsValues(tokens))
+	assert.Equal(t, `acpi_osi=\"Windows 2015\"`, tokens[2].raw)
+
+	tokens, quote, err = parseKernelParamsValue(`'quiet acpi_osi="Windows 2015"'`)
+	require.NoError(t, err)
+	assert.Equal(t, byte('\''), quote)
+	assert.Equal(t, []string{"quiet", `acpi_osi="Windows 2015"`}, getKernelParamsValues(tokens))
+
+	tokens, quote, err = parseKernelParamsValue("quiet")
+	require.NoError(t, err)
+	assert.Equal(t, byte(0), quote)
+	assert.Equal(t, []string{"quiet"}, getKernelParamsValues(tokens))
+
+	tokens, _, err = parseKernelParamsValue("")
+	require.NoError(t, err)
+	assert.Len(t, tokens, 0)
+
+	for _, value := range []string{
+		`"quiet acpi_osi=\"Windows"`,
+		`"quiet "splash`,
+		`quiet\ splash`,
+	} {
+		_, _, err = parseKernelParamsValue(value)
+		assert.Error(t, err, value)
+	}
+
+	// 需要 shell 展开的值不能修改
+	for _, value := range []string{
+		`"quiet splash $vt_handoff"`,
+		`"$GRUB_CMDLINE_LINUX_DEFAULT DEEPIN_GFXMODE=\$DEEPIN_GFXMODE"`,
+		"\"quiet `cat /etc/cmdline`\"",
+	} {
+		_, _, err = parseKernelParamsValue(value)
+		assert.Equal(t, errKernelParamsShellExpansion, err, value)
+	}
+
+	assert.Equal(t, `quiet acpi_osi="Windows 2015"`,
+		joinKernelParams([]string{"quiet", `acpi_osi="Windows 2015"`}))
+}
+
+func Test_encodeKernelParamsValue(t *testing.T) {
+	// 没有修改的参数保持原样
+	tokens, quote, err := parseKernelParamsValue(`"quiet  path=C:\dir acpi_osi=\"Windows 2015\""`)
+	require.NoError(t, err)
+	params, err := addKernelParam(getKernelParamsValues(tokens), `acpi_osi="Linux"`)
+	require.NoError(t, err)
+	assert.Equal(t, `"quiet path=C:\dir acpi_osi=\"Windows 2015\" acpi_osi=\"Linux\""`,
+		encodeKernelParamsValue(tokens, quote, params))
+
+	tokens, quote, err = parseKernelParamsValue(`'quiet splash'`)
+	require.NoError(t, err)
+	params, err = addKernelParam(getKernelParamsValues(tokens), `acpi_osi="Linux"`)
+	require.NoError(t, err)
+	assert.Equal(t, `'quiet splash acpi_osi="Linux"'`, encodeKernelParamsValue(tokens, quote, params))
+
+	tokens, quote, err = parseKernelParamsValue("quiet")
+	require.NoError(t, err)
+	assert.Equal(t, `"quiet nomodeset"`, encodeKernelParamsValue(tokens, quote, []string{"quiet", "nomodeset"}))
+	assert.Equal(t, `""`, encodeKernelParamsValue(nil, 0, nil))
+}
+
+func Test_checkKernelParam(t *testing.T) {
+	for _, param := range []string{
+		"nomodeset",
+		"mem_sleep_default=deep",
+		"iommu=pt",
+		"nvidia-drm.modeset=1",
+		"root=UUID=0a1b2c3d-0000-1111-2222-333344445555",
+		`acpi_osi="Windows 2015"`,
+		"acpi_osi=",
+	} {
+		assert.NoError(t, checkKernelParam(param), param)
+	}
+
+	for _, param := range []string{
+		"",
+		"=1",
+		"-quiet",
+		"no modeset",
+		"acpi_osi=Windows 2015",
+		`acpi_osi="Win"dows"`,
+		"init=/bin/sh;reboot",
+		"quiet=$HOME",
+		"quiet=`id`",
+		"quiet=a\nb",
+	} {
+		assert.Error(t, checkKernelParam(param), param)
+	}
+}
+
+func Test_addKernelParam(t *testing.T) {
+	params := []string{"splash", "quiet", "console=tty0"}
+
+	result, err := addKernelParam(params, "nomodeset")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"splash", "quiet", "console=tty0", "nomodeset"}, result)
+	// 原来的列表不变
+	assert.Equal(t, []string{"splash", "quiet", "console=tty0"}, params)
+
+	result, err = addKernelParam(params, "console=ttyS0,115200")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"splash", "quiet", "console=tty0", "console=ttyS0,115200"}, result)
+
+	_, err = addKernelParam(params, "quiet")
+	assert.Equal(t, errKernelParamExists, err)
+
+	_, err = addKernelParam([]string{"mem_sleep_default=deep"}, "mem-sleep-default=deep")
+	assert.Equal(t, errKernelParamExists, err)
+
+	_, err = addKernelParam(params, "bad param")
+	assert.Error(t, err)
+}
+
+func Test_removeKernelParam(t *testing.T) {
+	params := []string{"splash", "console=tty0", "quiet", "console=ttyS0"}
+
+	result, err := removeKernelParam(params, "console")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"splash", "quiet"}, result)
+
+	result, err = removeKernelParam(params, "console=ttyS0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"splash", "console=tty0", "quiet"}, result)
+
+	_, err = removeKernelParam(params, "console=ttyS1")
+	assert.Equal(t, errKernelParamNotFound, err)
+
+	_, err = removeKernelParam(params, "nomodeset")
+	assert.Equal(t, errKernelParamNotFound, err)
+}
+
+func Test_replaceKernelParam(t *testing.T) {
+	params := []string{"splash", "iommu", "quiet", "iommu=soft"}
+
+	result, err := replaceKernelParam(params, "iommu", "iommu=pt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"splash", "iommu=pt", "quiet"}, result)
+
+	result, err = replaceKernelParam(params, "iommu=soft", "iommu=pt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"splash", "iommu", "quiet", "iommu=pt"}, result)
+
+	result, err = replaceKernelParam([]string{"splash", "quiet"}, "splash", "quiet")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"quiet"}, result)
+
+	_, err = replaceKernelParam(params, "nomodeset", "iommu=pt")
+	assert.Equal(t, errKernelParamNotFound, err)
+
+	_, err = replaceKernelParam(params, "iommu", "iommu=$(reboot)")
+	assert.Error(t, err)
+}
+
+func Test_getModifyTaskKernelParams(t *testing.T) {
+	params := map[string]string{
+		grubCmdlineLinuxDefault: `"splash quiet acpi_osi=\"Windows 2015\""`,
+	}
+	kernelParams, err := getKernelParams(params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"splash", "quiet", `acpi_osi="Windows 2015"`}, kernelParams)
+
+	task := getModifyTaskKernelParams(func(kernelParams []string) ([]string, error) {
+		return addKernelParam(kernelParams, "nomodeset")
+	})
+	assert.True(t, task.rollbackKernelParams)
+	task.paramsModifyFunc(params)
+	assert.Equal(t, `"splash quiet acpi_osi=\"Windows 2015\" nomodeset"`, params[grubCmdlineLinuxDefault])
+
+	kernelParams, err = getKernelParams(params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"splash", "quiet", `acpi_osi="Windows 2015"`, "nomodeset"}, kernelParams)
+
+	kernelParams, err = getKernelParams(map[string]string{})
+	require.NoError(t, err)
+	assert.Len(t, kernelParams, 0)
+
+	// 需要 shell 展开的值保持不变
+	value := `"quiet splash $vt_handoff"`
+	params[grubCmdlineLinuxDefault] = value
+	task.paramsModifyFunc(params)
+	assert.Equal(t, value, params[grubCmdlineLinuxDefault])
+}
+
+func Test_kernelParamsBackup(t *testing.T) {
+	params := map[string]string{grubCmdlineLinuxDefault: `"quiet nomodeset"`}
+	backup := &kernelParamsBackup{value: `"quiet"`, exist: true}
+	backup.restore(params)
+	assert.Equal(t, `"quiet"`, params[grubCmdlineLinuxDefault])
+
+	backup = &kernelParamsBackup{}
+	backup.restore(params)
+	_, ok := params[grubCmdlineLinuxDefault]
+	assert.False(t, ok)
+}
@@ -88,8 +88,10 @@ func (m *modifyManager) start(tasks ...modifyTask) {
 	params, _ := grub_common.LoadGrubParams()
 
 	logger.Debug("modifyManager.start len(tasks):", len(tasks))
+	oldCmdline, oldCmdlineExist := params[grubCmdlineLinuxDefault]
 	var adjustTheme bool
 	var adjustThemeLang string
+	var rollback *kernelParamsBackup
 	for _, task := range tasks {
 		f := task.paramsModifyFunc
 		if f != nil {
@@ -99,6 +101,16 @@ func (m *modifyManager) start(tasks ...modifyTask) {
 			adjustTheme = true
 			adjustThemeLang = task.adjustThemeLang
 		}
+		if task.rollbackKernelParams && rollback == nil {
+			rollback = &kernelParamsBackup{
+				value: oldCmdline,
+				exist: oldCmdlineExist,
+			}
+		}
+	}
+	if rollback != nil && params[grubCmdlineLinuxDefault] == oldCmdline {
+		// 内核参数没有变化，不需要回滚
+		rollback = nil
 	}
 	err := writeGrubParams(params)
 	if err != nil {
@@ -109,10 +121,24 @@ func (m *modifyManager) start(tasks ...modifyTask) {
 	logStart()
 	m.running = true
 	m.notifyStateChange()
-	go m.update(adjustTheme, adjustThemeLang)
+	go m.update(adjustTheme, adjustThemeLang, rollback)
+}
+
+// kernelParamsBackup 记录修改前的 GRUB_CMDLINE_LINUX_DEFAULT，用于 update-grub 失败时回滚
+type kernelParamsBackup struct {
+	value string
+	exist bool
+}
+
+func (b *kernelParamsBackup) restore(params map[string]string) {
+	if b.exist {
+		params[grubCmdlineLinuxDefault] = b.value
+	} else {
+		delete(params, grubCmdlineLinuxDefault)
+	}
 }
 
-func (m *modifyManager) update(adjustTheme bool, adjustThemeLang string) {
+func (m *modifyManager) update(adjustTheme bool, adjustThemeLang string, rollback *kernelParamsBackup) {
 	if adjustTheme {
 		logJobStart(logJobAdjustTheme)
 		err := copyBgSource(defaultThemeDir, defaultThemeTmpDir)
@@ -150,9 +176,40 @@ func (m *modifyManager) update(adjustTheme bool, adjustThemeLang string) {
 		logger.Warning("failed to make config:", err)
 	}
 	logJobEnd(logJobMkConfig, err)
+	if err != nil {
+		var rolledBack bool
+		if rollback != nil {
+			rolledBack = m.rollbackKernelParams(rollback)
+		}
+		m.g.emitSignalUpdateFailed(err, rolledBack)
+	}
 	m.updateEnd()
 }
 
+// rollbackKernelParams 恢复修改前的内核参数，并重新生成 grub 配置，返回是否恢复成功
+func (m *modifyManager) rollbackKernelParams(rollback *kernelParamsBackup) bool {
+	logger.Warningf("update grub failed, rollback kernel params to %s", rollback.value)
+	params, err := grub_common.LoadGrubParams()
+	if err != nil {
+		logger.Warning("failed to load grub params:", err)
+		return false
+	}
+	rollback.restore(params)
+	err = writeGrubParams(params)
+	if err != nil {
+		logger.Warning("failed to write grub params:", err)
+		return false
+	}
+
+	logJobStart(logJobMkConfig)
+	err = runUpdateGrub()
+	if err != nil {
+		logger.Warning("failed to make config after rollback:", err)
+	}
+	logJobEnd(logJobMkConfig, err)
+	return err == nil
+}
+
 func runUpdateGrub() error {
 	updateGrubPath, err := exec.LookPath(updateGrubCmd)
 	var cmd *exec.Cmd
@@ -0,0 +1,240 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package housekeeping
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	cleanupTrash      = "trash"
+	cleanupThumbnails = "thumbnails"
+	cleanupAppLogs    = "app-logs"
+	cleanupCache      = "cache"
+)
+
+// 按照通知中动作的顺序排列
+var cleanupNames = []string{cleanupTrash, cleanupThumbnails, cleanupAppLogs, cleanupCache}
+
+type cleanupItem struct {
+	path string
+	size uint64
+}
+
+type cleanupReport struct {
+	Name  string
+	Bytes uint64
+	Files int
+}
+
+// cleaner 收集各个清理项可以删除的文件
+type cleaner struct {
+	home string
+	cfg  *config
+	now  time.Time
+}
+
+func newCleaner(home string, cfg *config) *cleaner {
+	return &cleaner{
+		home: home,
+		cfg:  cfg,
+		now:  time.Now(),
+	}
+}
+
+func isValidCleanup(name string) bool {
+	for _, n := range cleanupNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cleaner) cacheDir() string {
+	return filepath.Join(c.home, ".cache")
+}
+
+func (c *cleaner) thumbnailsDir() string {
+	return filepath.Join(c.cacheDir(), "thumbnails")
+}
+
+// 应用的日志文件，比如 ~/.cache/deepin/deepin-music/deepin-music.log
+func (c *cleaner) appLogsDir() string {
+	return filepath.Join(c.cacheDir(), "deepin")
+}
+
+func (c *cleaner) collect(name string) ([]cleanupItem, error) {
+	switch name {
+	case cleanupTrash:
+		return c.collectTrash()
+	case cleanupThumbnails:
+		return collectDirEntries(c.thumbnailsDir())
+	case cleanupAppLogs:
+		return c.collectAppLogs()
+	case cleanupCache:
+		return c.collectCache()
+	default:
+		return nil, fmt.Errorf("invalid cleanup %q", name)
+	}
+}
+
+func (c *cleaner) collectTrash() ([]cleanupItem, error) {
+	trashDir := filepath.Join(c.home, ".local/share/Trash")
+	var result []cleanupItem
+	for _, sub := range []string{"files", "info", "expunged"} {
+		items, err := collectDirEntries(filepath.Join(trashDir, sub))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+// collectAppLogs 收集应用的旧日志文件。
+// 用户的 journal 位于 /var/log/journal 中，普通用户没有权限删除，由 journald 按自己的配置清理。
+func (c *cleaner) collectAppLogs() ([]cleanupItem, error) {
+	maxAge := time.Duration(c.cfg.LogMaxAgeDays) * 24 * time.Hour
+	return c.collectOldFiles(c.appLogsDir(), maxAge, isLogFile)
+}
+
+// collectCache 收集 ~/.cache 中过期的文件，缩略图和应用日志由单独的清理项处理
+func (c *cleaner) collectCache() ([]cleanupItem, error) {
+	maxAge := time.Duration(c.cfg.CacheMaxAgeDays) * 24 * time.Hour
+	thumbnailsDir := c.thumbnailsDir()
+	appLogsDir := c.appLogsDir()
+	return c.collectOldFiles(c.cacheDir(), maxAge, func(path string) bool {
+		if isSubPath(thumbnailsDir, path) {
+			return false
+		}
+		if isSubPath(appLogsDir, path) && isLogFile(path) {
+			return false
+		}
+		return true
+	})
+}
+
+// collectOldFiles 收集 dir 中超过 maxAge 没有修改的文件，不跟随符号链接
+func (c *cleaner) collectOldFiles(dir string, maxAge time.Duration, filter func(path string) bool) ([]cleanupItem, error) {
+	var result []cleanupItem
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if c.now.Sub(info.ModTime()) < maxAge {
+			return nil
+		}
+		if filter != nil && !filter(path) {
+			return nil
+		}
+		result = append(result, cleanupItem{path: path, size: uint64(info.Size())})
+		return nil
+	})
+	return result, err
+}
+
+// collectDirEntries 收集 dir 中的所有一级子项，目录的大小为其中所有文件的大小之和
+func collectDirEntries(dir string) ([]cleanupItem, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	_ = f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cleanupItem, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		result = append(result, cleanupItem{path: path, size: getDiskUsage(path)})
+	}
+	return result, nil
+}
+
+// getDiskUsage 返回文件或目录的大小，不跟随符号链接
+func getDiskUsage(path string) uint64 {
+	var size uint64
+	_ = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size
+}
+
+func isLogFile(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasSuffix(name, ".log") || strings.Contains(name, ".log.")
+}
+
+func isSubPath(dir, path string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// scan 返回清理项可以释放的空间，不删除文件
+func (c *cleaner) scan(name string) (*cleanupReport, error) {
+	items, err := c.collect(name)
+	if err != nil {
+		return nil, err
+	}
+	report := &cleanupReport{Name: name, Files: len(items)}
+	for _, item := range items {
+		report.Bytes += item.size
+	}
+	return report, nil
+}
+
+// run 删除清理项的文件，返回实际释放的空间
+func (c *cleaner) run(name string) (*cleanupReport, error) {
+	items, err := c.collect(name)
+	if err != nil {
+		return nil, err
+	}
+	report := &cleanupReport{Name: name}
+	for _, item := range items {
+		err = os.RemoveAll(item.path)
+		if err != nil {
+			logger.Warningf("remove %s failed: %v", item.path, err)
+			continue
+		}
+		report.Files++
+		report.Bytes += item.size
+	}
+	logger.Infof("cleanup %s: removed %d files, %d bytes", name, report.Files, report.Bytes)
+	return report, nil
+}
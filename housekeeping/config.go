@@ -0,0 +1,223 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package housekeeping
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var configPaths = []string{
+	"/etc/deepin/housekeeping/config.json",       // 用户
+	"/usr/share/deepin/housekeeping/config.json", // 软件包
+}
+
+type config struct {
+	IntervalSec     int              `json:"intervalSec"`     // 检查磁盘空间的周期
+	RenotifyMinutes int              `json:"renotifyMinutes"` // 级别没有变化时再次提醒的间隔，为 0 时不再提醒
+	CacheMaxAgeDays int              `json:"cacheMaxAgeDays"` // 清理 ~/.cache 中超过该天数未修改的文件
+	LogMaxAgeDays   int              `json:"logMaxAgeDays"`   // 清理应用日志中超过该天数未修改的文件
+	MountPoints     []*mountPointCfg `json:"mountPoints"`
+}
+
+type mountPointCfg struct {
+	Path     string        `json:"path"` // 挂载点中的任意路径，支持 $HOME
+	Warning  *thresholdCfg `json:"warning"`
+	Critical *thresholdCfg `json:"critical"`
+}
+
+// thresholdCfg 可用空间低于 MinAvail 或者低于总空间的 MinAvailPercent 时触发
+type thresholdCfg struct {
+	MinAvail        string  `json:"minAvail"` // 字节数，支持 K M G 后缀
+	MinAvailPercent float64 `json:"minAvailPercent"`
+
+	minAvail uint64
+}
+
+func getDefaultConfig() *config {
+	return &config{
+		IntervalSec:     60,
+		RenotifyMinutes: 30,
+		CacheMaxAgeDays: 30,
+		LogMaxAgeDays:   7,
+		MountPoints: []*mountPointCfg{
+			{
+				Path:     "$HOME",
+				Warning:  &thresholdCfg{MinAvail: "2G", MinAvailPercent: 5},
+				Critical: &thresholdCfg{MinAvail: "500M"},
+			},
+			{
+				Path:     "/",
+				Warning:  &thresholdCfg{MinAvail: "1G", MinAvailPercent: 5},
+				Critical: &thresholdCfg{MinAvail: "200M"},
+			},
+			{
+				Path:     "/tmp",
+				Critical: &thresholdCfg{MinAvail: "100M"},
+			},
+			{
+				Path:     "/var",
+				Warning:  &thresholdCfg{MinAvail: "1G"},
+				Critical: &thresholdCfg{MinAvail: "200M"},
+			},
+		},
+	}
+}
+
+func (t *thresholdCfg) check() error {
+	if t.MinAvailPercent < 0 || t.MinAvailPercent > 100 {
+		return fmt.Errorf("invalid minAvailPercent %v", t.MinAvailPercent)
+	}
+	if t.MinAvail != "" {
+		size, err := parseSize(t.MinAvail)
+		if err != nil {
+			return err
+		}
+		t.minAvail = size
+	}
+	return nil
+}
+
+// match 返回可用空间是否低于阈值
+func (t *thresholdCfg) match(total, avail uint64) bool {
+	if t == nil {
+		return false
+	}
+	if t.minAvail != 0 && avail < t.minAvail {
+		return true
+	}
+	if t.MinAvailPercent != 0 && total != 0 &&
+		float64(avail)*100 < t.MinAvailPercent*float64(total) {
+		return true
+	}
+	return false
+}
+
+func (mp *mountPointCfg) getLevel(total, avail uint64) diskLevel {
+	if mp.Critical.match(total, avail) {
+		return diskLevelCritical
+	}
+	if mp.Warning.match(total, avail) {
+		return diskLevelWarning
+	}
+	return diskLevelNormal
+}
+
+// getPath 返回展开 $HOME 等环境变量后的路径
+func (mp *mountPointCfg) getPath() string {
+	return os.ExpandEnv(mp.Path)
+}
+
+func (c *config) check() error {
+	if c.IntervalSec <= 0 {
+		return fmt.Errorf("invalid intervalSec %d", c.IntervalSec)
+	}
+	if c.RenotifyMinutes < 0 {
+		return fmt.Errorf("invalid renotifyMinutes %d", c.RenotifyMinutes)
+	}
+	if c.CacheMaxAgeDays <= 0 {
+		return fmt.Errorf("invalid cacheMaxAgeDays %d", c.CacheMaxAgeDays)
+	}
+	if c.LogMaxAgeDays <= 0 {
+		return fmt.Errorf("invalid logMaxAgeDays %d", c.LogMaxAgeDays)
+	}
+	for _, mp := range c.MountPoints {
+		if mp == nil || mp.Path == "" {
+			return fmt.Errorf("invalid mount point %v", mp)
+		}
+		for _, t := range []*thresholdCfg{mp.Warning, mp.Critical} {
+			if t == nil {
+				continue
+			}
+			err := t.check()
+			if err != nil {
+				return fmt.Errorf("mount point %q: %v", mp.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseSize 将 2G 512M 这样的大小转换为字节数
+func parseSize(str string) (uint64, error) {
+	str = strings.TrimSpace(str)
+	var unit uint64 = 1
+	if len(str) > 0 {
+		switch str[len(str)-1] {
+		case 'K', 'k':
+			unit = 1 << 10
+		case 'M', 'm':
+			unit = 1 << 20
+		case 'G', 'g':
+			unit = 1 << 30
+		}
+		if unit != 1 {
+			str = str[:len(str)-1]
+		}
+	}
+
+	num, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", str)
+	}
+	return num * unit, nil
+}
+
+func loadConfigAux(filename string) (*config, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// 配置文件中没有的项使用默认值
+	cfg := getDefaultConfig()
+	defaultMountPoints := cfg.MountPoints
+	cfg.MountPoints = nil
+	err = json.Unmarshal(content, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MountPoints == nil {
+		cfg.MountPoints = defaultMountPoints
+	}
+	err = cfg.check()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadConfig() *config {
+	for _, p := range configPaths {
+		cfg, err := loadConfigAux(p)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logger.Warningf("load config %s failed: %v", p, err)
+			}
+			continue
+		}
+		logger.Debug("load config file:", p)
+		return cfg
+	}
+	cfg := getDefaultConfig()
+	_ = cfg.check()
+	return cfg
+}
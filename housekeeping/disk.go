@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package housekeeping
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+type diskLevel int
+
+const (
+	diskLevelNormal diskLevel = iota
+	diskLevelWarning
+	diskLevelCritical
+)
+
+func (l diskLevel) String() string {
+	switch l {
+	case diskLevelNormal:
+		return "normal"
+	case diskLevelWarning:
+		return "warning"
+	case diskLevelCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("diskLevel(%d)", int(l))
+	}
+}
+
+func (l diskLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+type mountPointStatus struct {
+	Path  string
+	Total uint64
+	Avail uint64
+	Level diskLevel
+
+	dev uint64
+}
+
+// queryMountPoint 获取路径所在文件系统的总空间和普通用户可用的空间
+func queryMountPoint(path string) (*mountPointStatus, error) {
+	var st syscall.Statfs_t
+	err := syscall.Statfs(path, &st)
+	if err != nil {
+		return nil, err
+	}
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("failed to get device of %s", path)
+	}
+	return &mountPointStatus{
+		Path:  path,
+		Total: uint64(st.Blocks) * uint64(st.Bsize),
+		Avail: uint64(st.Bavail) * uint64(st.Bsize),
+		dev:   uint64(stat.Dev),
+	}, nil
+}
+
+// queryMountPoints 获取配置的各个挂载点的状态，位于同一个文件系统的路径只保留级别最高的一个
+func queryMountPoints(cfg *config) []*mountPointStatus {
+	var result []*mountPointStatus
+	devIdx := make(map[uint64]int)
+	for _, mp := range cfg.MountPoints {
+		path := mp.getPath()
+		status, err := queryMountPoint(path)
+		if err != nil {
+			logger.Debugf("query filesystem info of %s failed: %v", path, err)
+			continue
+		}
+		status.Level = mp.getLevel(status.Total, status.Avail)
+
+		idx, ok := devIdx[status.dev]
+		if !ok {
+			devIdx[status.dev] = len(result)
+			result = append(result, status)
+			continue
+		}
+		if status.Level > result[idx].Level {
+			result[idx] = status
+		}
+	}
+	return result
+}
+
+// formatSize 将字节数转换为便于阅读的字符串
+func formatSize(size uint64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d %s", size, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
@@ -0,0 +1,144 @@
+package housekeeping
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "housekeeping")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(filename, []byte(`{"intervalSec": 30,
+"mountPoints": [{"path": "/", "critical": {"minAvail": "1G", "minAvailPercent": 2}}]}`), 0644)
+	require.NoError(t, err)
+	cfg, err := loadConfigAux(filename)
+	require.NoError(t, err)
+	assert.Equal(t, 30, cfg.IntervalSec)
+	assert.Equal(t, 30, cfg.CacheMaxAgeDays)
+	require.Len(t, cfg.MountPoints, 1)
+	assert.Nil(t, cfg.MountPoints[0].Warning)
+	assert.Equal(t, uint64(1<<30), cfg.MountPoints[0].Critical.minAvail)
+
+	err = ioutil.WriteFile(filename, []byte(`{"mountPoints": [{"path": "/", "warning": {"minAvail": "1T"}}]}`), 0644)
+	require.NoError(t, err)
+	_, err = loadConfigAux(filename)
+	assert.Error(t, err)
+
+	err = ioutil.WriteFile(filename, []byte(`{"logMaxAgeDays": 3}`), 0644)
+	require.NoError(t, err)
+	cfg, err = loadConfigAux(filename)
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.LogMaxAgeDays)
+	assert.Len(t, cfg.MountPoints, len(getDefaultConfig().MountPoints))
+}
+
+func TestMountPointLevel(t *testing.T) {
+	mp := &mountPointCfg{
+		Path:     "/",
+		Warning:  &thresholdCfg{MinAvail: "2G", MinAvailPercent: 10},
+		Critical: &thresholdCfg{MinAvail: "500M"},
+	}
+	require.NoError(t, mp.Warning.check())
+	require.NoError(t, mp.Critical.check())
+
+	const gb = 1 << 30
+	assert.Equal(t, diskLevelNormal, mp.getLevel(100*gb, 50*gb))
+	assert.Equal(t, diskLevelWarning, mp.getLevel(100*gb, 9*gb))
+	assert.Equal(t, diskLevelWarning, mp.getLevel(10*gb, 1*gb+1))
+	assert.Equal(t, diskLevelCritical, mp.getLevel(100*gb, 100<<20))
+}
+
+func TestUpdateLevel(t *testing.T) {
+	m := &Manager{
+		cfg:    &config{RenotifyMinutes: 30},
+		levels: make(map[uint64]*levelState),
+	}
+	now := time.Now()
+	status := &mountPointStatus{dev: 1, Level: diskLevelWarning}
+	assert.True(t, m.updateLevel(status, now))
+	assert.False(t, m.updateLevel(status, now.Add(time.Minute)))
+	assert.True(t, m.updateLevel(status, now.Add(31*time.Minute)))
+
+	// 级别升高时立即提醒
+	status.Level = diskLevelCritical
+	assert.True(t, m.updateLevel(status, now.Add(32*time.Minute)))
+	status.Level = diskLevelWarning
+	assert.False(t, m.updateLevel(status, now.Add(33*time.Minute)))
+
+	// 空间恢复后再次不足时重新提醒
+	status.Level = diskLevelNormal
+	assert.False(t, m.updateLevel(status, now.Add(34*time.Minute)))
+	status.Level = diskLevelWarning
+	assert.True(t, m.updateLevel(status, now.Add(35*time.Minute)))
+}
+
+func TestCleaner(t *testing.T) {
+	home, err := ioutil.TempDir("", "housekeeping-home")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	writeFile := func(path string, size int, modTime time.Time) {
+		path = filepath.Join(home, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, make([]byte, size), 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	writeFile(".local/share/Trash/files/a/b.txt", 100, time.Now())
+	writeFile(".local/share/Trash/info/a.trashinfo", 10, time.Now())
+	writeFile(".cache/thumbnails/normal/x.png", 20, time.Now())
+	writeFile(".cache/deepin/app/app.log", 30, old)
+	writeFile(".cache/deepin/app/app.log.1", 40, time.Now())
+	writeFile(".cache/deepin/app/data", 50, old)
+	writeFile(".cache/other/old", 60, old)
+	writeFile(".cache/other/new", 70, time.Now())
+
+	c := newCleaner(home, getDefaultConfig())
+	tests := []struct {
+		name  string
+		bytes uint64
+		files int
+	}{
+		{cleanupTrash, 110, 2},
+		{cleanupThumbnails, 20, 1},
+		{cleanupAppLogs, 30, 1},
+		{cleanupCache, 110, 2},
+	}
+	for _, test := range tests {
+		report, err := c.scan(test.name)
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.bytes, report.Bytes, test.name)
+		assert.Equal(t, test.files, report.Files, test.name)
+	}
+	_, err = c.scan("invalid")
+	assert.Error(t, err)
+
+	report, err := c.run(cleanupCache)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(110), report.Bytes)
+	_, err = os.Stat(filepath.Join(home, ".cache/other/old"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(home, ".cache/other/new"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(home, ".cache/deepin/app/app.log"))
+	assert.NoError(t, err)
+
+	report, err = c.scan(cleanupCache)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Files)
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "100 B", formatSize(100))
+	assert.Equal(t, "1.5 KB", formatSize(1536))
+	assert.Equal(t, "2.0 GB", formatSize(2<<30))
+}
@@ -20,20 +20,8 @@
 package housekeeping
 
 import (
-	"os"
-	"time"
-
-	"github.com/godbus/dbus"
-	notifications "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.notifications"
-	. "github.com/linuxdeepin/go-lib/gettext"
-	"github.com/linuxdeepin/go-lib/log"
-	"github.com/linuxdeepin/go-lib/utils"
 	"github.com/linuxdeepin/dde-daemon/loader"
-)
-
-const (
-	// 500MB
-	fsMinLeftSpace = 1024 * 1024 * 500
+	"github.com/linuxdeepin/go-lib/log"
 )
 
 func init() {
@@ -42,8 +30,7 @@ func init() {
 
 type Daemon struct {
 	*loader.ModuleBase
-	ticker   *time.Ticker
-	stopChan chan struct{}
+	manager *Manager
 }
 
 func NewDaemon(logger *log.Logger) *Daemon {
@@ -61,65 +48,41 @@ var (
 )
 
 func (d *Daemon) Start() error {
-	if d.stopChan != nil {
+	if d.manager != nil {
 		return nil
 	}
 
-	d.ticker = time.NewTicker(time.Minute * 1)
-	d.stopChan = make(chan struct{})
-	go func() {
-		for {
-			select {
-			case _, ok := <-d.ticker.C:
-				if !ok {
-					logger.Error("Invalid ticker event")
-					return
-				}
+	service := loader.GetService()
+	m := newManager(service)
+	err := m.init()
+	if err != nil {
+		return err
+	}
 
-				fs, err := utils.QueryFilesytemInfo(os.Getenv("HOME"))
-				if err != nil {
-					logger.Error("Failed to get filesystem info:", err)
-					break
-				}
-				logger.Debug("Home filesystem info(total, free, avail):",
-					fs.TotalSize, fs.FreeSize, fs.AvailSize)
-				if fs.AvailSize > fsMinLeftSpace {
-					break
-				}
-				err = sendNotify("dialog-warning", "",
-					Tr("Insufficient disk space, please clean up in time!"))
-				if err != nil {
-					logger.Warning(err)
-				}
-			case <-d.stopChan:
-				logger.Debug("Stop housekeeping")
-				if d.ticker != nil {
-					d.ticker.Stop()
-					d.ticker = nil
-				}
-				return
-			}
-		}
-	}()
-	return nil
-}
+	err = service.Export(dbusPath, m)
+	if err != nil {
+		m.destroy()
+		return err
+	}
 
-func (d *Daemon) Stop() error {
-	if d.stopChan != nil {
-		close(d.stopChan)
-		d.stopChan = nil
+	err = service.RequestName(dbusServiceName)
+	if err != nil {
+		m.destroy()
+		_ = service.StopExport(m)
+		return err
 	}
+	d.manager = m
 	return nil
 }
 
-func sendNotify(icon, summary, body string) error {
-	sessionConn, err := dbus.SessionBus()
-	if err != nil {
-		return err
+func (d *Daemon) Stop() error {
+	if d.manager == nil {
+		return nil
 	}
-	notifier := notifications.NewNotifications(sessionConn)
-	_, err = notifier.Notify(0, "dde-control-center", 0,
-		icon, summary, body,
-		nil, nil, -1)
-	return err
+
+	service := loader.GetService()
+	_ = service.StopExport(d.manager)
+	d.manager.destroy()
+	d.manager = nil
+	return nil
 }
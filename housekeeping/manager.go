@@ -0,0 +1,213 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package housekeeping
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus"
+	notifications "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.notifications"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/go-lib/dbusutil/proxy"
+)
+
+const (
+	dbusServiceName = "com.deepin.daemon.Housekeeping"
+	dbusPath        = "/com/deepin/daemon/Housekeeping"
+	dbusInterface   = dbusServiceName
+)
+
+//go:generate dbusutil-gen em -type Manager
+
+type Manager struct {
+	service       *dbusutil.Service
+	sigLoop       *dbusutil.SignalLoop
+	notifications notifications.Notifications
+	cfg           *config
+	home          string
+
+	mu            sync.Mutex
+	levels        map[uint64]*levelState // key 为文件系统的设备号
+	notifyActions map[uint32]struct{}    // 带有清理动作的通知
+
+	cleanupMu sync.Mutex // 同一时间只运行一个清理
+	stopChan  chan struct{}
+}
+
+// levelState 记录文件系统上次提醒时的级别
+type levelState struct {
+	level      diskLevel
+	notifyTime time.Time
+}
+
+func newManager(service *dbusutil.Service) *Manager {
+	return &Manager{
+		service:       service,
+		cfg:           loadConfig(),
+		home:          os.Getenv("HOME"),
+		levels:        make(map[uint64]*levelState),
+		notifyActions: make(map[uint32]struct{}),
+	}
+}
+
+func (*Manager) GetInterfaceName() string {
+	return dbusInterface
+}
+
+func (m *Manager) init() error {
+	sessionBus := m.service.Conn()
+	m.sigLoop = dbusutil.NewSignalLoop(sessionBus, 10)
+	m.sigLoop.Start()
+	m.notifications = notifications.NewNotifications(sessionBus)
+	m.notifications.InitSignalExt(m.sigLoop, true)
+	_, err := m.notifications.ConnectActionInvoked(m.handleActionInvoked)
+	if err != nil {
+		logger.Warning("connect action invoked failed:", err)
+	}
+
+	m.stopChan = make(chan struct{})
+	go m.loop()
+	return nil
+}
+
+func (m *Manager) destroy() {
+	if m.stopChan != nil {
+		close(m.stopChan)
+		m.stopChan = nil
+	}
+	if m.notifications != nil {
+		m.notifications.RemoveHandler(proxy.RemoveAllHandlers)
+	}
+	if m.sigLoop != nil {
+		m.sigLoop.Stop()
+	}
+}
+
+func (m *Manager) loop() {
+	ticker := time.NewTicker(time.Duration(m.cfg.IntervalSec) * time.Second)
+	defer ticker.Stop()
+	stopChan := m.stopChan
+	m.checkDiskSpace()
+	for {
+		select {
+		case <-ticker.C:
+			m.checkDiskSpace()
+		case <-stopChan:
+			logger.Debug("Stop housekeeping")
+			return
+		}
+	}
+}
+
+// checkDiskSpace 检查各个挂载点的可用空间，级别升高时提醒
+func (m *Manager) checkDiskSpace() {
+	statuses := queryMountPoints(m.cfg)
+	now := time.Now()
+	for _, status := range statuses {
+		logger.Debugf("filesystem info of %s (total, avail, level): %d %d %v",
+			status.Path, status.Total, status.Avail, status.Level)
+		if !m.updateLevel(status, now) {
+			continue
+		}
+		m.notifyDiskSpace(status)
+	}
+}
+
+// updateLevel 记录文件系统的级别，返回是否需要提醒
+func (m *Manager) updateLevel(status *mountPointStatus, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.levels[status.dev]
+	if !ok {
+		state = &levelState{}
+		m.levels[status.dev] = state
+	}
+	if status.Level == diskLevelNormal || status.Level < state.level {
+		// 空间恢复后降低级别，再次升高时重新提醒
+		state.level = status.Level
+		return false
+	}
+	if status.Level == state.level {
+		renotify := time.Duration(m.cfg.RenotifyMinutes) * time.Minute
+		if renotify == 0 || now.Sub(state.notifyTime) < renotify {
+			return false
+		}
+	}
+	state.level = status.Level
+	state.notifyTime = now
+	return true
+}
+
+// scanCleanups 返回所有清理项可以释放的空间
+func (m *Manager) scanCleanups() ([]*cleanupReport, error) {
+	c := newCleaner(m.home, m.cfg)
+	result := make([]*cleanupReport, 0, len(cleanupNames))
+	for _, name := range cleanupNames {
+		report, err := c.scan(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, report)
+	}
+	return result, nil
+}
+
+func (m *Manager) runCleanup(name string) (*cleanupReport, error) {
+	if !isValidCleanup(name) {
+		return nil, fmt.Errorf("invalid cleanup %q", name)
+	}
+	m.cleanupMu.Lock()
+	defer m.cleanupMu.Unlock()
+	return newCleaner(m.home, m.cfg).run(name)
+}
+
+// GetDiskUsage 以 JSON 格式返回配置的各个挂载点的空间和级别
+func (m *Manager) GetDiskUsage() (usage string, busErr *dbus.Error) {
+	data, err := json.Marshal(queryMountPoints(m.cfg))
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// Scan 以 JSON 格式返回各个清理项可以释放的空间，不会删除文件
+func (m *Manager) Scan() (report string, busErr *dbus.Error) {
+	reports, err := m.scanCleanups()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(reports)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// Cleanup 运行清理项，name 可以是 trash thumbnails app-logs cache，返回释放的空间
+func (m *Manager) Cleanup(name string) (freed uint64, busErr *dbus.Error) {
+	report, err := m.runCleanup(name)
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+	return report.Bytes, nil
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package housekeeping
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/linuxdeepin/go-lib/gettext"
+)
+
+const (
+	notifyAppName     = "dde-control-center"
+	notifyIconWarning = "dialog-warning"
+	notifyIconInfo    = "dialog-information"
+	notifyActPrefix   = "cleanup:"
+)
+
+func getCleanupLabel(name string) string {
+	switch name {
+	case cleanupTrash:
+		return Tr("Empty Trash")
+	case cleanupThumbnails:
+		return Tr("Clear Thumbnails")
+	case cleanupAppLogs:
+		return Tr("Clear App Logs")
+	case cleanupCache:
+		return Tr("Clear Old Cache")
+	default:
+		return name
+	}
+}
+
+func (m *Manager) sendNotify(icon, summary, body string, actions []string) (uint32, error) {
+	return m.notifications.Notify(0, notifyAppName, 0,
+		icon, summary, body,
+		actions, nil, -1)
+}
+
+// notifyDiskSpace 提醒空间不足，如果文件系统和家目录是同一个，附带可以释放空间的清理动作
+func (m *Manager) notifyDiskSpace(status *mountPointStatus) {
+	summary := Tr("Insufficient disk space, please clean up in time!")
+	if status.Level == diskLevelCritical {
+		summary = Tr("Disk space is almost full, please clean up immediately!")
+	}
+	body := fmt.Sprintf(Tr("Only %s left on %s"), formatSize(status.Avail), status.Path)
+
+	var actions []string
+	home, err := queryMountPoint(m.home)
+	if err == nil && home.dev == status.dev {
+		reports, err := m.scanCleanups()
+		if err != nil {
+			logger.Warning("scan cleanups failed:", err)
+		}
+		for _, report := range reports {
+			if report.Bytes == 0 {
+				continue
+			}
+			actions = append(actions, notifyActPrefix+report.Name,
+				fmt.Sprintf("%s (%s)", getCleanupLabel(report.Name), formatSize(report.Bytes)))
+		}
+	}
+
+	id, err := m.sendNotify(notifyIconWarning, summary, body, actions)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	if len(actions) > 0 {
+		m.mu.Lock()
+		m.notifyActions[id] = struct{}{}
+		m.mu.Unlock()
+	}
+}
+
+func (m *Manager) handleActionInvoked(id uint32, actionKey string) {
+	m.mu.Lock()
+	_, ok := m.notifyActions[id]
+	delete(m.notifyActions, id)
+	m.mu.Unlock()
+	if !ok || !strings.HasPrefix(actionKey, notifyActPrefix) {
+		return
+	}
+
+	name := strings.TrimPrefix(actionKey, notifyActPrefix)
+	go func() {
+		report, err := m.runCleanup(name)
+		if err != nil {
+			logger.Warning(err)
+			return
+		}
+		_, err = m.sendNotify(notifyIconInfo, "",
+			fmt.Sprintf(Tr("%s of disk space freed"), formatSize(report.Bytes)), nil)
+		if err != nil {
+			logger.Warning(err)
+		}
+		m.checkDiskSpace()
+	}()
+}
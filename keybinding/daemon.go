@@ -91,6 +91,7 @@ func (d *Daemon) Start() error {
 		m.listenSystemPlatformChanged()
 
 		m.eliminateKeystrokeConflict()
+		m.shortcutManager.ListenActiveWindowChanged()
 		m.shortcutManager.EventLoop()
 	}()
 
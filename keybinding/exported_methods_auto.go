@@ -20,6 +20,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"name", "action", "keystroke"},
 			OutArgs: []string{"id", "type0"},
 		},
+		{
+			Name:    "AddScopedCustomShortcut",
+			Fn:      v.AddScopedCustomShortcut,
+			InArgs:  []string{"name", "action", "keystroke", "scope"},
+			OutArgs: []string{"id", "type0"},
+		},
 		{
 			Name:   "AddShortcutKeystroke",
 			Fn:     v.AddShortcutKeystroke,
@@ -93,6 +99,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"keystroke"},
 			OutArgs: []string{"shortcut"},
 		},
+		{
+			Name:    "LookupConflictingShortcutInScope",
+			Fn:      v.LookupConflictingShortcutInScope,
+			InArgs:  []string{"keystroke", "scope"},
+			OutArgs: []string{"shortcut"},
+		},
 		{
 			Name:    "ModifiedAccel",
 			Fn:      v.ModifiedAccel,
@@ -129,6 +141,11 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetCapsLockState,
 			InArgs: []string{"state"},
 		},
+		{
+			Name:   "SetCustomShortcutScope",
+			Fn:     v.SetCustomShortcutScope,
+			InArgs: []string{"id", "scope"},
+		},
 		{
 			Name:   "SetNumLockState",
 			Fn:     v.SetNumLockState,
@@ -171,6 +171,12 @@ type Manager struct {
 			pressed   bool
 			keystroke string
 		}
+
+		// 按下按键序列的前缀按键后等待第二个按键
+		SequencePendingChanged struct {
+			prefix  string
+			pending bool
+		}
 	}
 }
 
@@ -205,6 +205,13 @@ func (m *Manager) initHandlers() {
 		}
 		m.switchKbdLayoutState = SKLStateNone
 	})
+
+	m.shortcutManager.SetSequencePendingCallback(func(prefix string, pending bool) {
+		err := m.service.Emit(m, "SequencePendingChanged", prefix, pending)
+		if err != nil {
+			logger.Warning("emit SequencePendingChanged Failed:", err)
+		}
+	})
 }
 
 func (m *Manager) sklWait() {
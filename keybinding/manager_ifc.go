@@ -60,6 +60,24 @@ var errTypeAssertionFail = errors.New("type assertion failed")
 var errShortcutKeystrokesUnmodifiable = errors.New("keystrokes of this shortcut is unmodifiable")
 var errKeystrokeUsed = errors.New("keystroke had been used")
 var errNameUsed = errors.New("name had been used")
+var errScopeNotSupported = errors.New("scoped shortcut is not supported on wayland")
+var errSequenceNotSupported = errors.New("key sequence is not supported on wayland")
+var errSequenceOnlyCustom = errors.New("key sequence is only supported by custom shortcuts")
+
+// checkSequenceKeystroke 按键序列只有自定义快捷键支持，
+// 窗管等其他类型的快捷键无法保存和抓取按键序列
+func checkSequenceKeystroke(type0 int32, ks *shortcuts.Keystroke) error {
+	if !ks.IsSequence() {
+		return nil
+	}
+	if _useWayland {
+		return errSequenceNotSupported
+	}
+	if type0 != shortcuts.ShortcutTypeCustom {
+		return errSequenceOnlyCustom
+	}
+	return nil
+}
 
 func (*Manager) GetInterfaceName() string {
 	return dbusInterface
@@ -210,14 +228,35 @@ func (m *Manager) ListShortcutsByType(type0 int32) (shortcuts string, busErr *db
 
 func (m *Manager) AddCustomShortcut(name, action, keystroke string) (id string,
 	type0 int32, busErr *dbus.Error) {
+	return m.addCustomShortcut(name, action, keystroke, "")
+}
+
+// AddScopedCustomShortcut 添加只在指定应用窗口激活时生效的自定义快捷键，
+// scope 为应用的 WM_CLASS 或 desktop 文件。
+func (m *Manager) AddScopedCustomShortcut(name, action, keystroke, scope string) (id string,
+	type0 int32, busErr *dbus.Error) {
+	if _useWayland && scope != "" {
+		return "", 0, dbusutil.ToError(errScopeNotSupported)
+	}
+	return m.addCustomShortcut(name, action, keystroke, scope)
+}
+
+func (m *Manager) addCustomShortcut(name, action, keystroke, scope string) (id string,
+	type0 int32, busErr *dbus.Error) {
 
-	logger.Debugf("Add custom key: %q %q %q", name, action, keystroke)
+	logger.Debugf("Add custom key: %q %q %q %q", name, action, keystroke, scope)
 	ks, err := shortcuts.ParseKeystroke(keystroke)
 	if err != nil {
 		logger.Warning(err)
 		busErr = dbusutil.ToError(err)
 		return
 	}
+	if _useWayland && ks.IsSequence() {
+		err = errSequenceNotSupported
+		logger.Warning(err)
+		busErr = dbusutil.ToError(err)
+		return
+	}
 
 	exist := m.shortcutManager.GetByIdType(name, shortcuts.ShortcutTypeCustom)
 	if exist != nil {
@@ -227,7 +266,7 @@ func (m *Manager) AddCustomShortcut(name, action, keystroke string) (id string,
 		return
 	}
 
-	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks)
+	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystrokeInScope(ks, scope)
 	if err != nil {
 		logger.Warning(err)
 		busErr = dbusutil.ToError(err)
@@ -240,7 +279,7 @@ func (m *Manager) AddCustomShortcut(name, action, keystroke string) (id string,
 		return
 	}
 
-	shortcut, err := m.customShortcutManager.Add(name, action, []*shortcuts.Keystroke{ks})
+	shortcut, err := m.customShortcutManager.AddScoped(name, action, scope, []*shortcuts.Keystroke{ks})
 	if err != nil {
 		logger.Warning(err)
 		busErr = dbusutil.ToError(err)
@@ -325,13 +364,19 @@ func (m *Manager) ClearShortcutKeystrokes(id string, type0 int32) *dbus.Error {
 }
 
 func (m *Manager) LookupConflictingShortcut(keystroke string) (shortcut string, busErr *dbus.Error) {
+	return m.LookupConflictingShortcutInScope(keystroke, "")
+}
+
+// LookupConflictingShortcutInScope 查找在应用 scope 内与 keystroke 冲突的快捷键，
+// 全局快捷键与所有快捷键冲突，不同应用的快捷键之间不冲突。
+func (m *Manager) LookupConflictingShortcutInScope(keystroke, scope string) (shortcut string, busErr *dbus.Error) {
 	ks, err := shortcuts.ParseKeystroke(keystroke)
 	if err != nil {
 		// parse keystroke error
 		return "", dbusutil.ToError(err)
 	}
 
-	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks)
+	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystrokeInScope(ks, scope)
 	if err != nil {
 		return "", dbusutil.ToError(err)
 	}
@@ -370,8 +415,11 @@ func (m *Manager) ModifyCustomShortcut(id, name, cmd, keystroke string) *dbus.Er
 		if err != nil {
 			return dbusutil.ToError(err)
 		}
+		if _useWayland && ks.IsSequence() {
+			return dbusutil.ToError(errSequenceNotSupported)
+		}
 		// check conflicting
-		conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks)
+		conflictKeystroke, err := m.shortcutManager.FindConflictingKeystrokeInScope(ks, customShortcut.Scope)
 		if err != nil {
 			return dbusutil.ToError(err)
 		}
@@ -393,6 +441,45 @@ func (m *Manager) ModifyCustomShortcut(id, name, cmd, keystroke string) *dbus.Er
 	return nil
 }
 
+// SetCustomShortcutScope 修改自定义快捷键的作用域，scope 为空表示全局生效。
+func (m *Manager) SetCustomShortcutScope(id, scope string) *dbus.Error {
+	logger.Debugf("SetCustomShortcutScope id: %q, scope: %q", id, scope)
+	if _useWayland && scope != "" {
+		return dbusutil.ToError(errScopeNotSupported)
+	}
+	const ty = shortcuts.ShortcutTypeCustom
+	shortcut := m.shortcutManager.GetByIdType(id, ty)
+	if shortcut == nil {
+		return dbusutil.ToError(ErrShortcutNotFound{id, ty})
+	}
+	customShortcut, ok := shortcut.(*shortcuts.CustomShortcut)
+	if !ok {
+		return dbusutil.ToError(errTypeAssertionFail)
+	}
+	if customShortcut.Scope == scope {
+		return nil
+	}
+
+	// check conflicting in the new scope
+	for _, ks := range shortcut.GetKeystrokes() {
+		conflictKeystroke, err := m.shortcutManager.FindConflictingKeystrokeInScope(ks, scope)
+		if err != nil {
+			return dbusutil.ToError(err)
+		}
+		if conflictKeystroke != nil && conflictKeystroke.Shortcut != shortcut {
+			return dbusutil.ToError(errKeystrokeUsed)
+		}
+	}
+
+	m.shortcutManager.SetShortcutScope(customShortcut, scope)
+	err := customShortcut.Save()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	m.emitShortcutSignal(shortcutSignalChanged, shortcut)
+	return nil
+}
+
 func (m *Manager) AddShortcutKeystroke(id string, type0 int32, keystroke string) *dbus.Error {
 	logger.Debug("AddShortcutKeystroke", id, type0, keystroke)
 	shortcut := m.shortcutManager.GetByIdType(id, type0)
@@ -409,6 +496,10 @@ func (m *Manager) AddShortcutKeystroke(id string, type0 int32, keystroke string)
 		return dbusutil.ToError(err)
 	}
 	logger.Debug("keystroke:", ks.DebugString())
+	err = checkSequenceKeystroke(type0, ks)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
 
 	if type0 == shortcuts.ShortcutTypeWM && ks.Mods == 0 {
 		keyLower := strings.ToLower(ks.Keystr)
@@ -418,7 +509,8 @@ func (m *Manager) AddShortcutKeystroke(id string, type0 int32, keystroke string)
 		}
 	}
 
-	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks)
+	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystrokeInScope(ks,
+		shortcuts.GetShortcutScope(shortcut))
 	if err != nil {
 		return dbusutil.ToError(err)
 	}
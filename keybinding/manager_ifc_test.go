@@ -0,0 +1,44 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package keybinding
+
+import (
+	"testing"
+
+	"github.com/linuxdeepin/dde-daemon/keybinding/shortcuts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_checkSequenceKeystroke(t *testing.T) {
+	seq, err := shortcuts.ParseKeystroke("<Super>w, t")
+	require.NoError(t, err)
+	single, err := shortcuts.ParseKeystroke("<Super>w")
+	require.NoError(t, err)
+
+	assert.NoError(t, checkSequenceKeystroke(shortcuts.ShortcutTypeCustom, seq))
+	assert.Equal(t, errSequenceOnlyCustom, checkSequenceKeystroke(shortcuts.ShortcutTypeWM, seq))
+	assert.Equal(t, errSequenceOnlyCustom, checkSequenceKeystroke(shortcuts.ShortcutTypeSystem, seq))
+	assert.NoError(t, checkSequenceKeystroke(shortcuts.ShortcutTypeWM, single))
+
+	_useWayland = true
+	defer func() {
+		_useWayland = false
+	}()
+	assert.Equal(t, errSequenceNotSupported, checkSequenceKeystroke(shortcuts.ShortcutTypeCustom, seq))
+}
@@ -33,13 +33,16 @@ const (
 	kfKeyName       = "Name"
 	kfKeyKeystrokes = "Accels"
 	kfKeyAction     = "Action"
+	kfKeyScope      = "Scope"
 )
 
 type CustomShortcut struct {
 	BaseShortcut
 	manager *CustomShortcutManager
 	Cmd     string `json:"Exec"`
-	wm      wm.Wm
+	// 为空时全局生效，否则只在该应用窗口激活时生效
+	Scope string `json:"Scope"`
+	wm    wm.Wm
 }
 
 func (cs *CustomShortcut) Marshal() (string, error) {
@@ -78,6 +81,11 @@ func (cs *CustomShortcut) Save() error {
 	kfile.SetString(section, kfKeyName, cs.Name)
 	kfile.SetString(section, kfKeyAction, cs.Cmd)
 	kfile.SetStringList(section, kfKeyKeystrokes, cs.getKeystrokesStrv())
+	if cs.Scope != "" {
+		kfile.SetString(section, kfKeyScope, cs.Scope)
+	} else {
+		kfile.DeleteKey(section, kfKeyScope)
+	}
 	return cs.manager.Save()
 }
 
@@ -151,6 +159,7 @@ func (csm *CustomShortcutManager) List() []Shortcut {
 		name, _ := kfile.GetString(section, kfKeyName)
 		cmd, _ := kfile.GetString(section, kfKeyAction)
 		keystrokes, _ := kfile.GetStringList(section, kfKeyKeystrokes)
+		scope, _ := kfile.GetString(section, kfKeyScope)
 
 		shortcut := &CustomShortcut{
 			BaseShortcut: BaseShortcut{
@@ -161,6 +170,7 @@ func (csm *CustomShortcutManager) List() []Shortcut {
 			},
 			manager: csm,
 			Cmd:     cmd,
+			Scope:   scope,
 		}
 
 		ret = append(ret, shortcut)
@@ -177,9 +187,17 @@ func (csm *CustomShortcutManager) Save() error {
 }
 
 func (csm *CustomShortcutManager) Add(name, action string, keystrokes []*Keystroke) (Shortcut, error) {
+	return csm.AddScoped(name, action, "", keystrokes)
+}
+
+// AddScoped 添加只在 scope 指定的应用窗口激活时生效的快捷键，scope 为空时等同于 Add。
+func (csm *CustomShortcutManager) AddScoped(name, action, scope string, keystrokes []*Keystroke) (Shortcut, error) {
 	id := name
 	csm.kfile.SetString(id, kfKeyName, name)
 	csm.kfile.SetString(id, kfKeyAction, action)
+	if scope != "" {
+		csm.kfile.SetString(id, kfKeyScope, scope)
+	}
 
 	keystrokesStrv := make([]string, 0, len(keystrokes))
 	for _, ks := range keystrokes {
@@ -196,6 +214,7 @@ func (csm *CustomShortcutManager) Add(name, action string, keystrokes []*Keystro
 		},
 		manager: csm,
 		Cmd:     action,
+		Scope:   scope,
 	}
 	return shortcut, csm.Save()
 }
@@ -29,13 +29,18 @@ import (
 	"github.com/linuxdeepin/go-x11-client/util/keysyms"
 )
 
+// 按键序列中各个按键之间的分隔符，比如 <Super>w, t
+const keystrokeSeqSep = ", "
+
 // Keystroke
 // field Mods ignore mod2(Num_Lock) and lock(Caps_Lock)
+// field Next 不为空时表示按键序列，Keystroke 本身是前缀按键，Next 是其后的第二个按键
 type Keystroke struct {
 	Mods     Modifiers
 	Keystr   string
 	Keysym   x.Keysym
 	Shortcut Shortcut
+	Next     *Keystroke
 
 	isKeystrAboveTab bool
 }
@@ -49,8 +54,19 @@ func (ks *Keystroke) DebugString() string {
 	}
 }
 
+func (ks *Keystroke) IsSequence() bool {
+	return ks.Next != nil
+}
+
 func (a *Keystroke) Equal(keySymbols *keysyms.KeySymbols, b *Keystroke) bool {
 	logger.Debug(a, " equal? ", b)
+	if a.IsSequence() != b.IsSequence() {
+		logger.Debug("one of them is sequence, return false")
+		return false
+	}
+	if a.IsSequence() && !a.Next.Equal(keySymbols, b.Next) {
+		return false
+	}
 	if a.Mods != b.Mods {
 		logger.Debug("Mods no equal, return false")
 		return false
@@ -223,7 +239,33 @@ func splitKeystroke(str string) ([]string, error) {
 // Print mods() key Print
 // <Control>Print mods(Control) key Print
 // check Keystroke.Keystr valid later
+// <Super>w, t 按键序列，先按 <Super>w 再按 t
 func ParseKeystroke(keystroke string) (*Keystroke, error) {
+	idx := strings.Index(keystroke, keystrokeSeqSep)
+	if idx == -1 {
+		return parseSingleKeystroke(keystroke)
+	}
+
+	prefix, err := parseSingleKeystroke(keystroke[:idx])
+	if err != nil {
+		return nil, err
+	}
+	rest := strings.TrimSpace(keystroke[idx+len(keystrokeSeqSep):])
+	if strings.Contains(rest, keystrokeSeqSep) {
+		return nil, errors.New("only two keystrokes are supported in a sequence")
+	}
+	next, err := parseSingleKeystroke(rest)
+	if err != nil {
+		return nil, err
+	}
+	if keysyms.IsModifierKey(next.Keysym) {
+		return nil, errors.New("bad key " + next.Keystr + " in sequence")
+	}
+	prefix.Next = next
+	return prefix, nil
+}
+
+func parseSingleKeystroke(keystroke string) (*Keystroke, error) {
 	parts, err := splitKeystroke(keystroke)
 	if err != nil {
 		return nil, err
@@ -302,7 +344,11 @@ func (ks *Keystroke) String() string {
 	}
 
 	keys = append(keys, ks.Keystr)
-	return strings.Join(keys, "")
+	str := strings.Join(keys, "")
+	if ks.Next != nil {
+		str += keystrokeSeqSep + ks.Next.String()
+	}
+	return str
 }
 
 func (ks *Keystroke) searchString() string {
@@ -327,6 +373,9 @@ func (ks *Keystroke) searchString() string {
 	} else {
 		strs = append(strs, strings.ToLower(ks.Keystr))
 	}
+	if ks.Next != nil {
+		strs = append(strs, ks.Next.searchString())
+	}
 
 	return strings.Join(strs, "")
 }
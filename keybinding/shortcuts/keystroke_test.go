@@ -117,6 +117,37 @@ func TestParseKeystrokes(t *testing.T) {
 	assert.Equal(t, len(ret), len(keystrokes))
 }
 
+func TestParseKeystrokeSequence(t *testing.T) {
+	ks, err := ParseKeystroke("<Super>w, t")
+	assert.NoError(t, err)
+	assert.Equal(t, &Keystroke{
+		Keystr: "w",
+		Keysym: keysyms.XK_w,
+		Mods:   keysyms.ModMaskSuper,
+		Next: &Keystroke{
+			Keystr: "t",
+			Keysym: keysyms.XK_t,
+		},
+	}, ks)
+	assert.True(t, ks.IsSequence())
+	assert.Equal(t, "<Super>w, t", ks.String())
+	assert.Equal(t, "superwt", ks.searchString())
+
+	ks, err = ParseKeystroke("<Control>x, <Control>comma")
+	assert.NoError(t, err)
+	assert.Equal(t, "<Control>x, <Control>comma", ks.String())
+
+	// abnormal situation:
+	_, err = ParseKeystroke("<Super>w, t, x")
+	assert.Error(t, err)
+
+	_, err = ParseKeystroke("<Super>w, Shift_L")
+	assert.Error(t, err)
+
+	_, err = ParseKeystroke("<Super>w, ")
+	assert.Error(t, err)
+}
+
 func TestKeystrokeMethodString(t *testing.T) {
 	var ks Keystroke
 	ks = Keystroke{
@@ -0,0 +1,374 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package shortcuts
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	x "github.com/linuxdeepin/go-x11-client"
+	"github.com/linuxdeepin/go-x11-client/util/wm/ewmh"
+	"github.com/linuxdeepin/go-x11-client/util/wm/icccm"
+)
+
+// 自定义快捷键可以限定作用域（Scope）为某个应用，只有该应用的窗口处于激活状态时才抓取按键。
+// 作用域可以是 WM_CLASS，也可以是 desktop 文件的 id 或路径，与任务栏识别窗口所用的信息一致。
+
+const (
+	desktopExt       = ".desktop"
+	envDesktopFile   = "GIO_LAUNCHED_DESKTOP_FILE"
+	scopePropLenMax  = 1024
+	atomNameUTF8     = "UTF8_STRING"
+	atomNameGtkAppId = "_GTK_APPLICATION_ID"
+)
+
+// normalizeScope 统一作用域的写法，
+// 比如 /usr/share/applications/deepin-terminal.desktop 和 Deepin-Terminal 都得到 deepin-terminal。
+func normalizeScope(scope string) string {
+	scope = strings.TrimSpace(scope)
+	if scope == "" {
+		return ""
+	}
+	scope = filepath.Base(scope)
+	scope = strings.TrimSuffix(scope, desktopExt)
+	return strings.ToLower(scope)
+}
+
+// scopesConflict 判断两个作用域的快捷键使用相同按键时是否冲突，
+// 全局快捷键（作用域为空）与任何快捷键冲突，不同应用的快捷键之间不冲突。
+func scopesConflict(scope1, scope2 string) bool {
+	scope1 = normalizeScope(scope1)
+	scope2 = normalizeScope(scope2)
+	if scope1 == "" || scope2 == "" {
+		return true
+	}
+	return scope1 == scope2
+}
+
+// GetShortcutScope 返回快捷键的作用域，只有自定义快捷键可以设置作用域。
+func GetShortcutScope(shortcut Shortcut) string {
+	cs, ok := shortcut.(*CustomShortcut)
+	if !ok {
+		return ""
+	}
+	return cs.Scope
+}
+
+// activeApp 记录激活窗口所属应用的各种标识
+type activeApp struct {
+	win x.Window
+	ids []string
+}
+
+func (app *activeApp) match(scope string) bool {
+	if app == nil {
+		return false
+	}
+	scope = normalizeScope(scope)
+	if scope == "" {
+		return false
+	}
+	for _, id := range app.ids {
+		if id == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func newActiveApp(win x.Window, ids []string) *activeApp {
+	app := &activeApp{win: win}
+	for _, id := range ids {
+		id = normalizeScope(id)
+		if id == "" {
+			continue
+		}
+		exist := false
+		for _, id0 := range app.ids {
+			if id0 == id {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			app.ids = append(app.ids, id)
+		}
+	}
+	return app
+}
+
+func getEnvFromEnviron(environ []byte, name string) string {
+	prefix := []byte(name + "=")
+	for _, item := range bytes.Split(environ, []byte{0}) {
+		if bytes.HasPrefix(item, prefix) {
+			return string(item[len(prefix):])
+		}
+	}
+	return ""
+}
+
+func (sm *ShortcutManager) getWindowPropertyString(win x.Window, atomName string) (string, error) {
+	atom, err := sm.conn.GetAtom(atomName)
+	if err != nil {
+		return "", err
+	}
+	atomUTF8String, err := sm.conn.GetAtom(atomNameUTF8)
+	if err != nil {
+		return "", err
+	}
+	reply, err := x.GetProperty(sm.conn, false, win, atom, atomUTF8String,
+		0, scopePropLenMax).Reply(sm.conn)
+	if err != nil {
+		return "", err
+	}
+	if reply.Format != 8 {
+		return "", fmt.Errorf("bad reply format %d", reply.Format)
+	}
+	return string(reply.Value), nil
+}
+
+// getWindowAppIds 获取窗口所属应用的标识，包括 WM_CLASS、GTK application id 和启动它的 desktop 文件。
+func (sm *ShortcutManager) getWindowAppIds(win x.Window) []string {
+	var ids []string
+	wmClass, err := icccm.GetWMClass(sm.conn, win).Reply(sm.conn)
+	if err == nil {
+		ids = append(ids, wmClass.Instance, wmClass.Class)
+	} else {
+		logger.Debug(err)
+	}
+
+	gtkAppId, err := sm.getWindowPropertyString(win, atomNameGtkAppId)
+	if err == nil {
+		ids = append(ids, gtkAppId)
+	}
+
+	pid, err := ewmh.GetWMPid(sm.conn, win).Reply(sm.conn)
+	if err == nil && pid != 0 {
+		environ, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+		if err == nil {
+			ids = append(ids, getEnvFromEnviron(environ, envDesktopFile))
+		}
+	}
+	return ids
+}
+
+// ListenActiveWindowChanged 监听激活窗口变化，以便切换应用内快捷键的抓取。
+func (sm *ShortcutManager) ListenActiveWindowChanged() {
+	if _useWayland {
+		return
+	}
+	atom, err := sm.conn.GetAtom("_NET_ACTIVE_WINDOW")
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	sm.atomNetActiveWindow = atom
+
+	rootWin := sm.conn.GetDefaultScreen().Root
+	err = x.ChangeWindowAttributesChecked(sm.conn, rootWin, x.CWEventMask,
+		[]uint32{x.EventMaskPropertyChange}).Check(sm.conn)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	sm.handleActiveWindowChanged()
+}
+
+func (sm *ShortcutManager) handleActiveWindowChanged() {
+	activeWin, err := ewmh.GetActiveWindow(sm.conn).Reply(sm.conn)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+
+	sm.activeAppMu.Lock()
+	if sm.activeApp != nil && sm.activeApp.win == activeWin {
+		sm.activeAppMu.Unlock()
+		return
+	}
+	var app *activeApp
+	if activeWin != 0 {
+		app = newActiveApp(activeWin, sm.getWindowAppIds(activeWin))
+		logger.Debugf("active window changed to %d, app ids: %v", activeWin, app.ids)
+	}
+	sm.activeApp = app
+	sm.activeAppMu.Unlock()
+
+	sm.regrabScopedShortcuts()
+}
+
+func (sm *ShortcutManager) isScopeActive(scope string) bool {
+	sm.activeAppMu.Lock()
+	defer sm.activeAppMu.Unlock()
+	return sm.activeApp.match(scope)
+}
+
+func (sm *ShortcutManager) listScopedShortcuts() []Shortcut {
+	sm.idShortcutMapMu.Lock()
+	defer sm.idShortcutMapMu.Unlock()
+
+	var result []Shortcut
+	for _, shortcut := range sm.idShortcutMap {
+		if GetShortcutScope(shortcut) != "" {
+			result = append(result, shortcut)
+		}
+	}
+	return result
+}
+
+// SetShortcutScope 修改自定义快捷键的作用域，并按新的作用域重新抓取按键。
+func (sm *ShortcutManager) SetShortcutScope(cs *CustomShortcut, scope string) {
+	sm.ungrabShortcut(cs)
+	cs.Scope = scope
+	sm.grabShortcut(cs)
+}
+
+// regrabScopedShortcuts 抓取属于激活应用的快捷键，释放其他应用的快捷键。
+func (sm *ShortcutManager) regrabScopedShortcuts() {
+	scoped := sm.listScopedShortcuts()
+	// 先释放再抓取，避免两个应用使用相同按键时抓取失败
+	var actives []Shortcut
+	for _, shortcut := range scoped {
+		if sm.isScopeActive(GetShortcutScope(shortcut)) {
+			actives = append(actives, shortcut)
+			continue
+		}
+		for _, ks := range shortcut.GetKeystrokes() {
+			sm.ungrabScopedKeystroke(ks)
+		}
+	}
+	for _, shortcut := range actives {
+		for _, ks := range shortcut.GetKeystrokes() {
+			if sm.isKeystrokeGrabbed(ks) {
+				continue
+			}
+			sm.grabKeystroke(shortcut, ks, dummyGrab(shortcut, ks))
+		}
+	}
+}
+
+func (sm *ShortcutManager) isKeystrokeGrabbed(ks *Keystroke) bool {
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		return false
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	defer sm.keyKeystrokeMapMu.Unlock()
+	for _, key := range keyList {
+		if sm.keyKeystrokeMap[key] == ks {
+			return true
+		}
+		for _, ks0 := range sm.prefixKeyMap[key] {
+			if ks0 == ks {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ungrabScopedKeystroke 只释放被 ks 自己占用的按键，按键可能已被全局快捷键接管。
+func (sm *ShortcutManager) ungrabScopedKeystroke(ks *Keystroke) {
+	dummy := ks.Shortcut != nil && dummyGrab(ks.Shortcut, ks)
+	if ks.IsSequence() {
+		sm.ungrabSequenceKeystroke(ks, dummy)
+		return
+	}
+
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		logger.Debug(err)
+		return
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	defer sm.keyKeystrokeMapMu.Unlock()
+	for _, key := range keyList {
+		if sm.keyKeystrokeMap[key] != ks {
+			continue
+		}
+		delete(sm.keyKeystrokeMap, key)
+		if !dummy {
+			key.Ungrab(sm.conn)
+		}
+	}
+}
+
+// FindConflictingKeystrokeInScope 查找与作用域为 scope 的按键 ks 冲突的按键，
+// 除了已抓取的按键，还要检查当前未抓取的应用内快捷键和共用前缀按键的按键序列。
+func (sm *ShortcutManager) FindConflictingKeystrokeInScope(ks *Keystroke, scope string) (*Keystroke, error) {
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyList) == 0 {
+		return nil, nil
+	}
+
+	logger.Debug("ShortcutManager.FindConflictingKeystrokeInScope", ks.DebugString(), scope)
+
+	scopedKeyMap := make(map[Key][]*Keystroke)
+	for _, shortcut := range sm.listScopedShortcuts() {
+		if !scopesConflict(scope, GetShortcutScope(shortcut)) {
+			continue
+		}
+		for _, ks0 := range shortcut.GetKeystrokes() {
+			keyList0, err := ks0.ToKeyList(sm.keySymbols)
+			if err != nil {
+				continue
+			}
+			for _, key := range keyList0 {
+				scopedKeyMap[key] = append(scopedKeyMap[key], ks0)
+			}
+		}
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	defer sm.keyKeystrokeMapMu.Unlock()
+	var count = 0
+	var ks1 *Keystroke
+	for _, key := range keyList {
+		var candidates []*Keystroke
+		if tmp, ok := sm.keyKeystrokeMap[key]; ok {
+			candidates = append(candidates, tmp)
+		}
+		candidates = append(candidates, sm.prefixKeyMap[key]...)
+		candidates = append(candidates, scopedKeyMap[key]...)
+
+		for _, tmp := range candidates {
+			if tmp.Shortcut != nil && !scopesConflict(scope, GetShortcutScope(tmp.Shortcut)) {
+				continue
+			}
+			if !keystrokesConflict(sm.keySymbols, ks, tmp) {
+				continue
+			}
+			count++
+			ks1 = tmp
+			break
+		}
+	}
+
+	if count == len(keyList) {
+		return ks1, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package shortcuts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_normalizeScope(t *testing.T) {
+	testData := []struct {
+		in  string
+		out string
+	}{
+		{"", ""},
+		{"  ", ""},
+		{"Deepin-Terminal", "deepin-terminal"},
+		{"deepin-terminal.desktop", "deepin-terminal"},
+		{"/usr/share/applications/deepin-terminal.desktop", "deepin-terminal"},
+	}
+
+	for _, data := range testData {
+		assert.Equal(t, data.out, normalizeScope(data.in))
+	}
+}
+
+func Test_scopesConflict(t *testing.T) {
+	assert.True(t, scopesConflict("", ""))
+	assert.True(t, scopesConflict("", "deepin-terminal"))
+	assert.True(t, scopesConflict("deepin-terminal", ""))
+	assert.True(t, scopesConflict("Deepin-Terminal", "deepin-terminal.desktop"))
+	assert.False(t, scopesConflict("deepin-terminal", "dde-file-manager"))
+}
+
+func Test_activeApp(t *testing.T) {
+	var app *activeApp
+	assert.False(t, app.match("deepin-terminal"))
+
+	app = newActiveApp(1, []string{"deepin-terminal", "Deepin-Terminal", "",
+		"/usr/share/applications/deepin-terminal.desktop"})
+	assert.Equal(t, []string{"deepin-terminal"}, app.ids)
+	assert.True(t, app.match("deepin-terminal.desktop"))
+	assert.False(t, app.match(""))
+	assert.False(t, app.match("dde-file-manager"))
+}
+
+func Test_getEnvFromEnviron(t *testing.T) {
+	environ := []byte("HOME=/home/test\x00GIO_LAUNCHED_DESKTOP_FILE=/usr/share/applications/a.desktop\x00")
+	assert.Equal(t, "/usr/share/applications/a.desktop", getEnvFromEnviron(environ, envDesktopFile))
+	assert.Equal(t, "/home/test", getEnvFromEnviron(environ, "HOME"))
+	assert.Equal(t, "", getEnvFromEnviron(environ, "PATH"))
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package shortcuts
+
+import (
+	"time"
+
+	x "github.com/linuxdeepin/go-x11-client"
+	"github.com/linuxdeepin/go-x11-client/util/keybind"
+	"github.com/linuxdeepin/go-x11-client/util/keysyms"
+)
+
+// 按下前缀按键后等待第二个按键的时长，超时后取消
+const sequenceTimeout = 2 * time.Second
+
+type SequencePendingFunc func(prefix string, pending bool)
+
+// sequenceState 按下按键序列的前缀按键后的等待状态
+type sequenceState struct {
+	prefix     Key
+	candidates []*Keystroke
+	timer      *time.Timer
+}
+
+func (ks *Keystroke) prefixString() string {
+	prefix := Keystroke{
+		Mods:   ks.Mods,
+		Keystr: ks.Keystr,
+	}
+	return prefix.String()
+}
+
+// keystrokesConflict 判断两个前缀按键相同的按键是否冲突，
+// 普通按键与以它为前缀的按键序列冲突，按键序列之间只有第二个按键也相同才冲突。
+func keystrokesConflict(keySymbols *keysyms.KeySymbols, a, b *Keystroke) bool {
+	if !a.IsSequence() || !b.IsSequence() {
+		return true
+	}
+	return a.Next.Equal(keySymbols, b.Next)
+}
+
+// matchSequence 在候选按键序列中查找第二个按键为 key 的，
+// 按下第二个按键时前缀按键的修饰键可能还没有松开，这种情况也算匹配。
+func matchSequence(keySymbols *keysyms.KeySymbols, candidates []*Keystroke, prefix, key Key) *Keystroke {
+	keys := []Key{key}
+	if prefix.Mods != 0 && key.Mods&prefix.Mods == prefix.Mods && key.Mods != prefix.Mods {
+		keys = append(keys, Key{Mods: key.Mods &^ prefix.Mods, Code: key.Code})
+	}
+	if prefix.Mods != 0 && key.Mods == prefix.Mods {
+		keys = append(keys, Key{Code: key.Code})
+	}
+
+	for _, k := range keys {
+		for _, ks := range candidates {
+			keyList, err := ks.Next.ToKeyList(keySymbols)
+			if err != nil {
+				continue
+			}
+			for _, k0 := range keyList {
+				if k0 == k {
+					return ks
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (sm *ShortcutManager) SetSequencePendingCallback(cb SequencePendingFunc) {
+	sm.sequencePendingCb = cb
+}
+
+func (sm *ShortcutManager) grabSequenceKeystroke(ks *Keystroke, dummy bool) {
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		logger.Debugf("grabSequenceKeystroke failed, ks: %v, err: %v", ks, err)
+		return
+	}
+
+	var conflictCount int
+	sm.keyKeystrokeMapMu.Lock()
+	for _, key := range keyList {
+		if conflictKeystroke, ok := sm.keyKeystrokeMap[key]; ok {
+			conflictCount++
+			logger.Debugf("prefix key %v is grabbed by %v", key, conflictKeystroke.DebugString())
+			continue
+		}
+
+		list := sm.prefixKeyMap[key]
+		var registered, conflict bool
+		for _, ks0 := range list {
+			if ks0 == ks {
+				registered = true
+				break
+			}
+			if keystrokesConflict(sm.keySymbols, ks0, ks) {
+				conflict = true
+				logger.Debugf("key sequence %v is grabbed by %v", ks, ks0.DebugString())
+				break
+			}
+		}
+		if registered {
+			continue
+		}
+		if conflict {
+			conflictCount++
+			continue
+		}
+
+		// 多个按键序列共用前缀按键，只在第一次时抓取
+		if len(list) == 0 && !dummy {
+			err = key.Grab(sm.conn)
+			if err != nil {
+				logger.Debug(err)
+				continue
+			}
+		}
+		sm.prefixKeyMap[key] = append(list, ks)
+	}
+	sm.keyKeystrokeMapMu.Unlock()
+
+	// Delete completely conflicting key
+	if conflictCount == len(keyList) && !sm.EliminateConflictDone {
+		sm.storeConflictingKeystroke(ks)
+	}
+}
+
+func (sm *ShortcutManager) ungrabSequenceKeystroke(ks *Keystroke, dummy bool) {
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		logger.Debug(err)
+		return
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	defer sm.keyKeystrokeMapMu.Unlock()
+	for _, key := range keyList {
+		list := sm.prefixKeyMap[key]
+		var newList []*Keystroke
+		for _, ks0 := range list {
+			if ks0 != ks {
+				newList = append(newList, ks0)
+			}
+		}
+		if len(newList) == len(list) {
+			continue
+		}
+
+		if len(newList) > 0 {
+			sm.prefixKeyMap[key] = newList
+			continue
+		}
+		delete(sm.prefixKeyMap, key)
+		if !dummy {
+			key.Ungrab(sm.conn)
+		}
+	}
+}
+
+func (sm *ShortcutManager) ungrabAllSequences() {
+	sm.cancelSequence()
+
+	sm.keyKeystrokeMapMu.Lock()
+	for key, list := range sm.prefixKeyMap {
+		ks := list[0]
+		if ks.Shortcut != nil && dummyGrab(ks.Shortcut, ks) {
+			continue
+		}
+		key.Ungrab(sm.conn)
+	}
+	sm.prefixKeyMap = make(map[Key][]*Keystroke)
+	sm.keyKeystrokeMapMu.Unlock()
+}
+
+func (sm *ShortcutManager) isSequencePending() bool {
+	sm.sequenceMu.Lock()
+	defer sm.sequenceMu.Unlock()
+	return sm.sequence != nil
+}
+
+// handleSequenceKeyEvent 处理按键序列，返回 true 表示按键已被处理。
+func (sm *ShortcutManager) handleSequenceKeyEvent(key Key, code x.Keycode, state uint16) bool {
+	sm.sequenceMu.Lock()
+	pending := sm.sequence
+	sm.sequenceMu.Unlock()
+	if pending != nil {
+		sm.handleSequenceSecondKey(pending, key, code, state)
+		return true
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	_, isSingle := sm.keyKeystrokeMap[key]
+	candidates := append([]*Keystroke(nil), sm.prefixKeyMap[key]...)
+	sm.keyKeystrokeMapMu.Unlock()
+	if isSingle || len(candidates) == 0 {
+		return false
+	}
+
+	sm.startSequence(key, candidates)
+	return true
+}
+
+func (sm *ShortcutManager) startSequence(prefix Key, candidates []*Keystroke) {
+	// 抓取键盘，保证第二个按键也由我们处理
+	rootWin := sm.conn.GetDefaultScreen().Root
+	err := keybind.GrabKeyboard(sm.conn, rootWin)
+	if err != nil {
+		logger.Warning("failed to grab keyboard for key sequence:", err)
+		return
+	}
+
+	state := &sequenceState{
+		prefix:     prefix,
+		candidates: candidates,
+	}
+	sm.sequenceMu.Lock()
+	sm.sequence = state
+	state.timer = time.AfterFunc(sequenceTimeout, func() {
+		logger.Debug("key sequence timeout")
+		sm.finishSequence(state)
+	})
+	sm.sequenceMu.Unlock()
+
+	prefixStr := candidates[0].prefixString()
+	logger.Debug("key sequence pending, prefix:", prefixStr)
+	if sm.sequencePendingCb != nil {
+		sm.sequencePendingCb(prefixStr, true)
+	}
+}
+
+func (sm *ShortcutManager) handleSequenceSecondKey(seq *sequenceState, key Key, code x.Keycode, state uint16) {
+	keystr, _ := sm.keySymbols.LookupString(code, state)
+	if _, ok := key2Mod(keystr); ok {
+		// 等待非修饰键
+		return
+	}
+
+	ks := matchSequence(sm.keySymbols, seq.candidates, seq.prefix, key)
+	if !sm.finishSequence(seq) {
+		return
+	}
+	if ks == nil {
+		logger.Debug("key sequence not matched:", key)
+		return
+	}
+
+	logger.Debugf("emitKeyEvent key sequence: %#v", ks)
+	sm.callEventCallback(&KeyEvent{
+		Mods:     key.Mods,
+		Code:     key.Code,
+		Shortcut: ks.Shortcut,
+	})
+}
+
+// finishSequence 结束等待状态，返回 false 表示 state 已经结束了。
+func (sm *ShortcutManager) finishSequence(state *sequenceState) bool {
+	sm.sequenceMu.Lock()
+	if sm.sequence != state {
+		sm.sequenceMu.Unlock()
+		return false
+	}
+	sm.sequence = nil
+	sm.sequenceMu.Unlock()
+
+	state.timer.Stop()
+	err := keybind.UngrabKeyboard(sm.conn)
+	if err != nil {
+		logger.Warning("failed to ungrab keyboard:", err)
+	}
+	if sm.sequencePendingCb != nil {
+		sm.sequencePendingCb(state.candidates[0].prefixString(), false)
+	}
+	return true
+}
+
+func (sm *ShortcutManager) cancelSequence() {
+	sm.sequenceMu.Lock()
+	state := sm.sequence
+	sm.sequenceMu.Unlock()
+	if state != nil {
+		sm.finishSequence(state)
+	}
+}
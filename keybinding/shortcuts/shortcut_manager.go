@@ -137,7 +137,17 @@ type ShortcutManager struct {
 	idShortcutMapMu   sync.Mutex
 	keyKeystrokeMap   map[Key]*Keystroke
 	keyKeystrokeMapMu sync.Mutex
-	keySymbols        *keysyms.KeySymbols
+	// 按键序列的前缀按键，和 keyKeystrokeMap 一起由 keyKeystrokeMapMu 保护
+	prefixKeyMap map[Key][]*Keystroke
+	keySymbols   *keysyms.KeySymbols
+
+	activeApp           *activeApp
+	activeAppMu         sync.Mutex
+	atomNetActiveWindow x.Atom
+
+	sequence          *sequenceState
+	sequenceMu        sync.Mutex
+	sequencePendingCb SequencePendingFunc
 
 	recordEnable        bool
 	recordEnableMu      sync.Mutex
@@ -163,19 +173,24 @@ type KeyEvent struct {
 func NewShortcutManager(conn *x.Conn, keySymbols *keysyms.KeySymbols, eventCb KeyEventFunc) *ShortcutManager {
 	setUseWayland(strings.Contains(os.Getenv("XDG_SESSION_TYPE"), "wayland"))
 	ss := &ShortcutManager{
-		idShortcutMap:         make(map[string]Shortcut),
-		eventCb:               eventCb,
-		conn:                  conn,
-		keySymbols:            keySymbols,
-		recordEnable:          true,
-		keyKeystrokeMap:       make(map[Key]*Keystroke),
-		layoutChanged:         make(chan struct{}),
-		pinyinEnabled:         isZH(),
+		idShortcutMap:            make(map[string]Shortcut),
+		eventCb:                  eventCb,
+		conn:                     conn,
+		keySymbols:               keySymbols,
+		recordEnable:             true,
+		keyKeystrokeMap:          make(map[Key]*Keystroke),
+		prefixKeyMap:             make(map[Key][]*Keystroke),
+		layoutChanged:            make(chan struct{}),
+		pinyinEnabled:            isZH(),
 		WaylandCustomShortCutMap: make(map[string]string),
 	}
 
 	ss.xRecordEventHandler = NewXRecordEventHandler(keySymbols)
 	ss.xRecordEventHandler.modKeyReleasedCb = func(code uint8, mods uint16) {
+		if ss.isSequencePending() {
+			// 正在等待按键序列的第二个按键，键盘被自己抓取了
+			return
+		}
 		isGrabbed := isKbdAlreadyGrabbed(ss.conn)
 		switch mods {
 		case keysyms.ModMaskCapsLock, keysyms.ModMaskSuper:
@@ -404,6 +419,11 @@ func (sm *ShortcutManager) storeConflictingKeystroke(ks *Keystroke) {
 }
 
 func (sm *ShortcutManager) grabKeystroke(shortcut Shortcut, ks *Keystroke, dummy bool) {
+	if ks.IsSequence() {
+		sm.grabSequenceKeystroke(ks, dummy)
+		return
+	}
+
 	keyList, err := ks.ToKeyList(sm.keySymbols)
 	if err != nil {
 		logger.Debugf("grabKeystroke failed, shortcut: %v, ks: %v, err: %v", shortcut.GetId(), ks, err)
@@ -416,8 +436,20 @@ func (sm *ShortcutManager) grabKeystroke(shortcut Shortcut, ks *Keystroke, dummy
 	for i, key := range keyList {
 		sm.keyKeystrokeMapMu.Lock()
 		conflictKeystroke, ok := sm.keyKeystrokeMap[key]
+		prefixList := sm.prefixKeyMap[key]
 		sm.keyKeystrokeMapMu.Unlock()
 
+		if !ok && len(prefixList) > 0 {
+			// conflict with key sequences
+			conflictCount++
+			logger.Debugf("key %v is grabbed as prefix by %v", key, prefixList[0].DebugString())
+			continue
+		}
+
+		if ok && sm.takeOverScopedKey(shortcut, ks, key, conflictKeystroke, dummy) {
+			continue
+		}
+
 		if ok {
 			// conflict
 			if conflictKeystroke.Shortcut != nil {
@@ -457,7 +489,38 @@ func (sm *ShortcutManager) grabKeystroke(shortcut Shortcut, ks *Keystroke, dummy
 	}
 }
 
+// takeOverScopedKey 全局快捷键优先，接管被应用内快捷键抓取的按键。
+func (sm *ShortcutManager) takeOverScopedKey(shortcut Shortcut, ks *Keystroke, key Key,
+	conflictKeystroke *Keystroke, dummy bool) bool {
+	if GetShortcutScope(shortcut) != "" || conflictKeystroke.Shortcut == nil ||
+		GetShortcutScope(conflictKeystroke.Shortcut) == "" {
+		return false
+	}
+
+	conflictDummy := dummyGrab(conflictKeystroke.Shortcut, conflictKeystroke)
+	if dummy && !conflictDummy {
+		key.Ungrab(sm.conn)
+	} else if !dummy && conflictDummy {
+		err := key.Grab(sm.conn)
+		if err != nil {
+			logger.Debug(err)
+			return false
+		}
+	}
+	logger.Debugf("key %v is taken over from %v by %v", key,
+		conflictKeystroke.Shortcut.GetId(), shortcut.GetId())
+	sm.keyKeystrokeMapMu.Lock()
+	sm.keyKeystrokeMap[key] = ks
+	sm.keyKeystrokeMapMu.Unlock()
+	return true
+}
+
 func (sm *ShortcutManager) ungrabKeystroke(ks *Keystroke, dummy bool) {
+	if ks.IsSequence() {
+		sm.ungrabSequenceKeystroke(ks, dummy)
+		return
+	}
+
 	keyList, err := ks.ToKeyList(sm.keySymbols)
 	if err != nil {
 		logger.Debug(err)
@@ -482,6 +545,17 @@ func (sm *ShortcutManager) grabShortcut(shortcut Shortcut) {
 		return
 	}
 	//logger.Debug("grabShortcut shortcut id:", shortcut.GetId())
+	if scope := GetShortcutScope(shortcut); scope != "" {
+		// 应用内快捷键只在应用窗口激活时抓取
+		active := sm.isScopeActive(scope)
+		for _, ks := range shortcut.GetKeystrokes() {
+			ks.Shortcut = shortcut
+			if active {
+				sm.grabKeystroke(shortcut, ks, dummyGrab(shortcut, ks))
+			}
+		}
+		return
+	}
 	for _, ks := range shortcut.GetKeystrokes() {
 		dummy := dummyGrab(shortcut, ks)
 		sm.grabKeystroke(shortcut, ks, dummy)
@@ -490,6 +564,13 @@ func (sm *ShortcutManager) grabShortcut(shortcut Shortcut) {
 }
 
 func (sm *ShortcutManager) ungrabShortcut(shortcut Shortcut) {
+	if GetShortcutScope(shortcut) != "" {
+		for _, ks := range shortcut.GetKeystrokes() {
+			sm.ungrabScopedKeystroke(ks)
+			ks.Shortcut = nil
+		}
+		return
+	}
 
 	for _, ks := range shortcut.GetKeystrokes() {
 		dummy := dummyGrab(shortcut, ks)
@@ -520,8 +601,10 @@ func (sm *ShortcutManager) AddShortcutKeystroke(shortcut Shortcut, ks *Keystroke
 		logger.Debug("shortcut.Keystrokes append", ks.DebugString())
 
 		// grab keystroke
-		dummy := dummyGrab(shortcut, ks)
-		sm.grabKeystroke(shortcut, ks, dummy)
+		if scope := GetShortcutScope(shortcut); scope == "" || sm.isScopeActive(scope) {
+			dummy := dummyGrab(shortcut, ks)
+			sm.grabKeystroke(shortcut, ks, dummy)
+		}
 	}
 	ks.Shortcut = shortcut
 }
@@ -540,8 +623,17 @@ func (sm *ShortcutManager) DeleteShortcutKeystroke(shortcut Shortcut, ks *Keystr
 	logger.Debugf("shortcut.Keystrokes  %v -> %v", oldVal, newVal)
 
 	// ungrab keystroke
-	dummy := dummyGrab(shortcut, ks)
-	sm.ungrabKeystroke(ks, dummy)
+	if GetShortcutScope(shortcut) != "" || ks.IsSequence() {
+		// 应用内快捷键和按键序列按照对象释放，需要使用 shortcut 中保存的对象
+		for _, ks0 := range oldVal {
+			if ks.Equal(sm.keySymbols, ks0) {
+				sm.ungrabScopedKeystroke(ks0)
+			}
+		}
+	} else {
+		dummy := dummyGrab(shortcut, ks)
+		sm.ungrabKeystroke(ks, dummy)
+	}
 	ks.Shortcut = nil
 }
 
@@ -570,6 +662,8 @@ func (sm *ShortcutManager) UngrabAll() {
 	count := len(sm.keyKeystrokeMap)
 	sm.keyKeystrokeMap = make(map[Key]*Keystroke, count)
 	sm.keyKeystrokeMapMu.Unlock()
+
+	sm.ungrabAllSequences()
 }
 
 func (sm *ShortcutManager) GrabAll() {
@@ -645,6 +739,9 @@ func (sm *ShortcutManager) handleKeyEvent(pressed bool, detail x.Keycode, state
 
 	if pressed {
 		// key press
+		if sm.handleSequenceKeyEvent(key, detail, state) {
+			return
+		}
 		sm.emitKeyEvent(Modifiers(state), key)
 	}
 }
@@ -799,6 +896,12 @@ func (sm *ShortcutManager) EventLoop() {
 			event, _ := x.NewKeyReleaseEvent(ev)
 			logger.Debug(event)
 			sm.handleKeyEvent(false, event.Detail, event.State)
+		case x.PropertyNotifyEventCode:
+			event, _ := x.NewPropertyNotifyEvent(ev)
+			if event.Window == sm.conn.GetDefaultScreen().Root &&
+				event.Atom == sm.atomNetActiveWindow {
+				sm.handleActiveWindowChanged()
+			}
 		case x.MappingNotifyEventCode:
 			event, _ := x.NewMappingNotifyEvent(ev)
 			logger.Debug(event)
@@ -876,34 +979,8 @@ func (sm *ShortcutManager) GetByUid(uid string) Shortcut {
 // ret0: Conflicting keystroke
 // ret1: error
 func (sm *ShortcutManager) FindConflictingKeystroke(ks *Keystroke) (*Keystroke, error) {
-	keyList, err := ks.ToKeyList(sm.keySymbols)
-	if err != nil {
-		return nil, err
-	}
-	if len(keyList) == 0 {
-		return nil, nil
-	}
-
-	logger.Debug("ShortcutManager.FindConflictingKeystroke", ks.DebugString())
-	logger.Debug("key list:", keyList)
-
-	sm.keyKeystrokeMapMu.Lock()
-	defer sm.keyKeystrokeMapMu.Unlock()
-	var count = 0
-	var ks1 *Keystroke
-	for _, key := range keyList {
-		tmp, ok := sm.keyKeystrokeMap[key]
-		if !ok {
-			continue
-		}
-		count++
-		ks1 = tmp
-	}
-
-	if count == len(keyList) {
-		return ks1, nil
-	}
-	return nil, nil
+	// 全局快捷键与所有快捷键冲突
+	return sm.FindConflictingKeystrokeInScope(ks, "")
 }
 
 func systemType() string {
@@ -61,6 +61,18 @@ func GetModule(name string) Module {
 	return getLoader().GetModule(name)
 }
 
+func RestartModule(name string) error {
+	return getLoader().RestartModule(name)
+}
+
+func ListStates() []ModuleStateInfo {
+	return getLoader().ListStates()
+}
+
+func GetStartupReports() []StartupReport {
+	return getLoader().GetStartupReports()
+}
+
 func SetLogLevel(pri log.Priority) {
 	getLoader().SetLogLevel(pri)
 }
@@ -21,6 +21,7 @@ package loader
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -76,6 +77,11 @@ type Loader struct {
 	log     *log.Logger
 	lock    sync.Mutex
 	service *dbusutil.Service
+
+	startWorkers int // 并发启动模块的 worker 数量，为 0 时根据 CPU 数量决定
+
+	reportMu sync.Mutex
+	reports  []StartupReport
 }
 
 func (l *Loader) SetLogLevel(pri log.Priority) {
@@ -124,12 +130,65 @@ func (l *Loader) GetModule(name string) Module {
 	return l.modules[name]
 }
 
+// WaitDependencies 等待模块的依赖启动结束，调用者需要持有 l.lock
 func (l *Loader) WaitDependencies(module Module) {
 	for _, dependencyName := range module.GetDependencies() {
 		l.modules[dependencyName].WaitEnable()
 	}
 }
 
+// checkDependencies 检查模块的依赖是否都已正常运行，调用者需要持有 l.lock
+func (l *Loader) checkDependencies(module Module) error {
+	for _, dependencyName := range module.GetDependencies() {
+		dependency := l.modules[dependencyName]
+		if dependency == nil {
+			return &EnableError{ModuleName: module.Name(), Code: ErrorNoDependencies, detail: dependencyName}
+		}
+		if dependency.GetState().State != ModuleStateRunning {
+			return &EnableError{ModuleName: module.Name(), Code: ErrorNoDependencies, detail: dependencyName}
+		}
+	}
+	return nil
+}
+
+type failedMarker interface {
+	markFailed(err error)
+}
+
+func (l *Loader) RestartModule(name string) error {
+	l.lock.Lock()
+	module := l.modules[name]
+	if module == nil {
+		l.lock.Unlock()
+		return &EnableError{ModuleName: name, Code: ErrorMissingModule}
+	}
+	err := l.checkDependencies(module)
+	l.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	l.log.Info("restart module", name)
+	err = module.Restart()
+	if err != nil {
+		return &EnableError{ModuleName: name, Code: ErrorInternalError, detail: err.Error()}
+	}
+	l.log.Infof("restart module %s done, cost %s", name, module.GetState().StartDuration)
+	return nil
+}
+
+func (l *Loader) ListStates() []ModuleStateInfo {
+	modules := l.List()
+	states := make([]ModuleStateInfo, 0, len(modules))
+	for _, module := range modules {
+		states = append(states, module.GetState())
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
 func (l *Loader) EnableModules(enablingModules []string, disableModules []string, flag EnableFlag) error {
 	l.lock.Lock()
 	defer l.lock.Unlock()
@@ -155,38 +214,8 @@ func (l *Loader) EnableModules(enablingModules []string, disableModules []string
 	l.log.Infof("topo sort done, cost add up to %s", duration)
 
 	// enable modules
-	for _, node := range nodes {
-		if node == nil {
-			continue
-		}
-		module := l.modules[node.ID]
-		name := node.ID
-
-		go func() {
-			l.log.Info("enable module", name)
-			startTime := time.Now()
-
-			// wait for its dependency
-			l.WaitDependencies(module)
-			endTime := time.Now()
-			duration := endTime.Sub(startTime)
-			l.log.Info("module", name, "wait done, cost", duration)
-
-			err := module.Enable(true)
-			endTime = time.Now()
-			duration = endTime.Sub(startTime)
-			if err != nil {
-				l.log.Fatalf("enable module %s failed: %s, cost %s", name, err, duration)
-			} else {
-				l.log.Infof("enable module %s done cost %s", name, duration)
-			}
-		}()
-	}
-
-	for _, n := range nodes {
-		m := l.modules[n.ID]
-		m.WaitEnable()
-	}
+	report := l.startModules(nodes)
+	l.addStartupReport(report)
 
 	endTime = time.Now()
 	duration = endTime.Sub(startTime)
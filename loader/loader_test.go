@@ -2,7 +2,10 @@ package loader
 
 import (
 	"math/rand"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -96,3 +99,178 @@ func Test_Loader(t *testing.T) {
 		assert.Equal(t, err, data.output)
 	}
 }
+
+type panicModule struct {
+	*ModuleBase
+	dependencies []string
+	panicOnStart bool
+	startTimes   int
+}
+
+func newPanicModule(name string, panicOnStart bool, dependencies ...string) *panicModule {
+	m := &panicModule{
+		panicOnStart: panicOnStart,
+		dependencies: dependencies,
+	}
+	m.ModuleBase = NewModuleBase(name, m, log.NewLogger(name))
+	return m
+}
+
+func (m *panicModule) GetDependencies() []string {
+	return m.dependencies
+}
+
+func (m *panicModule) Start() error {
+	m.startTimes++
+	if m.panicOnStart {
+		panic("start failed")
+	}
+	return nil
+}
+
+func (m *panicModule) Stop() error {
+	return nil
+}
+
+func Test_LoaderCrashIsolation(t *testing.T) {
+	_loader = &Loader{
+		modules: Modules{},
+		log:     log.NewLogger("daemon/loader"),
+	}
+	bad := newPanicModule("bad", true)
+	dependent := newPanicModule("dependent", false, "bad")
+	good := newPanicModule("good", false)
+	Register(bad)
+	Register(dependent)
+	Register(good)
+
+	err := EnableModules([]string{"bad", "dependent", "good"}, nil, EnableFlagNone)
+	assert.Nil(t, err)
+
+	assert.Equal(t, ModuleStateFailed, bad.GetState().State)
+	assert.Contains(t, bad.GetState().LastError, "start failed")
+	assert.Equal(t, ModuleStateFailed, dependent.GetState().State)
+	assert.Equal(t, 0, dependent.startTimes)
+	assert.Equal(t, ModuleStateRunning, good.GetState().State)
+
+	// 依赖未运行时不允许重启
+	assert.NotNil(t, RestartModule("dependent"))
+
+	bad.panicOnStart = false
+	assert.Nil(t, RestartModule("bad"))
+	assert.Equal(t, ModuleStateRunning, bad.GetState().State)
+	assert.Nil(t, RestartModule("dependent"))
+	assert.Equal(t, ModuleStateRunning, dependent.GetState().State)
+
+	assert.Nil(t, RestartModule("good"))
+	assert.Equal(t, 2, good.startTimes)
+	assert.True(t, good.IsEnable())
+
+	states := ListStates()
+	assert.Len(t, states, 3)
+	assert.Equal(t, "bad", states[0].Name)
+	assert.NotNil(t, RestartModule("missing"))
+}
+
+func Test_LoaderConcurrentRestart(t *testing.T) {
+	_loader = &Loader{
+		modules: Modules{},
+		log:     log.NewLogger("daemon/loader"),
+	}
+	a := newPanicModule("a", false)
+	Register(a)
+	assert.Nil(t, EnableModules([]string{"a"}, nil, EnableFlagNone))
+
+	// 重启模块时，其他 goroutine 注册模块和读取模块的状态
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, RestartModule("a"))
+		}()
+		go func(i int) {
+			defer wg.Done()
+			Register(newPanicModule("b"+strconv.Itoa(i), false, "a"))
+			_ = a.IsEnable()
+		}(i)
+	}
+	wg.Wait()
+	assert.True(t, a.IsEnable())
+	assert.Equal(t, 5, a.startTimes)
+}
+
+type timingModule struct {
+	*ModuleBase
+	dependencies []string
+	cost         time.Duration
+	running      *int32
+	maxRunning   *int32
+	started      time.Time
+	done         time.Time
+}
+
+func newTimingModule(name string, cost time.Duration, running, maxRunning *int32, dependencies ...string) *timingModule {
+	m := &timingModule{
+		dependencies: dependencies,
+		cost:         cost,
+		running:      running,
+		maxRunning:   maxRunning,
+	}
+	m.ModuleBase = NewModuleBase(name, m, log.NewLogger(name))
+	return m
+}
+
+func (m *timingModule) GetDependencies() []string {
+	return m.dependencies
+}
+
+func (m *timingModule) Start() error {
+	m.started = time.Now()
+	n := atomic.AddInt32(m.running, 1)
+	for {
+		max := atomic.LoadInt32(m.maxRunning)
+		if n <= max || atomic.CompareAndSwapInt32(m.maxRunning, max, n) {
+			break
+		}
+	}
+	time.Sleep(m.cost)
+	atomic.AddInt32(m.running, -1)
+	m.done = time.Now()
+	return nil
+}
+
+func (m *timingModule) Stop() error {
+	return nil
+}
+
+func Test_LoaderParallelStart(t *testing.T) {
+	_loader = &Loader{
+		modules:      Modules{},
+		log:          log.NewLogger("daemon/loader"),
+		startWorkers: 2,
+	}
+	var running, maxRunning int32
+	a := newTimingModule("a", 100*time.Millisecond, &running, &maxRunning)
+	b := newTimingModule("b", 100*time.Millisecond, &running, &maxRunning)
+	c := newTimingModule("c", 100*time.Millisecond, &running, &maxRunning)
+	d := newTimingModule("d", 10*time.Millisecond, &running, &maxRunning, "a", "b")
+	for _, m := range []Module{a, b, c, d} {
+		Register(m)
+	}
+
+	err := EnableModules([]string{"a", "b", "c", "d"}, nil, EnableFlagNone)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), maxRunning)
+	assert.False(t, d.started.Before(a.done))
+	assert.False(t, d.started.Before(b.done))
+
+	reports := GetStartupReports()
+	assert.Len(t, reports, 1)
+	assert.Equal(t, 2, reports[0].Workers)
+	assert.Len(t, reports[0].Modules, 4)
+	assert.Equal(t, "d", reports[0].Modules[3].Name)
+	for _, timing := range reports[0].Modules {
+		assert.Equal(t, ModuleStateRunning, timing.State)
+	}
+}
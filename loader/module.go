@@ -21,19 +21,57 @@ package loader
 
 import (
 	"fmt"
+	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/linuxdeepin/go-lib/log"
 )
 
+type ModuleState uint32
+
+const (
+	ModuleStateStopped ModuleState = iota
+	ModuleStateStarting
+	ModuleStateRunning
+	ModuleStateFailed
+)
+
+func (s ModuleState) String() string {
+	switch s {
+	case ModuleStateStopped:
+		return "stopped"
+	case ModuleStateStarting:
+		return "starting"
+	case ModuleStateRunning:
+		return "running"
+	case ModuleStateFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("unknown(%d)", uint32(s))
+}
+
+func (s ModuleState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type ModuleStateInfo struct {
+	Name          string
+	State         ModuleState
+	LastError     string
+	StartDuration time.Duration // 最近一次 Start 的耗时
+}
+
 type Module interface {
 	Name() string
 	IsEnable() bool
 	Enable(bool) error
+	Restart() error
+	GetState() ModuleStateInfo
 	GetDependencies() []string
 	SetLogLevel(log.Priority)
 	LogLevel() log.Priority
-	WaitEnable() // TODO: should this function return when modules enable failed?
+	WaitEnable() // 模块第一次启动结束（无论成功与否）后返回，通过 GetState 获取启动结果
 	ModuleImpl
 }
 
@@ -46,10 +84,18 @@ type ModuleImpl interface {
 
 type ModuleBase struct {
 	impl    ModuleImpl
-	enabled bool
+	enabled bool // 在 enableMu 中修改，同时需要持有 stateMu，以便 IsEnable 在模块启动时读取
 	name    string
 	log     *log.Logger
 	wg      sync.WaitGroup
+	wgDone  sync.Once
+
+	enableMu sync.Mutex
+
+	stateMu       sync.Mutex
+	state         ModuleState
+	lastErr       error
+	startDuration time.Duration
 }
 
 func NewModuleBase(name string, impl ModuleImpl, logger *log.Logger) *ModuleBase {
@@ -67,32 +113,125 @@ func NewModuleBase(name string, impl ModuleImpl, logger *log.Logger) *ModuleBase
 }
 
 func (d *ModuleBase) doEnable(enable bool) error {
-	if d.impl != nil {
-		fn := d.impl.Stop
-		if enable {
-			fn = d.impl.Start
-		}
-
-		if err := fn(); err != nil {
+	if !enable {
+		err := d.callImpl(false)
+		if err != nil {
+			d.setState(ModuleStateFailed, err, d.getStartDuration())
 			return err
 		}
+		d.setState(ModuleStateStopped, nil, d.getStartDuration())
+		d.setEnabled(false)
+		return nil
+	}
 
-		if enable {
-			d.wg.Done()
-		}
+	d.setState(ModuleStateStarting, nil, 0)
+	startTime := time.Now()
+	err := d.callImpl(true)
+	duration := time.Since(startTime)
+	// 启动失败也需要结束等待，否则依赖当前模块的模块会一直阻塞，
+	// 依赖方通过 GetState 判断当前模块是否启动成功。
+	d.markEnableDone()
+	if err != nil {
+		d.setState(ModuleStateFailed, err, duration)
+		return err
 	}
-	d.enabled = enable
+	d.setState(ModuleStateRunning, nil, duration)
+	d.setEnabled(true)
 	return nil
 }
 
+// callImpl 调用模块的 Start 或 Stop，并将其中的 panic 转换为错误，避免单个模块导致整个进程退出。
+// 注意：模块在 Start 中另起的 goroutine 发生的 panic 无法在此恢复。
+func (d *ModuleBase) callImpl(enable bool) (err error) {
+	if d.impl == nil {
+		return nil
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			d.log.Warningf("module %s panic: %v\n%s", d.name, v, debug.Stack())
+			err = fmt.Errorf("%s panic: %v", d.name, v)
+		}
+	}()
+
+	if enable {
+		return d.impl.Start()
+	}
+	return d.impl.Stop()
+}
+
+func (d *ModuleBase) markEnableDone() {
+	d.wgDone.Do(d.wg.Done)
+}
+
+// markFailed 将模块标记为启动失败，用于模块因依赖启动失败而未被启动的情况。
+func (d *ModuleBase) markFailed(err error) {
+	d.setState(ModuleStateFailed, err, 0)
+	d.markEnableDone()
+}
+
+func (d *ModuleBase) setState(state ModuleState, err error, startDuration time.Duration) {
+	d.stateMu.Lock()
+	d.state = state
+	d.lastErr = err
+	d.startDuration = startDuration
+	d.stateMu.Unlock()
+}
+
+func (d *ModuleBase) setEnabled(enabled bool) {
+	d.stateMu.Lock()
+	d.enabled = enabled
+	d.stateMu.Unlock()
+}
+
+func (d *ModuleBase) getStartDuration() time.Duration {
+	d.stateMu.Lock()
+	defer d.stateMu.Unlock()
+	return d.startDuration
+}
+
 func (d *ModuleBase) Enable(enable bool) error {
+	d.enableMu.Lock()
+	defer d.enableMu.Unlock()
+
 	if d.enabled == enable {
 		return fmt.Errorf("%s daemon is already started", d.name)
 	}
 	return d.doEnable(enable)
 }
 
+// Restart 停止（如果已启动）并重新启动模块，用于在不重启整个进程的情况下恢复单个模块。
+func (d *ModuleBase) Restart() error {
+	d.enableMu.Lock()
+	defer d.enableMu.Unlock()
+
+	if d.enabled {
+		err := d.doEnable(false)
+		if err != nil {
+			d.log.Warningf("stop module %s failed: %v", d.name, err)
+		}
+	}
+	return d.doEnable(true)
+}
+
+func (d *ModuleBase) GetState() ModuleStateInfo {
+	d.stateMu.Lock()
+	defer d.stateMu.Unlock()
+
+	info := ModuleStateInfo{
+		Name:          d.name,
+		State:         d.state,
+		StartDuration: d.startDuration,
+	}
+	if d.lastErr != nil {
+		info.LastError = d.lastErr.Error()
+	}
+	return info
+}
+
 func (d *ModuleBase) IsEnable() bool {
+	d.stateMu.Lock()
+	defer d.stateMu.Unlock()
 	return d.enabled
 }
 
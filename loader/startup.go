@@ -0,0 +1,190 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package loader
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/linuxdeepin/dde-daemon/graph"
+)
+
+const (
+	minStartWorkers = 2
+	maxStartWorkers = 8
+)
+
+// ModuleTiming 记录单个模块的启动耗时
+type ModuleTiming struct {
+	Name  string
+	Begin time.Duration // 相对于本批次开始的时间
+	Wait  time.Duration // 等待依赖模块的耗时
+	Cost  time.Duration // Start 的耗时
+	State ModuleState
+	Error string
+}
+
+// StartupReport 为一次 EnableModules 的启动报告，Modules 按 Cost 从大到小排序
+type StartupReport struct {
+	Time    time.Time
+	Total   time.Duration
+	Workers int
+	Modules []ModuleTiming
+}
+
+func defaultStartWorkers() int {
+	n := runtime.NumCPU()
+	if n < minStartWorkers {
+		return minStartWorkers
+	}
+	if n > maxStartWorkers {
+		return maxStartWorkers
+	}
+	return n
+}
+
+// startModules 沿着依赖图并发启动模块：依赖都已启动完成的模块进入就绪队列，由固定数量的 worker 启动。
+func (l *Loader) startModules(nodes graph.Nodes) *StartupReport {
+	workers := l.startWorkers
+	if workers <= 0 {
+		workers = defaultStartWorkers()
+	}
+	report := &StartupReport{
+		Time:    time.Now(),
+		Workers: workers,
+		Modules: make([]ModuleTiming, 0, len(nodes)),
+	}
+	if len(nodes) == 0 {
+		return report
+	}
+
+	pending := make(map[*graph.Node]int, len(nodes))
+	readyCh := make(chan *graph.Node, len(nodes))
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		pending[node] = len(node.WeightFrom)
+		if pending[node] == 0 {
+			readyCh <- node
+		}
+	}
+
+	type result struct {
+		node   *graph.Node
+		timing ModuleTiming
+	}
+	resultCh := make(chan result, len(nodes))
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for node := range readyCh {
+				resultCh <- result{node: node, timing: l.startModule(node.ID, report.Time)}
+			}
+		}()
+	}
+
+	for i := 0; i < len(pending); i++ {
+		r := <-resultCh
+		report.Modules = append(report.Modules, r.timing)
+		for dependent := range r.node.WeightTo {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				readyCh <- dependent
+			}
+		}
+	}
+	close(readyCh)
+	wg.Wait()
+
+	report.Total = time.Since(report.Time)
+	sort.SliceStable(report.Modules, func(i, j int) bool {
+		return report.Modules[i].Cost > report.Modules[j].Cost
+	})
+	return report
+}
+
+// startModule 在 worker 中启动单个模块，EnableModules 在启动期间一直持有 l.lock，可以直接读取 l.modules
+func (l *Loader) startModule(name string, batchStart time.Time) ModuleTiming {
+	timing := ModuleTiming{
+		Name:  name,
+		Begin: time.Since(batchStart),
+	}
+	module := l.modules[name]
+	if module == nil {
+		timing.Error = "module is missing"
+		return timing
+	}
+	if module.IsEnable() {
+		l.log.Debug("module", name, "is already enabled")
+		timing.State = module.GetState().State
+		return timing
+	}
+
+	l.log.Info("enable module", name)
+	startTime := time.Now()
+	// 就绪队列中的模块依赖已经启动结束，此处仍保留等待，兼容依赖不在本批次中的情况
+	l.WaitDependencies(module)
+	timing.Wait = time.Since(startTime)
+
+	err := l.checkDependencies(module)
+	if err != nil {
+		l.log.Warningf("skip module %s: %s", name, err)
+		if marker, ok := module.(failedMarker); ok {
+			marker.markFailed(err)
+		}
+	} else {
+		err = module.Enable(true)
+		if err != nil {
+			// 单个模块启动失败不影响其他模块，失败原因记录在模块状态中
+			l.log.Warningf("enable module %s failed: %s, cost %s", name, err, time.Since(startTime))
+		} else {
+			l.log.Infof("enable module %s done cost %s", name, time.Since(startTime))
+		}
+	}
+
+	state := module.GetState()
+	timing.Cost = state.StartDuration
+	timing.State = state.State
+	timing.Error = state.LastError
+	return timing
+}
+
+func (l *Loader) addStartupReport(report *StartupReport) {
+	l.reportMu.Lock()
+	l.reports = append(l.reports, *report)
+	l.reportMu.Unlock()
+
+	for _, timing := range report.Modules {
+		l.log.Infof("startup report: module %s begin %s wait %s cost %s state %s",
+			timing.Name, timing.Begin, timing.Wait, timing.Cost, timing.State)
+	}
+}
+
+// GetStartupReports 返回每次 EnableModules 的启动报告
+func (l *Loader) GetStartupReports() []StartupReport {
+	l.reportMu.Lock()
+	defer l.reportMu.Unlock()
+	reports := make([]StartupReport, len(l.reports))
+	copy(reports, l.reports)
+	return reports
+}
@@ -0,0 +1,644 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/linuxdeepin/dde-daemon/network/nm"
+)
+
+// 连接的导入导出。导出的文件是 JSON 格式，每个配置项保存 D-Bus 签名和 GVariant 文本，
+// 可以原样还原成 NetworkManager 的连接数据；导入时还支持 OpenVPN 的 .ovpn 和 WireGuard 的 .conf 文件。
+
+const (
+	portableConnectionsVersion = "1.0"
+
+	nmSettingWireGuardSettingName = "wireguard"
+	wireGuardIfcPrefix            = "wg-"
+)
+
+var errUnknownImportFormat = errors.New("unknown connection file format")
+
+// 支持导入导出的连接类型，802.1x 包含在有线和无线连接中
+var portableConnectionTypes = []string{
+	nm.NM_SETTING_WIRED_SETTING_NAME,
+	nm.NM_SETTING_WIRELESS_SETTING_NAME,
+	nm.NM_SETTING_VPN_SETTING_NAME,
+	nmSettingWireGuardSettingName,
+}
+
+// 可能带有密码的配置，导出密码时需要单独获取
+var secretSettingNames = []string{
+	nm.NM_SETTING_WIRELESS_SECURITY_SETTING_NAME,
+	nm.NM_SETTING_802_1X_SETTING_NAME,
+	nm.NM_SETTING_VPN_SETTING_NAME,
+	nmSettingWireGuardSettingName,
+}
+
+// 与本机相关的配置，导出时删除
+var machineSpecificKeys = map[string][]string{
+	nm.NM_SETTING_CONNECTION_SETTING_NAME: {"interface-name", "permissions", "timestamp"},
+	nm.NM_SETTING_WIRED_SETTING_NAME:      {"mac-address", "cloned-mac-address", "mac-address-blacklist"},
+	nm.NM_SETTING_WIRELESS_SETTING_NAME:   {"mac-address", "cloned-mac-address", "mac-address-blacklist", "seen-bssids"},
+}
+
+type portableValue struct {
+	Signature string
+	Value     string
+}
+
+type portableConnection struct {
+	Id       string
+	Uuid     string
+	Type     string
+	Settings map[string]map[string]portableValue
+}
+
+type portableConnections struct {
+	Version     string
+	Connections []*portableConnection
+}
+
+func isPortableConnectionType(connType string) bool {
+	return isStringInArray(connType, portableConnectionTypes)
+}
+
+// cleanPortableConnectionData 删除与本机相关的配置和已被新配置项替代的旧配置项
+func cleanPortableConnectionData(data connectionData) {
+	for section, keys := range machineSpecificKeys {
+		removeSettingKey(data, section, keys...)
+	}
+	for _, section := range []string{nm.NM_SETTING_IP4_CONFIG_SETTING_NAME, nm.NM_SETTING_IP6_CONFIG_SETTING_NAME} {
+		if isSettingKeyExists(data, section, "address-data") {
+			removeSettingKey(data, section, "addresses")
+		}
+		if isSettingKeyExists(data, section, "route-data") {
+			removeSettingKey(data, section, "routes")
+		}
+	}
+}
+
+// mergeSecrets 把 GetSecrets 获取的密码合并到连接数据中
+func mergeSecrets(data connectionData, secrets connectionData) {
+	for section, values := range secrets {
+		if !isSettingExists(data, section) {
+			continue
+		}
+		for key, value := range values {
+			data[section][key] = value
+		}
+	}
+}
+
+func encodePortableConnection(data connectionData) *portableConnection {
+	pc := &portableConnection{
+		Id:       getSettingConnectionId(data),
+		Uuid:     getSettingConnectionUuid(data),
+		Type:     getSettingConnectionType(data),
+		Settings: make(map[string]map[string]portableValue, len(data)),
+	}
+	for section, values := range data {
+		pvs := make(map[string]portableValue, len(values))
+		for key, value := range values {
+			pvs[key] = portableValue{
+				Signature: value.Signature().String(),
+				Value:     value.String(),
+			}
+		}
+		pc.Settings[section] = pvs
+	}
+	return pc
+}
+
+func decodePortableConnection(pc *portableConnection) (connectionData, error) {
+	data := make(connectionData, len(pc.Settings))
+	for section, pvs := range pc.Settings {
+		values := make(map[string]dbus.Variant, len(pvs))
+		for key, pv := range pvs {
+			sig, err := dbus.ParseSignature(pv.Signature)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", section, key, err)
+			}
+			value, err := dbus.ParseVariant(pv.Value, sig)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", section, key, err)
+			}
+			values[key] = value
+		}
+		data[section] = values
+	}
+
+	connType := getSettingConnectionType(data)
+	if !isPortableConnectionType(connType) {
+		return nil, fmt.Errorf("unsupported connection type %q", connType)
+	}
+	if getSettingConnectionId(data) == "" || getSettingConnectionUuid(data) == "" {
+		return nil, errors.New("connection id or uuid is empty")
+	}
+	return data, nil
+}
+
+func parsePortableConnections(content string) ([]connectionData, error) {
+	var pcs portableConnections
+	err := json.Unmarshal([]byte(content), &pcs)
+	if err != nil {
+		return nil, err
+	}
+	if pcs.Version != portableConnectionsVersion {
+		return nil, fmt.Errorf("unsupported version %q", pcs.Version)
+	}
+	result := make([]connectionData, 0, len(pcs.Connections))
+	for _, pc := range pcs.Connections {
+		data, err := decodePortableConnection(pc)
+		if err != nil {
+			return nil, fmt.Errorf("connection %q: %v", pc.Id, err)
+		}
+		result = append(result, data)
+	}
+	return result, nil
+}
+
+// splitConfigLine 去掉注释并按空白分割，OpenVPN 和 WireGuard 都使用 # 和 ; 作为注释
+func splitConfigLine(line string) []string {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' || line[0] == ';' {
+		return nil
+	}
+	return strings.Fields(line)
+}
+
+func isOpenVPNConfig(content string) bool {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := splitConfigLine(scanner.Text())
+		if len(fields) > 0 && (fields[0] == "remote" || fields[0] == "client") {
+			return true
+		}
+	}
+	return false
+}
+
+func isWireGuardConfig(content string) bool {
+	return strings.Contains(content, "[Interface]") && strings.Contains(content, "[Peer]")
+}
+
+// openVPNConfig 解析后的 OpenVPN 配置，inline 为内嵌在配置中的证书和密钥，需要保存成文件后填入 data
+type openVPNConfig struct {
+	data   map[string]string
+	inline map[string]string
+}
+
+// 内嵌块的名称与 NetworkManager openvpn 插件配置项的对应关系
+var openVPNInlineKeys = map[string]string{
+	"ca":        "ca",
+	"cert":      "cert",
+	"key":       "key",
+	"tls-auth":  "ta",
+	"tls-crypt": "tls-crypt",
+	"secret":    "static-key",
+}
+
+func parseOpenVPNConfig(content string) (*openVPNConfig, error) {
+	cfg := &openVPNConfig{
+		data:   make(map[string]string),
+		inline: make(map[string]string),
+	}
+	var remotes []string
+	var port, proto string
+	var authUserPass bool
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	var inlineName string
+	var inlineContent strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inlineName != "" {
+			if strings.TrimSpace(line) == "</"+inlineName+">" {
+				cfg.inline[inlineName] = inlineContent.String()
+				inlineName = ""
+				inlineContent.Reset()
+				continue
+			}
+			inlineContent.WriteString(line)
+			inlineContent.WriteByte('\n')
+			continue
+		}
+
+		fields := splitConfigLine(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "<") && strings.HasSuffix(fields[0], ">") {
+			inlineName = strings.Trim(fields[0], "<>")
+			continue
+		}
+
+		args := fields[1:]
+		arg := func(i int) string {
+			if i < len(args) {
+				return args[i]
+			}
+			return ""
+		}
+		switch fields[0] {
+		case "remote":
+			if arg(0) == "" {
+				continue
+			}
+			remote := arg(0)
+			if arg(1) != "" {
+				remote += ":" + arg(1)
+			}
+			if arg(2) != "" {
+				remote += ":" + arg(2)
+			}
+			remotes = append(remotes, remote)
+		case "port":
+			port = arg(0)
+		case "proto":
+			proto = arg(0)
+		case "dev":
+			if strings.HasPrefix(arg(0), "tap") {
+				cfg.data["dev-type"] = "tap"
+			} else {
+				cfg.data["dev-type"] = "tun"
+			}
+		case "ca", "cert", "key", "tls-auth", "tls-crypt", "secret":
+			if arg(0) != "" && arg(0) != "[inline]" {
+				cfg.data[openVPNInlineKeys[fields[0]]] = arg(0)
+			}
+			if fields[0] == "tls-auth" && arg(1) != "" {
+				cfg.data["ta-dir"] = arg(1)
+			}
+			if fields[0] == "secret" && arg(1) != "" {
+				cfg.data["static-key-direction"] = arg(1)
+			}
+		case "key-direction":
+			cfg.data["ta-dir"] = arg(0)
+		case "auth-user-pass":
+			authUserPass = true
+		case "cipher":
+			cfg.data["cipher"] = arg(0)
+		case "auth":
+			cfg.data["auth"] = arg(0)
+		case "comp-lzo":
+			if arg(0) == "" {
+				cfg.data["comp-lzo"] = "adaptive"
+			} else {
+				cfg.data["comp-lzo"] = arg(0)
+			}
+		case "remote-cert-tls":
+			cfg.data["remote-cert-tls"] = arg(0)
+		case "verify-x509-name":
+			if arg(1) != "" {
+				cfg.data["verify-x509-name"] = arg(1) + ":" + arg(0)
+			} else {
+				cfg.data["verify-x509-name"] = "subject:" + arg(0)
+			}
+		case "reneg-sec":
+			cfg.data["reneg-seconds"] = arg(0)
+		case "tun-mtu":
+			cfg.data["tunnel-mtu"] = arg(0)
+		case "fragment":
+			cfg.data["fragment-size"] = arg(0)
+		case "mssfix":
+			cfg.data["mssfix"] = "yes"
+		case "float":
+			cfg.data["float"] = "yes"
+		}
+	}
+	if inlineName != "" {
+		return nil, fmt.Errorf("inline block <%s> is not closed", inlineName)
+	}
+	if len(remotes) == 0 {
+		return nil, errors.New("no remote in openvpn config")
+	}
+
+	if port != "" {
+		cfg.data["port"] = port
+	}
+	if strings.HasPrefix(proto, "tcp") {
+		cfg.data["proto-tcp"] = "yes"
+	}
+	cfg.data["remote"] = strings.Join(remotes, ", ")
+
+	hasKey := func(name string) bool {
+		_, inline := cfg.inline[name]
+		_, file := cfg.data[openVPNInlineKeys[name]]
+		return inline || file
+	}
+	switch {
+	case hasKey("secret"):
+		cfg.data["connection-type"] = "static-key"
+	case hasKey("cert") && hasKey("key"):
+		if authUserPass {
+			cfg.data["connection-type"] = "password-tls"
+		} else {
+			cfg.data["connection-type"] = "tls"
+		}
+	case authUserPass:
+		cfg.data["connection-type"] = "password"
+	default:
+		return nil, errors.New("no authentication method in openvpn config")
+	}
+	return cfg, nil
+}
+
+// newOpenVPNConnectionData 创建 OpenVPN 连接，内嵌的证书应已保存为文件并填入 vpnData
+func newOpenVPNConnectionData(id, uuid string, vpnData map[string]string) connectionData {
+	data := make(connectionData)
+	addSetting(data, nm.NM_SETTING_CONNECTION_SETTING_NAME)
+	setSettingConnectionId(data, id)
+	setSettingConnectionUuid(data, uuid)
+	setSettingConnectionType(data, nm.NM_SETTING_VPN_SETTING_NAME)
+	setSettingConnectionAutoconnect(data, false)
+
+	addSetting(data, nm.NM_SETTING_VPN_SETTING_NAME)
+	setSettingVpnServiceType(data, nm.NM_DBUS_SERVICE_OPENVPN)
+	setSettingVpnData(data, vpnData)
+
+	initSettingSectionIpv4(data)
+	initSettingSectionIpv6(data)
+	return data
+}
+
+type wireGuardPeer struct {
+	publicKey    string
+	presharedKey string
+	endpoint     string
+	allowedIPs   []string
+	keepalive    uint32
+}
+
+type wireGuardConfig struct {
+	privateKey string
+	listenPort uint32
+	fwmark     uint32
+	mtu        uint32
+	addresses  []string // CIDR
+	dns        []string
+	dnsSearch  []string
+	peers      []*wireGuardPeer
+}
+
+func splitCommaList(value string) []string {
+	var result []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func parseWireGuardUint(key, value string) (uint32, error) {
+	v, err := strconv.ParseUint(value, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return uint32(v), nil
+}
+
+func parseWireGuardConfig(content string) (*wireGuardConfig, error) {
+	cfg := &wireGuardConfig{}
+	var section string
+	var peer *wireGuardPeer
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			if section == "peer" {
+				peer = &wireGuardPeer{}
+				cfg.peers = append(cfg.peers, peer)
+			}
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := strings.TrimSpace(kv[1])
+		var err error
+		switch section {
+		case "interface":
+			switch key {
+			case "privatekey":
+				cfg.privateKey = value
+			case "listenport":
+				cfg.listenPort, err = parseWireGuardUint(key, value)
+			case "fwmark":
+				if value != "off" {
+					cfg.fwmark, err = parseWireGuardUint(key, value)
+				}
+			case "mtu":
+				cfg.mtu, err = parseWireGuardUint(key, value)
+			case "address":
+				cfg.addresses = append(cfg.addresses, splitCommaList(value)...)
+			case "dns":
+				for _, v := range splitCommaList(value) {
+					if net.ParseIP(v) != nil {
+						cfg.dns = append(cfg.dns, v)
+					} else {
+						cfg.dnsSearch = append(cfg.dnsSearch, v)
+					}
+				}
+			}
+		case "peer":
+			switch key {
+			case "publickey":
+				peer.publicKey = value
+			case "presharedkey":
+				peer.presharedKey = value
+			case "endpoint":
+				peer.endpoint = value
+			case "allowedips":
+				peer.allowedIPs = append(peer.allowedIPs, splitCommaList(value)...)
+			case "persistentkeepalive":
+				if value != "off" {
+					peer.keepalive, err = parseWireGuardUint(key, value)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("line %q is not in a section", line)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.privateKey == "" {
+		return nil, errors.New("no private key in wireguard config")
+	}
+	if len(cfg.peers) == 0 {
+		return nil, errors.New("no peer in wireguard config")
+	}
+	for _, p := range cfg.peers {
+		if p.publicKey == "" {
+			return nil, errors.New("peer without public key in wireguard config")
+		}
+	}
+	return cfg, nil
+}
+
+// wireGuardConnectionId 用第一个 peer 的地址作为连接名称
+func (cfg *wireGuardConfig) connectionId() string {
+	host := cfg.peers[0].endpoint
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		return "WireGuard"
+	}
+	return "WireGuard " + host
+}
+
+func newWireGuardConnectionData(id, uuid string, cfg *wireGuardConfig) (connectionData, error) {
+	data := make(connectionData)
+	addSetting(data, nm.NM_SETTING_CONNECTION_SETTING_NAME)
+	setSettingConnectionId(data, id)
+	setSettingConnectionUuid(data, uuid)
+	setSettingConnectionType(data, nmSettingWireGuardSettingName)
+	setSettingConnectionAutoconnect(data, false)
+	// WireGuard 连接必须指定网卡名称，网卡名称不能超过 15 个字符
+	setSettingConnectionInterfaceName(data, wireGuardIfcPrefix+strings.Replace(uuid, "-", "", -1)[:8])
+
+	addSetting(data, nmSettingWireGuardSettingName)
+	setSettingKey(data, nmSettingWireGuardSettingName, "private-key", cfg.privateKey)
+	if cfg.listenPort != 0 {
+		setSettingKey(data, nmSettingWireGuardSettingName, "listen-port", cfg.listenPort)
+	}
+	if cfg.fwmark != 0 {
+		setSettingKey(data, nmSettingWireGuardSettingName, "fwmark", cfg.fwmark)
+	}
+	if cfg.mtu != 0 {
+		setSettingKey(data, nmSettingWireGuardSettingName, "mtu", cfg.mtu)
+	}
+	peers := make([]map[string]dbus.Variant, 0, len(cfg.peers))
+	for _, p := range cfg.peers {
+		peer := map[string]dbus.Variant{
+			"public-key":  dbus.MakeVariant(p.publicKey),
+			"allowed-ips": dbus.MakeVariant(p.allowedIPs),
+		}
+		if p.endpoint != "" {
+			peer["endpoint"] = dbus.MakeVariant(p.endpoint)
+		}
+		if p.presharedKey != "" {
+			peer["preshared-key"] = dbus.MakeVariant(p.presharedKey)
+			peer["preshared-key-flags"] = dbus.MakeVariant(uint32(0))
+		}
+		if p.keepalive != 0 {
+			peer["persistent-keepalive"] = dbus.MakeVariant(p.keepalive)
+		}
+		peers = append(peers, peer)
+	}
+	setSettingKey(data, nmSettingWireGuardSettingName, "peers", peers)
+
+	var ip4Addrs, ip6Addrs []map[string]dbus.Variant
+	for _, addr := range cfg.addresses {
+		ip, ipNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			ip = net.ParseIP(addr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", addr)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+		prefix, _ := ipNet.Mask.Size()
+		item := map[string]dbus.Variant{
+			"address": dbus.MakeVariant(ip.String()),
+			"prefix":  dbus.MakeVariant(uint32(prefix)),
+		}
+		if ip.To4() != nil {
+			ip4Addrs = append(ip4Addrs, item)
+		} else {
+			ip6Addrs = append(ip6Addrs, item)
+		}
+	}
+
+	var dns4 []uint32
+	var dns6 [][]byte
+	for _, dns := range cfg.dns {
+		ip := net.ParseIP(dns)
+		if ip4 := ip.To4(); ip4 != nil {
+			dns4 = append(dns4, htonl(ipToUint32(ip4.String())))
+		} else {
+			dns6 = append(dns6, []byte(ip.To16()))
+		}
+	}
+
+	addSetting(data, nm.NM_SETTING_IP4_CONFIG_SETTING_NAME)
+	if len(ip4Addrs) > 0 {
+		setSettingIP4ConfigMethod(data, nm.NM_SETTING_IP4_CONFIG_METHOD_MANUAL)
+		setSettingKey(data, nm.NM_SETTING_IP4_CONFIG_SETTING_NAME, "address-data", ip4Addrs)
+		if len(dns4) > 0 {
+			setSettingKey(data, nm.NM_SETTING_IP4_CONFIG_SETTING_NAME, "dns", dns4)
+		}
+		if len(cfg.dnsSearch) > 0 {
+			setSettingKey(data, nm.NM_SETTING_IP4_CONFIG_SETTING_NAME, "dns-search", cfg.dnsSearch)
+		}
+	} else {
+		setSettingIP4ConfigMethod(data, nm.NM_SETTING_IP4_CONFIG_METHOD_DISABLED)
+	}
+
+	addSetting(data, nm.NM_SETTING_IP6_CONFIG_SETTING_NAME)
+	if len(ip6Addrs) > 0 {
+		setSettingIP6ConfigMethod(data, nm.NM_SETTING_IP6_CONFIG_METHOD_MANUAL)
+		setSettingKey(data, nm.NM_SETTING_IP6_CONFIG_SETTING_NAME, "address-data", ip6Addrs)
+		if len(dns6) > 0 {
+			setSettingKey(data, nm.NM_SETTING_IP6_CONFIG_SETTING_NAME, "dns", dns6)
+		}
+	} else {
+		setSettingIP6ConfigMethod(data, nm.NM_SETTING_IP6_CONFIG_METHOD_IGNORE)
+	}
+	return data, nil
+}
+
+// uniqueConnectionId 名称与已有的连接重复时在后面加上序号
+func uniqueConnectionId(id string, existIds []string) string {
+	if !isStringInArray(id, existIds) {
+		return id
+	}
+	for i := 2; ; i++ {
+		newId := fmt.Sprintf("%s (%d)", id, i)
+		if !isStringInArray(newId, existIds) {
+			return newId
+		}
+	}
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"testing"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/linuxdeepin/dde-daemon/network/nm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_portableConnection(t *testing.T) {
+	data := make(connectionData)
+	addSetting(data, nm.NM_SETTING_CONNECTION_SETTING_NAME)
+	setSettingConnectionId(data, "office")
+	setSettingConnectionUuid(data, "d3c7a4a2-5d0b-4f5e-9f73-2a1f6c0e8b11")
+	setSettingConnectionType(data, nm.NM_SETTING_WIRELESS_SETTING_NAME)
+	setSettingConnectionInterfaceName(data, "wlp2s0")
+	addSetting(data, nm.NM_SETTING_WIRELESS_SETTING_NAME)
+	setSettingWirelessSsid(data, []byte("office \"5G\""))
+	setSettingWirelessMacAddress(data, []byte{0xa4, 0x39, 0xb3, 0x0e, 0x1f, 0x20})
+	addSetting(data, nm.NM_SETTING_IP4_CONFIG_SETTING_NAME)
+	setSettingKey(data, nm.NM_SETTING_IP4_CONFIG_SETTING_NAME, "dns", []uint32{0x08080808})
+	setSettingKey(data, nm.NM_SETTING_IP4_CONFIG_SETTING_NAME, "addresses", [][]uint32{{1, 24, 2}})
+	setSettingKey(data, nm.NM_SETTING_IP4_CONFIG_SETTING_NAME, "address-data", []map[string]dbus.Variant{{
+		"address": dbus.MakeVariant("192.168.1.100"),
+		"prefix":  dbus.MakeVariant(uint32(24)),
+	}})
+
+	mergeSecrets(data, connectionData{
+		nm.NM_SETTING_WIRELESS_SECURITY_SETTING_NAME: {"psk": dbus.MakeVariant("secret")},
+		nm.NM_SETTING_WIRELESS_SETTING_NAME:          {"ssid": dbus.MakeVariant([]byte("ignored"))},
+	})
+	assert.False(t, isSettingExists(data, nm.NM_SETTING_WIRELESS_SECURITY_SETTING_NAME))
+	assert.Equal(t, []byte("ignored"), getSettingWirelessSsid(data))
+	setSettingWirelessSsid(data, []byte("office \"5G\""))
+
+	cleanPortableConnectionData(data)
+	assert.False(t, isSettingKeyExists(data, nm.NM_SETTING_CONNECTION_SETTING_NAME, "interface-name"))
+	assert.False(t, isSettingKeyExists(data, nm.NM_SETTING_WIRELESS_SETTING_NAME, "mac-address"))
+	assert.False(t, isSettingKeyExists(data, nm.NM_SETTING_IP4_CONFIG_SETTING_NAME, "addresses"))
+
+	pc := encodePortableConnection(data)
+	assert.Equal(t, "office", pc.Id)
+	assert.Equal(t, nm.NM_SETTING_WIRELESS_SETTING_NAME, pc.Type)
+
+	decoded, err := decodePortableConnection(pc)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("office \"5G\""), decoded[nm.NM_SETTING_WIRELESS_SETTING_NAME]["ssid"].Value())
+	assert.Equal(t, []uint32{0x08080808}, decoded[nm.NM_SETTING_IP4_CONFIG_SETTING_NAME]["dns"].Value())
+	assert.Equal(t, data[nm.NM_SETTING_IP4_CONFIG_SETTING_NAME]["address-data"].String(),
+		decoded[nm.NM_SETTING_IP4_CONFIG_SETTING_NAME]["address-data"].String())
+
+	setSettingConnectionType(data, "bond")
+	_, err = decodePortableConnection(encodePortableConnection(data))
+	assert.Error(t, err)
+
+	_, err = parsePortableConnections(`{"Version":"2.0","Connections":[]}`)
+	assert.Error(t, err)
+}
+
+func Test_parseOpenVPNConfig(t *testing.T) {
+	content := `# generated by server
+client
+dev tun
+proto udp
+remote vpn.example.com 1194
+remote vpn2.example.com 1195 tcp
+cipher AES-256-GCM
+auth SHA256
+remote-cert-tls server
+auth-user-pass
+key-direction 1
+<ca>
+-----BEGIN CERTIFICATE-----
+MIIB
+-----END CERTIFICATE-----
+</ca>
+cert client.crt
+key client.key
+<tls-auth>
+-----BEGIN OpenVPN Static key V1-----
+-----END OpenVPN Static key V1-----
+</tls-auth>
+`
+	assert.True(t, isOpenVPNConfig(content))
+	assert.False(t, isWireGuardConfig(content))
+
+	cfg, err := parseOpenVPNConfig(content)
+	require.NoError(t, err)
+	assert.Equal(t, "vpn.example.com:1194, vpn2.example.com:1195:tcp", cfg.data["remote"])
+	assert.Equal(t, "tun", cfg.data["dev-type"])
+	assert.Equal(t, "password-tls", cfg.data["connection-type"])
+	assert.Equal(t, "AES-256-GCM", cfg.data["cipher"])
+	assert.Equal(t, "SHA256", cfg.data["auth"])
+	assert.Equal(t, "1", cfg.data["ta-dir"])
+	assert.Equal(t, "client.crt", cfg.data["cert"])
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n", cfg.inline["ca"])
+	assert.Contains(t, cfg.inline, "tls-auth")
+	_, ok := cfg.data["proto-tcp"]
+	assert.False(t, ok)
+
+	_, err = parseOpenVPNConfig("client\n<ca>\nabc\n")
+	assert.Error(t, err)
+	_, err = parseOpenVPNConfig("remote vpn.example.com\n")
+	assert.Error(t, err)
+
+	data := newOpenVPNConnectionData("OpenVPN vpn.example.com", "3f0f8c1e-0000-4000-8000-000000000001", cfg.data)
+	assert.Equal(t, nm.NM_DBUS_SERVICE_OPENVPN, getSettingVpnServiceType(data))
+	assert.Equal(t, nm.NM_SETTING_VPN_SETTING_NAME, getSettingConnectionType(data))
+}
+
+func Test_parseWireGuardConfig(t *testing.T) {
+	content := `[Interface]
+PrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=
+Address = 10.0.0.2/24, fd00::2/64
+DNS = 1.1.1.1, example.com
+ListenPort = 51820
+
+[Peer]
+PublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=
+Endpoint = wg.example.com:51820
+AllowedIPs = 0.0.0.0/0, ::/0
+PersistentKeepalive = 25
+`
+	assert.True(t, isWireGuardConfig(content))
+	cfg, err := parseWireGuardConfig(content)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(51820), cfg.listenPort)
+	assert.Equal(t, []string{"10.0.0.2/24", "fd00::2/64"}, cfg.addresses)
+	assert.Equal(t, []string{"1.1.1.1"}, cfg.dns)
+	assert.Equal(t, []string{"example.com"}, cfg.dnsSearch)
+	require.Len(t, cfg.peers, 1)
+	assert.Equal(t, []string{"0.0.0.0/0", "::/0"}, cfg.peers[0].allowedIPs)
+	assert.Equal(t, uint32(25), cfg.peers[0].keepalive)
+	assert.Equal(t, "WireGuard wg.example.com", cfg.connectionId())
+
+	data, err := newWireGuardConnectionData(cfg.connectionId(), "3f0f8c1e-0000-4000-8000-000000000002", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "wg-3f0f8c1e", getSettingConnectionInterfaceName(data))
+	assert.Equal(t, nm.NM_SETTING_IP4_CONFIG_METHOD_MANUAL, getSettingIP4ConfigMethod(data))
+	assert.Equal(t, nm.NM_SETTING_IP6_CONFIG_METHOD_MANUAL, getSettingIP6ConfigMethod(data))
+	peers := data[nmSettingWireGuardSettingName]["peers"].Value().([]map[string]dbus.Variant)
+	require.Len(t, peers, 1)
+	assert.Equal(t, "wg.example.com:51820", peers[0]["endpoint"].Value())
+
+	_, err = parseWireGuardConfig("[Interface]\nPrivateKey = abc\n")
+	assert.Error(t, err)
+	_, err = parseWireGuardConfig("[Interface]\nPrivateKey = abc\nListenPort = x\n[Peer]\nPublicKey = def\n")
+	assert.Error(t, err)
+}
+
+func Test_uniqueConnectionId(t *testing.T) {
+	assert.Equal(t, "home", uniqueConnectionId("home", []string{"office"}))
+	assert.Equal(t, "home (2)", uniqueConnectionId("home", []string{"home"}))
+	assert.Equal(t, "home (3)", uniqueConnectionId("home", []string{"home", "home (2)"}))
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 连接的流量统计，按连接的 uuid 记录每天接收和发送的字节数，并支持设置每月的流量限额
+
+const (
+	dataUsageDateLayout = "2006-01-02"
+	// 每日流量最多保留的天数
+	dataUsageKeepDays = 400
+	// 每月的起始日最大为 28，避免出现没有这一天的月份
+	maxDataUsageResetDay = 28
+)
+
+var defaultDataUsageThresholds = []uint32{80, 100}
+
+var errInvalidResetDay = errors.New("invalid reset day")
+
+type dailyDataUsage struct {
+	RxBytes uint64
+	TxBytes uint64
+}
+
+type connectionDataUsage struct {
+	Days       map[string]*dailyDataUsage // 日期 => 当天的流量
+	Quota      uint64                     // 每月的流量限额，单位字节，为 0 时不限制
+	ResetDay   int                        // 每月从这一天开始重新计算流量
+	Thresholds []uint32                   // 流量达到限额的这些百分比时发出通知
+
+	// 本周期内已通知过的最高百分比，避免重复通知
+	NotifiedCycle     string `json:",omitempty"`
+	NotifiedThreshold uint32 `json:",omitempty"`
+}
+
+func newConnectionDataUsage() *connectionDataUsage {
+	return &connectionDataUsage{
+		Days:       make(map[string]*dailyDataUsage),
+		ResetDay:   1,
+		Thresholds: defaultDataUsageThresholds,
+	}
+}
+
+// add 把流量记到 t 这一天
+func (u *connectionDataUsage) add(t time.Time, rx, tx uint64) {
+	date := t.Format(dataUsageDateLayout)
+	day := u.Days[date]
+	if day == nil {
+		day = &dailyDataUsage{}
+		u.Days[date] = day
+	}
+	day.RxBytes += rx
+	day.TxBytes += tx
+}
+
+// prune 删除太久以前的记录
+func (u *connectionDataUsage) prune(t time.Time) {
+	oldest := t.AddDate(0, 0, -dataUsageKeepDays).Format(dataUsageDateLayout)
+	for date := range u.Days {
+		if date < oldest {
+			delete(u.Days, date)
+		}
+	}
+}
+
+func (u *connectionDataUsage) today(t time.Time) dailyDataUsage {
+	day := u.Days[t.Format(dataUsageDateLayout)]
+	if day == nil {
+		return dailyDataUsage{}
+	}
+	return *day
+}
+
+// cycleStart 返回 t 所在的统计周期的第一天
+func (u *connectionDataUsage) cycleStart(t time.Time) time.Time {
+	resetDay := u.ResetDay
+	if resetDay < 1 || resetDay > maxDataUsageResetDay {
+		resetDay = 1
+	}
+	start := time.Date(t.Year(), t.Month(), resetDay, 0, 0, 0, 0, t.Location())
+	if t.Day() < resetDay {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start
+}
+
+// cycleUsage 返回 t 所在的统计周期内的流量
+func (u *connectionDataUsage) cycleUsage(t time.Time) dailyDataUsage {
+	start := u.cycleStart(t).Format(dataUsageDateLayout)
+	end := u.cycleStart(t).AddDate(0, 1, 0).Format(dataUsageDateLayout)
+	var total dailyDataUsage
+	for date, day := range u.Days {
+		if date >= start && date < end {
+			total.RxBytes += day.RxBytes
+			total.TxBytes += day.TxBytes
+		}
+	}
+	return total
+}
+
+// checkThreshold 检查本周期的流量是否达到了新的百分比，返回达到的最高百分比，
+// 同一周期内每个百分比只返回一次
+func (u *connectionDataUsage) checkThreshold(t time.Time) (threshold uint32, ok bool) {
+	if u.Quota == 0 {
+		return 0, false
+	}
+	cycle := u.cycleStart(t).Format(dataUsageDateLayout)
+	if u.NotifiedCycle != cycle {
+		u.NotifiedCycle = cycle
+		u.NotifiedThreshold = 0
+	}
+
+	usage := u.cycleUsage(t)
+	used := usage.RxBytes + usage.TxBytes
+	for _, th := range u.Thresholds {
+		if th <= u.NotifiedThreshold || th <= threshold {
+			continue
+		}
+		// 避免溢出，不直接计算 used*100
+		if float64(used) >= float64(u.Quota)*float64(th)/100 {
+			threshold = th
+		}
+	}
+	if threshold == 0 {
+		return 0, false
+	}
+	u.NotifiedThreshold = threshold
+	return threshold, true
+}
+
+func checkDataUsageThresholds(thresholds []uint32) error {
+	for _, th := range thresholds {
+		if th == 0 || th > 100 {
+			return fmt.Errorf("invalid threshold %d", th)
+		}
+	}
+	return nil
+}
+
+// calcCounterDelta 计算两次读取之间网卡计数的增量，计数变小时说明网卡被重新创建，从 0 开始计算
+func calcCounterDelta(last, current uint64) uint64 {
+	if current < last {
+		return current
+	}
+	return current - last
+}
+
+func loadDataUsage(file string) (map[string]*connectionDataUsage, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var usages map[string]*connectionDataUsage
+	err = json.Unmarshal(content, &usages)
+	if err != nil {
+		return nil, err
+	}
+	for uuid, usage := range usages {
+		if usage == nil {
+			delete(usages, uuid)
+			continue
+		}
+		if usage.Days == nil {
+			usage.Days = make(map[string]*dailyDataUsage)
+		}
+	}
+	return usages, nil
+}
+
+func saveDataUsage(file string, usages map[string]*connectionDataUsage) error {
+	content, err := json.Marshal(usages)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, content, 0644)
+}
+
+const sysClassNetDir = "/sys/class/net"
+
+// readInterfaceCounters 读取网卡接收和发送的字节数
+func readInterfaceCounters(ifc string) (rx, tx uint64, err error) {
+	read := func(name string) (uint64, error) {
+		content, err := ioutil.ReadFile(filepath.Join(sysClassNetDir, ifc, "statistics", name))
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	}
+	rx, err = read("rx_bytes")
+	if err != nil {
+		return
+	}
+	tx, err = read("tx_bytes")
+	return
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_connectionDataUsage_cycle(t *testing.T) {
+	u := newConnectionDataUsage()
+	u.ResetDay = 15
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2022, month, d, 12, 0, 0, 0, time.Local)
+	}
+
+	assert.Equal(t, "2022-03-15", u.cycleStart(day(3, 20)).Format(dataUsageDateLayout))
+	assert.Equal(t, "2022-02-15", u.cycleStart(day(3, 14)).Format(dataUsageDateLayout))
+	assert.Equal(t, "2021-12-15", u.cycleStart(day(1, 1)).Format(dataUsageDateLayout))
+
+	u.add(day(2, 14), 1000, 1000) // 上个周期
+	u.add(day(2, 15), 100, 10)
+	u.add(day(3, 1), 200, 20)
+	u.add(day(3, 1), 300, 30)
+	u.add(day(3, 15), 5000, 5000) // 下个周期
+
+	assert.Equal(t, dailyDataUsage{RxBytes: 500, TxBytes: 50}, u.today(day(3, 1)))
+	assert.Equal(t, dailyDataUsage{RxBytes: 600, TxBytes: 60}, u.cycleUsage(day(3, 10)))
+	assert.Equal(t, dailyDataUsage{}, u.today(day(3, 2)))
+
+	u.prune(day(3, 1).AddDate(0, 0, dataUsageKeepDays))
+	assert.Len(t, u.Days, 2)
+}
+
+func Test_connectionDataUsage_checkThreshold(t *testing.T) {
+	now := time.Date(2022, 5, 10, 12, 0, 0, 0, time.Local)
+	u := newConnectionDataUsage()
+	u.add(now, 900, 0)
+	_, ok := u.checkThreshold(now)
+	assert.False(t, ok, "no quota")
+
+	u.Quota = 1000
+	u.Thresholds = []uint32{50, 80, 100}
+	th, ok := u.checkThreshold(now)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(80), th)
+	_, ok = u.checkThreshold(now)
+	assert.False(t, ok, "notified once")
+
+	u.add(now, 0, 100)
+	th, ok = u.checkThreshold(now)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(100), th)
+
+	// 进入新的周期后重新计算
+	next := now.AddDate(0, 1, 0)
+	_, ok = u.checkThreshold(next)
+	assert.False(t, ok)
+	u.add(next, 600, 0)
+	th, ok = u.checkThreshold(next)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(50), th)
+}
+
+func Test_calcCounterDelta(t *testing.T) {
+	assert.Equal(t, uint64(100), calcCounterDelta(200, 300))
+	assert.Equal(t, uint64(0), calcCounterDelta(300, 300))
+	assert.Equal(t, uint64(50), calcCounterDelta(300, 50))
+}
+
+func Test_checkDataUsageThresholds(t *testing.T) {
+	assert.NoError(t, checkDataUsageThresholds(nil))
+	assert.NoError(t, checkDataUsageThresholds([]uint32{50, 100}))
+	assert.Error(t, checkDataUsageThresholds([]uint32{0}))
+	assert.Error(t, checkDataUsageThresholds([]uint32{101}))
+}
+
+func Test_saveLoadDataUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "network-data-usage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "network-data-usage.json")
+	now := time.Date(2022, 5, 10, 12, 0, 0, 0, time.Local)
+	u := newConnectionDataUsage()
+	u.Quota = 1 << 30
+	u.add(now, 1, 2)
+	err = saveDataUsage(file, map[string]*connectionDataUsage{"uuid1": u})
+	require.NoError(t, err)
+
+	usages, err := loadDataUsage(file)
+	require.NoError(t, err)
+	require.Contains(t, usages, "uuid1")
+	assert.Equal(t, u.Quota, usages["uuid1"].Quota)
+	assert.Equal(t, u.today(now), usages["uuid1"].today(now))
+}
@@ -20,6 +20,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"uuid", "devPath"},
 			OutArgs: []string{"cpath"},
 		},
+		{
+			Name:    "AddLocation",
+			Fn:      v.AddLocation,
+			InArgs:  []string{"locationJSON"},
+			OutArgs: []string{"id"},
+		},
 		{
 			Name:   "DeactivateConnection",
 			Fn:     v.DeactivateConnection,
@@ -35,6 +41,17 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.DeleteConnection,
 			InArgs: []string{"uuid"},
 		},
+		{
+			Name:   "DeleteLocation",
+			Fn:     v.DeleteLocation,
+			InArgs: []string{"id"},
+		},
+		{
+			Name:    "Diagnose",
+			Fn:      v.Diagnose,
+			InArgs:  []string{"devPath"},
+			OutArgs: []string{"reportJSON"},
+		},
 		{
 			Name:   "DisableWirelessHotspotMode",
 			Fn:     v.DisableWirelessHotspotMode,
@@ -55,6 +72,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.EnableWirelessHotspotMode,
 			InArgs: []string{"devPath"},
 		},
+		{
+			Name:    "ExportConnections",
+			Fn:      v.ExportConnections,
+			InArgs:  []string{"uuids", "includeSecrets"},
+			OutArgs: []string{"data"},
+		},
 		{
 			Name:    "GetAccessPoints",
 			Fn:      v.GetAccessPoints,
@@ -71,6 +94,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.GetAutoProxy,
 			OutArgs: []string{"proxyAuto"},
 		},
+		{
+			Name:    "GetDataUsage",
+			Fn:      v.GetDataUsage,
+			InArgs:  []string{"uuid"},
+			OutArgs: []string{"usageJSON"},
+		},
 		{
 			Name:    "GetProxy",
 			Fn:      v.GetProxy,
@@ -92,6 +121,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.GetSupportedConnectionTypes,
 			OutArgs: []string{"types"},
 		},
+		{
+			Name:    "ImportConnections",
+			Fn:      v.ImportConnections,
+			InArgs:  []string{"data"},
+			OutArgs: []string{"uuids"},
+		},
 		{
 			Name:    "IsDeviceEnabled",
 			Fn:      v.IsDeviceEnabled,
@@ -110,6 +145,16 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"devPath"},
 			OutArgs: []string{"connections"},
 		},
+		{
+			Name:    "ListLocations",
+			Fn:      v.ListLocations,
+			OutArgs: []string{"locationsJSON"},
+		},
+		{
+			Name:   "ModifyLocation",
+			Fn:     v.ModifyLocation,
+			InArgs: []string{"id", "locationJSON"},
+		},
 		{
 			Name:   "RequestIPConflictCheck",
 			Fn:     v.RequestIPConflictCheck,
@@ -119,11 +164,26 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "RequestWirelessScan",
 			Fn:   v.RequestWirelessScan,
 		},
+		{
+			Name:   "ResetDataUsage",
+			Fn:     v.ResetDataUsage,
+			InArgs: []string{"uuid"},
+		},
 		{
 			Name:   "SetAutoProxy",
 			Fn:     v.SetAutoProxy,
 			InArgs: []string{"proxyAuto"},
 		},
+		{
+			Name:   "SetConnectionMetered",
+			Fn:     v.SetConnectionMetered,
+			InArgs: []string{"uuid", "metered"},
+		},
+		{
+			Name:   "SetDataQuota",
+			Fn:     v.SetDataQuota,
+			InArgs: []string{"uuid", "quota", "resetDay", "thresholds"},
+		},
 		{
 			Name:   "SetDeviceManaged",
 			Fn:     v.SetDeviceManaged,
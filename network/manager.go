@@ -127,6 +127,27 @@ type Manager struct {
 
 	connectionSettingsLock sync.Mutex
 
+	// update by manager_location.go
+	locationsLock      sync.Mutex
+	locations          map[string]*networkLocation
+	locationsFile      string
+	locationBackup     *locationBackup // configs changed by the entered location
+	locationBackupFile string
+	ActiveLocation     string // id of the network location currently entered
+	// coalesce location updates triggered while one is running
+	locationUpdateLock    sync.Mutex
+	locationUpdating      bool
+	locationUpdatePending bool
+	locationReapplyIds    []string
+
+	// update by manager_data_usage.go
+	dataUsageLock     sync.Mutex
+	dataUsage         map[string]*connectionDataUsage // connection uuid => data usage
+	dataUsageFile     string
+	dataUsageCounters map[string]*interfaceCounter // interface => counters of last sample
+	dataUsageDirty    bool
+	dataUsageQuit     chan struct{}
+
 	// dsg config
 	protalAuthEnable  bool
 	configManagerPath dbus.ObjectPath
@@ -272,6 +293,8 @@ func (m *Manager) init() {
 	m.initConnectionManage()
 	m.initDeviceManage()
 	m.initActiveConnectionManage()
+	m.initLocations()
+	m.initDataUsage()
 	m.initNMObjManager(systemBus)
 	m.stateHandler = newStateHandler(m.sysSigLoop, m)
 	m.initSysNetwork(systemBus)
@@ -403,6 +426,7 @@ func (m *Manager) destroy() {
 	m.multiVpn = nil
 	m.sessionSigLoop.Stop()
 	m.syncConfig.Destroy()
+	m.destroyDataUsage()
 	m.nmObjManager.RemoveHandler(proxy.RemoveAllHandlers)
 	m.sysNetwork.RemoveHandler(proxy.RemoveAllHandlers)
 	destroyDbusObjects()
@@ -499,6 +523,7 @@ func (m *Manager) initNMObjManager(systemBus *dbus.Conn) {
 			aConn := m.newActiveConnection(objectPath)
 			m.activeConnections[objectPath] = aConn
 			m.updatePropActiveConnections()
+			m.requestLocationUpdate("")
 		}
 	})
 	if err != nil {
@@ -513,6 +538,7 @@ func (m *Manager) initNMObjManager(systemBus *dbus.Conn) {
 			logger.Debug("remove active connection", objectPath)
 			delete(m.activeConnections, objectPath)
 			m.updatePropActiveConnections()
+			m.requestLocationUpdate("")
 		}
 	})
 	if err != nil {
@@ -227,7 +227,12 @@ func (m *Manager) initActiveConnectionManage() {
 			}
 
 			if stateChanged && state == nm.NM_ACTIVE_CONNECTION_STATE_ACTIVATED {
-				go m.checkConnectivity()
+				go func() {
+					m.checkConnectivity()
+					// 刚激活时网关可能还不在 ARP 表中，检查连通性之后再识别一次
+					m.requestLocationUpdate("")
+				}()
+				m.requestLocationUpdate("")
 			}
 		}
 	})
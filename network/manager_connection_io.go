@@ -0,0 +1,236 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/go-lib/utils"
+	"github.com/linuxdeepin/go-lib/xdg/basedir"
+)
+
+// 从 .ovpn 文件中提取出的证书和密钥保存在此目录
+var openVPNCertDir = filepath.Join(basedir.GetUserHomeDir(), ".cert", "nm-openvpn")
+
+// getExportConnectionData 获取连接数据，includeSecrets 为 true 时一并获取密码
+func getExportConnectionData(cpath dbus.ObjectPath, includeSecrets bool) (connectionData, error) {
+	nmConn, err := nmNewSettingsConnection(cpath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := nmConn.GetSettings(0)
+	if err != nil {
+		return nil, err
+	}
+	if !isPortableConnectionType(getSettingConnectionType(data)) {
+		return data, nil
+	}
+
+	if includeSecrets {
+		for _, setting := range secretSettingNames {
+			if !isSettingExists(data, setting) {
+				continue
+			}
+			secrets, err := nmConn.GetSecrets(0, setting)
+			if err != nil {
+				// 没有保存密码或密码由用户的 keyring 保存时会失败，忽略即可
+				logger.Debugf("failed to get secrets of %s %s: %v", cpath, setting, err)
+				continue
+			}
+			mergeSecrets(data, secrets)
+		}
+	}
+	cleanPortableConnectionData(data)
+	return data, nil
+}
+
+// ExportConnections 导出连接为 JSON 格式的文件内容，uuids 为空时导出所有支持的连接，
+// 支持有线、无线（包括 802.1x）、VPN 和 WireGuard 连接。includeSecrets 为 true 时包含密码，
+// 证书等文件只导出路径。
+func (m *Manager) ExportConnections(uuids []string, includeSecrets bool) (data string, busErr *dbus.Error) {
+	var cpaths []dbus.ObjectPath
+	if len(uuids) == 0 {
+		cpaths = nmGetConnectionList()
+	} else {
+		for _, uuid := range uuids {
+			cpath, err := nmGetConnectionByUuid(uuid)
+			if err != nil {
+				return "", dbusutil.ToError(fmt.Errorf("connection %s not found", uuid))
+			}
+			cpaths = append(cpaths, cpath)
+		}
+	}
+
+	pcs := portableConnections{
+		Version:     portableConnectionsVersion,
+		Connections: make([]*portableConnection, 0, len(cpaths)),
+	}
+	for _, cpath := range cpaths {
+		cdata, err := getExportConnectionData(cpath, includeSecrets)
+		if err != nil {
+			return "", dbusutil.ToError(err)
+		}
+		connType := getSettingConnectionType(cdata)
+		if !isPortableConnectionType(connType) {
+			if len(uuids) != 0 {
+				return "", dbusutil.ToError(fmt.Errorf("connection type %q can not be exported", connType))
+			}
+			continue
+		}
+		pcs.Connections = append(pcs.Connections, encodePortableConnection(cdata))
+	}
+
+	content, err := json.MarshalIndent(pcs, "", "  ")
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(content), nil
+}
+
+// saveOpenVPNInlineFiles 把 .ovpn 中内嵌的证书和密钥保存为文件，并把路径填入 vpn 配置
+func saveOpenVPNInlineFiles(uuid string, cfg *openVPNConfig) error {
+	if len(cfg.inline) == 0 {
+		return nil
+	}
+	err := os.MkdirAll(openVPNCertDir, 0700)
+	if err != nil {
+		return err
+	}
+	for name, content := range cfg.inline {
+		key, ok := openVPNInlineKeys[name]
+		if !ok {
+			continue
+		}
+		file := filepath.Join(openVPNCertDir, fmt.Sprintf("%s-%s.pem", uuid, name))
+		err = ioutil.WriteFile(file, []byte(content), 0600)
+		if err != nil {
+			return err
+		}
+		cfg.data[key] = file
+	}
+	return nil
+}
+
+// parseImportData 根据文件内容解析出要导入的连接
+func parseImportData(content string) ([]connectionData, error) {
+	trimmed := strings.TrimSpace(content)
+	switch {
+	case strings.HasPrefix(trimmed, "{"):
+		return parsePortableConnections(trimmed)
+
+	case isWireGuardConfig(content):
+		cfg, err := parseWireGuardConfig(content)
+		if err != nil {
+			return nil, err
+		}
+		data, err := newWireGuardConnectionData(cfg.connectionId(), utils.GenUuid(), cfg)
+		if err != nil {
+			return nil, err
+		}
+		return []connectionData{data}, nil
+
+	case isOpenVPNConfig(content):
+		cfg, err := parseOpenVPNConfig(content)
+		if err != nil {
+			return nil, err
+		}
+		uuid := utils.GenUuid()
+		err = saveOpenVPNInlineFiles(uuid, cfg)
+		if err != nil {
+			return nil, err
+		}
+		id := "OpenVPN " + strings.SplitN(cfg.data["remote"], ":", 2)[0]
+		return []connectionData{newOpenVPNConnectionData(id, uuid, cfg.data)}, nil
+	}
+	return nil, errUnknownImportFormat
+}
+
+// importConnection 导入一个连接，uuid 相同的连接会被更新，名称与其他连接重复时自动改名
+func importConnection(data connectionData, existIds []string) (id string, err error) {
+	uuid := getSettingConnectionUuid(data)
+	cpath, err := nmGetConnectionByUuid(uuid)
+	if err == nil {
+		nmConn, err := nmNewSettingsConnection(cpath)
+		if err != nil {
+			return "", err
+		}
+		oldData, err := nmConn.GetSettings(0)
+		if err != nil {
+			return "", err
+		}
+		// 不与被更新的连接自身比较
+		oldId := getSettingConnectionId(oldData)
+		var otherIds []string
+		for _, existId := range existIds {
+			if existId != oldId {
+				otherIds = append(otherIds, existId)
+			}
+		}
+		id = uniqueConnectionId(getSettingConnectionId(data), otherIds)
+		setSettingConnectionId(data, id)
+		logger.Debugf("import connection %s, update existing connection %s", uuid, cpath)
+		return id, nmConn.Update(0, data)
+	}
+
+	id = uniqueConnectionId(getSettingConnectionId(data), existIds)
+	setSettingConnectionId(data, id)
+	_, err = nmAddConnection(data)
+	return id, err
+}
+
+// ImportConnections 导入连接，data 可以是 ExportConnections 导出的内容、
+// OpenVPN 的 .ovpn 文件内容或 WireGuard 的 .conf 文件内容，返回导入的连接的 uuid。
+func (m *Manager) ImportConnections(data string) (uuids []string, busErr *dbus.Error) {
+	connections, err := parseImportData(data)
+	if err != nil {
+		return nil, dbusutil.ToError(err)
+	}
+	if len(connections) == 0 {
+		return nil, dbusutil.ToError(errors.New("no connection to import"))
+	}
+
+	var existIds []string
+	for _, cpath := range nmGetConnectionList() {
+		existIds = append(existIds, nmGetConnectionId(cpath))
+	}
+
+	var errs []string
+	for _, cdata := range connections {
+		uuid := getSettingConnectionUuid(cdata)
+		id, err := importConnection(cdata, existIds)
+		if err != nil {
+			logger.Warningf("failed to import connection %s: %v", uuid, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", uuid, err))
+			continue
+		}
+		existIds = append(existIds, id)
+		uuids = append(uuids, uuid)
+	}
+	if len(errs) > 0 {
+		return uuids, dbusutil.ToError(fmt.Errorf("failed to import connections: %s", strings.Join(errs, "; ")))
+	}
+	return uuids, nil
+}
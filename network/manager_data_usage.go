@@ -0,0 +1,322 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/linuxdeepin/dde-daemon/network/nm"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/go-lib/xdg/basedir"
+)
+
+const (
+	dataUsageSampleInterval = time.Minute
+	dataUsageSaveInterval   = 5 * time.Minute
+)
+
+// interfaceCounter 网卡上次读取到的计数
+type interfaceCounter struct {
+	uuid string
+	rx   uint64
+	tx   uint64
+}
+
+type dataUsageSource struct {
+	uuid string
+	id   string
+	ifc  string
+}
+
+type dataUsageInfo struct {
+	Uuid       string
+	Metered    bool
+	Today      dailyDataUsage
+	Cycle      dailyDataUsage // 本周期的流量
+	CycleStart string
+	Quota      uint64
+	ResetDay   int
+	Thresholds []uint32
+	Days       map[string]*dailyDataUsage
+}
+
+func (m *Manager) initDataUsage() {
+	m.dataUsageFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/network-data-usage.json")
+	usages, err := loadDataUsage(m.dataUsageFile)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning("failed to load data usage:", err)
+	}
+	if usages == nil {
+		usages = make(map[string]*connectionDataUsage)
+	}
+
+	m.dataUsageLock.Lock()
+	m.dataUsage = usages
+	m.dataUsageCounters = make(map[string]*interfaceCounter)
+	m.dataUsageQuit = make(chan struct{})
+	m.dataUsageLock.Unlock()
+
+	go m.dataUsageLoop(m.dataUsageQuit)
+}
+
+func (m *Manager) destroyDataUsage() {
+	if m.dataUsageQuit == nil {
+		return
+	}
+	close(m.dataUsageQuit)
+	m.dataUsageQuit = nil
+	m.sampleDataUsage()
+	m.saveDataUsage(false)
+}
+
+func (m *Manager) dataUsageLoop(quit chan struct{}) {
+	sampleTicker := time.NewTicker(dataUsageSampleInterval)
+	defer sampleTicker.Stop()
+	saveTicker := time.NewTicker(dataUsageSaveInterval)
+	defer saveTicker.Stop()
+
+	m.sampleDataUsage()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-sampleTicker.C:
+			m.sampleDataUsage()
+		case <-saveTicker.C:
+			m.saveDataUsage(true)
+		}
+	}
+}
+
+// getDataUsageSources 获取已激活的连接及其网卡，VPN 的流量已经计入了底层的连接，不单独统计
+func (m *Manager) getDataUsageSources() []dataUsageSource {
+	m.activeConnectionsLock.Lock()
+	var sources []dataUsageSource
+	var devPaths []dbus.ObjectPath
+	for _, aConn := range m.activeConnections {
+		if aConn.Vpn || aConn.State != nm.NM_ACTIVE_CONNECTION_STATE_ACTIVATED || len(aConn.Devices) == 0 {
+			continue
+		}
+		sources = append(sources, dataUsageSource{uuid: aConn.Uuid, id: aConn.Id})
+		devPaths = append(devPaths, aConn.Devices[0])
+	}
+	m.activeConnectionsLock.Unlock()
+
+	for i, devPath := range devPaths {
+		sources[i].ifc = nmGetDeviceIpInterface(devPath)
+	}
+	return sources
+}
+
+// sampleDataUsage 读取网卡计数，把与上次读取的差值记到对应的连接上
+func (m *Manager) sampleDataUsage() {
+	sources := m.getDataUsageSources()
+	now := time.Now()
+
+	m.dataUsageLock.Lock()
+	defer m.dataUsageLock.Unlock()
+	counters := make(map[string]*interfaceCounter, len(sources))
+	for _, src := range sources {
+		if src.ifc == "" || src.uuid == "" {
+			continue
+		}
+		rx, tx, err := readInterfaceCounters(src.ifc)
+		if err != nil {
+			logger.Debugf("failed to read counters of %s: %v", src.ifc, err)
+			continue
+		}
+		counters[src.ifc] = &interfaceCounter{uuid: src.uuid, rx: rx, tx: tx}
+
+		last := m.dataUsageCounters[src.ifc]
+		if last == nil || last.uuid != src.uuid {
+			// 第一次读取时网卡的计数可能包含其他连接的流量，只作为基准
+			continue
+		}
+		rxDelta := calcCounterDelta(last.rx, rx)
+		txDelta := calcCounterDelta(last.tx, tx)
+		if rxDelta == 0 && txDelta == 0 {
+			continue
+		}
+
+		usage := m.dataUsage[src.uuid]
+		if usage == nil {
+			usage = newConnectionDataUsage()
+			m.dataUsage[src.uuid] = usage
+		}
+		usage.add(now, rxDelta, txDelta)
+		usage.prune(now)
+		m.dataUsageDirty = true
+
+		threshold, ok := usage.checkThreshold(now)
+		if ok {
+			logger.Infof("data usage of connection %s reached %d%% of quota", src.uuid, threshold)
+			notifyDataUsageThreshold(src.id, threshold)
+		}
+	}
+	m.dataUsageCounters = counters
+}
+
+// saveDataUsage onlyDirty 为 true 时只在有变化时保存
+func (m *Manager) saveDataUsage(onlyDirty bool) {
+	m.dataUsageLock.Lock()
+	defer m.dataUsageLock.Unlock()
+	if onlyDirty && !m.dataUsageDirty {
+		return
+	}
+	err := saveDataUsage(m.dataUsageFile, m.dataUsage)
+	if err != nil {
+		logger.Warning("failed to save data usage:", err)
+		return
+	}
+	m.dataUsageDirty = false
+}
+
+func getConnectionSettingsByUuid(uuid string) (connectionData, error) {
+	cpath, err := nmGetConnectionByUuid(uuid)
+	if err != nil {
+		return nil, fmt.Errorf("connection %s not found", uuid)
+	}
+	return nmGetConnectionData(cpath)
+}
+
+// GetDataUsage 返回 JSON 格式的连接的流量统计，包括今天和本周期的流量、限额和每天的流量
+func (m *Manager) GetDataUsage(uuid string) (usageJSON string, busErr *dbus.Error) {
+	cdata, err := getConnectionSettingsByUuid(uuid)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+
+	now := time.Now()
+	m.dataUsageLock.Lock()
+	usage := m.dataUsage[uuid]
+	if usage == nil {
+		usage = newConnectionDataUsage()
+	}
+	info := dataUsageInfo{
+		Uuid:       uuid,
+		Metered:    getSettingConnectionMetered(cdata) == nm.NM_METERED_YES,
+		Today:      usage.today(now),
+		Cycle:      usage.cycleUsage(now),
+		CycleStart: usage.cycleStart(now).Format(dataUsageDateLayout),
+		Quota:      usage.Quota,
+		ResetDay:   usage.ResetDay,
+		Thresholds: usage.Thresholds,
+		Days:       usage.Days,
+	}
+	data, err := json.Marshal(info)
+	m.dataUsageLock.Unlock()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetDataQuota 设置连接每月的流量限额，quota 单位为字节，为 0 时不限制；
+// resetDay 为每月开始重新计算的日期（1~28）；thresholds 为发出通知的百分比，为空时使用默认值。
+func (m *Manager) SetDataQuota(uuid string, quota uint64, resetDay uint32, thresholds []uint32) *dbus.Error {
+	if resetDay < 1 || resetDay > maxDataUsageResetDay {
+		return dbusutil.ToError(errInvalidResetDay)
+	}
+	err := checkDataUsageThresholds(thresholds)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	if len(thresholds) == 0 {
+		thresholds = defaultDataUsageThresholds
+	} else {
+		thresholds = append([]uint32(nil), thresholds...)
+		sort.Slice(thresholds, func(i, j int) bool {
+			return thresholds[i] < thresholds[j]
+		})
+	}
+
+	m.dataUsageLock.Lock()
+	usage := m.dataUsage[uuid]
+	if usage == nil {
+		usage = newConnectionDataUsage()
+		m.dataUsage[uuid] = usage
+	}
+	usage.Quota = quota
+	usage.ResetDay = int(resetDay)
+	usage.Thresholds = thresholds
+	// 限额改变后重新判断是否需要通知
+	usage.NotifiedCycle = ""
+	usage.NotifiedThreshold = 0
+	m.dataUsageDirty = true
+	m.dataUsageLock.Unlock()
+
+	m.saveDataUsage(false)
+	return nil
+}
+
+// ResetDataUsage 清除连接的流量记录
+func (m *Manager) ResetDataUsage(uuid string) *dbus.Error {
+	m.dataUsageLock.Lock()
+	usage := m.dataUsage[uuid]
+	if usage == nil {
+		m.dataUsageLock.Unlock()
+		return nil
+	}
+	usage.Days = make(map[string]*dailyDataUsage)
+	usage.NotifiedCycle = ""
+	usage.NotifiedThreshold = 0
+	m.dataUsageDirty = true
+	m.dataUsageLock.Unlock()
+
+	m.saveDataUsage(false)
+	return nil
+}
+
+// SetConnectionMetered 设置连接是否为按流量计费，NetworkManager 会据此让应用减少后台流量
+func (m *Manager) SetConnectionMetered(uuid string, metered bool) *dbus.Error {
+	cpath, err := nmGetConnectionByUuid(uuid)
+	if err != nil {
+		return dbusutil.ToError(fmt.Errorf("connection %s not found", uuid))
+	}
+	nmConn, err := nmNewSettingsConnection(cpath)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	m.connectionSettingsLock.Lock()
+	defer m.connectionSettingsLock.Unlock()
+	cdata, err := nmConn.GetSettings(0)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	// fix ipv6 addresses and routes data structure, interface{}
+	if isSettingIP6ConfigAddressesExists(cdata) {
+		setSettingIP6ConfigAddresses(cdata, getSettingIP6ConfigAddresses(cdata))
+	}
+	if isSettingIP6ConfigRoutesExists(cdata) {
+		setSettingIP6ConfigRoutes(cdata, getSettingIP6ConfigRoutes(cdata))
+	}
+	if metered {
+		setSettingConnectionMetered(cdata, nm.NM_METERED_YES)
+	} else {
+		setSettingConnectionMetered(cdata, nm.NM_METERED_NO)
+	}
+	err = nmConn.Update(0, cdata)
+	return dbusutil.ToError(err)
+}
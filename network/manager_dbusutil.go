@@ -107,3 +107,16 @@ func (v *Manager) setPropWirelessAccessPoints(value string) (changed bool) {
 func (v *Manager) emitPropChangedWirelessAccessPoints(value string) error {
 	return v.service.EmitPropertyChanged(v, "WirelessAccessPoints", value)
 }
+
+func (v *Manager) setPropActiveLocation(value string) (changed bool) {
+	if v.ActiveLocation != value {
+		v.ActiveLocation = value
+		v.emitPropChangedActiveLocation(value)
+		return true
+	}
+	return false
+}
+
+func (v *Manager) emitPropChangedActiveLocation(value string) error {
+	return v.service.EmitPropertyChanged(v, "ActiveLocation", value)
+}
@@ -0,0 +1,280 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/linuxdeepin/dde-daemon/network/nm"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+)
+
+// 网络诊断，按顺序检查链路、DHCP、网关、DNS、认证网页和 IP 冲突
+
+const (
+	diagnoseStageLink       = "link"
+	diagnoseStageDhcp       = "dhcp"
+	diagnoseStageGateway    = "gateway"
+	diagnoseStageDns        = "dns"
+	diagnoseStagePortal     = "portal"
+	diagnoseStageIPConflict = "ipConflict"
+
+	diagnoseStatusOk      = "ok"
+	diagnoseStatusWarning = "warning"
+	diagnoseStatusFailed  = "failed"
+	diagnoseStatusSkipped = "skipped"
+
+	defaultDiagnoseHost = "www.deepin.org"
+	diagnoseDnsTimeout  = 5 * time.Second
+	diagnoseIPCheckWait = 2 * time.Second
+)
+
+type diagnoseStage struct {
+	Name      string
+	Status    string
+	Message   string
+	ElapsedMs int64
+}
+
+type diagnoseReport struct {
+	Device    dbus.ObjectPath
+	Interface string
+	Host      string // DNS 检查解析的域名
+	Stages    []*diagnoseStage
+	// 第一个失败的检查，为空表示没有发现问题
+	FailedStage string
+}
+
+// diagnoseStep 一项检查，blocking 为 true 时失败后跳过之后的检查
+type diagnoseStep struct {
+	name     string
+	blocking bool
+	fn       func() (status, message string)
+}
+
+func runDiagnoseSteps(steps []diagnoseStep) (stages []*diagnoseStage, failedStage string) {
+	var skipReason string
+	for _, step := range steps {
+		stage := &diagnoseStage{Name: step.name}
+		stages = append(stages, stage)
+		if skipReason != "" {
+			stage.Status = diagnoseStatusSkipped
+			stage.Message = skipReason
+			continue
+		}
+
+		start := time.Now()
+		stage.Status, stage.Message = step.fn()
+		stage.ElapsedMs = int64(time.Since(start) / time.Millisecond)
+		if stage.Status != diagnoseStatusFailed {
+			continue
+		}
+		if failedStage == "" {
+			failedStage = step.name
+		}
+		if step.blocking {
+			skipReason = fmt.Sprintf("%s check failed", step.name)
+		}
+	}
+	return
+}
+
+// checkIPv4Address 检查 DHCP 获取到的地址，链路本地地址说明没有拿到租约
+func checkIPv4Address(address string) (status, message string) {
+	ip := net.ParseIP(address)
+	if ip == nil || ip.IsUnspecified() {
+		return diagnoseStatusFailed, "no IPv4 address"
+	}
+	if ip.IsLinkLocalUnicast() {
+		return diagnoseStatusFailed, fmt.Sprintf("link-local address %s, no DHCP lease", address)
+	}
+	return diagnoseStatusOk, address
+}
+
+func (m *Manager) getDiagnoseHost() string {
+	systemConn, err := dbus.SystemBus()
+	if err != nil {
+		return defaultDiagnoseHost
+	}
+	systemConnObj := systemConn.Object("org.desktopspec.ConfigManager", m.configManagerPath)
+	var value string
+	err = systemConnObj.Call("org.desktopspec.ConfigManager.Manager.value", 0, "diagnoseHost").Store(&value)
+	if err != nil {
+		logger.Warning(err)
+		return defaultDiagnoseHost
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return defaultDiagnoseHost
+	}
+	return value
+}
+
+// diagnoseContext 各项检查之间传递的信息
+type diagnoseContext struct {
+	devPath dbus.ObjectPath
+	ifc     string
+	host    string
+	ip4     ipv4Info
+}
+
+func (m *Manager) diagnoseLink(dc *diagnoseContext) (status, message string) {
+	if !nmGetNetworkEnabled() {
+		return diagnoseStatusFailed, "networking is disabled"
+	}
+	if isWiredDevice(dc.devPath) && !nmGetWiredCarrier(dc.devPath) {
+		return diagnoseStatusFailed, deviceErrorTable[CUSTOM_NM_DEVICE_STATE_REASON_CABLE_UNPLUGGED]
+	}
+
+	state := nmGetDeviceState(dc.devPath)
+	if isDeviceStateActivated(state) {
+		return diagnoseStatusOk, "device activated"
+	}
+	message = fmt.Sprintf("device state %d", state)
+	if m.stateHandler != nil {
+		if reason, ok := m.stateHandler.getLastReason(dc.devPath); ok {
+			message += ": " + deviceErrorTable[reason]
+		}
+	}
+	return diagnoseStatusFailed, message
+}
+
+func (m *Manager) diagnoseDhcp(dc *diagnoseContext) (status, message string) {
+	apath := nmGetDeviceActiveConnection(dc.devPath)
+	nmAConn, err := nmNewActiveConnection(apath)
+	if err != nil {
+		return diagnoseStatusFailed, "no active connection"
+	}
+	ip4Path, _ := nmAConn.Ip4Config().Get(0)
+	if !isNmObjectPathValid(ip4Path) {
+		return diagnoseStatusFailed, "no IPv4 configuration"
+	}
+	dc.ip4 = nmGetIp4ConfigInfo(ip4Path)
+	if len(dc.ip4.Addresses) == 0 {
+		return diagnoseStatusFailed, "no IPv4 address"
+	}
+	status, message = checkIPv4Address(dc.ip4.Addresses[0].Address)
+	if status != diagnoseStatusOk {
+		return
+	}
+
+	if data, err := nmGetDeviceActiveConnectionData(dc.devPath); err == nil {
+		method := getSettingIP4ConfigMethod(data)
+		if method != nm.NM_SETTING_IP4_CONFIG_METHOD_AUTO {
+			message = fmt.Sprintf("%s (method %s)", message, method)
+		}
+	}
+	return
+}
+
+func (m *Manager) diagnoseGateway(dc *diagnoseContext) (status, message string) {
+	if dc.ip4.Gateway == "" {
+		return diagnoseStatusFailed, "no default gateway"
+	}
+	err := m.sysNetwork.Ping(0, dc.ip4.Gateway)
+	if err != nil {
+		return diagnoseStatusFailed, fmt.Sprintf("gateway %s unreachable: %v", dc.ip4.Gateway, err)
+	}
+	return diagnoseStatusOk, dc.ip4.Gateway
+}
+
+func (m *Manager) diagnoseDns(dc *diagnoseContext) (status, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnoseDnsTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, dc.host)
+	if err != nil {
+		return diagnoseStatusFailed, fmt.Sprintf("failed to resolve %s with nameservers %v: %v",
+			dc.host, dc.ip4.Nameservers, err)
+	}
+	return diagnoseStatusOk, fmt.Sprintf("%s => %s", dc.host, strings.Join(addrs, ", "))
+}
+
+func (m *Manager) diagnosePortal(dc *diagnoseContext) (status, message string) {
+	connectivity, err := nmManager.CheckConnectivity(0)
+	if err != nil {
+		return diagnoseStatusWarning, err.Error()
+	}
+	switch connectivity {
+	case nm.NM_CONNECTIVITY_FULL:
+		return diagnoseStatusOk, "full connectivity"
+	case nm.NM_CONNECTIVITY_PORTAL:
+		return diagnoseStatusFailed, "captive portal detected, web authentication required"
+	case nm.NM_CONNECTIVITY_LIMITED:
+		return diagnoseStatusFailed, "limited connectivity, internet is not reachable"
+	case nm.NM_CONNECTIVITY_NONE:
+		return diagnoseStatusFailed, "no connectivity"
+	default:
+		return diagnoseStatusWarning, "connectivity unknown, checking may be disabled"
+	}
+}
+
+func (m *Manager) diagnoseIPConflict(dc *diagnoseContext) (status, message string) {
+	ip := dc.ip4.Addresses[0].Address
+	ch := make(chan *dbus.Call, 1)
+	m.sysIPWatchD.GoRequestIPConflictCheck(0, ch, ip, dc.ifc)
+	select {
+	case ret := <-ch:
+		var mac string
+		err := ret.Store(&mac)
+		if err != nil {
+			return diagnoseStatusWarning, err.Error()
+		}
+		if mac != "" {
+			return diagnoseStatusFailed, fmt.Sprintf("%s is also used by %s", ip, mac)
+		}
+		return diagnoseStatusOk, ip
+	case <-time.After(diagnoseIPCheckWait):
+		return diagnoseStatusWarning, "ip conflict check timed out"
+	}
+}
+
+// Diagnose 诊断设备的网络连接，返回 JSON 格式的诊断报告
+func (m *Manager) Diagnose(devPath dbus.ObjectPath) (reportJSON string, busErr *dbus.Error) {
+	if _, err := nmNewDevice(devPath); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	dc := &diagnoseContext{
+		devPath: devPath,
+		ifc:     nmGetDeviceInterface(devPath),
+		host:    m.getDiagnoseHost(),
+	}
+	logger.Debugf("diagnose device %s(%s), host %s", devPath, dc.ifc, dc.host)
+
+	steps := []diagnoseStep{
+		{diagnoseStageLink, true, func() (string, string) { return m.diagnoseLink(dc) }},
+		{diagnoseStageDhcp, true, func() (string, string) { return m.diagnoseDhcp(dc) }},
+		{diagnoseStageGateway, false, func() (string, string) { return m.diagnoseGateway(dc) }},
+		{diagnoseStageDns, false, func() (string, string) { return m.diagnoseDns(dc) }},
+		{diagnoseStagePortal, false, func() (string, string) { return m.diagnosePortal(dc) }},
+		{diagnoseStageIPConflict, false, func() (string, string) { return m.diagnoseIPConflict(dc) }},
+	}
+	report := &diagnoseReport{
+		Device:    devPath,
+		Interface: dc.ifc,
+		Host:      dc.host,
+	}
+	report.Stages, report.FailedStage = runDiagnoseSteps(steps)
+
+	reportJSON, err := marshalJSON(report)
+	return reportJSON, dbusutil.ToError(err)
+}
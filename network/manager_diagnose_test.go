@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_runDiagnoseSteps(t *testing.T) {
+	var called []string
+	step := func(name string, blocking bool, status string) diagnoseStep {
+		return diagnoseStep{name, blocking, func() (string, string) {
+			called = append(called, name)
+			return status, name + " " + status
+		}}
+	}
+
+	stages, failed := runDiagnoseSteps([]diagnoseStep{
+		step(diagnoseStageLink, true, diagnoseStatusOk),
+		step(diagnoseStageDhcp, true, diagnoseStatusOk),
+		step(diagnoseStageGateway, false, diagnoseStatusFailed),
+		step(diagnoseStageDns, false, diagnoseStatusOk),
+		step(diagnoseStagePortal, false, diagnoseStatusFailed),
+	})
+	assert.Equal(t, diagnoseStageGateway, failed)
+	require.Len(t, stages, 5)
+	assert.Equal(t, []string{diagnoseStageLink, diagnoseStageDhcp, diagnoseStageGateway,
+		diagnoseStageDns, diagnoseStagePortal}, called)
+	assert.Equal(t, diagnoseStatusFailed, stages[2].Status)
+	assert.Equal(t, "gateway failed", stages[2].Message)
+
+	// 阻塞的检查失败后跳过之后的检查
+	called = nil
+	stages, failed = runDiagnoseSteps([]diagnoseStep{
+		step(diagnoseStageLink, true, diagnoseStatusFailed),
+		step(diagnoseStageDhcp, true, diagnoseStatusOk),
+		step(diagnoseStageDns, false, diagnoseStatusOk),
+	})
+	assert.Equal(t, diagnoseStageLink, failed)
+	assert.Equal(t, []string{diagnoseStageLink}, called)
+	require.Len(t, stages, 3)
+	assert.Equal(t, diagnoseStatusSkipped, stages[1].Status)
+	assert.Equal(t, diagnoseStatusSkipped, stages[2].Status)
+
+	_, failed = runDiagnoseSteps([]diagnoseStep{
+		step(diagnoseStageLink, true, diagnoseStatusOk),
+		step(diagnoseStagePortal, false, diagnoseStatusWarning),
+	})
+	assert.Equal(t, "", failed)
+}
+
+func Test_checkIPv4Address(t *testing.T) {
+	status, _ := checkIPv4Address("192.168.1.100")
+	assert.Equal(t, diagnoseStatusOk, status)
+	status, _ = checkIPv4Address("169.254.10.2")
+	assert.Equal(t, diagnoseStatusFailed, status)
+	status, _ = checkIPv4Address("0.0.0.0")
+	assert.Equal(t, diagnoseStatusFailed, status)
+	status, _ = checkIPv4Address("")
+	assert.Equal(t, diagnoseStatusFailed, status)
+}
@@ -0,0 +1,666 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/linuxdeepin/dde-daemon/network/nm"
+	"github.com/linuxdeepin/dde-daemon/network/proxychains"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/go-lib/utils"
+	"github.com/linuxdeepin/go-lib/xdg/basedir"
+)
+
+// 网络位置，比如“公司”、“家”，进入某个位置时自动应用其中的代理配置并连接 VPN。
+// 进入位置前备份被它修改的系统代理和应用代理，离开位置后恢复，VPN 不会断开。
+
+const procNetArpFile = "/proc/net/arp"
+
+const (
+	// 等待网关出现在 ARP 表中的次数和间隔
+	gatewayMacRetries  = 5
+	gatewayMacInterval = 200 * time.Millisecond
+)
+
+var errLocationNotFound = errors.New("location not found")
+
+// locationMatch 位置的识别条件，不为空的条件都满足时才认为处于此位置
+type locationMatch struct {
+	ConnectionUuid string `json:",omitempty"`
+	Ssid           string `json:",omitempty"`
+	GatewayMac     string `json:",omitempty"`
+}
+
+func (lm *locationMatch) isEmpty() bool {
+	return lm.ConnectionUuid == "" && lm.Ssid == "" && lm.GatewayMac == ""
+}
+
+// count 返回条件的数量，条件越多越精确
+func (lm *locationMatch) count() (n int) {
+	for _, v := range []string{lm.ConnectionUuid, lm.Ssid, lm.GatewayMac} {
+		if v != "" {
+			n++
+		}
+	}
+	return
+}
+
+type locationProxyServer struct {
+	Host string
+	Port string
+}
+
+// locationProxy 位置的系统代理配置，与 SetProxyMethod、SetProxy 等接口的参数一致
+type locationProxy struct {
+	Method      string
+	AutoUrl     string                         `json:",omitempty"`
+	IgnoreHosts string                         `json:",omitempty"`
+	Servers     map[string]locationProxyServer `json:",omitempty"` // proxyType => server
+}
+
+type networkLocation struct {
+	Id          string
+	Name        string
+	Match       locationMatch
+	Proxy       *locationProxy      `json:",omitempty"` // 为空时不修改系统代理
+	ProxyChains *proxychains.Config `json:",omitempty"` // 为空时不修改应用代理
+	VpnUuid     string              `json:",omitempty"` // 进入此位置时自动连接的 VPN，离开时不断开
+}
+
+// locationBackup 进入位置前的系统代理和应用代理，为空表示没有被位置修改
+type locationBackup struct {
+	Proxy       *locationProxy      `json:",omitempty"`
+	ProxyChains *proxychains.Config `json:",omitempty"`
+}
+
+func (b *locationBackup) isEmpty() bool {
+	return b.Proxy == nil && b.ProxyChains == nil
+}
+
+func (l *networkLocation) check() error {
+	if strings.TrimSpace(l.Name) == "" {
+		return errors.New("location name is empty")
+	}
+	if l.Match.isEmpty() {
+		return errors.New("location match is empty")
+	}
+	if l.Proxy != nil {
+		err := checkProxyMethod(l.Proxy.Method)
+		if err != nil {
+			return err
+		}
+		for proxyType := range l.Proxy.Servers {
+			switch proxyType {
+			case proxyTypeHttp, proxyTypeHttps, proxyTypeFtp, proxyTypeSocks:
+			default:
+				return fmt.Errorf("not a valid proxy type: %s", proxyType)
+			}
+		}
+	}
+	return nil
+}
+
+// locationFacts 当前一个活动连接的信息，用来识别位置
+type locationFacts struct {
+	ConnectionUuid string
+	Ssid           string
+	GatewayMac     string
+}
+
+func normalizeMac(mac string) string {
+	return strings.ToUpper(strings.TrimSpace(mac))
+}
+
+func (lm *locationMatch) matchFacts(facts *locationFacts) bool {
+	if lm.isEmpty() {
+		return false
+	}
+	if lm.ConnectionUuid != "" && lm.ConnectionUuid != facts.ConnectionUuid {
+		return false
+	}
+	if lm.Ssid != "" && lm.Ssid != facts.Ssid {
+		return false
+	}
+	if lm.GatewayMac != "" && normalizeMac(lm.GatewayMac) != normalizeMac(facts.GatewayMac) {
+		return false
+	}
+	return true
+}
+
+// matchLocation 返回与当前活动连接匹配的位置，有多个时条件多的优先，条件一样多时按名称排序
+func matchLocation(locations []*networkLocation, factsList []*locationFacts) *networkLocation {
+	var result *networkLocation
+	for _, l := range locations {
+		matched := false
+		for _, facts := range factsList {
+			if l.Match.matchFacts(facts) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if result == nil || l.Match.count() > result.Match.count() ||
+			(l.Match.count() == result.Match.count() && l.Name < result.Name) {
+			result = l
+		}
+	}
+	return result
+}
+
+// getArpHwAddr 从 /proc/net/arp 的内容中查找 IP 对应的 MAC 地址
+func getArpHwAddr(content []byte, ip, ifc string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	// 跳过表头
+	scanner.Scan()
+	for scanner.Scan() {
+		// IP address  HW type  Flags  HW address  Mask  Device
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		if fields[0] != ip || (ifc != "" && fields[5] != ifc) {
+			continue
+		}
+		if fields[3] == "00:00:00:00:00:00" {
+			continue
+		}
+		return normalizeMac(fields[3])
+	}
+	return ""
+}
+
+func readGatewayMac(gateway, ifc string) string {
+	content, err := ioutil.ReadFile(procNetArpFile)
+	if err != nil {
+		logger.Warning(err)
+		return ""
+	}
+	return getArpHwAddr(content, gateway, ifc)
+}
+
+// probeGateway 向网关发送一个 UDP 包，让内核解析网关的 MAC 地址
+func probeGateway(gateway string) {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(gateway, "9"), time.Second)
+	if err != nil {
+		logger.Debug("failed to probe gateway:", err)
+		return
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte{0})
+	if err != nil {
+		logger.Debug("failed to probe gateway:", err)
+	}
+}
+
+// getGatewayMac 获取网关的 MAC 地址，连接刚激活时 ARP 表中可能还没有网关，先探测网关再等待
+func getGatewayMac(gateway, ifc string) string {
+	if gateway == "" {
+		return ""
+	}
+	mac := readGatewayMac(gateway, ifc)
+	if mac != "" {
+		return mac
+	}
+	probeGateway(gateway)
+	for i := 0; i < gatewayMacRetries; i++ {
+		time.Sleep(gatewayMacInterval)
+		mac = readGatewayMac(gateway, ifc)
+		if mac != "" {
+			return mac
+		}
+	}
+	logger.Debugf("gateway %s of %s not found in arp table", gateway, ifc)
+	return ""
+}
+
+func (m *Manager) initLocations() {
+	m.locationsFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/network-locations.json")
+	m.locationBackupFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/network-location-backup.json")
+	m.locations = make(map[string]*networkLocation)
+	m.locationBackup = loadLocationBackup(m.locationBackupFile)
+
+	data, err := ioutil.ReadFile(m.locationsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning("failed to load network locations:", err)
+		}
+		return
+	}
+	var locations []*networkLocation
+	err = json.Unmarshal(data, &locations)
+	if err != nil {
+		logger.Warning("failed to load network locations:", err)
+		return
+	}
+	for _, l := range locations {
+		if l == nil || l.Id == "" {
+			continue
+		}
+		m.locations[l.Id] = l
+	}
+}
+
+// listLocations 需要持有 m.locationsLock
+func (m *Manager) listLocations() []*networkLocation {
+	locations := make([]*networkLocation, 0, len(m.locations))
+	for _, l := range m.locations {
+		locations = append(locations, l)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].Name < locations[j].Name
+	})
+	return locations
+}
+
+// saveLocations 需要持有 m.locationsLock
+func (m *Manager) saveLocations() error {
+	data, err := json.MarshalIndent(m.listLocations(), "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(m.locationsFile), 0755)
+	if err != nil {
+		return err
+	}
+	// 可能包含代理的密码
+	return ioutil.WriteFile(m.locationsFile, data, 0600)
+}
+
+// loadLocationBackup 加载上次进入位置前备份的配置，本程序重启后离开位置时仍然可以恢复
+func loadLocationBackup(file string) *locationBackup {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning("failed to load network location backup:", err)
+		}
+		return nil
+	}
+	var backup locationBackup
+	err = json.Unmarshal(data, &backup)
+	if err != nil {
+		logger.Warning("failed to load network location backup:", err)
+		return nil
+	}
+	if backup.isEmpty() {
+		return nil
+	}
+	return &backup
+}
+
+// setLocationBackup 需要持有 m.locationsLock
+func (m *Manager) setLocationBackup(backup *locationBackup) {
+	if backup.isEmpty() {
+		m.locationBackup = nil
+		err := os.Remove(m.locationBackupFile)
+		if err != nil && !os.IsNotExist(err) {
+			logger.Warning(err)
+		}
+		return
+	}
+
+	backupCopy := *backup
+	m.locationBackup = &backupCopy
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(m.locationBackupFile), 0755)
+	}
+	if err == nil {
+		// 可能包含代理的密码
+		err = ioutil.WriteFile(m.locationBackupFile, data, 0600)
+	}
+	if err != nil {
+		logger.Warning("failed to save network location backup:", err)
+	}
+}
+
+// getLocationProxy 获取当前的系统代理配置
+func (m *Manager) getLocationProxy() *locationProxy {
+	p := &locationProxy{
+		Servers: make(map[string]locationProxyServer),
+	}
+	p.Method, _ = m.GetProxyMethod()
+	p.AutoUrl, _ = m.GetAutoProxy()
+	p.IgnoreHosts, _ = m.GetProxyIgnoreHosts()
+	for _, proxyType := range []string{proxyTypeHttp, proxyTypeHttps, proxyTypeFtp, proxyTypeSocks} {
+		host, port, busErr := m.GetProxy(proxyType)
+		if busErr != nil {
+			continue
+		}
+		p.Servers[proxyType] = locationProxyServer{Host: host, Port: port}
+	}
+	return p
+}
+
+func hasGatewayMacMatch(locations []*networkLocation) bool {
+	for _, l := range locations {
+		if l.Match.GatewayMac != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// getLocationFacts 获取当前已激活的非 VPN 连接的信息，withGatewayMac 为 false 时不获取网关的 MAC 地址
+func (m *Manager) getLocationFacts(withGatewayMac bool) []*locationFacts {
+	m.activeConnectionsLock.Lock()
+	var apaths []dbus.ObjectPath
+	for apath, aConn := range m.activeConnections {
+		if aConn.Vpn || aConn.State != nm.NM_ACTIVE_CONNECTION_STATE_ACTIVATED {
+			continue
+		}
+		apaths = append(apaths, apath)
+	}
+	m.activeConnectionsLock.Unlock()
+
+	var factsList []*locationFacts
+	for _, apath := range apaths {
+		nmAConn, err := nmNewActiveConnection(apath)
+		if err != nil {
+			continue
+		}
+		facts := &locationFacts{}
+		facts.ConnectionUuid, _ = nmAConn.Uuid().Get(0)
+
+		var ifc string
+		devs, _ := nmAConn.Devices().Get(0)
+		if len(devs) > 0 {
+			ifc = nmGetDeviceInterface(devs[0])
+			if nmGetDeviceType(devs[0]) == nm.NM_DEVICE_TYPE_WIFI {
+				facts.Ssid = decodeSsid(nmGetWirelessConnectionSsidByUuid(facts.ConnectionUuid))
+			}
+		}
+
+		if !withGatewayMac {
+			factsList = append(factsList, facts)
+			continue
+		}
+		if ip4Path, _ := nmAConn.Ip4Config().Get(0); isNmObjectPathValid(ip4Path) {
+			ip4Data := nmGetIp4ConfigInfo(ip4Path)
+			facts.GatewayMac = getGatewayMac(ip4Data.Gateway, ifc)
+		}
+		factsList = append(factsList, facts)
+	}
+	return factsList
+}
+
+// requestLocationUpdate 请求重新识别位置，reapplyId 不为空时仍处于此位置也重新应用它的配置。
+// 同一时间只有一个 goroutine 识别位置，期间的请求合并为下一次识别。
+func (m *Manager) requestLocationUpdate(reapplyId string) {
+	m.locationUpdateLock.Lock()
+	defer m.locationUpdateLock.Unlock()
+	if reapplyId != "" && !isStringInArray(reapplyId, m.locationReapplyIds) {
+		m.locationReapplyIds = append(m.locationReapplyIds, reapplyId)
+	}
+	m.locationUpdatePending = true
+	if m.locationUpdating {
+		return
+	}
+	m.locationUpdating = true
+	go m.locationUpdateLoop()
+}
+
+func (m *Manager) locationUpdateLoop() {
+	for {
+		m.locationUpdateLock.Lock()
+		if !m.locationUpdatePending {
+			m.locationUpdating = false
+			m.locationUpdateLock.Unlock()
+			return
+		}
+		m.locationUpdatePending = false
+		reapplyIds := m.locationReapplyIds
+		m.locationReapplyIds = nil
+		m.locationUpdateLock.Unlock()
+
+		m.updateActiveLocation(reapplyIds)
+	}
+}
+
+// updateActiveLocation 在活动连接变化后重新识别位置，进入新的位置时应用它的配置，
+// 仍处于 reapplyIds 中的位置时也重新应用它的配置，离开位置时恢复备份的配置
+func (m *Manager) updateActiveLocation(reapplyIds []string) {
+	// 获取网关的 MAC 地址可能需要等待，不持有 m.locationsLock
+	m.locationsLock.Lock()
+	withGatewayMac := hasGatewayMacMatch(m.listLocations())
+	m.locationsLock.Unlock()
+	factsList := m.getLocationFacts(withGatewayMac)
+
+	m.locationsLock.Lock()
+	defer m.locationsLock.Unlock()
+
+	location := matchLocation(m.listLocations(), factsList)
+	var id string
+	if location != nil {
+		id = location.Id
+	}
+
+	m.PropsMu.Lock()
+	changed := m.setPropActiveLocation(id)
+	m.PropsMu.Unlock()
+	if location == nil {
+		// 本程序重启前进入过的位置也需要恢复
+		if m.locationBackup != nil {
+			logger.Info("leave network location")
+			m.applyLocation(&networkLocation{})
+		}
+		return
+	}
+	if !changed && !isStringInArray(id, reapplyIds) {
+		return
+	}
+	logger.Infof("enter network location %s(%s)", location.Name, location.Id)
+	m.applyLocation(location)
+}
+
+func (m *Manager) applyLocationProxy(p *locationProxy) error {
+	for proxyType, server := range p.Servers {
+		err := m.setProxy(proxyType, server.Host, server.Port)
+		if err != nil {
+			return err
+		}
+	}
+	if busErr := m.SetAutoProxy(p.AutoUrl); busErr != nil {
+		return busErr
+	}
+	if busErr := m.SetProxyIgnoreHosts(p.IgnoreHosts); busErr != nil {
+		return busErr
+	}
+	return m.setProxyMethod(p.Method)
+}
+
+// applyLocation 应用位置的配置，需要持有 m.locationsLock。
+// 修改系统代理或应用代理前先备份，位置不再修改它们时恢复备份的配置。
+func (m *Manager) applyLocation(location *networkLocation) {
+	var backup locationBackup
+	if m.locationBackup != nil {
+		backup = *m.locationBackup
+	}
+	if location.Proxy != nil && backup.Proxy == nil {
+		backup.Proxy = m.getLocationProxy()
+	}
+	if location.ProxyChains != nil && backup.ProxyChains == nil && m.proxyChainsManager != nil {
+		backup.ProxyChains = m.proxyChainsManager.GetConfig()
+	}
+	m.setLocationBackup(&backup)
+
+	if location.Proxy != nil {
+		err := m.applyLocationProxy(location.Proxy)
+		if err != nil {
+			logger.Warning("failed to apply location proxy:", err)
+		}
+	} else if backup.Proxy != nil {
+		err := m.applyLocationProxy(backup.Proxy)
+		if err != nil {
+			logger.Warning("failed to restore proxy:", err)
+		}
+		backup.Proxy = nil
+	}
+
+	if m.proxyChainsManager != nil {
+		if location.ProxyChains != nil {
+			err := m.proxyChainsManager.SetConfig(location.ProxyChains)
+			if err != nil {
+				logger.Warning("failed to apply location proxychains:", err)
+			}
+		} else if backup.ProxyChains != nil {
+			err := m.proxyChainsManager.SetConfig(backup.ProxyChains)
+			if err != nil {
+				logger.Warning("failed to restore proxychains:", err)
+			}
+			backup.ProxyChains = nil
+		}
+	}
+	m.setLocationBackup(&backup)
+
+	if location.VpnUuid != "" {
+		if apaths, _ := nmGetActiveConnectionByUuid(location.VpnUuid); len(apaths) > 0 {
+			return
+		}
+		_, err := m.activateConnection(location.VpnUuid, "/")
+		if err != nil {
+			logger.Warning("failed to activate location vpn:", err)
+		}
+	}
+}
+
+func parseLocation(locationJSON string) (*networkLocation, error) {
+	var location networkLocation
+	err := json.Unmarshal([]byte(locationJSON), &location)
+	if err != nil {
+		return nil, err
+	}
+	err = location.check()
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+// AddLocation 添加网络位置，参数为 JSON 格式的位置，返回位置的 Id
+func (m *Manager) AddLocation(locationJSON string) (id string, busErr *dbus.Error) {
+	location, err := parseLocation(locationJSON)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	location.Id = utils.GenUuid()
+
+	m.locationsLock.Lock()
+	m.locations[location.Id] = location
+	err = m.saveLocations()
+	m.locationsLock.Unlock()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+
+	m.requestLocationUpdate("")
+	return location.Id, nil
+}
+
+// ModifyLocation 修改网络位置，如果当前正处于此位置，重新应用它的配置。
+// 应用代理的密码为空并且代理服务器和用户没有变化时，保留原来的密码。
+func (m *Manager) ModifyLocation(id string, locationJSON string) *dbus.Error {
+	location, err := parseLocation(locationJSON)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	location.Id = id
+
+	m.locationsLock.Lock()
+	old, ok := m.locations[id]
+	if !ok {
+		m.locationsLock.Unlock()
+		return dbusutil.ToError(errLocationNotFound)
+	}
+	keepLocationSecrets(location, old)
+	m.locations[id] = location
+	err = m.saveLocations()
+	m.locationsLock.Unlock()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	m.requestLocationUpdate(id)
+	return nil
+}
+
+func (m *Manager) DeleteLocation(id string) *dbus.Error {
+	m.locationsLock.Lock()
+	if _, ok := m.locations[id]; !ok {
+		m.locationsLock.Unlock()
+		return dbusutil.ToError(errLocationNotFound)
+	}
+	delete(m.locations, id)
+	err := m.saveLocations()
+	m.locationsLock.Unlock()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	m.requestLocationUpdate("")
+	return nil
+}
+
+// hideLocationSecrets 返回不包含应用代理密码的位置列表，不修改原来的位置
+func hideLocationSecrets(locations []*networkLocation) []*networkLocation {
+	result := make([]*networkLocation, 0, len(locations))
+	for _, l := range locations {
+		if l.ProxyChains != nil && l.ProxyChains.Password != "" {
+			lCopy := *l
+			proxyChainsCopy := *l.ProxyChains
+			proxyChainsCopy.Password = ""
+			lCopy.ProxyChains = &proxyChainsCopy
+			l = &lCopy
+		}
+		result = append(result, l)
+	}
+	return result
+}
+
+// keepLocationSecrets 修改位置时没有提供应用代理的密码，代理服务器和用户没有变化时使用 old 中的密码
+func keepLocationSecrets(location, old *networkLocation) {
+	pc, oldPc := location.ProxyChains, old.ProxyChains
+	if pc == nil || oldPc == nil || pc.Password != "" {
+		return
+	}
+	if pc.Type == oldPc.Type && pc.IP == oldPc.IP && pc.Port == oldPc.Port && pc.User == oldPc.User {
+		pc.Password = oldPc.Password
+	}
+}
+
+// ListLocations 返回 JSON 格式的网络位置列表，不包含应用代理的密码
+func (m *Manager) ListLocations() (locationsJSON string, busErr *dbus.Error) {
+	m.locationsLock.Lock()
+	locationsJSON, err := marshalJSON(hideLocationSecrets(m.listLocations()))
+	m.locationsLock.Unlock()
+	return locationsJSON, dbusutil.ToError(err)
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/linuxdeepin/dde-daemon/network/proxychains"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getArpHwAddr(t *testing.T) {
+	content := []byte(`IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.1      0x1         0x2         a4:39:b3:0e:1f:20     *        wlp2s0
+10.0.0.1         0x1         0x2         00:1b:21:aa:bb:cc     *        enp3s0
+10.0.0.2         0x1         0x0         00:00:00:00:00:00     *        enp3s0
+`)
+	assert.Equal(t, "A4:39:B3:0E:1F:20", getArpHwAddr(content, "192.168.1.1", "wlp2s0"))
+	assert.Equal(t, "A4:39:B3:0E:1F:20", getArpHwAddr(content, "192.168.1.1", ""))
+	assert.Equal(t, "", getArpHwAddr(content, "192.168.1.1", "enp3s0"))
+	assert.Equal(t, "", getArpHwAddr(content, "10.0.0.2", "enp3s0"))
+	assert.Equal(t, "", getArpHwAddr(content, "10.0.0.3", ""))
+}
+
+func Test_matchLocation(t *testing.T) {
+	office := &networkLocation{
+		Id:    "1",
+		Name:  "Office",
+		Match: locationMatch{Ssid: "corp"},
+	}
+	officeWired := &networkLocation{
+		Id:    "2",
+		Name:  "Office wired",
+		Match: locationMatch{ConnectionUuid: "uuid-wired", GatewayMac: "00:1b:21:aa:bb:cc"},
+	}
+	home := &networkLocation{
+		Id:    "3",
+		Name:  "Home",
+		Match: locationMatch{GatewayMac: "A4:39:B3:0E:1F:20"},
+	}
+	locations := []*networkLocation{home, office, officeWired}
+
+	assert.Nil(t, matchLocation(locations, nil))
+	assert.Equal(t, office, matchLocation(locations, []*locationFacts{
+		{ConnectionUuid: "uuid-wifi", Ssid: "corp"},
+	}))
+	assert.Equal(t, home, matchLocation(locations, []*locationFacts{
+		{ConnectionUuid: "uuid-wifi", Ssid: "home", GatewayMac: "a4:39:b3:0e:1f:20"},
+	}))
+	// 条件多的位置优先
+	assert.Equal(t, officeWired, matchLocation(locations, []*locationFacts{
+		{ConnectionUuid: "uuid-wifi", Ssid: "corp"},
+		{ConnectionUuid: "uuid-wired", GatewayMac: "00:1B:21:AA:BB:CC"},
+	}))
+	// 所有条件都要满足
+	assert.Nil(t, matchLocation(locations, []*locationFacts{
+		{ConnectionUuid: "uuid-wired", GatewayMac: "00:1b:21:00:00:00"},
+	}))
+	// 没有条件的位置不会被匹配
+	assert.Nil(t, matchLocation([]*networkLocation{{Id: "4", Name: "Any"}}, []*locationFacts{{}}))
+}
+
+func Test_networkLocationCheck(t *testing.T) {
+	l := &networkLocation{Name: "Office", Match: locationMatch{Ssid: "corp"}}
+	assert.NoError(t, l.check())
+
+	l.Proxy = &locationProxy{
+		Method:  proxyModeManual,
+		Servers: map[string]locationProxyServer{proxyTypeHttp: {Host: "proxy.corp", Port: "3128"}},
+	}
+	assert.NoError(t, l.check())
+
+	l.Proxy.Servers["gopher"] = locationProxyServer{}
+	assert.Error(t, l.check())
+
+	l.Proxy = &locationProxy{Method: "pac"}
+	assert.Error(t, l.check())
+
+	assert.Error(t, (&networkLocation{Name: "Office"}).check())
+	assert.Error(t, (&networkLocation{Name: " ", Match: locationMatch{Ssid: "corp"}}).check())
+}
+
+func Test_hasGatewayMacMatch(t *testing.T) {
+	assert.False(t, hasGatewayMacMatch(nil))
+	assert.False(t, hasGatewayMacMatch([]*networkLocation{{Match: locationMatch{Ssid: "corp"}}}))
+	assert.True(t, hasGatewayMacMatch([]*networkLocation{
+		{Match: locationMatch{Ssid: "corp"}},
+		{Match: locationMatch{GatewayMac: "a4:39:b3:0e:1f:20"}},
+	}))
+}
+
+func TestManager_setLocationBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "network-location")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	m := &Manager{locationBackupFile: filepath.Join(dir, "sub/network-location-backup.json")}
+	assert.Nil(t, loadLocationBackup(m.locationBackupFile))
+
+	backup := &locationBackup{
+		Proxy: &locationProxy{
+			Method:  proxyModeNone,
+			Servers: map[string]locationProxyServer{proxyTypeHttp: {Host: "", Port: "0"}},
+		},
+		ProxyChains: &proxychains.Config{Type: "http"},
+	}
+	m.setLocationBackup(backup)
+	assert.Equal(t, backup, m.locationBackup)
+	assert.Equal(t, backup, loadLocationBackup(m.locationBackupFile))
+
+	// 修改参数不影响保存的备份
+	backup.ProxyChains = nil
+	assert.NotNil(t, m.locationBackup.ProxyChains)
+
+	// 配置都恢复后删除备份
+	m.setLocationBackup(&locationBackup{})
+	assert.Nil(t, m.locationBackup)
+	_, err = os.Stat(m.locationBackupFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_hideLocationSecrets(t *testing.T) {
+	locations := []*networkLocation{
+		{Id: "1", Name: "corp", ProxyChains: &proxychains.Config{Type: "socks5", IP: "10.0.0.1", Port: 1080, User: "u", Password: "secret"}},
+		{Id: "2", Name: "home"},
+	}
+	result := hideLocationSecrets(locations)
+	require.Len(t, result, 2)
+	assert.Equal(t, "", result[0].ProxyChains.Password)
+	assert.Equal(t, "u", result[0].ProxyChains.User)
+	assert.Equal(t, locations[1], result[1])
+	// 不修改保存的位置
+	assert.Equal(t, "secret", locations[0].ProxyChains.Password)
+}
+
+func Test_keepLocationSecrets(t *testing.T) {
+	old := &networkLocation{
+		ProxyChains: &proxychains.Config{Type: "socks5", IP: "10.0.0.1", Port: 1080, User: "u", Password: "secret"},
+	}
+
+	location := &networkLocation{
+		ProxyChains: &proxychains.Config{Type: "socks5", IP: "10.0.0.1", Port: 1080, User: "u"},
+	}
+	keepLocationSecrets(location, old)
+	assert.Equal(t, "secret", location.ProxyChains.Password)
+
+	location.ProxyChains.Password = "new"
+	keepLocationSecrets(location, old)
+	assert.Equal(t, "new", location.ProxyChains.Password)
+
+	// 代理服务器变化时不使用原来的密码
+	location.ProxyChains = &proxychains.Config{Type: "socks5", IP: "10.0.0.2", Port: 1080, User: "u"}
+	keepLocationSecrets(location, old)
+	assert.Equal(t, "", location.ProxyChains.Password)
+
+	location.ProxyChains = nil
+	keepLocationSecrets(location, old)
+	assert.Nil(t, location.ProxyChains)
+}
@@ -115,6 +115,9 @@ func (d *Module) start() error {
 		logger.Warning("Failed to register sync service:", err)
 	}
 
+	// 代理相关的对象都已经准备好，识别当前所在的网络位置
+	manager.requestLocationUpdate("")
+
 	initDBusDaemon()
 	watchNetworkManagerRestart(manager)
 	return nil
@@ -225,6 +225,24 @@ func (m *Manager) Set(type0, ip string, port uint32, user, password string) *dbu
 	return dbusutil.ToError(err)
 }
 
+// SetConfig 供 network 模块在切换网络位置时修改应用代理
+func (m *Manager) SetConfig(cfg *Config) error {
+	return m.set(cfg.Type, cfg.IP, cfg.Port, cfg.User, cfg.Password)
+}
+
+// GetConfig 供 network 模块在进入网络位置前备份应用代理
+func (m *Manager) GetConfig() *Config {
+	m.PropsMu.RLock()
+	defer m.PropsMu.RUnlock()
+	return &Config{
+		Type:     m.Type,
+		IP:       m.IP,
+		Port:     m.Port,
+		User:     m.User,
+		Password: m.Password,
+	}
+}
+
 func (m *Manager) set(type0, ip string, port uint32, user, password string) error {
 	// allow type0 is empty
 	if type0 == "" {
@@ -136,6 +136,7 @@ type deviceStateInfo struct {
 	devType        uint32
 	aconnId        string
 	connectionType string
+	lastReason     uint32 // 最近一次状态变化的原因，用于网络诊断
 }
 
 func newStateHandler(sysSigLoop *dbusutil.SignalLoop, m *Manager) (sh *stateHandler) {
@@ -185,6 +186,16 @@ func destroyStateHandler(sh *stateHandler) {
 	sh.devices = nil
 }
 
+func (sh *stateHandler) getLastReason(path dbus.ObjectPath) (reason uint32, ok bool) {
+	sh.locker.Lock()
+	defer sh.locker.Unlock()
+	dsi, ok := sh.devices[path]
+	if !ok || dsi.lastReason == nm.NM_DEVICE_STATE_REASON_NONE {
+		return 0, false
+	}
+	return dsi.lastReason, true
+}
+
 func (sh *stateHandler) watch(path dbus.ObjectPath) {
 	defer func() {
 		if err := recover(); err != nil {
@@ -247,6 +258,9 @@ func (sh *stateHandler) watch(path dbus.ObjectPath) {
 		logger.Debugf("device state changed, %d => %d, reason[%d] %s", oldState, newState, reason, deviceErrorTable[reason])
 		sh.locker.Lock()
 		defer sh.locker.Unlock()
+		if dsi, ok := sh.devices[path]; ok {
+			dsi.lastReason = reason
+		}
 		// update id here
 		if id != "" && id != "/" {
 			sh.devices[path].aconnId = id
@@ -528,6 +528,21 @@ func nmGetDeviceInterface(devPath dbus.ObjectPath) (devInterface string) {
 	return
 }
 
+// nmGetDeviceIpInterface 返回设备用于收发数据的网卡，比如移动网络设备的 Interface 是 ttyUSB2，IpInterface 是 wwan0
+func nmGetDeviceIpInterface(devPath dbus.ObjectPath) (ipInterface string) {
+	d, err := nmNewDevice(devPath)
+	if err != nil {
+		return
+	}
+
+	dev := d.Device()
+	ipInterface, _ = dev.IpInterface().Get(0)
+	if ipInterface == "" {
+		ipInterface, _ = dev.Interface().Get(0)
+	}
+	return
+}
+
 func nmAddAndActivateConnection(data connectionData, devPath dbus.ObjectPath, forced bool) (cpath, apath dbus.ObjectPath, err error) {
 	if len(devPath) == 0 {
 		devPath = "/"
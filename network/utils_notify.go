@@ -21,6 +21,7 @@ package network
 
 import (
 	"container/list"
+	"fmt"
 	"sync"
 	"time"
 
@@ -214,6 +215,14 @@ func notifyVpnFailed(id string, reason uint32) {
 	notify(notifyIconVpnDisconnected, Tr("Disconnected"), vpnErrorTable[reason])
 }
 
+func notifyDataUsageThreshold(id string, threshold uint32) {
+	if threshold >= 100 {
+		notify(notifyIconNetworkConnected, Tr("Data Usage"), fmt.Sprintf(Tr("%q has reached its monthly data limit"), id))
+		return
+	}
+	notify(notifyIconNetworkConnected, Tr("Data Usage"), fmt.Sprintf(Tr("%q has used %d%% of its monthly data limit"), id, threshold))
+}
+
 func getMobileConnectedNotifyIcon(mobileNetworkType string) (icon string) {
 	switch mobileNetworkType {
 	case moblieNetworkType4G:
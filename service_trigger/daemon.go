@@ -32,7 +32,13 @@ func init() {
 
 var logger = log.NewLogger("daemon/" + moduleName)
 
-const moduleName = "service-trigger"
+const (
+	moduleName = "service-trigger"
+
+	dbusServiceName = "com.deepin.daemon.ServiceTrigger"
+	dbusPath        = "/com/deepin/daemon/ServiceTrigger"
+	dbusInterface   = dbusServiceName
+)
 
 type Daemon struct {
 	*loader.ModuleBase
@@ -53,12 +59,27 @@ func (d *Daemon) Start() error {
 		return err
 	}
 	d.manager = m
+
+	err = service.Export(dbusPath, m)
+	if err != nil {
+		return err
+	}
+
+	err = service.RequestName(dbusServiceName)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 func (d *Daemon) Stop() error {
 	if d.manager != nil {
-		err := d.manager.stop()
+		err := loader.GetService().StopExport(d.manager)
+		if err != nil {
+			logger.Warning(err)
+		}
+
+		err = d.manager.stop()
 		if err != nil {
 			return err
 		}
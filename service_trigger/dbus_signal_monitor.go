@@ -85,13 +85,13 @@ func (sigMonitor *DBusSignalMonitor) findMatchedServices(signal *dbus.Signal) []
 		if dbusField.Sender == sender &&
 			signal.Name == dbusField.Interface+"."+dbusField.Signal {
 
-			if dbusField.Path != "" {
-				if dbusField.Path == string(signal.Path) {
-					matched = append(matched, service)
-				}
-			} else {
-				matched = append(matched, service)
+			if dbusField.Path != "" && dbusField.Path != string(signal.Path) {
+				continue
 			}
+			if !dbusField.matchSignal(signal) {
+				continue
+			}
+			matched = append(matched, service)
 		}
 	}
 	return matched
@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package service_trigger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var fileEventOpMap = map[string]fsnotify.Op{
+	"Create": fsnotify.Create,
+	"Write":  fsnotify.Write,
+	"Remove": fsnotify.Remove,
+	"Rename": fsnotify.Rename,
+	"Chmod":  fsnotify.Chmod,
+}
+
+type fileWatch struct {
+	service *Service
+	path    string
+	isDir   bool
+	ops     fsnotify.Op
+}
+
+type FileMonitor struct {
+	services []*Service
+	watches  []*fileWatch
+	watcher  *fsnotify.Watcher
+	quit     chan struct{}
+}
+
+func newFileMonitor() *FileMonitor {
+	return &FileMonitor{}
+}
+
+func (fileMonitor *FileMonitor) appendService(service *Service) {
+	fileMonitor.services = append(fileMonitor.services, service)
+}
+
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = os.Getenv("HOME") + path[1:]
+	}
+	return filepath.Clean(os.ExpandEnv(path))
+}
+
+func getFileEventOps(events []string) fsnotify.Op {
+	if len(events) == 0 {
+		return fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename | fsnotify.Chmod
+	}
+	var ops fsnotify.Op
+	for _, event := range events {
+		ops |= fileEventOpMap[event]
+	}
+	return ops
+}
+
+func (fileMonitor *FileMonitor) start(m *Manager) error {
+	if len(fileMonitor.services) == 0 {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	fileMonitor.watcher = watcher
+	fileMonitor.quit = make(chan struct{})
+
+	watchedDirs := make(map[string]struct{})
+	for _, service := range fileMonitor.services {
+		fileField := service.Monitor.File
+		ops := getFileEventOps(fileField.Events)
+		for _, path := range fileField.Paths {
+			path = expandPath(path)
+			fileInfo, err := os.Stat(path)
+			isDir := err == nil && fileInfo.IsDir()
+
+			// 对于文件，监听其所在的目录，这样文件被删除后重新创建（比如编辑器保存）也能继续收到事件。
+			dir := path
+			if !isDir {
+				dir = filepath.Dir(path)
+			}
+			if _, ok := watchedDirs[dir]; !ok {
+				err = watcher.Add(dir)
+				if err != nil {
+					logger.Warningf("failed to watch %q for %v: %v", dir, service, err)
+					continue
+				}
+				watchedDirs[dir] = struct{}{}
+			}
+
+			fileMonitor.watches = append(fileMonitor.watches, &fileWatch{
+				service: service,
+				path:    path,
+				isDir:   isDir,
+				ops:     ops,
+			})
+		}
+	}
+
+	go fileMonitor.eventLoop(m)
+	return nil
+}
+
+func (w *fileWatch) match(event fsnotify.Event) bool {
+	if event.Op&w.ops == 0 {
+		return false
+	}
+
+	name := filepath.Clean(event.Name)
+	if w.isDir {
+		return name == w.path || filepath.Dir(name) == w.path
+	}
+	return name == w.path
+}
+
+func (fileMonitor *FileMonitor) eventLoop(m *Manager) {
+	watcher := fileMonitor.watcher
+	for {
+		select {
+		case <-fileMonitor.quit:
+			return
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warning("file watcher error:", err)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			logger.Debug("file event:", event)
+
+			for _, w := range fileMonitor.watches {
+				if !w.match(event) {
+					continue
+				}
+				logger.Debug("exec service", w.service)
+				replacer := newVarsReplacer(map[string]string{
+					"path": event.Name,
+					"op":   event.Op.String(),
+				})
+				m.triggerService(w.service, replacer)
+			}
+		}
+	}
+}
+
+func (fileMonitor *FileMonitor) stop() error {
+	if fileMonitor.watcher == nil {
+		return nil
+	}
+
+	close(fileMonitor.quit)
+	err := fileMonitor.watcher.Close()
+	fileMonitor.watcher = nil
+	return err
+}
@@ -23,6 +23,7 @@ package service_trigger
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os/exec"
@@ -38,6 +39,8 @@ import (
 	"github.com/linuxdeepin/go-lib/log"
 )
 
+//go:generate dbusutil-gen em -type Manager
+
 type Manager struct {
 	service    *dbusutil.Service
 	serviceMap map[string]*Service
@@ -47,6 +50,9 @@ type Manager struct {
 
 	systemSigMonitor  *DBusSignalMonitor
 	sessionSigMonitor *DBusSignalMonitor
+	fileMonitor       *FileMonitor
+	timerMonitor      *TimerMonitor
+	udevMonitor       *UdevMonitor
 	sysSigLoop        *dbusutil.SignalLoop
 	agents            map[string]*agent
 }
@@ -56,11 +62,18 @@ func newManager(service *dbusutil.Service) *Manager {
 		service:           service,
 		systemSigMonitor:  newDBusSignalMonitor(busTypeSystem),
 		sessionSigMonitor: newDBusSignalMonitor(busTypeSession),
+		fileMonitor:       newFileMonitor(),
+		timerMonitor:      newTimerMonitor(),
+		udevMonitor:       newUdevMonitor(),
 		agents:            make(map[string]*agent),
 	}
 	return m
 }
 
+func (*Manager) GetInterfaceName() string {
+	return dbusInterface
+}
+
 func (m *Manager) handleSysBusNameOwnerChanged(name, oldOwner, newOwner string) {
 	if name != "" && oldOwner == "" && newOwner != "" && !strings.HasPrefix(name, ":") {
 		// 新服务注册了, 需要重新注册 agent
@@ -104,13 +117,33 @@ func (m *Manager) start() error {
 
 	m.systemSigMonitor.init()
 	go m.systemSigMonitor.signalLoop(m)
+
+	err = m.fileMonitor.start(m)
+	if err != nil {
+		logger.Warning(err)
+	}
+	m.timerMonitor.start(m)
+	err = m.udevMonitor.start(m)
+	if err != nil {
+		logger.Warning(err)
+	}
 	return nil
 }
 
 func (m *Manager) stop() error {
 	m.sysSigLoop.Stop()
+	m.timerMonitor.stop()
+	m.udevMonitor.stop()
+	for _, service := range m.serviceMap {
+		service.stopDebounce()
+	}
+
+	err := m.fileMonitor.stop()
+	if err != nil {
+		logger.Warning(err)
+	}
 
-	err := m.sessionSigMonitor.stop()
+	err = m.sessionSigMonitor.stop()
 	if err != nil {
 		return err
 	}
@@ -212,6 +245,12 @@ func (m *Manager) loadServices() {
 			} else if dbusField.BusType == busTypeSessionStr {
 				m.sessionSigMonitor.appendService(service)
 			}
+		case typeFile:
+			m.fileMonitor.appendService(service)
+		case typeTimer:
+			m.timerMonitor.appendService(service)
+		case typeUdev:
+			m.udevMonitor.appendService(service)
 		}
 	}
 }
@@ -219,8 +258,13 @@ func (m *Manager) loadServices() {
 const (
 	serviceFileExt    = ".service.json"
 	typeDBus          = "DBus"
+	typeFile          = "File"
+	typeTimer         = "Timer"
+	typeUdev          = "Udev"
 	busTypeSystemStr  = "System"
 	busTypeSessionStr = "Session"
+
+	propertiesChangedSignal = "org.freedesktop.DBus.Properties.PropertiesChanged"
 )
 
 func (m *Manager) loadServicesFromDir(dirname string) {
@@ -270,6 +314,26 @@ func newReplacer(signal *dbus.Signal) *strings.Replacer {
 		logger.Debugf("old %q => new %q", oldStr, newStr)
 		oldNewSlice = append(oldNewSlice, oldStr, newStr)
 	}
+
+	// PropertiesChanged 信号中变化的属性，可以使用 %{prop:Name} 获取其值
+	changed, _ := getChangedProperties(signal)
+	for name, value := range changed {
+		oldStr := "%{prop:" + name + "}"
+		newStr := signalArgToString(value)
+		logger.Debugf("old %q => new %q", oldStr, newStr)
+		oldNewSlice = append(oldNewSlice, oldStr, newStr)
+	}
+	return strings.NewReplacer(oldNewSlice...)
+}
+
+// newVarsReplacer 将 vars 中的每一项 key => value 转换为 %{key} => value 的替换
+func newVarsReplacer(vars map[string]string) *strings.Replacer {
+	var oldNewSlice []string
+	for key, value := range vars {
+		oldStr := "%{" + key + "}"
+		logger.Debugf("old %q => new %q", oldStr, value)
+		oldNewSlice = append(oldNewSlice, oldStr, value)
+	}
 	return strings.NewReplacer(oldNewSlice...)
 }
 
@@ -280,9 +344,14 @@ func (m *Manager) execService(service *Service,
 		return
 	}
 
+	m.triggerService(service, newReplacer(signal))
+}
+
+// execServiceWithReplacer 执行服务的命令，返回命令的退出码
+func (m *Manager) execServiceWithReplacer(service *Service, replacer *strings.Replacer) (int, error) {
 	if len(service.Exec) == 0 {
 		logger.Warning("service Exec empty")
-		return
+		return -1, errors.New("service Exec empty")
 	}
 
 	var args []string
@@ -294,7 +363,6 @@ func (m *Manager) execService(service *Service,
 		}
 	}
 
-	replacer := newReplacer(signal)
 	for _, arg := range execArgs {
 		args = append(args, replacer.Replace(arg))
 	}
@@ -306,4 +374,8 @@ func (m *Manager) execService(service *Service,
 	if err != nil {
 		logger.Warning(err)
 	}
+	if cmd.ProcessState == nil {
+		return -1, err
+	}
+	return cmd.ProcessState.ExitCode(), err
 }
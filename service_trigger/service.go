@@ -27,14 +27,20 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/godbus/dbus"
 )
 
 type ServiceMonitor struct {
-	Type string
-	DBus *ServiceMonitorDBus
+	Type  string
+	DBus  *ServiceMonitorDBus
+	File  *ServiceMonitorFile
+	Timer *ServiceMonitorTimer
+	Udev  *ServiceMonitorUdev
 }
 
 // ServiceMonitorDBus type DBus
@@ -44,6 +50,38 @@ type ServiceMonitorDBus struct {
 	Interface string
 	Signal    string
 	Path      string // optional
+
+	Args       []ServiceMonitorDBusArg // optional, 信号参数匹配，全部匹配时才触发
+	Properties []string                // optional, 仅用于 PropertiesChanged 信号，有其中任一属性变化时才触发
+}
+
+// ServiceMonitorDBusArg 信号第 Index 个参数（从 0 开始）的匹配条件，Value 为相等匹配，Regexp 为正则匹配，二选一
+type ServiceMonitorDBusArg struct {
+	Index  int
+	Value  string
+	Regexp string
+
+	regexp *regexp.Regexp
+}
+
+// ServiceMonitorFile type File
+type ServiceMonitorFile struct {
+	Paths  []string // 文件或目录，支持 ~ 和环境变量
+	Events []string // optional, Create Write Remove Rename Chmod，为空时监听全部
+}
+
+// ServiceMonitorTimer type Timer
+type ServiceMonitorTimer struct {
+	IntervalSec int    // 每隔多少秒触发一次
+	OnCalendar  string // 类似 cron 的 "分 时 日 月 周"，与 IntervalSec 二选一
+	RunOnStart  bool   // optional, 启动时立即触发一次
+}
+
+// ServiceMonitorUdev type Udev
+type ServiceMonitorUdev struct {
+	Subsystem  string
+	Actions    []string          // optional, add remove change 等，为空时监听全部
+	Properties map[string]string // optional, 设备属性匹配，值支持通配符
 }
 
 type Service struct {
@@ -55,6 +93,25 @@ type Service struct {
 	Description string
 	Exec        []string
 	execFn      func(signal *dbus.Signal)
+
+	DebounceMs     int // optional, 触发后等待 DebounceMs 毫秒内没有新的触发才执行，使用最后一次触发的参数
+	MinIntervalMs  int // optional, 两次执行之间的最小间隔，间隔内的触发将被丢弃
+	MaxConcurrency int // optional, 同时执行的最大数量，超出时触发将被丢弃，为 0 表示不限制
+
+	state serviceState
+}
+
+// serviceState 记录服务的运行状态
+type serviceState struct {
+	mu            sync.Mutex
+	lastFireTime  time.Time
+	fireCount     uint64
+	droppedCount  uint64
+	running       int
+	lastExitCode  int
+	lastErr       error
+	debounceTimer *time.Timer
+	pendingArgs   *strings.Replacer
 }
 
 func (service *Service) getDBusMatchRule() string {
@@ -72,15 +129,21 @@ func (service *Service) getDBusMatchRule() string {
 }
 
 func (service *Service) check() error {
-	if service.Monitor.Type != "DBus" {
-		return fmt.Errorf("unknown Monitor.Type %q" + service.Monitor.Type)
+	var err error
+	switch service.Monitor.Type {
+	case typeDBus:
+		err = service.checkDBus()
+	case typeFile:
+		err = service.checkFile()
+	case typeTimer:
+		err = service.checkTimer()
+	case typeUdev:
+		err = service.checkUdev()
+	default:
+		return fmt.Errorf("unknown Monitor.Type %q", service.Monitor.Type)
 	}
-
-	if service.Monitor.Type == "DBus" {
-		err := service.checkDBus()
-		if err != nil {
-			return err
-		}
+	if err != nil {
+		return err
 	}
 
 	if service.Name == "" {
@@ -91,6 +154,18 @@ func (service *Service) check() error {
 		return errors.New("field Exec is empty")
 	}
 
+	if service.DebounceMs < 0 {
+		return errors.New("field DebounceMs is invalid")
+	}
+
+	if service.MinIntervalMs < 0 {
+		return errors.New("field MinIntervalMs is invalid")
+	}
+
+	if service.MaxConcurrency < 0 {
+		return errors.New("field MaxConcurrency is invalid")
+	}
+
 	return nil
 }
 
@@ -121,6 +196,144 @@ func (service *Service) checkDBus() error {
 	if dbusField.Signal == "" {
 		return errors.New("field Monitor.DBus.Signal is empty")
 	}
+
+	for i := range dbusField.Args {
+		arg := &dbusField.Args[i]
+		if arg.Index < 0 {
+			return fmt.Errorf("field Monitor.DBus.Args[%d].Index is invalid", i)
+		}
+		if arg.Regexp != "" {
+			if arg.Value != "" {
+				return fmt.Errorf("field Monitor.DBus.Args[%d].Value and Regexp are both set", i)
+			}
+			var err error
+			arg.regexp, err = regexp.Compile(arg.Regexp)
+			if err != nil {
+				return fmt.Errorf("field Monitor.DBus.Args[%d].Regexp is invalid: %v", i, err)
+			}
+		}
+	}
+
+	if len(dbusField.Properties) > 0 && !isPropertiesChanged(dbusField.Interface, dbusField.Signal) {
+		return errors.New("field Monitor.DBus.Properties is only for signal org.freedesktop.DBus.Properties.PropertiesChanged")
+	}
+	return nil
+}
+
+func isPropertiesChanged(ifc, member string) bool {
+	return ifc+"."+member == propertiesChangedSignal
+}
+
+func signalArgToString(arg interface{}) string {
+	if variant, ok := arg.(dbus.Variant); ok {
+		arg = variant.Value()
+	}
+	return fmt.Sprintf("%v", arg)
+}
+
+// matchSignal 检查信号参数是否满足 Args 和 Properties 的匹配条件
+func (dbusField *ServiceMonitorDBus) matchSignal(signal *dbus.Signal) bool {
+	for _, arg := range dbusField.Args {
+		if arg.Index >= len(signal.Body) {
+			return false
+		}
+		value := signalArgToString(signal.Body[arg.Index])
+		if arg.regexp != nil {
+			if !arg.regexp.MatchString(value) {
+				return false
+			}
+		} else if value != arg.Value {
+			return false
+		}
+	}
+
+	if len(dbusField.Properties) > 0 {
+		changed, invalidated := getChangedProperties(signal)
+		for _, name := range dbusField.Properties {
+			if _, ok := changed[name]; ok {
+				return true
+			}
+			for _, invalidatedName := range invalidated {
+				if invalidatedName == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func getChangedProperties(signal *dbus.Signal) (map[string]dbus.Variant, []string) {
+	if signal.Name != propertiesChangedSignal || len(signal.Body) != 3 {
+		return nil, nil
+	}
+	changed, _ := signal.Body[1].(map[string]dbus.Variant)
+	invalidated, _ := signal.Body[2].([]string)
+	return changed, invalidated
+}
+
+func (service *Service) checkFile() error {
+	fileField := service.Monitor.File
+	if fileField == nil {
+		return errors.New("field Monitor.File is nil")
+	}
+
+	if len(fileField.Paths) == 0 {
+		return errors.New("field Monitor.File.Paths is empty")
+	}
+
+	for _, event := range fileField.Events {
+		if _, ok := fileEventOpMap[event]; !ok {
+			return fmt.Errorf("field Monitor.File.Events has invalid event %q", event)
+		}
+	}
+	return nil
+}
+
+func (service *Service) checkTimer() error {
+	timerField := service.Monitor.Timer
+	if timerField == nil {
+		return errors.New("field Monitor.Timer is nil")
+	}
+
+	if timerField.IntervalSec < 0 {
+		return errors.New("field Monitor.Timer.IntervalSec is invalid")
+	}
+
+	if timerField.IntervalSec == 0 && timerField.OnCalendar == "" {
+		return errors.New("field Monitor.Timer.IntervalSec and Monitor.Timer.OnCalendar are both empty")
+	}
+
+	if timerField.IntervalSec != 0 && timerField.OnCalendar != "" {
+		return errors.New("field Monitor.Timer.IntervalSec and Monitor.Timer.OnCalendar are both set")
+	}
+
+	if timerField.OnCalendar != "" {
+		_, err := parseCalendar(timerField.OnCalendar)
+		if err != nil {
+			return fmt.Errorf("field Monitor.Timer.OnCalendar is invalid: %v", err)
+		}
+	}
+	return nil
+}
+
+func (service *Service) checkUdev() error {
+	udevField := service.Monitor.Udev
+	if udevField == nil {
+		return errors.New("field Monitor.Udev is nil")
+	}
+
+	if udevField.Subsystem == "" {
+		return errors.New("field Monitor.Udev.Subsystem is empty")
+	}
+
+	for key, pattern := range udevField.Properties {
+		_, err := filepath.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("field Monitor.Udev.Properties[%q] is invalid: %v", key, err)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,204 @@
+package service_trigger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/godbus/dbus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCalendar(t *testing.T) {
+	for _, expr := range []string{
+		"* * * * *",
+		"*/15 8-18 * * 1-5",
+		"0 0 1,15 * *",
+		"30 2 * * 7",
+	} {
+		_, err := parseCalendar(expr)
+		assert.NoError(t, err, expr)
+	}
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		_, err := parseCalendar(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCalendarNext(t *testing.T) {
+	loc := time.UTC
+	// 2021-03-05 是周五
+	now := time.Date(2021, 3, 5, 10, 7, 30, 0, loc)
+
+	var tests = []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2021, 3, 5, 10, 8, 0, 0, loc)},
+		{"*/15 * * * *", time.Date(2021, 3, 5, 10, 15, 0, 0, loc)},
+		{"0 9 * * *", time.Date(2021, 3, 6, 9, 0, 0, 0, loc)},
+		{"0 9 * * 1-5", time.Date(2021, 3, 8, 9, 0, 0, 0, loc)},
+		{"30 2 * * 0", time.Date(2021, 3, 7, 2, 30, 0, 0, loc)},
+		{"30 2 * * 7", time.Date(2021, 3, 7, 2, 30, 0, 0, loc)},
+		{"0 0 1 * *", time.Date(2021, 4, 1, 0, 0, 0, 0, loc)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, loc)},
+		{"0 0 13 * 1", time.Date(2021, 3, 8, 0, 0, 0, 0, loc)},
+	}
+	for _, test := range tests {
+		cal, err := parseCalendar(test.expr)
+		require.NoError(t, err, test.expr)
+		next, err := cal.next(now)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.next, next, test.expr)
+	}
+}
+
+func TestServiceCheckTimer(t *testing.T) {
+	service := &Service{
+		Name: "test",
+		Exec: []string{"true"},
+		Monitor: ServiceMonitor{
+			Type:  typeTimer,
+			Timer: &ServiceMonitorTimer{IntervalSec: 60},
+		},
+	}
+	assert.NoError(t, service.check())
+
+	service.Monitor.Timer.OnCalendar = "0 * * * *"
+	assert.Error(t, service.check())
+
+	service.Monitor.Timer.IntervalSec = 0
+	assert.NoError(t, service.check())
+
+	service.Monitor.Timer.OnCalendar = "0 *"
+	assert.Error(t, service.check())
+}
+
+func TestMatchUdevService(t *testing.T) {
+	udevField := &ServiceMonitorUdev{
+		Subsystem: "usb",
+		Actions:   []string{"add"},
+		Properties: map[string]string{
+			"ID_VENDOR_ID": "046d",
+			"ID_MODEL":     "*Receiver*",
+		},
+	}
+	props := map[string]string{
+		"ID_VENDOR_ID": "046d",
+		"ID_MODEL":     "USB_Receiver",
+	}
+	getProperty := func(key string) string {
+		return props[key]
+	}
+
+	assert.True(t, matchUdevService(udevField, "add", "usb", getProperty))
+	assert.False(t, matchUdevService(udevField, "remove", "usb", getProperty))
+	assert.False(t, matchUdevService(udevField, "add", "input", getProperty))
+
+	props["ID_VENDOR_ID"] = "1234"
+	assert.False(t, matchUdevService(udevField, "add", "usb", getProperty))
+}
+
+func TestFileWatchMatch(t *testing.T) {
+	w := &fileWatch{
+		path: "/tmp/a/b.conf",
+		ops:  getFileEventOps([]string{"Write", "Create"}),
+	}
+	assert.True(t, w.match(fsnotify.Event{Name: "/tmp/a/b.conf", Op: fsnotify.Write}))
+	assert.False(t, w.match(fsnotify.Event{Name: "/tmp/a/b.conf", Op: fsnotify.Remove}))
+	assert.False(t, w.match(fsnotify.Event{Name: "/tmp/a/c.conf", Op: fsnotify.Write}))
+
+	w = &fileWatch{
+		path:  "/tmp/a",
+		isDir: true,
+		ops:   getFileEventOps(nil),
+	}
+	assert.True(t, w.match(fsnotify.Event{Name: "/tmp/a/c.conf", Op: fsnotify.Remove}))
+	assert.False(t, w.match(fsnotify.Event{Name: "/tmp/a/d/e", Op: fsnotify.Write}))
+}
+
+func TestServiceMonitorDBusMatchSignal(t *testing.T) {
+	service := &Service{
+		Name: "test",
+		Exec: []string{"true"},
+		Monitor: ServiceMonitor{
+			Type: typeDBus,
+			DBus: &ServiceMonitorDBus{
+				BusType:   "System",
+				Sender:    "org.freedesktop.NetworkManager",
+				Interface: "org.freedesktop.DBus.Properties",
+				Signal:    "PropertiesChanged",
+				Args: []ServiceMonitorDBusArg{
+					{Index: 0, Regexp: `^org\.freedesktop\.NetworkManager(\.Device)?$`},
+				},
+				Properties: []string{"State"},
+			},
+		},
+	}
+	require.NoError(t, service.check())
+	dbusField := service.Monitor.DBus
+
+	signal := &dbus.Signal{
+		Name: propertiesChangedSignal,
+		Body: []interface{}{
+			"org.freedesktop.NetworkManager",
+			map[string]dbus.Variant{"State": dbus.MakeVariant(uint32(70))},
+			[]string{},
+		},
+	}
+	assert.True(t, dbusField.matchSignal(signal))
+
+	signal.Body[1] = map[string]dbus.Variant{"Metered": dbus.MakeVariant(uint32(1))}
+	assert.False(t, dbusField.matchSignal(signal))
+
+	signal.Body[2] = []string{"State"}
+	assert.True(t, dbusField.matchSignal(signal))
+
+	signal.Body[0] = "org.freedesktop.NetworkManager.Settings"
+	assert.False(t, dbusField.matchSignal(signal))
+
+	dbusField.Args = []ServiceMonitorDBusArg{{Index: 3, Value: "x"}}
+	assert.False(t, dbusField.matchSignal(signal))
+
+	dbusField.Args = []ServiceMonitorDBusArg{{Index: 0, Regexp: "("}}
+	assert.Error(t, service.check())
+
+	dbusField.Args = nil
+	dbusField.Interface = "com.deepin.daemon.ACL.ELF"
+	dbusField.Signal = "Denied"
+	assert.Error(t, service.check())
+}
+
+func TestServiceAcquire(t *testing.T) {
+	service := &Service{
+		MinIntervalMs:  1000,
+		MaxConcurrency: 1,
+	}
+	now := time.Now()
+	assert.True(t, service.acquire(now))
+	// 执行中，超出并发数量
+	assert.False(t, service.acquire(now.Add(2*time.Second)))
+	service.release(0, nil)
+	// 间隔太短
+	assert.False(t, service.acquire(now.Add(500*time.Millisecond)))
+	assert.True(t, service.acquire(now.Add(2*time.Second)))
+	service.release(1, nil)
+
+	status := service.getStatus()
+	assert.Equal(t, uint64(2), status.FireCount)
+	assert.Equal(t, uint64(2), status.DroppedCount)
+	assert.Equal(t, 0, status.Running)
+	assert.Equal(t, 1, status.LastExitCode)
+	assert.Equal(t, now.Add(2*time.Second).Unix(), status.LastFireTime)
+}
@@ -0,0 +1,256 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package service_trigger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// calendarField 为日历表达式中的一个字段，bits 的第 n 位表示值 n 是否匹配
+type calendarField struct {
+	bits uint64
+	any  bool
+}
+
+func (f calendarField) match(v int) bool {
+	return f.bits&(1<<uint(v)) != 0
+}
+
+// calendar 为类似 cron 的日历表达式："分 时 日 月 周"，
+// 每个字段支持 *、数值、列表（1,2）、范围（1-5）和步长（*/15、0-30/10），周日为 0 或 7。
+type calendar struct {
+	minute  calendarField
+	hour    calendarField
+	day     calendarField
+	month   calendarField
+	weekday calendarField
+}
+
+var calendarFieldRanges = [5][2]int{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week
+}
+
+func parseCalendar(expr string) (*calendar, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expect 5 fields, but got %d", len(fields))
+	}
+
+	var parsed [5]calendarField
+	for i, field := range fields {
+		var err error
+		parsed[i], err = parseCalendarField(field, calendarFieldRanges[i][0], calendarFieldRanges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %v", field, err)
+		}
+	}
+
+	// 周日可以用 0 或 7 表示
+	if parsed[4].match(7) {
+		parsed[4].bits |= 1
+	}
+
+	return &calendar{
+		minute:  parsed[0],
+		hour:    parsed[1],
+		day:     parsed[2],
+		month:   parsed[3],
+		weekday: parsed[4],
+	}, nil
+}
+
+func parseCalendarField(field string, min, max int) (calendarField, error) {
+	var result calendarField
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(item, "/"); idx != -1 {
+			var err error
+			step, err = strconv.Atoi(item[idx+1:])
+			if err != nil || step <= 0 {
+				return result, fmt.Errorf("invalid step %q", item[idx+1:])
+			}
+			item = item[:idx]
+		}
+
+		var begin, end int
+		switch {
+		case item == "*":
+			begin, end = min, max
+			if step == 1 {
+				result.any = true
+			}
+		case strings.Contains(item, "-"):
+			parts := strings.SplitN(item, "-", 2)
+			var err error
+			begin, err = strconv.Atoi(parts[0])
+			if err != nil {
+				return result, fmt.Errorf("invalid value %q", parts[0])
+			}
+			end, err = strconv.Atoi(parts[1])
+			if err != nil {
+				return result, fmt.Errorf("invalid value %q", parts[1])
+			}
+		default:
+			var err error
+			begin, err = strconv.Atoi(item)
+			if err != nil {
+				return result, fmt.Errorf("invalid value %q", item)
+			}
+			end = begin
+		}
+
+		if begin < min || end > max || begin > end {
+			return result, fmt.Errorf("value out of range [%d, %d]", min, max)
+		}
+		for v := begin; v <= end; v += step {
+			result.bits |= 1 << uint(v)
+		}
+	}
+	return result, nil
+}
+
+func (c *calendar) matchDay(t time.Time) bool {
+	// 与 cron 一致：日和周都不是 * 时，满足其一即可
+	if c.day.any || c.weekday.any {
+		return c.day.match(t.Day()) && c.weekday.match(int(t.Weekday()))
+	}
+	return c.day.match(t.Day()) || c.weekday.match(int(t.Weekday()))
+}
+
+// next 返回 t 之后第一个满足表达式的时间（精确到分钟）
+func (c *calendar) next(t time.Time) (time.Time, error) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// 最多查找 5 年，足以覆盖 2 月 29 日这样的表达式
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.month.match(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hour.match(t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minute.match(t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, nil
+	}
+	return time.Time{}, errors.New("no matched time")
+}
+
+type TimerMonitor struct {
+	services []*Service
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newTimerMonitor() *TimerMonitor {
+	return &TimerMonitor{}
+}
+
+func (timerMonitor *TimerMonitor) appendService(service *Service) {
+	timerMonitor.services = append(timerMonitor.services, service)
+}
+
+func (timerMonitor *TimerMonitor) start(m *Manager) {
+	if len(timerMonitor.services) == 0 {
+		return
+	}
+
+	timerMonitor.quit = make(chan struct{})
+	for _, service := range timerMonitor.services {
+		timerMonitor.wg.Add(1)
+		go timerMonitor.serviceLoop(m, service)
+	}
+}
+
+func (timerMonitor *TimerMonitor) fire(m *Manager, service *Service, now time.Time) {
+	logger.Debug("exec service", service)
+	replacer := newVarsReplacer(map[string]string{
+		"time":      now.Format(time.RFC3339),
+		"timestamp": strconv.FormatInt(now.Unix(), 10),
+	})
+	m.triggerService(service, replacer)
+}
+
+func (timerMonitor *TimerMonitor) serviceLoop(m *Manager, service *Service) {
+	defer timerMonitor.wg.Done()
+	timerField := service.Monitor.Timer
+
+	var cal *calendar
+	if timerField.OnCalendar != "" {
+		var err error
+		cal, err = parseCalendar(timerField.OnCalendar)
+		if err != nil {
+			logger.Warningf("%v: %v", service, err)
+			return
+		}
+	}
+
+	if timerField.RunOnStart {
+		timerMonitor.fire(m, service, time.Now())
+	}
+
+	for {
+		var delay time.Duration
+		if cal != nil {
+			now := time.Now()
+			next, err := cal.next(now)
+			if err != nil {
+				logger.Warningf("%v: %v", service, err)
+				return
+			}
+			delay = next.Sub(now)
+		} else {
+			delay = time.Duration(timerField.IntervalSec) * time.Second
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timerMonitor.quit:
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			timerMonitor.fire(m, service, now)
+		}
+	}
+}
+
+func (timerMonitor *TimerMonitor) stop() {
+	if timerMonitor.quit == nil {
+		return
+	}
+	close(timerMonitor.quit)
+	timerMonitor.wg.Wait()
+	timerMonitor.quit = nil
+}
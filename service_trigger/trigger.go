@@ -0,0 +1,167 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package service_trigger
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+)
+
+// triggerService 在服务被触发时调用，根据 DebounceMs 决定立即执行还是延后执行，不会阻塞。
+func (m *Manager) triggerService(service *Service, replacer *strings.Replacer) {
+	if service.DebounceMs <= 0 {
+		go m.fireService(service, replacer)
+		return
+	}
+
+	state := &service.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.pendingArgs = replacer
+	if state.debounceTimer != nil {
+		state.debounceTimer.Stop()
+	}
+	state.debounceTimer = time.AfterFunc(time.Duration(service.DebounceMs)*time.Millisecond, func() {
+		state.mu.Lock()
+		replacer := state.pendingArgs
+		state.pendingArgs = nil
+		state.debounceTimer = nil
+		state.mu.Unlock()
+
+		if replacer != nil {
+			m.fireService(service, replacer)
+		}
+	})
+}
+
+func (service *Service) stopDebounce() {
+	state := &service.state
+	state.mu.Lock()
+	if state.debounceTimer != nil {
+		state.debounceTimer.Stop()
+		state.debounceTimer = nil
+	}
+	state.pendingArgs = nil
+	state.mu.Unlock()
+}
+
+// acquire 检查 MinIntervalMs 和 MaxConcurrency 的限制，允许执行时记录执行状态并返回 true
+func (service *Service) acquire(now time.Time) bool {
+	state := &service.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if service.MinIntervalMs > 0 && !state.lastFireTime.IsZero() &&
+		now.Sub(state.lastFireTime) < time.Duration(service.MinIntervalMs)*time.Millisecond {
+		state.droppedCount++
+		logger.Debugf("drop %v: fire too frequently", service)
+		return false
+	}
+
+	if service.MaxConcurrency > 0 && state.running >= service.MaxConcurrency {
+		state.droppedCount++
+		logger.Debugf("drop %v: reach max concurrency %d", service, service.MaxConcurrency)
+		return false
+	}
+
+	state.lastFireTime = now
+	state.fireCount++
+	state.running++
+	return true
+}
+
+func (service *Service) release(exitCode int, err error) {
+	state := &service.state
+	state.mu.Lock()
+	state.running--
+	state.lastExitCode = exitCode
+	state.lastErr = err
+	state.mu.Unlock()
+}
+
+func (m *Manager) fireService(service *Service, replacer *strings.Replacer) {
+	if !service.acquire(time.Now()) {
+		return
+	}
+	logger.Debug("exec service", service)
+	exitCode, err := m.execServiceWithReplacer(service, replacer)
+	service.release(exitCode, err)
+}
+
+type ServiceStatus struct {
+	Name         string // 配置文件名去除后缀
+	Description  string
+	File         string
+	Type         string
+	LastFireTime int64 // Unix 时间戳，从未执行时为 0
+	FireCount    uint64
+	DroppedCount uint64
+	Running      int
+	LastExitCode int
+	LastError    string
+}
+
+func (service *Service) getStatus() ServiceStatus {
+	state := &service.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	status := ServiceStatus{
+		Name:         service.basename,
+		Description:  service.Name,
+		File:         service.filename,
+		Type:         service.Monitor.Type,
+		FireCount:    state.fireCount,
+		DroppedCount: state.droppedCount,
+		Running:      state.running,
+		LastExitCode: state.lastExitCode,
+	}
+	if !state.lastFireTime.IsZero() {
+		status.LastFireTime = state.lastFireTime.Unix()
+	}
+	if state.lastErr != nil {
+		status.LastError = state.lastErr.Error()
+	}
+	return status
+}
+
+func (m *Manager) getServicesStatus() []ServiceStatus {
+	result := make([]ServiceStatus, 0, len(m.serviceMap))
+	for _, service := range m.serviceMap {
+		result = append(result, service.getStatus())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// ListServices 以 JSON 格式返回已加载的服务及其最近一次执行的状态
+func (m *Manager) ListServices() (services string, busErr *dbus.Error) {
+	data, err := json.Marshal(m.getServicesStatus())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
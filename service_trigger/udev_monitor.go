@@ -0,0 +1,148 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package service_trigger
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	gudev "github.com/linuxdeepin/go-gir/gudev-1.0"
+)
+
+// 匹配 Exec 中的 %{prop:KEY} 占位符
+var udevPropPlaceholderRegexp = regexp.MustCompile(`%\{prop:([^}]+)\}`)
+
+type UdevMonitor struct {
+	services []*Service
+	client   *gudev.Client
+	mu       sync.Mutex
+}
+
+func newUdevMonitor() *UdevMonitor {
+	return &UdevMonitor{}
+}
+
+func (udevMonitor *UdevMonitor) appendService(service *Service) {
+	udevMonitor.services = append(udevMonitor.services, service)
+}
+
+func (udevMonitor *UdevMonitor) start(m *Manager) error {
+	if len(udevMonitor.services) == 0 {
+		return nil
+	}
+
+	var subsystems []string
+	subsystemMap := make(map[string]struct{})
+	for _, service := range udevMonitor.services {
+		subsystem := service.Monitor.Udev.Subsystem
+		if _, ok := subsystemMap[subsystem]; ok {
+			continue
+		}
+		subsystemMap[subsystem] = struct{}{}
+		subsystems = append(subsystems, subsystem)
+	}
+
+	client := gudev.NewClient(subsystems)
+	if client == nil {
+		return errors.New("gudev client is nil")
+	}
+
+	udevMonitor.mu.Lock()
+	udevMonitor.client = client
+	udevMonitor.mu.Unlock()
+
+	client.Connect("uevent", func(client *gudev.Client, action string, device *gudev.Device) {
+		defer device.Unref()
+		udevMonitor.handleUEvent(m, action, device)
+	})
+	return nil
+}
+
+func matchUdevService(udevField *ServiceMonitorUdev, action, subsystem string, getProperty func(string) string) bool {
+	if udevField.Subsystem != subsystem {
+		return false
+	}
+
+	if len(udevField.Actions) > 0 {
+		var found bool
+		for _, a := range udevField.Actions {
+			if a == action {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for key, pattern := range udevField.Properties {
+		matched, err := filepath.Match(pattern, getProperty(key))
+		if err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func (udevMonitor *UdevMonitor) handleUEvent(m *Manager, action string, device *gudev.Device) {
+	udevMonitor.mu.Lock()
+	stopped := udevMonitor.client == nil
+	udevMonitor.mu.Unlock()
+	if stopped {
+		return
+	}
+
+	subsystem := device.GetSubsystem()
+	logger.Debugf("uevent action: %s, subsystem: %s, sysfs path: %s", action, subsystem, device.GetSysfsPath())
+
+	for _, service := range udevMonitor.services {
+		if !matchUdevService(service.Monitor.Udev, action, subsystem, device.GetProperty) {
+			continue
+		}
+
+		vars := map[string]string{
+			"action":    action,
+			"subsystem": subsystem,
+			"devpath":   device.GetSysfsPath(),
+			"devname":   device.GetDeviceFile(),
+			"name":      device.GetName(),
+		}
+		for _, arg := range service.Exec {
+			for _, match := range udevPropPlaceholderRegexp.FindAllStringSubmatch(arg, -1) {
+				key := match[1]
+				vars["prop:"+key] = device.GetProperty(key)
+			}
+		}
+
+		logger.Debug("exec service", service)
+		m.triggerService(service, newVarsReplacer(vars))
+	}
+}
+
+func (udevMonitor *UdevMonitor) stop() {
+	udevMonitor.mu.Lock()
+	defer udevMonitor.mu.Unlock()
+
+	if udevMonitor.client != nil {
+		udevMonitor.client.Unref()
+		udevMonitor.client = nil
+	}
+}
@@ -0,0 +1,510 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	cgroup2MountPoint = "/sys/fs/cgroup"
+	// 所有策略的 slice 都在这个 slice 下
+	rootSliceName   = "deepin_scheduler.slice"
+	unitNamePrefix  = "deepin_scheduler-"
+	unitDescription = "deepin scheduler policy "
+)
+
+// cgroupOrigin 进程被移动前所在的 systemd 单元，subcgroup 为进程在单元中的子 cgroup，
+// 例如 user@1000.service 委派给用户 systemd 管理，进程可能在其中的 /app.slice/xxx.scope 中。
+type cgroupOrigin struct {
+	unit      string
+	subcgroup string
+}
+
+// cgroupScope 记录进程被移动到策略中时创建的 scope
+type cgroupScope struct {
+	policy string
+	origin cgroupOrigin
+}
+
+// cgroupManager 为每个配置项创建一个 systemd slice 并设置资源限制，
+// 匹配的进程通过临时的 scope 放入 slice 中，cgroup 树始终由 systemd 维护。
+type cgroupManager struct {
+	mu       sync.Mutex
+	mount    string
+	sd       systemdManager
+	policies map[string]*priorityCfg // key 为策略名
+	scopes   map[string]*cgroupScope // key 为 scope 单元名，用于策略被删除或焦点离开时将进程移回原来的单元
+}
+
+func newCgroupManager(mount string, sd systemdManager) *cgroupManager {
+	return &cgroupManager{
+		mount:    mount,
+		sd:       sd,
+		policies: make(map[string]*priorityCfg),
+		scopes:   make(map[string]*cgroupScope),
+	}
+}
+
+func isCgroup2(mount string) bool {
+	_, err := os.Stat(filepath.Join(mount, "cgroup.controllers"))
+	return err == nil
+}
+
+func strSliceContains(slice []string, str string) bool {
+	for _, v := range slice {
+		if v == str {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeUnitName 按 systemd-escape 的规则转义单元名中的字符，- 在 slice 名中表示层级，也需要转义
+func escapeUnitName(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '/':
+			sb.WriteByte('-')
+		case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+			c == ':' || c == '_' || (c == '.' && i > 0):
+			sb.WriteByte(c)
+		default:
+			sb.WriteString(fmt.Sprintf("\\x%02x", c))
+		}
+	}
+	return sb.String()
+}
+
+// getPolicySliceName 返回策略的 slice 单元名，它是 deepin_scheduler.slice 的子 slice
+func getPolicySliceName(policy string) string {
+	return unitNamePrefix + escapeUnitName(policy) + ".slice"
+}
+
+// getPolicyScopeName 返回进程在策略中的 scope 单元名
+func getPolicyScopeName(policy string, pid uint32) string {
+	return unitNamePrefix + escapeUnitName(policy) + "-" + strconv.FormatUint(uint64(pid), 10) + ".scope"
+}
+
+// getPolicyCgroupPath 返回策略的 slice 在 cgroup 树中的路径
+func getPolicyCgroupPath(policy string) string {
+	return "/" + rootSliceName + "/" + getPolicySliceName(policy)
+}
+
+// getCgroupOrigin 从进程的 cgroup 路径中找出 system 实例的 systemd 管理的单元，
+// 路径中第一个 service 或 scope 就是这个单元，其下的路径由单元自己（比如用户 systemd）管理。
+func getCgroupOrigin(cgroupPath string) (cgroupOrigin, bool) {
+	items := strings.Split(strings.Trim(cgroupPath, "/"), "/")
+	for i, item := range items {
+		if strings.HasSuffix(item, ".service") || strings.HasSuffix(item, ".scope") {
+			origin := cgroupOrigin{unit: item}
+			if i+1 < len(items) {
+				origin.subcgroup = "/" + strings.Join(items[i+1:], "/")
+			}
+			return origin, true
+		}
+	}
+	return cgroupOrigin{}, false
+}
+
+func getMemoryLimitBytes(str string) uint64 {
+	if str == "" {
+		return math.MaxUint64
+	}
+	limit, _ := parseMemoryLimit(str)
+	if limit == "max" {
+		return math.MaxUint64
+	}
+	bytes, _ := strconv.ParseUint(limit, 10, 64)
+	return bytes
+}
+
+// getSliceProperties 返回策略 slice 的资源限制，未配置的项为 systemd 中的默认值（math.MaxUint64），
+// 以便热加载配置时可以取消限制
+func getSliceProperties(policy string, pCfg *priorityCfg) []systemdProperty {
+	cpuWeight := uint64(math.MaxUint64)
+	if pCfg.CPUWeight != 0 {
+		cpuWeight = uint64(pCfg.CPUWeight)
+	}
+	ioWeight := uint64(math.MaxUint64)
+	if pCfg.IOWeight != 0 {
+		ioWeight = uint64(pCfg.IOWeight)
+	}
+	return []systemdProperty{
+		newSystemdProperty("Description", unitDescription+policy),
+		newSystemdProperty("CPUWeight", cpuWeight),
+		newSystemdProperty("IOWeight", ioWeight),
+		newSystemdProperty("MemoryHigh", getMemoryLimitBytes(pCfg.MemoryHigh)),
+		newSystemdProperty("MemoryMax", getMemoryLimitBytes(pCfg.MemoryMax)),
+	}
+}
+
+// startOrUpdateSlice 创建临时的 slice，已经存在时（比如模块重启）更新它的属性
+func (cm *cgroupManager) startOrUpdateSlice(name string, props []systemdProperty) error {
+	err := cm.sd.StartTransientUnit(name, "fail", props)
+	if isUnitExistsError(err) {
+		err = cm.sd.SetUnitProperties(name, props)
+	}
+	return err
+}
+
+// setup 根据配置创建或更新各个策略的 slice，并清理已经不在配置中的 slice
+func (cm *cgroupManager) setup(cfg *config) error {
+	if !isCgroup2(cm.mount) {
+		return errors.New("cgroup v2 is not mounted")
+	}
+
+	err := cm.startOrUpdateSlice(rootSliceName, []systemdProperty{
+		newSystemdProperty("Description", "deepin scheduler"),
+	})
+	if err != nil {
+		return fmt.Errorf("start %s failed: %v", rootSliceName, err)
+	}
+
+	policies := make(map[string]*priorityCfg)
+	for key, pCfg := range cfg.Processes {
+		if !pCfg.hasCgroupPolicy() {
+			continue
+		}
+		name := getPolicyName(key)
+		err = cm.setupPolicy(name, pCfg)
+		if err != nil {
+			logger.Warningf("setup cgroup policy %s failed: %v", name, err)
+			continue
+		}
+		policies[name] = pCfg
+	}
+
+	if cfg.isFocusBoostEnabled() && cfg.FocusBoost.CPUWeight != 0 {
+		pCfg := &priorityCfg{CPUWeight: cfg.FocusBoost.CPUWeight}
+		err = cm.setupPolicy(focusPolicyName, pCfg)
+		if err != nil {
+			logger.Warningf("setup cgroup policy %s failed: %v", focusPolicyName, err)
+		} else {
+			policies[focusPolicyName] = pCfg
+		}
+	}
+
+	cm.mu.Lock()
+	old := cm.policies
+	cm.policies = policies
+	cm.mu.Unlock()
+
+	for name := range old {
+		if _, ok := policies[name]; !ok {
+			cm.removePolicy(name)
+		}
+	}
+	return nil
+}
+
+// clear 删除所有策略的 slice，所有进程都移出后停止 deepin_scheduler.slice
+func (cm *cgroupManager) clear() {
+	cm.mu.Lock()
+	old := cm.policies
+	cm.policies = make(map[string]*priorityCfg)
+	cm.mu.Unlock()
+
+	for name := range old {
+		cm.removePolicy(name)
+	}
+
+	pids, err := readCgroupProcsRecursive(filepath.Join(cm.mount, rootSliceName))
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	if len(pids) > 0 {
+		return
+	}
+	err = cm.sd.StopUnit(rootSliceName)
+	if err != nil {
+		logger.Warningf("stop %s failed: %v", rootSliceName, err)
+	}
+}
+
+func (cm *cgroupManager) setupPolicy(name string, pCfg *priorityCfg) error {
+	return cm.startOrUpdateSlice(getPolicySliceName(name), getSliceProperties(name, pCfg))
+}
+
+// removePolicy 将策略中的进程移回原来的单元，然后停止策略的 slice
+func (cm *cgroupManager) removePolicy(name string) {
+	cm.movePidsBack(name)
+
+	slice := getPolicySliceName(name)
+	pids, err := readCgroupProcsRecursive(filepath.Join(cm.mount, getPolicyCgroupPath(name)))
+	if err != nil {
+		logger.Warning(err)
+	}
+	if len(pids) > 0 {
+		// 不知道原来所在单元的进程（比如模块重启前移动的进程）还在 slice 中，
+		// 停止 slice 会结束这些进程，只取消资源限制
+		logger.Warningf("processes %v are still in %s, reset its resource limits", pids, slice)
+		err = cm.sd.SetUnitProperties(slice, getSliceProperties(name, &priorityCfg{}))
+		if err != nil {
+			logger.Warningf("reset properties of %s failed: %v", slice, err)
+		}
+		return
+	}
+
+	err = cm.sd.StopUnit(slice)
+	if err != nil {
+		logger.Warningf("stop %s failed: %v", slice, err)
+	}
+}
+
+// attachPids 将进程移动到 origin 中，有进程已经退出时逐个移动其余的进程
+func (cm *cgroupManager) attachPids(origin cgroupOrigin, pids []uint32) {
+	err := cm.sd.AttachProcessesToUnit(origin.unit, origin.subcgroup, pids)
+	if err == nil || len(pids) == 1 {
+		if err != nil {
+			logger.Warningf("move process %d back to %s%s failed: %v", pids[0], origin.unit, origin.subcgroup, err)
+		}
+		return
+	}
+	for _, pid := range pids {
+		cm.attachPids(origin, []uint32{pid})
+	}
+}
+
+// movePidsBack 将策略中的进程移回原来的单元，之后进程所在的 scope 为空，会被 systemd 回收
+func (cm *cgroupManager) movePidsBack(name string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	dir := filepath.Join(cm.mount, getPolicyCgroupPath(name))
+	for scopeName, scope := range cm.scopes {
+		if scope.policy != name {
+			continue
+		}
+		pids, err := readCgroupProcsRecursive(filepath.Join(dir, scopeName))
+		if err != nil {
+			logger.Warning(err)
+		}
+		if len(pids) > 0 {
+			cm.attachPids(scope.origin, pids)
+		}
+		delete(cm.scopes, scopeName)
+	}
+}
+
+// getOrigin 返回 cgroup 路径对应的原来的单元，已经在策略的 scope 中时返回 scope 记录的单元。
+// 调用者需要持有 cm.mu
+func (cm *cgroupManager) getOrigin(cgroupPath string) (cgroupOrigin, bool) {
+	prefix := "/" + rootSliceName + "/"
+	if !strings.HasPrefix(cgroupPath, prefix) {
+		return getCgroupOrigin(cgroupPath)
+	}
+	// /deepin_scheduler.slice/<slice>/<scope>
+	items := strings.Split(strings.TrimPrefix(cgroupPath, prefix), "/")
+	if len(items) < 2 {
+		return cgroupOrigin{}, false
+	}
+	scope, ok := cm.scopes[items[1]]
+	if !ok {
+		return cgroupOrigin{}, false
+	}
+	return scope.origin, true
+}
+
+// getPolicy 返回 exe 匹配的策略名，没有匹配的策略时返回空
+func (cm *cgroupManager) getPolicy(cfg *config, exe string) string {
+	key, pCfg := cfg.getRule(exe)
+	if pCfg == nil || !pCfg.hasCgroupPolicy() {
+		return ""
+	}
+	name := getPolicyName(key)
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if _, ok := cm.policies[name]; !ok {
+		return ""
+	}
+	return name
+}
+
+// movePid 创建一个包含进程的临时 scope 并放入策略的 slice 中，之后进程 fork 出的子进程会自动继承该 scope
+func (cm *cgroupManager) movePid(policy string, pid uint32) error {
+	current, err := getProcessCgroup(pid)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(current, getPolicyCgroupPath(policy)+"/") {
+		return nil
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	origin, ok := cm.getOrigin(current)
+	if !ok {
+		return fmt.Errorf("process %d is not in a systemd unit: %s", pid, current)
+	}
+
+	scopeName := getPolicyScopeName(policy, pid)
+	err = cm.sd.StartTransientUnit(scopeName, "fail", []systemdProperty{
+		newSystemdProperty("Description", unitDescription+policy),
+		newSystemdProperty("Slice", getPolicySliceName(policy)),
+		newSystemdProperty("PIDs", []uint32{pid}),
+		newSystemdProperty("CollectMode", "inactive-or-failed"),
+	})
+	if isUnitExistsError(err) {
+		// 之前使用这个 pid 的进程的 scope 还没有被回收
+		err = cm.sd.AttachProcessesToUnit(scopeName, "", []uint32{pid})
+	}
+	if err != nil {
+		return err
+	}
+	cm.scopes[scopeName] = &cgroupScope{
+		policy: policy,
+		origin: origin,
+	}
+	return nil
+}
+
+// getPidPolicy 返回进程当前所在的策略名
+func (cm *cgroupManager) getPidPolicy(pid uint32) string {
+	current, err := getProcessCgroup(pid)
+	if err != nil {
+		return ""
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	for name := range cm.policies {
+		if strings.HasPrefix(current, getPolicyCgroupPath(name)+"/") {
+			return name
+		}
+	}
+	return ""
+}
+
+// pruneScopes 清理已经被 systemd 回收的 scope 的记录
+func (cm *cgroupManager) pruneScopes() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	for scopeName, scope := range cm.scopes {
+		dir := filepath.Join(cm.mount, getPolicyCgroupPath(scope.policy), scopeName)
+		_, err := os.Stat(dir)
+		if os.IsNotExist(err) {
+			delete(cm.scopes, scopeName)
+		}
+	}
+}
+
+// listPolicyPids 返回每个策略中的进程
+func (cm *cgroupManager) listPolicyPids() map[string][]uint32 {
+	cm.mu.Lock()
+	names := make([]string, 0, len(cm.policies))
+	for name := range cm.policies {
+		names = append(names, name)
+	}
+	cm.mu.Unlock()
+
+	result := make(map[string][]uint32, len(names))
+	for _, name := range names {
+		pids, err := readCgroupProcsRecursive(filepath.Join(cm.mount, getPolicyCgroupPath(name)))
+		if err != nil {
+			logger.Warning(err)
+		}
+		result[name] = pids
+	}
+	return result
+}
+
+// readCgroupProcsRecursive 读取 dir 及其所有子 cgroup 中的进程，cgroup 不存在时返回空
+func readCgroupProcsRecursive(dir string) ([]uint32, error) {
+	var result []uint32
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		pids, err := readCgroupProcs(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		result = append(result, pids...)
+		return nil
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result, err
+}
+
+func readCgroupProcs(dir string) ([]uint32, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(content))
+	pids := make([]uint32, 0, len(fields))
+	for _, field := range fields {
+		pid, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			continue
+		}
+		pids = append(pids, uint32(pid))
+	}
+	sort.Slice(pids, func(i, j int) bool {
+		return pids[i] < pids[j]
+	})
+	return pids, nil
+}
+
+// getProcessCgroup 读取进程在 cgroup v2 中的路径，即 /proc/<pid>/cgroup 中 0:: 开头的行
+func getProcessCgroup(pid uint32) (string, error) {
+	f, err := os.Open(filepath.Join("/proc", strconv.Itoa(int(pid)), "cgroup"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return parseProcessCgroup(f)
+}
+
+func parseProcessCgroup(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("not found cgroup v2 path")
+}
@@ -17,8 +17,11 @@
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 type config struct {
@@ -26,23 +29,131 @@ type config struct {
 	Processes          map[string]*priorityCfg `json:"processes"`
 	Enabled            bool                    `json:"enabled"`
 	ProcMonitorEnabled bool                    `json:"procMonitorEnabled"`
+	CgroupEnabled      bool                    `json:"cgroupEnabled"` // 是否使用 cgroup v2 限制进程资源
+	FocusBoost         *focusBoostCfg          `json:"focusBoost"`
+}
+
+// focusBoostCfg 为活动窗口所属的进程临时提高优先级，焦点离开后恢复
+type focusBoostCfg struct {
+	Enabled   bool     `json:"enabled"`
+	CPU       *int     `json:"cpu"`       // nice 值，仅在比进程原来的优先级高时设置，为空时不设置
+	CPUWeight int      `json:"cpuWeight"` // cpu.weight，需要开启 cgroupEnabled，为零值时不设置
+	Exclude   []string `json:"exclude"`   // 不提升优先级的进程，可执行文件路径或文件名
 }
 
 type priorityCfg struct {
-	CPU int `json:"cpu"`
+	CPU *int `json:"cpu"` // nice 值，范围 19（低） ～ -20（高），为空时不设置
+
+	// 以下为 cgroup v2 的资源限制，为零值时不设置
+	CPUWeight  int    `json:"cpuWeight"`  // cpu.weight，范围 1 ～ 10000
+	MemoryHigh string `json:"memoryHigh"` // memory.high，字节数，支持 K M G 后缀，或 max
+	MemoryMax  string `json:"memoryMax"`  // memory.max，格式同 memoryHigh
+	IOWeight   int    `json:"ioWeight"`   // io.weight，范围 1 ～ 10000
 }
 
 func (c *config) getPriority(exe string) *priorityCfg {
+	_, pCfg := c.getRule(exe)
+	return pCfg
+}
+
+// getRule 返回匹配 exe 的配置项的键和配置
+func (c *config) getRule(exe string) (string, *priorityCfg) {
 	if pCfg, ok := c.Processes[exe]; ok {
-		return pCfg
+		return exe, pCfg
 	}
 	name := filepath.Base(exe)
 	if pCfg, ok := c.Processes[name]; ok {
-		return pCfg
+		return name, pCfg
+	}
+	return "", nil
+}
+
+func (c *config) isFocusBoostEnabled() bool {
+	return c.Enabled && c.FocusBoost != nil && c.FocusBoost.Enabled
+}
+
+func (fCfg *focusBoostCfg) isExcluded(exe string) bool {
+	return strSliceContains(fCfg.Exclude, exe) || strSliceContains(fCfg.Exclude, filepath.Base(exe))
+}
+
+func (fCfg *focusBoostCfg) check() error {
+	if fCfg.CPU != nil && (*fCfg.CPU < -20 || *fCfg.CPU > 19) {
+		return fmt.Errorf("invalid cpu %d", *fCfg.CPU)
+	}
+	if fCfg.CPUWeight < 0 || fCfg.CPUWeight > 10000 {
+		return fmt.Errorf("invalid cpuWeight %d", fCfg.CPUWeight)
+	}
+	return nil
+}
+
+func (pCfg *priorityCfg) hasCgroupPolicy() bool {
+	return pCfg.CPUWeight != 0 || pCfg.MemoryHigh != "" || pCfg.MemoryMax != "" || pCfg.IOWeight != 0
+}
+
+func (pCfg *priorityCfg) check() error {
+	if pCfg.CPU != nil && (*pCfg.CPU < -20 || *pCfg.CPU > 19) {
+		return fmt.Errorf("invalid cpu %d", *pCfg.CPU)
+	}
+	if pCfg.CPUWeight < 0 || pCfg.CPUWeight > 10000 {
+		return fmt.Errorf("invalid cpuWeight %d", pCfg.CPUWeight)
+	}
+	if pCfg.IOWeight < 0 || pCfg.IOWeight > 10000 {
+		return fmt.Errorf("invalid ioWeight %d", pCfg.IOWeight)
+	}
+	if pCfg.MemoryHigh != "" {
+		_, err := parseMemoryLimit(pCfg.MemoryHigh)
+		if err != nil {
+			return err
+		}
+	}
+	if pCfg.MemoryMax != "" {
+		_, err := parseMemoryLimit(pCfg.MemoryMax)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// parseMemoryLimit 将 2G 512M 这样的内存大小转换为 cgroup 接受的字节数，max 表示不限制
+func parseMemoryLimit(str string) (string, error) {
+	str = strings.TrimSpace(str)
+	if str == "max" {
+		return str, nil
+	}
+
+	var unit uint64 = 1
+	if len(str) > 0 {
+		switch str[len(str)-1] {
+		case 'K', 'k':
+			unit = 1 << 10
+		case 'M', 'm':
+			unit = 1 << 20
+		case 'G', 'g':
+			unit = 1 << 30
+		}
+		if unit != 1 {
+			str = str[:len(str)-1]
+		}
+	}
+
+	num, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid memory limit %q", str)
+	}
+	return strconv.FormatUint(num*unit, 10), nil
+}
+
+// getPolicyName 将配置项的键转换为策略名，策略的 slice 名由它转义得到
+func getPolicyName(key string) string {
+	name := strings.Trim(key, "/")
+	name = strings.Replace(name, "/", "-", -1)
+	if name == "" || name == "." || name == ".." {
+		name = "_" + name
+	}
+	return name
+}
+
 func loadConfigAux(filename string) (*config, error) {
 	content, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -53,17 +164,33 @@ func loadConfigAux(filename string) (*config, error) {
 	if err != nil {
 		return nil, err
 	}
+	for key, pCfg := range cfg.Processes {
+		if pCfg == nil {
+			return nil, fmt.Errorf("process %q: config is null", key)
+		}
+		err = pCfg.check()
+		if err != nil {
+			return nil, fmt.Errorf("process %q: %v", key, err)
+		}
+	}
+	if cfg.FocusBoost != nil {
+		err = cfg.FocusBoost.check()
+		if err != nil {
+			return nil, fmt.Errorf("focusBoost: %v", err)
+		}
+	}
 	cfg.filename = filename
 	return &cfg, nil
 }
 
+var configPaths = []string{
+	"/etc/deepin/scheduler/config.json",       // 用户
+	"/usr/share/deepin/scheduler/config.json", // 软件包
+}
+
 func loadConfig() (*config, error) {
-	paths := []string{
-		"/etc/deepin/scheduler/config.json",       // 用户
-		"/usr/share/deepin/scheduler/config.json", // 软件包
-	}
 	var lastErr error
-	for _, p := range paths {
+	for _, p := range configPaths {
 		cfg, err := loadConfigAux(p)
 		if err != nil {
 			lastErr = err
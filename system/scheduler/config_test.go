@@ -0,0 +1,304 @@
+package scheduler
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMemoryLimit(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+	}{
+		{"max", "max"},
+		{"1024", "1024"},
+		{"4K", "4096"},
+		{"512M", "536870912"},
+		{"2G", "2147483648"},
+		{" 1g ", "1073741824"},
+	}
+	for _, test := range tests {
+		out, err := parseMemoryLimit(test.in)
+		assert.NoError(t, err, test.in)
+		assert.Equal(t, test.out, out, test.in)
+	}
+
+	for _, in := range []string{"", "G", "-1M", "1T", "abc"} {
+		_, err := parseMemoryLimit(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestGetPolicyName(t *testing.T) {
+	assert.Equal(t, "usr-bin-deepin-anything-tool", getPolicyName("/usr/bin/deepin-anything-tool"))
+	assert.Equal(t, "firefox", getPolicyName("firefox"))
+	assert.Equal(t, "_..", getPolicyName(".."))
+}
+
+func TestConfigGetRule(t *testing.T) {
+	cpu := 19
+	cfg := &config{
+		Processes: map[string]*priorityCfg{
+			"/usr/bin/a": {CPU: &cpu},
+			"b":          {CPUWeight: 50},
+		},
+	}
+	key, pCfg := cfg.getRule("/usr/bin/a")
+	assert.Equal(t, "/usr/bin/a", key)
+	assert.False(t, pCfg.hasCgroupPolicy())
+
+	key, pCfg = cfg.getRule("/opt/b")
+	assert.Equal(t, "b", key)
+	assert.True(t, pCfg.hasCgroupPolicy())
+
+	key, pCfg = cfg.getRule("/usr/bin/c")
+	assert.Equal(t, "", key)
+	assert.Nil(t, pCfg)
+
+	cpu = 20
+	assert.Error(t, cfg.Processes["/usr/bin/a"].check())
+	assert.Error(t, (&priorityCfg{IOWeight: 10001}).check())
+	assert.Error(t, (&priorityCfg{MemoryMax: "1T"}).check())
+	assert.NoError(t, (&priorityCfg{MemoryHigh: "1G", MemoryMax: "max"}).check())
+}
+
+func TestParseProcessCgroup(t *testing.T) {
+	path, err := parseProcessCgroup(strings.NewReader("0::/user.slice/user-1000.slice/session-2.scope\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/user.slice/user-1000.slice/session-2.scope", path)
+
+	_, err = parseProcessCgroup(strings.NewReader("12:memory:/user.slice\n"))
+	assert.Error(t, err)
+}
+
+type fakeSystemdCall struct {
+	method string
+	name   string
+	args   []interface{}
+}
+
+type fakeSystemd struct {
+	units map[string][]systemdProperty
+	calls []fakeSystemdCall
+}
+
+func newFakeSystemd() *fakeSystemd {
+	return &fakeSystemd{units: make(map[string][]systemdProperty)}
+}
+
+func (sd *fakeSystemd) StartTransientUnit(name, mode string, props []systemdProperty) error {
+	sd.calls = append(sd.calls, fakeSystemdCall{"StartTransientUnit", name, []interface{}{mode}})
+	if _, ok := sd.units[name]; ok {
+		return dbus.Error{Name: systemdErrUnitExists}
+	}
+	sd.units[name] = props
+	return nil
+}
+
+func (sd *fakeSystemd) SetUnitProperties(name string, props []systemdProperty) error {
+	sd.calls = append(sd.calls, fakeSystemdCall{"SetUnitProperties", name, nil})
+	sd.units[name] = props
+	return nil
+}
+
+func (sd *fakeSystemd) StopUnit(name string) error {
+	sd.calls = append(sd.calls, fakeSystemdCall{"StopUnit", name, nil})
+	delete(sd.units, name)
+	return nil
+}
+
+func (sd *fakeSystemd) AttachProcessesToUnit(name, subcgroup string, pids []uint32) error {
+	sd.calls = append(sd.calls, fakeSystemdCall{"AttachProcessesToUnit", name, []interface{}{subcgroup, pids}})
+	return nil
+}
+
+func getSystemdProperty(props []systemdProperty, name string) interface{} {
+	for _, prop := range props {
+		if prop.Name == name {
+			return prop.Value.Value()
+		}
+	}
+	return nil
+}
+
+func TestEscapeUnitName(t *testing.T) {
+	assert.Equal(t, `usr\x2dbin\x2ddeepin\x2danything\x2dtool`, escapeUnitName("usr-bin-deepin-anything-tool"))
+	assert.Equal(t, "_focus", escapeUnitName("_focus"))
+	assert.Equal(t, `\x2efoo.bar`, escapeUnitName(".foo.bar"))
+	assert.Equal(t, `deepin_scheduler-usr\x2dbin\x2da.slice`, getPolicySliceName("usr-bin-a"))
+	assert.Equal(t, `deepin_scheduler-_focus-1234.scope`, getPolicyScopeName(focusPolicyName, 1234))
+	assert.Equal(t, `/deepin_scheduler.slice/deepin_scheduler-firefox.slice`, getPolicyCgroupPath("firefox"))
+}
+
+func TestGetCgroupOrigin(t *testing.T) {
+	origin, ok := getCgroupOrigin("/user.slice/user-1000.slice/session-2.scope")
+	assert.True(t, ok)
+	assert.Equal(t, cgroupOrigin{unit: "session-2.scope"}, origin)
+
+	origin, ok = getCgroupOrigin("/user.slice/user-1000.slice/user@1000.service/app.slice/app-firefox.scope")
+	assert.True(t, ok)
+	assert.Equal(t, cgroupOrigin{unit: "user@1000.service", subcgroup: "/app.slice/app-firefox.scope"}, origin)
+
+	origin, ok = getCgroupOrigin("/system.slice/deepin-anything-tool.service")
+	assert.True(t, ok)
+	assert.Equal(t, cgroupOrigin{unit: "deepin-anything-tool.service"}, origin)
+
+	_, ok = getCgroupOrigin("/")
+	assert.False(t, ok)
+	_, ok = getCgroupOrigin("/user.slice")
+	assert.False(t, ok)
+}
+
+func TestCgroupManagerSetup(t *testing.T) {
+	mount, err := ioutil.TempDir("", "scheduler-cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(mount)
+
+	writeFile := func(path, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	writeFile(filepath.Join(mount, "cgroup.controllers"), "cpuset cpu io memory pids")
+	sd := newFakeSystemd()
+	cm := newCgroupManager(mount, sd)
+
+	cfg := &config{
+		Processes: map[string]*priorityCfg{
+			"/usr/bin/a": {CPUWeight: 50, MemoryHigh: "1G"},
+			"b":          {},
+		},
+	}
+	require.NoError(t, cm.setup(cfg))
+
+	assert.Contains(t, sd.units, rootSliceName)
+	slice := getPolicySliceName("usr-bin-a")
+	props, ok := sd.units[slice]
+	require.True(t, ok)
+	assert.Equal(t, uint64(50), getSystemdProperty(props, "CPUWeight"))
+	assert.Equal(t, uint64(1073741824), getSystemdProperty(props, "MemoryHigh"))
+	assert.Equal(t, uint64(math.MaxUint64), getSystemdProperty(props, "MemoryMax"))
+	assert.Equal(t, uint64(math.MaxUint64), getSystemdProperty(props, "IOWeight"))
+	assert.Len(t, sd.units, 2)
+	assert.Equal(t, "usr-bin-a", cm.getPolicy(cfg, "/usr/bin/a"))
+	assert.Equal(t, "", cm.getPolicy(cfg, "/usr/bin/b"))
+
+	// 再次加载配置时更新已经存在的 slice
+	cfg.Processes["/usr/bin/a"].CPUWeight = 60
+	require.NoError(t, cm.setup(cfg))
+	assert.Equal(t, uint64(60), getSystemdProperty(sd.units[slice], "CPUWeight"))
+	assert.Equal(t, fakeSystemdCall{"SetUnitProperties", slice, nil}, sd.calls[len(sd.calls)-1])
+
+	// 开启 focusBoost 后创建活动进程的策略
+	cfg.Enabled = true
+	cfg.FocusBoost = &focusBoostCfg{Enabled: true, CPUWeight: 400}
+	require.NoError(t, cm.setup(cfg))
+	assert.Equal(t, uint64(400), getSystemdProperty(sd.units[getPolicySliceName(focusPolicyName)], "CPUWeight"))
+	assert.Contains(t, cm.listPolicyPids(), focusPolicyName)
+
+	// 模拟 movePid 创建的 scope
+	scopeName := getPolicyScopeName("usr-bin-a", 100)
+	scopeDir := filepath.Join(mount, getPolicyCgroupPath("usr-bin-a"), scopeName)
+	writeFile(filepath.Join(scopeDir, "cgroup.procs"), "100\n101\n")
+	cm.scopes[scopeName] = &cgroupScope{
+		policy: "usr-bin-a",
+		origin: cgroupOrigin{unit: "user@1000.service", subcgroup: "/app.slice/a.scope"},
+	}
+	assert.Equal(t, []uint32{100, 101}, cm.listPolicyPids()["usr-bin-a"])
+
+	// 删除配置后，进程被移回原来的单元，策略的 slice 被停止
+	sd.calls = nil
+	cm.sd = &attachingSystemd{fakeSystemd: sd, mount: mount}
+	cfg.FocusBoost = nil
+	delete(cfg.Processes, "/usr/bin/a")
+	require.NoError(t, cm.setup(cfg))
+	assert.Contains(t, sd.calls, fakeSystemdCall{"AttachProcessesToUnit", "user@1000.service",
+		[]interface{}{"/app.slice/a.scope", []uint32{100, 101}}})
+	assert.Contains(t, sd.calls, fakeSystemdCall{"StopUnit", slice, nil})
+	assert.Contains(t, sd.calls, fakeSystemdCall{"StopUnit", getPolicySliceName(focusPolicyName), nil})
+	assert.Empty(t, cm.scopes)
+	assert.Empty(t, cm.listPolicyPids())
+}
+
+// attachingSystemd 移动进程时删除进程所在的 scope，模拟 systemd 回收空的 scope
+type attachingSystemd struct {
+	*fakeSystemd
+	mount string
+}
+
+func (sd *attachingSystemd) AttachProcessesToUnit(name, subcgroup string, pids []uint32) error {
+	dirs, _ := filepath.Glob(filepath.Join(sd.mount, rootSliceName, "*", "*.scope"))
+	for _, dir := range dirs {
+		_ = os.RemoveAll(dir)
+	}
+	return sd.fakeSystemd.AttachProcessesToUnit(name, subcgroup, pids)
+}
+
+func TestCgroupManagerRemovePolicyKeepsUnknownPids(t *testing.T) {
+	mount, err := ioutil.TempDir("", "scheduler-cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(mount)
+
+	sd := newFakeSystemd()
+	cm := newCgroupManager(mount, sd)
+	// 模块重启前移动的进程，不知道原来所在的单元
+	scopeDir := filepath.Join(mount, getPolicyCgroupPath("c"), getPolicyScopeName("c", 200))
+	require.NoError(t, os.MkdirAll(scopeDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(scopeDir, "cgroup.procs"), []byte("200\n"), 0644))
+
+	cm.removePolicy("c")
+	slice := getPolicySliceName("c")
+	require.Len(t, sd.calls, 1)
+	assert.Equal(t, fakeSystemdCall{"SetUnitProperties", slice, nil}, sd.calls[0])
+	assert.Equal(t, uint64(math.MaxUint64), getSystemdProperty(sd.units[slice], "CPUWeight"))
+}
+
+func TestFocusBoostConfig(t *testing.T) {
+	cpu := -5
+	cfg := &config{
+		Enabled: true,
+		FocusBoost: &focusBoostCfg{
+			Enabled: true,
+			CPU:     &cpu,
+			Exclude: []string{"/usr/bin/a", "b"},
+		},
+	}
+	assert.True(t, cfg.isFocusBoostEnabled())
+	assert.True(t, cfg.FocusBoost.isExcluded("/usr/bin/a"))
+	assert.True(t, cfg.FocusBoost.isExcluded("/opt/b"))
+	assert.False(t, cfg.FocusBoost.isExcluded("/usr/bin/c"))
+	assert.NoError(t, cfg.FocusBoost.check())
+
+	cfg.Enabled = false
+	assert.False(t, cfg.isFocusBoostEnabled())
+	cfg.FocusBoost = nil
+	assert.False(t, cfg.isFocusBoostEnabled())
+
+	cpu = -21
+	assert.Error(t, (&focusBoostCfg{CPU: &cpu}).check())
+	assert.Error(t, (&focusBoostCfg{CPUWeight: 10001}).check())
+}
+
+func TestGetCpuPriority(t *testing.T) {
+	pid := os.Getpid()
+	nice, err := getCpuPriority(pid)
+	require.NoError(t, err)
+
+	// 优先级已经不低于目标值时不修改，但仍然记录原来的值
+	nices, err := boostProcessCpuPriority(pid, nice)
+	require.NoError(t, err)
+	assert.Equal(t, nice, nices[pid])
+	require.NoError(t, restoreProcessCpuPriority(pid, nices))
+
+	uid, err := getProcessUid(uint32(pid))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(os.Getuid()), uid)
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// 活动进程所在的 cgroup 策略名，配置项的策略名不会以 _ 开头，不会冲突
+const focusPolicyName = "_focus"
+
+// focusState 记录被提升优先级的活动进程，用于焦点离开后恢复
+type focusState struct {
+	pid   uint32
+	exe   string
+	nices map[int]int // 线程 id -> 提升前的 nice 值
+	moved bool        // 是否被移动到了 focus 策略的 slice 中
+}
+
+// setFocusedProcess 恢复之前的活动进程的优先级，并提升新的活动进程的优先级，pid 为 0 表示没有活动进程
+func (s *Scheduler) setFocusedProcess(cfg *config, pid uint32) error {
+	s.focusMu.Lock()
+	if s.focus != nil && s.focus.pid == pid {
+		s.focusMu.Unlock()
+		return nil
+	}
+	old := s.restoreFocus()
+	var err error
+	if pid != 0 && cfg != nil && cfg.isFocusBoostEnabled() {
+		s.focus, err = s.boostFocus(cfg, pid)
+	}
+	s.focusMu.Unlock()
+
+	if old != nil {
+		// 恢复配置中的优先级
+		s.reapplyPriority(old)
+	}
+	return err
+}
+
+// clearFocus 恢复活动进程的优先级，返回之前的活动进程 pid
+func (s *Scheduler) clearFocus() uint32 {
+	s.focusMu.Lock()
+	old := s.restoreFocus()
+	s.focusMu.Unlock()
+
+	if old == nil {
+		return 0
+	}
+	s.reapplyPriority(old)
+	return old.pid
+}
+
+// handleFocusExit 活动进程退出后，将它留在 focus 策略中的子进程移回去
+func (s *Scheduler) handleFocusExit(exitPids []uint32) {
+	s.focusMu.Lock()
+	defer s.focusMu.Unlock()
+	if s.focus == nil || !u32SliceContains(exitPids, s.focus.pid) {
+		return
+	}
+	logger.Debug("focused process exited:", s.focus.pid)
+	s.restoreFocus()
+}
+
+func (s *Scheduler) getFocusedPid() uint32 {
+	s.focusMu.Lock()
+	defer s.focusMu.Unlock()
+	if s.focus == nil {
+		return 0
+	}
+	return s.focus.pid
+}
+
+func (s *Scheduler) reapplyPriority(old *focusState) {
+	cfg := s.getConfig()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	exe, err := getProcessExe(int(old.pid))
+	if err != nil || exe != old.exe {
+		return
+	}
+	s.setProcessPriority(cfg, int(old.pid))
+}
+
+// boostFocus 提升进程的优先级，调用者需要持有 focusMu
+func (s *Scheduler) boostFocus(cfg *config, pid uint32) (*focusState, error) {
+	fCfg := cfg.FocusBoost
+	exe, err := getProcessExe(int(pid))
+	if err != nil {
+		return nil, err
+	}
+	if fCfg.isExcluded(exe) {
+		logger.Debugf("focused process %d (exe: %v) is excluded", pid, exe)
+		return nil, nil
+	}
+
+	state := &focusState{
+		pid: pid,
+		exe: exe,
+	}
+	if fCfg.CPU != nil {
+		state.nices, err = boostProcessCpuPriority(int(pid), *fCfg.CPU)
+		if err != nil {
+			logger.Warningf("boost priority for process %d (exe: %v) failed: %v", pid, exe, err)
+		}
+	}
+
+	// 已经在配置的策略中的进程不移动，以免丢失配置的资源限制
+	if fCfg.CPUWeight != 0 && cfg.CgroupEnabled && s.isCgroupReady() &&
+		s.cgroup.getPidPolicy(pid) == "" {
+		err = s.cgroup.movePid(focusPolicyName, pid)
+		if err != nil {
+			logger.Warningf("move process %d (exe: %v) to cgroup %s failed: %v", pid, exe, focusPolicyName, err)
+		} else {
+			state.moved = true
+		}
+	}
+	logger.Debugf("boost focused process %d (exe: %v)", pid, exe)
+	return state, nil
+}
+
+// restoreFocus 恢复活动进程原来的 nice 值和 cgroup，调用者需要持有 focusMu
+func (s *Scheduler) restoreFocus() *focusState {
+	state := s.focus
+	if state == nil {
+		return nil
+	}
+	s.focus = nil
+
+	if state.moved {
+		s.cgroup.movePidsBack(focusPolicyName)
+	}
+
+	// 进程可能已经退出，或者 pid 已被其他进程复用
+	exe, err := getProcessExe(int(state.pid))
+	if err != nil || exe != state.exe {
+		return state
+	}
+	if len(state.nices) > 0 {
+		err = restoreProcessCpuPriority(int(state.pid), state.nices)
+		if err != nil {
+			logger.Warningf("restore priority for process %d (exe: %v) failed: %v", state.pid, state.exe, err)
+		}
+	}
+	return state
+}
+
+// boostProcessCpuPriority 将进程中优先级低于 priority 的线程的 nice 值设为 priority，返回所有线程原来的 nice 值
+func boostProcessCpuPriority(pid int, priority int) (map[int]int, error) {
+	tasks, err := getProcessTasks(pid)
+	if err != nil {
+		return nil, err
+	}
+	nices := make(map[int]int)
+	for _, taskId := range tasks {
+		nice, err := getCpuPriority(taskId)
+		if err != nil {
+			continue
+		}
+		nices[taskId] = nice
+		if nice <= priority {
+			continue
+		}
+		err = setCpuPriority(taskId, priority)
+		if err != nil {
+			return nices, err
+		}
+	}
+	return nices, nil
+}
+
+// restoreProcessCpuPriority 恢复线程的 nice 值，提升之后创建的线程继承了提升后的值，恢复为主线程原来的值
+func restoreProcessCpuPriority(pid int, nices map[int]int) error {
+	tasks, err := getProcessTasks(pid)
+	if err != nil {
+		return err
+	}
+	mainNice, hasMain := nices[pid]
+	for _, taskId := range tasks {
+		nice, ok := nices[taskId]
+		if !ok {
+			if !hasMain {
+				continue
+			}
+			nice = mainNice
+		}
+		err = setCpuPriority(taskId, nice)
+		if err != nil && !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 获取线程的 nice 值
+func getCpuPriority(taskId int) (int, error) {
+	// linux 的 getpriority 系统调用返回 20 - nice
+	prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, taskId)
+	if err != nil {
+		return 0, err
+	}
+	return 20 - prio, nil
+}
+
+// 获取进程所属的用户
+func getProcessUid(pid uint32) (uint32, error) {
+	fileInfo, err := os.Stat("/proc/" + strconv.Itoa(int(pid)))
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("failed to get owner of process %d", pid)
+	}
+	return stat.Uid, nil
+}
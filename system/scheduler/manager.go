@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/godbus/dbus"
+	login1 "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.login1"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+)
+
+const (
+	dbusServiceName = "com.deepin.system.Scheduler"
+	dbusPath        = "/com/deepin/system/Scheduler"
+	dbusInterface   = dbusServiceName
+)
+
+//go:generate dbusutil-gen em -type Scheduler
+
+// 允许调用 SetFocusedProcess 的程序，dock 模块运行在 dde-session-daemon 中
+var focusReporterExes = []string{
+	"/usr/lib/deepin-daemon/dde-session-daemon",
+}
+
+type Scheduler struct {
+	service      *dbusutil.Service
+	loginManager login1.Manager
+
+	mu          sync.Mutex
+	cfg         *config
+	cgroupReady bool
+	pm          *procMonitor
+	cgroup      *cgroupManager
+	watcher     *fsnotify.Watcher
+	ticker      *time.Ticker
+	quit        chan struct{}
+
+	focusMu sync.Mutex
+	focus   *focusState
+}
+
+func newScheduler(service *dbusutil.Service) *Scheduler {
+	return &Scheduler{
+		service:      service,
+		loginManager: login1.NewManager(service.Conn()),
+		cgroup:       newCgroupManager(cgroup2MountPoint, newSystemdDBus(service.Conn())),
+	}
+}
+
+func (*Scheduler) GetInterfaceName() string {
+	return dbusInterface
+}
+
+// ListPolicyProcesses 以 JSON 格式返回每个 cgroup 策略及其中的进程 id
+func (s *Scheduler) ListPolicyProcesses() (policies string, busErr *dbus.Error) {
+	result := make(map[string][]uint32)
+	if s.isCgroupReady() {
+		result = s.cgroup.listPolicyPids()
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetFocusedProcess 由活动会话中的 dock 调用，报告活动窗口所属的进程，pid 为 0 表示没有活动窗口
+func (s *Scheduler) SetFocusedProcess(sender dbus.Sender, pid uint32) *dbus.Error {
+	err := s.checkFocusReporter(sender, pid)
+	if err != nil {
+		logger.Warning(err)
+		return dbusutil.ToError(err)
+	}
+
+	err = s.setFocusedProcess(s.getConfig(), pid)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	return nil
+}
+
+// checkFocusReporter 检查调用者是活动会话中的 dde-session-daemon，并且 pid 属于同一个会话
+func (s *Scheduler) checkFocusReporter(sender dbus.Sender, pid uint32) error {
+	senderPid, err := s.service.GetConnPID(string(sender))
+	if err != nil {
+		return err
+	}
+	exe, err := getProcessExe(int(senderPid))
+	if err != nil {
+		return err
+	}
+	// 软件包升级后正在运行的程序的 exe 会带有 (deleted) 后缀
+	exe = strings.TrimSuffix(exe, " (deleted)")
+	if !strSliceContains(focusReporterExes, exe) {
+		return fmt.Errorf("sender %s (exe: %s) is not allowed to set focused process", sender, exe)
+	}
+
+	senderSession, err := s.loginManager.GetSessionByPID(0, senderPid)
+	if err != nil {
+		return err
+	}
+	session, err := login1.NewSession(s.service.Conn(), senderSession)
+	if err != nil {
+		return err
+	}
+	active, err := session.Active().Get(0)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return fmt.Errorf("session %s of sender %s is not active", senderSession, sender)
+	}
+
+	if pid == 0 {
+		return nil
+	}
+	// 只允许提升调用者所在会话中调用者自己的进程
+	uid, err := s.service.GetConnUID(string(sender))
+	if err != nil {
+		return err
+	}
+	owner, err := getProcessUid(pid)
+	if err != nil {
+		return err
+	}
+	if owner != uid {
+		return fmt.Errorf("process %d does not belong to uid %d", pid, uid)
+	}
+	pidSession, err := s.loginManager.GetSessionByPID(0, pid)
+	if err != nil {
+		return fmt.Errorf("get session of process %d failed: %v", pid, err)
+	}
+	if pidSession != senderSession {
+		return fmt.Errorf("process %d is not in session %s", pid, senderSession)
+	}
+	return nil
+}
@@ -30,6 +30,7 @@ func init() {
 
 type Module struct {
 	*loader.ModuleBase
+	scheduler *Scheduler
 }
 
 func (m *Module) GetDependencies() []string {
@@ -37,11 +38,48 @@ func (m *Module) GetDependencies() []string {
 }
 
 func (m *Module) Start() error {
-	err := start()
-	return err
+	if m.scheduler != nil {
+		return nil
+	}
+
+	service := loader.GetService()
+	s := newScheduler(service)
+	err := s.start()
+	if err != nil {
+		return err
+	}
+	m.scheduler = s
+
+	err = service.Export(dbusPath, s)
+	if err != nil {
+		return err
+	}
+
+	err = service.RequestName(dbusServiceName)
+	if err != nil {
+		return err
+	}
+	return nil
 }
 
 func (m *Module) Stop() error {
+	if m.scheduler == nil {
+		return nil
+	}
+
+	service := loader.GetService()
+	err := service.StopExport(m.scheduler)
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	err = service.ReleaseName(dbusServiceName)
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	m.scheduler.stop()
+	m.scheduler = nil
 	return nil
 }
 
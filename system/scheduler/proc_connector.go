@@ -84,24 +84,33 @@ func (pm *procMonitor) listenProcEvents() error {
 		return err
 	}
 
+	pm.mu.Lock()
+	if pm.isStopped() {
+		pm.mu.Unlock()
+		return conn.Close()
+	}
+	pm.conn = conn
+	pm.mu.Unlock()
+
 	err = sendRequestMsg(conn, uint32(C.PROC_CN_MCAST_LISTEN))
 	if err != nil {
 		return err
 	}
 
-	defer func() {
-		err := sendRequestMsg(conn, uint32(C.PROC_CN_MCAST_IGNORE))
-		if err != nil {
-			logger.Warning(err)
-		}
-	}()
-
 	for {
 		// 循环接收进程事件
 		msgs, err := conn.Receive()
 		if err != nil {
+			// stop 关闭连接后退出
+			if pm.isStopped() {
+				return nil
+			}
 			logger.Warning("connector conn receive failed, err:", err)
-			time.Sleep(10 * time.Second)
+			select {
+			case <-time.After(10 * time.Second):
+			case <-pm.quit:
+				return nil
+			}
 			continue
 		}
 		for _, msg := range msgs {
@@ -118,14 +127,51 @@ type procMonitor struct {
 	mu           sync.Mutex
 	exitPids     []uint32
 	execPids     []uint32
+	forkEvents   []ForkProcEvent
 	timer        *time.Timer
 	timerStarted bool
 	timerCb      func()
+	conn         *netlink.Conn
+	quit         chan struct{}
 }
 
 func newProcMonitor(cb func()) *procMonitor {
 	return &procMonitor{
 		timerCb: cb,
+		quit:    make(chan struct{}),
+	}
+}
+
+func (pm *procMonitor) isStopped() bool {
+	select {
+	case <-pm.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop 停止监控进程事件，关闭连接使 listenProcEvents 返回
+func (pm *procMonitor) stop() {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if pm.isStopped() {
+		return
+	}
+	close(pm.quit)
+	if pm.timer != nil {
+		pm.timer.Stop()
+	}
+	if pm.conn != nil {
+		err := sendRequestMsg(pm.conn, uint32(C.PROC_CN_MCAST_IGNORE))
+		if err != nil {
+			logger.Warning(err)
+		}
+		err = pm.conn.Close()
+		if err != nil {
+			logger.Warning(err)
+		}
+		pm.conn = nil
 	}
 }
 
@@ -152,6 +198,9 @@ func (pm *procMonitor) onTimerExpired() {
 	pm.timerStarted = false
 	pm.mu.Unlock()
 
+	if pm.isStopped() {
+		return
+	}
 	if pm.timerCb != nil {
 		pm.timerCb()
 	}
@@ -183,6 +232,20 @@ func u32SliceContains(slice []uint32, val uint32) bool {
 	return false
 }
 
+// 处理 fork 事件，只记录新进程，不记录新线程
+func (pm *procMonitor) handleForkEvent(ev *ForkProcEvent) {
+	if ev.ChildPid != ev.ChildTGid {
+		return
+	}
+
+	pm.mu.Lock()
+	if len(pm.forkEvents) <= eventsSizeLimit {
+		pm.forkEvents = append(pm.forkEvents, *ev)
+		pm.resetTimer()
+	}
+	pm.mu.Unlock()
+}
+
 // 处理 exit 退出事件
 func (pm *procMonitor) handleExitEvent(ev *ExitProcEvent) {
 	if ev.ProcessPid != ev.ProcessTgid {
@@ -198,8 +261,8 @@ func (pm *procMonitor) handleExitEvent(ev *ExitProcEvent) {
 	pm.mu.Unlock()
 }
 
-// 获取活着的进程列表
-func (pm *procMonitor) getAlivePids() []uint32 {
+// 获取活着的进程列表，以及活着的 fork 出的子进程事件，exitPids 为期间退出的进程
+func (pm *procMonitor) getAliveEvents() (pids []uint32, forks []ForkProcEvent, exitPids []uint32) {
 	logger.Debug("proc monitor handle events")
 
 	pm.mu.Lock()
@@ -208,26 +271,26 @@ func (pm *procMonitor) getAlivePids() []uint32 {
 	// logger.Debug("proc monitor execPids:", pm.execPids)
 	// logger.Debug("proc monitor exitPids:", pm.exitPids)
 
-	var pids []uint32
 	for _, execPid := range pm.execPids {
 		// 取出掉已经退出的
-		exited := false
-		for _, exitPid := range pm.exitPids {
-			if execPid == exitPid {
-				exited = true
-				break
-			}
-		}
-		if !exited {
+		if !u32SliceContains(pm.exitPids, execPid) {
 			pids = append(pids, execPid)
 		}
 	}
+	for _, ev := range pm.forkEvents {
+		if !u32SliceContains(pm.exitPids, ev.ChildPid) {
+			forks = append(forks, ev)
+		}
+	}
+	exitPids = make([]uint32, len(pm.exitPids))
+	copy(exitPids, pm.exitPids)
 	logger.Debug("proc monitor need handle pids:", pids)
 
 	// 清空，但保持容量
 	pm.execPids = pm.execPids[0:0:cap(pm.execPids)]
 	pm.exitPids = pm.exitPids[0:0:cap(pm.exitPids)]
-	return pids
+	pm.forkEvents = pm.forkEvents[0:0:cap(pm.forkEvents)]
+	return
 }
 
 // 解析含有进程事件的消息
@@ -248,6 +311,17 @@ func (pm *procMonitor) parseMsg(msg netlink.Message) (err error) {
 		return
 	}
 	switch header.What {
+	// proc fork
+	case C.PROC_EVENT_FORK:
+		event := &ForkProcEvent{}
+		err = binary.Read(bytBuf, binary.LittleEndian, event)
+		if err != nil {
+			logger.Warningf("read ForkProcEvent failed, err: %v", err)
+			return
+		}
+		logger.Debugf("recv message: proc fork %+v", event)
+		pm.handleForkEvent(event)
+
 	// proc exec
 	case C.PROC_EVENT_EXEC:
 		event := &ExecProcEvent{}
@@ -24,11 +24,12 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/linuxdeepin/go-lib/procfs"
 )
 
 // 遍历所有进程, 设置优先级
-func updateProcessesPriority(cfg *config) error {
+func (s *Scheduler) updateProcessesPriority(cfg *config) error {
 	fileInfos, err := readDir("/proc")
 	if err != nil {
 		return err
@@ -41,27 +42,61 @@ func updateProcessesPriority(cfg *config) error {
 			continue
 		}
 
-		setProcessPriority(cfg, pid)
+		s.setProcessPriority(cfg, pid)
 	}
 	return nil
 }
 
-// 设置进程优先级
-func setProcessPriority(cfg *config, pid int) {
+// 设置进程优先级，并将进程移动到对应策略的 slice 中，返回进程是否匹配了配置
+func (s *Scheduler) setProcessPriority(cfg *config, pid int) bool {
 	exe, err := getProcessExe(pid)
 	if err != nil {
 		// 有些无法获取 exe, 比如内核线程 kworker/2:1-events
-		return
+		return false
 	}
 	pCfg := cfg.getPriority(exe)
 	if pCfg == nil {
 		// 无配置
+		return false
+	}
+	// 仅在有配置时设置优先级，活动进程的优先级在焦点离开后恢复
+	if pCfg.CPU != nil && uint32(pid) != s.getFocusedPid() {
+		err = setProcessCpuPriority(pid, *pCfg.CPU)
+		if err != nil {
+			logger.Warningf("set priority for process %d (exe: %v) failed: %v", pid, exe, err)
+		}
+	}
+
+	if cfg.CgroupEnabled && s.isCgroupReady() {
+		policy := s.cgroup.getPolicy(cfg, exe)
+		if policy != "" {
+			err = s.cgroup.movePid(policy, uint32(pid))
+			if err != nil {
+				logger.Warningf("move process %d (exe: %v) to cgroup %s failed: %v", pid, exe, policy, err)
+			}
+		}
+	}
+	return true
+}
+
+// handleForkEvents 处理 fork 出的子进程。子进程会自动继承父进程的 scope，
+// 但父进程在 fork 之后才被移动到策略的 slice 中时，子进程需要单独移动。
+func (s *Scheduler) handleForkEvents(cfg *config, forks []ForkProcEvent) {
+	if !cfg.CgroupEnabled || !s.isCgroupReady() {
 		return
 	}
-	// 仅在有配置时设置优先级
-	err = setProcessCpuPriority(pid, pCfg.CPU)
-	if err != nil {
-		logger.Warningf("set priority for process %d (exe: %v) failed: %v", pid, exe, err)
+	for _, ev := range forks {
+		if s.cgroup.getPidPolicy(ev.ChildPid) != "" {
+			continue
+		}
+		policy := s.cgroup.getPidPolicy(ev.ParentPid)
+		if policy == "" {
+			continue
+		}
+		err := s.cgroup.movePid(policy, ev.ChildPid)
+		if err != nil {
+			logger.Warningf("move child process %d of %d to cgroup %s failed: %v", ev.ChildPid, ev.ParentPid, policy, err)
+		}
 	}
 }
 
@@ -131,49 +166,240 @@ const handleProcEventsIntervalSec = 5
 const updateAllIntervalSec = 90
 
 // 模块入口
-func start() error {
+func (s *Scheduler) start() error {
+	s.quit = make(chan struct{})
+
+	err := s.startConfigWatcher()
+	if err != nil {
+		logger.Warning("watch config failed:", err)
+	}
+
 	cfg, err := loadConfig()
 	if err != nil {
 		logger.Warning("load config failed:", err)
 		return nil
 	}
 	logger.Debug("load config file:", cfg.filename)
+	s.applyConfig(cfg)
+
+	ticker := time.NewTicker(time.Second * updateAllIntervalSec)
+	s.ticker = ticker
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.quit:
+				return
+			}
+			cfg := s.getConfig()
+			if cfg == nil || !cfg.Enabled {
+				continue
+			}
+			err := s.updateProcessesPriority(cfg)
+			if err != nil {
+				logger.Warning("updateProcessesPriority err:", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// stop 停止定时器、进程事件监控和配置文件监听，恢复活动进程，并将进程移出策略的 slice
+func (s *Scheduler) stop() {
+	close(s.quit)
+	if s.ticker != nil {
+		s.ticker.Stop()
+		s.ticker = nil
+	}
+	if s.watcher != nil {
+		err := s.watcher.Close()
+		if err != nil {
+			logger.Warning(err)
+		}
+		s.watcher = nil
+	}
+
+	s.mu.Lock()
+	pm := s.pm
+	s.pm = nil
+	s.mu.Unlock()
+	if pm != nil {
+		pm.stop()
+	}
+
+	s.clearFocus()
+	if s.isCgroupReady() {
+		s.cgroup.clear()
+		s.setCgroupReady(false)
+	}
+}
+
+func (s *Scheduler) isStopped() bool {
+	select {
+	case <-s.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Scheduler) isCgroupReady() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cgroupReady
+}
+
+func (s *Scheduler) setCgroupReady(ready bool) {
+	s.mu.Lock()
+	s.cgroupReady = ready
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) getConfig() *config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+// applyConfig 应用新的配置，初次加载和配置文件变化时调用
+func (s *Scheduler) applyConfig(cfg *config) {
+	// 先恢复活动进程，配置应用后按新的配置重新提升
+	focusedPid := s.clearFocus()
+
+	s.mu.Lock()
+	s.cfg = cfg
+	s.mu.Unlock()
+
+	if !cfg.Enabled || !cfg.CgroupEnabled {
+		if s.isCgroupReady() {
+			// 热加载时关闭了 cgroup 策略，将进程移回原来的单元
+			s.cgroup.clear()
+			s.setCgroupReady(false)
+		}
+	}
 
 	if !cfg.Enabled {
 		logger.Info("scheduler module is disabled")
-		return nil
+		return
+	}
+
+	if cfg.CgroupEnabled {
+		err := s.cgroup.setup(cfg)
+		if err != nil {
+			logger.Warning("setup cgroup failed:", err)
+		}
+		s.setCgroupReady(err == nil)
 	}
 
-	var pm *procMonitor
 	if cfg.ProcMonitorEnabled {
-		pm = newProcMonitor(func() {
-			// 定时器回调函数
-			pids := pm.getAlivePids()
-			for _, pid := range pids {
-				setProcessPriority(cfg, int(pid))
-			}
-		})
-		go func() {
-			err := pm.listenProcEvents()
-			if err != nil {
-				logger.Warning(err)
-			}
-		}()
+		s.startProcMonitor()
 	}
 
-	err = updateProcessesPriority(cfg)
+	err := s.updateProcessesPriority(cfg)
 	if err != nil {
 		logger.Warning("updateProcessesPriority err:", err)
 	}
-	ticker := time.NewTicker(time.Second * updateAllIntervalSec)
+
+	if focusedPid != 0 {
+		err = s.setFocusedProcess(cfg, focusedPid)
+		if err != nil {
+			logger.Warning("boost focused process failed:", err)
+		}
+	}
+}
+
+func (s *Scheduler) startProcMonitor() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pm != nil {
+		return
+	}
+
+	var pm *procMonitor
+	pm = newProcMonitor(func() {
+		// 定时器回调函数
+		pids, forks, exitPids := pm.getAliveEvents()
+		cfg := s.getConfig()
+		if cfg == nil || !cfg.Enabled || !cfg.ProcMonitorEnabled {
+			return
+		}
+		for _, pid := range pids {
+			s.setProcessPriority(cfg, int(pid))
+		}
+		s.handleForkEvents(cfg, forks)
+		s.handleFocusExit(exitPids)
+		s.cgroup.pruneScopes()
+	})
+	s.pm = pm
 	go func() {
-		for range ticker.C {
-			err := updateProcessesPriority(cfg)
-			if err != nil {
-				logger.Warning("updateProcessesPriority err:", err)
-			}
+		err := pm.listenProcEvents()
+		if err != nil {
+			logger.Warning(err)
 		}
 	}()
+}
+
+// startConfigWatcher 监听配置文件所在的目录，配置文件变化时重新加载
+func (s *Scheduler) startConfigWatcher() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, p := range configPaths {
+		dir := filepath.Dir(p)
+		err = watcher.Add(dir)
+		if err != nil {
+			logger.Debugf("watch %s failed: %v", dir, err)
+		}
+	}
+	s.watcher = watcher
 
+	go func() {
+		var timer *time.Timer
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !strSliceContains(configPaths, ev.Name) {
+					continue
+				}
+				logger.Debug("config file changed:", ev)
+				// 编辑器保存文件时可能产生多个事件，延迟加载
+				if timer == nil {
+					timer = time.AfterFunc(configReloadDelay, s.reloadConfig)
+				} else {
+					timer.Reset(configReloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warning("config watcher error:", err)
+			}
+		}
+	}()
 	return nil
 }
+
+const configReloadDelay = time.Second
+
+func (s *Scheduler) reloadConfig() {
+	if s.isStopped() {
+		return
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Warning("reload config failed:", err)
+		return
+	}
+	logger.Info("reload config file:", cfg.filename)
+	s.applyConfig(cfg)
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerStartStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduler")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mount := filepath.Join(dir, "cgroup")
+	require.NoError(t, os.MkdirAll(mount, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(mount, "cgroup.controllers"), []byte("cpu io memory"), 0644))
+
+	cfgFile := filepath.Join(dir, "config.json")
+	require.NoError(t, ioutil.WriteFile(cfgFile, []byte(`{
+		"enabled": true,
+		"cgroupEnabled": true,
+		"procMonitorEnabled": true,
+		"processes": {"/nonexistent/a": {"cpuWeight": 50}}
+	}`), 0644))
+	oldConfigPaths := configPaths
+	configPaths = []string{cfgFile}
+	defer func() {
+		configPaths = oldConfigPaths
+	}()
+
+	sd := newFakeSystemd()
+	s := &Scheduler{cgroup: newCgroupManager(mount, sd)}
+	require.NoError(t, s.start())
+	assert.True(t, s.isCgroupReady())
+	assert.Contains(t, sd.units, rootSliceName)
+	assert.Contains(t, sd.units, getPolicySliceName("nonexistent-a"))
+	assert.NotNil(t, s.pm)
+	assert.NotNil(t, s.watcher)
+	assert.NotNil(t, s.ticker)
+
+	// 停止后删除所有 slice，不再监控进程和配置文件
+	s.stop()
+	assert.False(t, s.isCgroupReady())
+	assert.Empty(t, sd.units)
+	assert.Nil(t, s.pm)
+	assert.Nil(t, s.watcher)
+	assert.Nil(t, s.ticker)
+	assert.True(t, s.isStopped())
+
+	// 停止后配置文件变化不会重新应用配置
+	s.reloadConfig()
+	assert.Nil(t, s.pm)
+	assert.Empty(t, sd.units)
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2019 ~ 2022 Uniontech Software Technology Co.,Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"github.com/godbus/dbus"
+)
+
+const (
+	systemdDBusServiceName = "org.freedesktop.systemd1"
+	systemdDBusPath        = "/org/freedesktop/systemd1"
+	systemdDBusInterface   = "org.freedesktop.systemd1.Manager"
+
+	systemdErrUnitExists = "org.freedesktop.systemd1.UnitExists"
+)
+
+// systemdProperty 对应 systemd 接口中的 (sv)
+type systemdProperty struct {
+	Name  string
+	Value dbus.Variant
+}
+
+func newSystemdProperty(name string, value interface{}) systemdProperty {
+	return systemdProperty{
+		Name:  name,
+		Value: dbus.MakeVariant(value),
+	}
+}
+
+// systemdAuxUnit 对应 StartTransientUnit 中的 (sa(sv))
+type systemdAuxUnit struct {
+	Name       string
+	Properties []systemdProperty
+}
+
+// systemdManager 是 cgroupManager 用到的 systemd 的方法，测试中会被替换
+type systemdManager interface {
+	StartTransientUnit(name, mode string, props []systemdProperty) error
+	SetUnitProperties(name string, props []systemdProperty) error
+	StopUnit(name string) error
+	AttachProcessesToUnit(name, subcgroup string, pids []uint32) error
+}
+
+type systemdDBus struct {
+	obj dbus.BusObject
+}
+
+func newSystemdDBus(conn *dbus.Conn) *systemdDBus {
+	return &systemdDBus{
+		obj: conn.Object(systemdDBusServiceName, systemdDBusPath),
+	}
+}
+
+func (sd *systemdDBus) StartTransientUnit(name, mode string, props []systemdProperty) error {
+	var job dbus.ObjectPath
+	return sd.obj.Call(systemdDBusInterface+".StartTransientUnit", dbus.FlagNoAutoStart,
+		name, mode, props, []systemdAuxUnit{}).Store(&job)
+}
+
+// SetUnitProperties 修改的属性只在运行时生效，不写入配置文件
+func (sd *systemdDBus) SetUnitProperties(name string, props []systemdProperty) error {
+	return sd.obj.Call(systemdDBusInterface+".SetUnitProperties", dbus.FlagNoAutoStart,
+		name, true, props).Err
+}
+
+func (sd *systemdDBus) StopUnit(name string) error {
+	var job dbus.ObjectPath
+	return sd.obj.Call(systemdDBusInterface+".StopUnit", dbus.FlagNoAutoStart,
+		name, "replace").Store(&job)
+}
+
+func (sd *systemdDBus) AttachProcessesToUnit(name, subcgroup string, pids []uint32) error {
+	return sd.obj.Call(systemdDBusInterface+".AttachProcessesToUnit", dbus.FlagNoAutoStart,
+		name, subcgroup, pids).Err
+}
+
+func isUnitExistsError(err error) bool {
+	dbusErr, ok := err.(dbus.Error)
+	return ok && dbusErr.Name == systemdErrUnitExists
+}